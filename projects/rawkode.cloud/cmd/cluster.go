@@ -11,6 +11,7 @@ import (
 	"sync"
 
 	"github.com/rawkode-academy/rawkode-cloud3/internal/cilium"
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/flux"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/gatewayapi"
@@ -53,35 +54,12 @@ var clusterCreateCmd = &cobra.Command{
 	RunE:  runClusterCreate,
 }
 
-var clusterStatusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Show cluster health and drift detection",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfgPath, _ := cmd.Flags().GetString("config")
-		cfg, err := config.Load(cfgPath)
-		if err != nil {
-			return fmt.Errorf("load config: %w", err)
-		}
-
-		fmt.Printf("Cluster: %s\n", cfg.Environment)
-		fmt.Printf("Talos:   %s\n", cfg.Cluster.TalosVersion)
-		fmt.Printf("K8s:     %s\n", cfg.Cluster.KubernetesVersion)
-		fmt.Printf("Cilium:  %s\n", cfg.Cluster.EffectiveCiliumVersion())
-		fmt.Printf("Flux:    %s\n", cfg.Cluster.EffectiveFluxVersion())
-		fmt.Printf("Pools:   %d\n", len(cfg.NodePools))
-
-		for _, pool := range cfg.NodePools {
-			fmt.Printf("  - %s (offer=%s, billing=%s)\n", pool.Name, pool.Offer, pool.BillingCycle)
-		}
-
-		return nil
-	},
-}
-
 func init() {
 	clusterCmd.AddCommand(clusterCreateCmd)
+	clusterCmd.AddCommand(clusterApplyCmd)
 	clusterCmd.AddCommand(clusterDeleteCmd)
 	clusterCmd.AddCommand(clusterStatusCmd)
+	clusterCmd.AddCommand(clusterRestoreCmd)
 	clusterCmd.AddCommand(clusterScaffoldCmd)
 
 	clusterCreateCmd.Flags().StringP("environment", "e", "", "Cluster/environment name")
@@ -94,7 +72,6 @@ func init() {
 	clusterDeleteCmd.Flags().StringP("environment", "e", "", "Cluster/environment name")
 	clusterDeleteCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 
-	clusterStatusCmd.Flags().String("config", "", "Path to cluster config YAML")
 }
 
 // createClusterPhases defines the phase order for creating a cluster.
@@ -132,6 +109,7 @@ var (
 	}
 	scalewayEnsureNetworkFoundationFn       = scaleway.EnsureNetworkFoundation
 	scalewayResolvePrivateNetworkIPv4CIDRFn = scaleway.ResolvePrivateNetworkIPv4CIDR
+	orderServerLoadNodeStateFn              = loadNodeState
 )
 
 func runClusterCreate(cmd *cobra.Command, args []string) error {
@@ -154,7 +132,7 @@ func runClusterCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	if pool.EffectiveType() != config.NodeTypeControlPlane {
-		return fmt.Errorf("cluster create currently supports control-plane pools only (pool %q is %q)", pool.Name, pool.EffectiveType())
+		return fmt.Errorf("cluster create bootstraps from a control-plane pool (pool %q is %q); worker pools are provisioned once the control plane is verified", pool.Name, pool.EffectiveType())
 	}
 	if strings.TrimSpace(nodeNameFlag) != "" {
 		return fmt.Errorf(
@@ -183,16 +161,50 @@ func runClusterCreate(cmd *cobra.Command, args []string) error {
 		netbirdSecretKey = strings.TrimSpace(cfg.Infisical.NetbirdSecretKey)
 	}
 
-	op := operation.New(operation.GenerateID(), operation.TypeCreateCluster, cfg.Environment, createClusterPhases)
-	op.SetContext("nodeName", nodeName)
-	op.SetContext("role", pool.EffectiveType())
-	op.SetContext("poolName", pool.Name)
-	op.SetContext("controlPlaneSlot", "1")
-	if privateIP != "" {
-		op.SetContext("privateIP", privateIP)
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, operation.TypeCreateCluster)
+	if err != nil {
+		return fmt.Errorf("look up in-flight create-cluster operation: %w", err)
+	}
+	if op != nil && op.GetContextString("poolName") != pool.Name {
+		return fmt.Errorf(
+			"operation %s is still in flight for pool %q; resume or abort it before creating from pool %q",
+			op.ID, op.GetContextString("poolName"), pool.Name,
+		)
+	}
+	if op == nil {
+		phases, err := createClusterPhasesForPool(cfg, pool)
+		if err != nil {
+			return err
+		}
+		op = operation.New(operation.GenerateID(), operation.TypeCreateCluster, cfg.Environment, phases)
+		op.SetContext("nodeName", nodeName)
+		op.SetContext("role", pool.EffectiveType())
+		op.SetContext("poolName", pool.Name)
+		op.SetContext("controlPlaneSlot", "1")
+		if privateIP != "" {
+			op.SetContext("privateIP", privateIP)
+		}
+		op.SetContext(opContextNetbirdSecretPath, netbirdSecretPath)
+		op.SetContext(opContextNetbirdSecretKey, netbirdSecretKey)
+
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
+	}
+	if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+		return fmt.Errorf("record operation on cluster lock: %w", err)
 	}
-	op.SetContext(opContextNetbirdSecretPath, netbirdSecretPath)
-	op.SetContext(opContextNetbirdSecretKey, netbirdSecretKey)
 
 	resumePhase := op.ResumePhase()
 	if resumePhase == "" {
@@ -208,11 +220,12 @@ func runClusterCreate(cmd *cobra.Command, args []string) error {
 		"resume_from", resumePhase,
 	)
 
-	return executeCreateCluster(ctx, op, cfg)
+	return executeCreateCluster(ctx, store, op, cfg)
 }
 
 func executeCreateCluster(
 	ctx context.Context,
+	store operation.Store,
 	op *operation.Operation,
 	cfg *config.Config,
 ) error {
@@ -223,11 +236,21 @@ func executeCreateCluster(
 			return nil
 		}
 
+		if group, ok := createNodePhaseGroup(phase); ok {
+			if err := runCreateNodePhases(ctx, store, op, cfg, group); err != nil {
+				return err
+			}
+			continue
+		}
+
 		slog.Info("executing phase", "phase", phase, "operation", op.ID)
 
 		if err := op.StartPhase(phase); err != nil {
 			return fmt.Errorf("start phase %s: %w", phase, err)
 		}
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
 
 		var phaseErr error
 		switch phase {
@@ -245,6 +268,8 @@ func executeCreateCluster(
 			phaseErr = phaseApplyConfig(ctx, op, cfg)
 		case "bootstrap":
 			phaseErr = phaseBootstrap(ctx, op, cfg)
+		case createPhaseWaitEtcd:
+			phaseErr = phaseWaitEtcd(ctx, op, cfg)
 		case "post-bootstrap":
 			phaseErr = phasePostBootstrap(ctx, op, cfg)
 		case "bootstrap-secrets":
@@ -258,13 +283,15 @@ func executeCreateCluster(
 		}
 
 		if phaseErr != nil {
-			_ = op.FailPhase(phase, phaseErr)
-			return fmt.Errorf("phase %s failed: %w", phase, phaseErr)
+			return failOperationPhase(ctx, store, op, phase, phaseErr)
 		}
 
 		if err := op.CompletePhase(phase, nil); err != nil {
 			return fmt.Errorf("complete phase %s: %w", phase, err)
 		}
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
 	}
 }
 
@@ -277,11 +304,30 @@ func phaseInit(ctx context.Context, op *operation.Operation, cfg *config.Config)
 	if err != nil {
 		return err
 	}
-	if _, err := ensureTalosSecretsYAML(ctx, cfg, client); err != nil {
+	_, generated, err := ensureTalosSecretsYAMLGenerated(ctx, cfg, client)
+	if err != nil {
 		return err
 	}
 
-	op.SetContext("secretsPath", infisicalSecretPathForCluster(cfg))
+	secretPath := infisicalSecretPathForCluster(cfg)
+	op.SetContext("secretsPath", secretPath)
+
+	// Only secrets minted for this cluster are rolled back; pre-existing
+	// secrets may belong to a cluster that is being recreated.
+	if generated {
+		if err := op.AddCleanup(cleanupActionDeleteInfisicalSecrets, cleanupDeleteInfisicalSecrets{
+			SecretPath: secretPath,
+			Keys: []string{
+				infisicalTalosSecretsKey,
+				infisicalTalosControlPlaneKey,
+				infisicalTalosWorkerKey,
+				infisicalTalosConfigKey,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -334,6 +380,36 @@ func phaseOrderServer(
 		return nil
 	}
 
+	zoneValue := pool.EffectiveZone()
+	if zoneValue == "" {
+		return fmt.Errorf("node pool %q must define zone", pool.Name)
+	}
+	op.SetContext("zone", zoneValue)
+	zone := scw.Zone(zoneValue)
+
+	// The server ID is only kept once the operation is saved, so a run that
+	// ordered the server and then failed to save leaves it in the inventory
+	// alone. Adopt it rather than order a second one.
+	state, err := orderServerLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if existing, ok := findNodeByName(state, nodeName); ok &&
+		existing.ServerID != "" &&
+		existing.ServerID != op.GetContextString("replacedServerID") &&
+		existing.Status != clusterstate.NodeStatusDeleted &&
+		existing.Status != clusterstate.NodeStatusFailed {
+		op.SetContext("serverId", existing.ServerID)
+		if err := op.AddCleanup(cleanupActionDeleteServer, cleanupDeleteServer{
+			ServerID: existing.ServerID,
+			Zone:     zoneValue,
+		}); err != nil {
+			return err
+		}
+		slog.Info("adopted server already in inventory", "node", nodeName, "server_id", existing.ServerID)
+		return nil
+	}
+
 	slog.Info("phase order-server: ordering Scaleway bare metal")
 
 	scwAccessKey, scwSecretKey := cfg.ScalewayCredentials()
@@ -347,13 +423,6 @@ func phaseOrderServer(
 		return fmt.Errorf("create scaleway client: %w", err)
 	}
 
-	zoneValue := pool.EffectiveZone()
-	if zoneValue == "" {
-		return fmt.Errorf("node pool %q must define zone", pool.Name)
-	}
-	op.SetContext("zone", zoneValue)
-	zone := scw.Zone(zoneValue)
-
 	// Resolve offer and OS
 	offerID, _, err := scaleway.ResolveOfferForBillingCycle(ctx, scwClient, zone, pool.Offer, pool.BillingCycle)
 	if err != nil {
@@ -412,6 +481,12 @@ func phaseOrderServer(
 	}
 
 	op.SetContext("serverId", server.ID)
+	if err := op.AddCleanup(cleanupActionDeleteServer, cleanupDeleteServer{
+		ServerID: server.ID,
+		Zone:     zoneValue,
+	}); err != nil {
+		return err
+	}
 
 	slog.Info("server ordered", "server_id", server.ID)
 	return nil
@@ -1000,16 +1075,24 @@ func extractServerIPs(server *baremetal.Server) (publicIP, privateIP string) {
 }
 
 func phaseVerify(ctx context.Context, op *operation.Operation, cfg *config.Config) error {
-	_ = ctx
 	slog.Info("phase verify: running health checks")
 
-	nodeName := op.GetContextString("nodeName")
-	if nodeName == "" {
-		poolName := strings.TrimSpace(op.GetContextString("poolName"))
-		if poolName == "" {
-			poolName = "control-plane"
+	pool, err := poolForOperation(cfg, op)
+	if err != nil {
+		return fmt.Errorf("resolve node pool: %w", err)
+	}
+	nodeName := nodeNameForOperation(op, cfg.Environment)
+
+	// The control planes could not become Ready before post-bootstrap
+	// installed the CNI.
+	controlPlanes := []string{nodeName}
+	for slot := 2; slot <= pool.DesiredSize(); slot++ {
+		controlPlanes = append(controlPlanes, controlPlaneNodeName(cfg.Environment, pool.Name, slot))
+	}
+	for _, name := range controlPlanes {
+		if err := createNodeReadyFn(ctx, op, cfg, name); err != nil {
+			return err
 		}
-		nodeName = controlPlaneNodeName(cfg.Environment, poolName, 1)
 	}
 
 	fmt.Printf("\nCluster %q created successfully!\n", cfg.Environment)
@@ -1200,6 +1283,40 @@ func getOrCreateInfisicalClient(ctx context.Context, cfg *config.Config) (*infis
 }
 
 func ensureTalosSecretsYAML(ctx context.Context, cfg *config.Config, client *infisical.Client) ([]byte, error) {
+	secretsYAML, _, err := ensureTalosSecretsYAMLGenerated(ctx, cfg, client)
+	return secretsYAML, err
+}
+
+// ensureTalosSecretsYAMLGenerated is ensureTalosSecretsYAML that also reports
+// whether the secrets were generated by this call rather than loaded.
+func ensureTalosSecretsYAMLGenerated(ctx context.Context, cfg *config.Config, client *infisical.Client) ([]byte, bool, error) {
+	existing, err := loadTalosSecretsYAML(ctx, cfg, client)
+	if err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	slog.Info("no Talos secrets found in Infisical; generating new secrets")
+	secretsYAML, err := talos.GenerateSecretsYAML(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("generate talos secrets: %w", err)
+	}
+
+	secretPath := infisicalSecretPathForCluster(cfg)
+	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosSecretsKey, string(secretsYAML)); err != nil {
+		return nil, false, fmt.Errorf("store talos secrets in infisical: %w", err)
+	}
+
+	return secretsYAML, true, nil
+}
+
+// infisicalSecretsReader is the read-only part of the Infisical client.
+type infisicalSecretsReader interface {
+	GetSecrets(ctx context.Context, projectID, environment, secretPath string) (map[string]string, error)
+}
+
+// loadTalosSecretsYAML returns the cluster's Talos secrets bundle, or nil if
+// Infisical has none.
+func loadTalosSecretsYAML(ctx context.Context, cfg *config.Config, client infisicalSecretsReader) ([]byte, error) {
 	secretPath := infisicalSecretPathForCluster(cfg)
 	for _, candidatePath := range infisicalSecretPathReadCandidates(cfg) {
 		all, err := client.GetSecrets(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, candidatePath)
@@ -1217,26 +1334,52 @@ func ensureTalosSecretsYAML(ctx context.Context, cfg *config.Config, client *inf
 			return []byte(existing), nil
 		}
 	}
+	return nil, nil
+}
 
-	slog.Info("no Talos secrets found in Infisical; generating new secrets")
-	secretsYAML, err := talos.GenerateSecretsYAML(ctx)
+// ensureTalosAssets generates the cluster's Talos configs, creating the
+// secrets bundle if there is none, and stores them in Infisical.
+func ensureTalosAssets(ctx context.Context, cfg *config.Config, endpoint string, client *infisical.Client) (*talos.GenConfigResult, error) {
+	secretsYAML, err := ensureTalosSecretsYAML(ctx, cfg, client)
 	if err != nil {
-		return nil, fmt.Errorf("generate talos secrets: %w", err)
+		return nil, err
 	}
 
-	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosSecretsKey, string(secretsYAML)); err != nil {
-		return nil, fmt.Errorf("store talos secrets in infisical: %w", err)
+	assets, err := generateTalosAssets(ctx, cfg, endpoint, secretsYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	secretPath := infisicalSecretPathForCluster(cfg)
+	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosControlPlaneKey, string(assets.ControlPlane)); err != nil {
+		return nil, fmt.Errorf("store control-plane config in infisical: %w", err)
+	}
+	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosWorkerKey, string(assets.Worker)); err != nil {
+		return nil, fmt.Errorf("store worker config in infisical: %w", err)
+	}
+	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosConfigKey, string(assets.Talosconfig)); err != nil {
+		return nil, fmt.Errorf("store talosconfig in infisical: %w", err)
 	}
 
-	return secretsYAML, nil
+	return assets, nil
 }
 
-func ensureTalosAssets(ctx context.Context, cfg *config.Config, endpoint string, client *infisical.Client) (*talos.GenConfigResult, error) {
-	secretsYAML, err := ensureTalosSecretsYAML(ctx, cfg, client)
+// renderTalosAssets generates the cluster's Talos configs from its existing
+// secrets bundle without writing anything back, for commands that only
+// inspect or apply configs.
+func renderTalosAssets(ctx context.Context, cfg *config.Config, endpoint string, client infisicalSecretsReader) (*talos.GenConfigResult, error) {
+	secretsYAML, err := loadTalosSecretsYAML(ctx, cfg, client)
 	if err != nil {
 		return nil, err
 	}
+	if secretsYAML == nil {
+		return nil, fmt.Errorf("no Talos secrets bundle for cluster %q in Infisical at %s; create the cluster first", cfg.Environment, infisicalSecretPathForCluster(cfg))
+	}
+	return generateTalosAssets(ctx, cfg, endpoint, secretsYAML)
+}
 
+// generateTalosAssets generates the Talos configs for a secrets bundle.
+func generateTalosAssets(ctx context.Context, cfg *config.Config, endpoint string, secretsYAML []byte) (*talos.GenConfigResult, error) {
 	installDisk := ""
 	if controlPlanePool, err := cfg.FirstNodePoolByType(config.NodeTypeControlPlane); err == nil {
 		installDisk = strings.TrimSpace(controlPlanePool.Disks.OS)
@@ -1255,17 +1398,5 @@ func ensureTalosAssets(ctx context.Context, cfg *config.Config, endpoint string,
 	if err != nil {
 		return nil, fmt.Errorf("generate talos assets: %w", err)
 	}
-
-	secretPath := infisicalSecretPathForCluster(cfg)
-	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosControlPlaneKey, string(assets.ControlPlane)); err != nil {
-		return nil, fmt.Errorf("store control-plane config in infisical: %w", err)
-	}
-	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosWorkerKey, string(assets.Worker)); err != nil {
-		return nil, fmt.Errorf("store worker config in infisical: %w", err)
-	}
-	if err := client.SetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, infisicalTalosConfigKey, string(assets.Talosconfig)); err != nil {
-		return nil, fmt.Errorf("store talosconfig in infisical: %w", err)
-	}
-
 	return assets, nil
 }
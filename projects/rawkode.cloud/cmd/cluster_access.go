@@ -71,18 +71,39 @@ func runClusterAccess(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("write kubeconfig: %w", err)
 	}
 
-	fmt.Printf("Cluster access configured from %s\n", materials.ConfigPath)
-	fmt.Printf("  Talos API:   %s\n", materials.TalosEndpoint)
-	fmt.Printf("  TALOSCONFIG: %s\n", talosOut)
-	fmt.Printf("  KUBECONFIG:  %s\n", kubeOut)
-	fmt.Printf("\nExport for this shell session:\n")
-	fmt.Printf("  export TALOSCONFIG=%q\n", talosOut)
-	fmt.Printf("  export KUBECONFIG=%q\n", kubeOut)
+	result := clusterAccessResult{
+		ConfigPath:      materials.ConfigPath,
+		TalosEndpoint:   materials.TalosEndpoint,
+		TalosconfigPath: talosOut,
+		KubeconfigPath:  kubeOut,
+	}
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Cluster access configured from %s\n", materials.ConfigPath)
+		fmt.Printf("  Talos API:   %s\n", materials.TalosEndpoint)
+		fmt.Printf("  TALOSCONFIG: %s\n", talosOut)
+		fmt.Printf("  KUBECONFIG:  %s\n", kubeOut)
+		fmt.Printf("\nExport for this shell session:\n")
+		fmt.Printf("  export TALOSCONFIG=%q\n", talosOut)
+		fmt.Printf("  export KUBECONFIG=%q\n", kubeOut)
+	})
+}
 
-	return nil
+// clusterAccessResult is the structured output of `cluster access`.
+type clusterAccessResult struct {
+	ConfigPath      string `json:"configPath"`
+	TalosEndpoint   string `json:"talosEndpoint"`
+	TalosconfigPath string `json:"talosconfigPath"`
+	KubeconfigPath  string `json:"kubeconfigPath"`
 }
 
 func buildClusterAccessMaterials(ctx context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
+	return buildClusterAccessMaterialsExcluding(ctx, clusterName, cfgFile, "")
+}
+
+// buildClusterAccessMaterialsExcluding fetches access through any active
+// control plane except excludeNode, for callers about to take that node out
+// of service.
+func buildClusterAccessMaterialsExcluding(ctx context.Context, clusterName, cfgFile, excludeNode string) (*clusterAccessMaterials, error) {
 	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
 	if err != nil {
 		return nil, err
@@ -103,12 +124,13 @@ func buildClusterAccessMaterials(ctx context.Context, clusterName, cfgFile strin
 		return nil, err
 	}
 
-	controlPlane, err := firstActiveNodeByRole(state, config.NodeTypeControlPlane)
-	if err != nil {
-		return nil, err
+	var candidates []string
+	for _, controlPlane := range activeNodesByRole(state, config.NodeTypeControlPlane) {
+		if controlPlane.Name == excludeNode {
+			continue
+		}
+		candidates = append(candidates, talosAccessEndpoints(controlPlane)...)
 	}
-
-	candidates := talosAccessEndpoints(controlPlane)
 	if len(candidates) == 0 {
 		return nil, fmt.Errorf("no control-plane endpoint available in state")
 	}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+var clusterApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Add or remove nodes until every pool matches its configured size",
+	Long: `Compare each node pool's configured size with the Scaleway inventory and
+add or remove nodes until they match. New nodes take the lowest free pool
+slots; scale-down drains and removes the highest slots first. Use --dry-run
+to print the plan without changing anything.`,
+	RunE: runClusterApply,
+}
+
+var (
+	clusterApplyLoadNodeStateFn = loadNodeState
+	clusterApplyAddNodeFn       = addNodeToPool
+	clusterApplyRemoveNodeFn    = executeNodeRemove
+)
+
+// poolPlan is what `cluster apply` will change in one node pool.
+type poolPlan struct {
+	Pool    string   `json:"pool"`
+	Role    string   `json:"role"`
+	Current int      `json:"current"`
+	Desired int      `json:"desired"`
+	Add     []string `json:"add,omitempty"`
+	Remove  []string `json:"remove,omitempty"`
+
+	addSlots    []int
+	removeNodes []clusterstate.NodeState
+}
+
+// clusterApplyResult is the structured output of `cluster apply`.
+type clusterApplyResult struct {
+	Cluster    string     `json:"cluster"`
+	ConfigPath string     `json:"configPath"`
+	DryRun     bool       `json:"dryRun,omitempty"`
+	Pools      []poolPlan `json:"pools"`
+	Added      []string   `json:"added,omitempty"`
+	Removed    []string   `json:"removed,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func init() {
+	clusterApplyCmd.Flags().String("cluster", "", "Cluster/environment name")
+	clusterApplyCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	clusterApplyCmd.Flags().Bool("dry-run", false, "Print the plan without adding or removing nodes")
+	clusterApplyCmd.Flags().Duration("drain-timeout", 10*time.Minute, "Maximum time to wait for each removed node to drain, including PodDisruptionBudget retries")
+}
+
+func runClusterApply(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	var (
+		store       operation.Store
+		clusterLock *lock.Lock
+	)
+	if !dryRun {
+		store, err = operationStoreForCommand(ctx, cmd, cfg)
+		if err != nil {
+			return fmt.Errorf("open operation store: %w", err)
+		}
+
+		clusterLock, err = acquireClusterLock(ctx, cmd, cfg, "")
+		if err != nil {
+			return err
+		}
+		defer releaseClusterLock(clusterLock)
+
+		for _, opType := range []operation.Type{operation.TypeRemoveNode, operation.TypeReplaceNode, operation.TypeRestoreCluster} {
+			op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, opType)
+			if err != nil {
+				return fmt.Errorf("look up in-flight %s operation: %w", opType, err)
+			}
+			if op != nil {
+				return fmt.Errorf("operation %s (%s) is still in flight; resume or abort it before applying", op.ID, opType)
+			}
+		}
+	}
+
+	state, err := clusterApplyLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	plans, err := planClusterApply(cfg, state)
+	if err != nil {
+		return err
+	}
+
+	result := &clusterApplyResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		DryRun:     dryRun,
+		Pools:      plans,
+	}
+	if dryRun {
+		return emitResult(cmd, result, func() {
+			printClusterApplyPlan(result)
+		})
+	}
+
+	if err := applyClusterPlan(ctx, store, clusterLock, cfg, cfgPath, state, plans, drainTimeout, result); err != nil {
+		result.Error = err.Error()
+		if format, _ := outputFormat(cmd); format == outputFormatText {
+			printClusterApplyPlan(result)
+		}
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printClusterApplyPlan(result)
+		fmt.Printf("Cluster %q matches %s (added=%d, removed=%d)\n", cfg.Environment, cfgPath, len(result.Added), len(result.Removed))
+	})
+}
+
+// planClusterApply compares every pool's desired size with the inventory.
+// Additions take the lowest free slots; removals take the highest
+// occupied ones.
+func planClusterApply(cfg *config.Config, state *clusterstate.NodesState) ([]poolPlan, error) {
+	plans := make([]poolPlan, 0, len(cfg.NodePools))
+	for i := range cfg.NodePools {
+		pool := &cfg.NodePools[i]
+		role := pool.EffectiveType()
+
+		var members []clusterstate.NodeState
+		for _, node := range state.Nodes {
+			if node.Pool == pool.Name && node.Role == role && node.Status != clusterstate.NodeStatusDeleted {
+				members = append(members, node)
+			}
+		}
+
+		plan := poolPlan{
+			Pool:    pool.Name,
+			Role:    role,
+			Current: len(members),
+			Desired: pool.DesiredSize(),
+		}
+
+		if plan.Current < plan.Desired {
+			slots, err := nextNodePoolSlots(state, cfg.Environment, pool.Name, role, plan.Desired-plan.Current)
+			if err != nil {
+				return nil, err
+			}
+			plan.addSlots = slots
+			for _, slot := range slots {
+				plan.Add = append(plan.Add, pooledNodeName(cfg.Environment, pool.Name, slot))
+			}
+		}
+
+		if plan.Current > plan.Desired {
+			slotOf := func(node clusterstate.NodeState) int {
+				slot, _ := parsePooledNodeSlot(cfg.Environment, pool.Name, node.Name)
+				return slot
+			}
+			sort.SliceStable(members, func(i, j int) bool {
+				return slotOf(members[i]) > slotOf(members[j])
+			})
+			for _, node := range members[:plan.Current-plan.Desired] {
+				plan.removeNodes = append(plan.removeNodes, node)
+				plan.Remove = append(plan.Remove, node.Name)
+			}
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// applyClusterPlan adds nodes before removing any, so capacity moving
+// between pools never dips. Control planes are added first and removed
+// last, one node at a time.
+func applyClusterPlan(
+	ctx context.Context,
+	store operation.Store,
+	clusterLock *lock.Lock,
+	cfg *config.Config,
+	cfgPath string,
+	state *clusterstate.NodesState,
+	plans []poolPlan,
+	drainTimeout time.Duration,
+	result *clusterApplyResult,
+) error {
+	ordered := append([]poolPlan(nil), plans...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Role == config.NodeTypeControlPlane && ordered[j].Role != config.NodeTypeControlPlane
+	})
+
+	adding := false
+	for _, plan := range ordered {
+		adding = adding || len(plan.addSlots) > 0
+	}
+	if adding {
+		if _, err := firstActiveNodeByRole(state, config.NodeTypeControlPlane); err != nil {
+			return fmt.Errorf("cannot join new nodes: %w; run `cluster create` first", err)
+		}
+	}
+
+	for _, plan := range ordered {
+		pool, err := cfg.FindNodePool(plan.Pool)
+		if err != nil {
+			return err
+		}
+		for _, slot := range plan.addSlots {
+			operationID := operation.GenerateID()
+			if err := clusterLock.SetOperationID(ctx, operationID); err != nil {
+				return fmt.Errorf("record operation on cluster lock: %w", err)
+			}
+
+			name := pooledNodeName(cfg.Environment, pool.Name, slot)
+			slog.Info("adding node", "pool", pool.Name, "node", name, "operation", operationID)
+			if _, err := clusterApplyAddNodeFn(ctx, store, operationID, cfg, cfgPath, state, pool, slot); err != nil {
+				return fmt.Errorf("add node %q to pool %q: %w", name, pool.Name, err)
+			}
+			result.Added = append(result.Added, name)
+		}
+	}
+
+	for i := len(ordered) - 1; i >= 0; i-- {
+		for _, node := range ordered[i].removeNodes {
+			op := newNodeRemoveOperation(cfg, cfgPath, node, drainTimeout, false)
+			if err := saveOperation(ctx, store, op); err != nil {
+				return err
+			}
+			if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+				return fmt.Errorf("record operation on cluster lock: %w", err)
+			}
+
+			slog.Info("removing node", "pool", node.Pool, "node", node.Name, "operation", op.ID)
+			if _, err := clusterApplyRemoveNodeFn(ctx, store, op, cfg); err != nil {
+				return fmt.Errorf("remove node %q (resume with `operation resume --id %s`): %w", node.Name, op.ID, err)
+			}
+			result.Removed = append(result.Removed, node.Name)
+		}
+	}
+
+	return nil
+}
+
+func printClusterApplyPlan(result *clusterApplyResult) {
+	fmt.Printf("Plan for cluster %q (config=%s)\n", result.Cluster, result.ConfigPath)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tROLE\tCURRENT\tDESIRED\tCHANGES")
+	for _, plan := range result.Pools {
+		var changes []string
+		if len(plan.Add) > 0 {
+			changes = append(changes, "add "+strings.Join(plan.Add, ", "))
+		}
+		if len(plan.Remove) > 0 {
+			changes = append(changes, "remove "+strings.Join(plan.Remove, ", "))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n",
+			plan.Pool,
+			plan.Role,
+			plan.Current,
+			plan.Desired,
+			defaultString(strings.Join(changes, "; "), "-"),
+		)
+	}
+	_ = w.Flush()
+}
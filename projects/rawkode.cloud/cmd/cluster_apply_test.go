@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+)
+
+func clusterApplyTestConfig() *config.Config {
+	return &config.Config{
+		Environment: "production",
+		NodePools: []config.NodePoolConfig{
+			{Name: "control-plane", Type: config.NodeTypeControlPlane, Size: 3},
+			{Name: "worker", Type: config.NodeTypeWorker, Size: 1},
+		},
+	}
+}
+
+func clusterApplyTestState() *clusterstate.NodesState {
+	return &clusterstate.NodesState{Environment: "production", Nodes: []clusterstate.NodeState{
+		{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+		{Name: "production-control-plane-03", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "3.3.3.3"},
+		{Name: "production-worker-01", Role: config.NodeTypeWorker, Pool: "worker", PublicIP: "4.4.4.4"},
+		{Name: "production-worker-03", Role: config.NodeTypeWorker, Pool: "worker", PublicIP: "6.6.6.6"},
+		{Name: "production-worker-02", Role: config.NodeTypeWorker, Pool: "worker", PublicIP: "5.5.5.5"},
+		{Name: "production-worker-04", Role: config.NodeTypeWorker, Pool: "worker", Status: clusterstate.NodeStatusDeleted},
+	}}
+}
+
+func TestPlanClusterApplyFillsLowestSlotsAndRemovesHighest(t *testing.T) {
+	plans, err := planClusterApply(clusterApplyTestConfig(), clusterApplyTestState())
+	if err != nil {
+		t.Fatalf("planClusterApply returned error: %v", err)
+	}
+
+	if got := strings.Join(plans[0].Add, ","); got != "production-control-plane-02" {
+		t.Fatalf("control-plane additions = %q, want production-control-plane-02", got)
+	}
+	if plans[1].Current != 3 || plans[1].Desired != 1 {
+		t.Fatalf("worker current/desired = %d/%d, want 3/1", plans[1].Current, plans[1].Desired)
+	}
+	if got := strings.Join(plans[1].Remove, ","); got != "production-worker-03,production-worker-02" {
+		t.Fatalf("worker removals = %q, want highest slots first", got)
+	}
+}
+
+func TestApplyClusterPlanAddsBeforeRemoving(t *testing.T) {
+	useLocalClusterLock(t)
+	originalAdd, originalRemove := clusterApplyAddNodeFn, clusterApplyRemoveNodeFn
+	t.Cleanup(func() {
+		clusterApplyAddNodeFn = originalAdd
+		clusterApplyRemoveNodeFn = originalRemove
+	})
+
+	var events []string
+	clusterApplyAddNodeFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		events = append(events, "add "+pooledNodeName(cfg.Environment, pool.Name, slot))
+		return &nodeAddResult{}, nil
+	}
+	clusterApplyRemoveNodeFn = func(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*nodeRemoveResult, error) {
+		if op.GetContextString("drainTimeout") != "5m0s" {
+			t.Errorf("drainTimeout = %q, want 5m0s", op.GetContextString("drainTimeout"))
+		}
+		events = append(events, "remove "+op.GetContextString("nodeName"))
+		return &nodeRemoveResult{}, nil
+	}
+
+	cfg := clusterApplyTestConfig()
+	state := clusterApplyTestState()
+	plans, err := planClusterApply(cfg, state)
+	if err != nil {
+		t.Fatalf("planClusterApply returned error: %v", err)
+	}
+
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	clusterLock, err := acquireClusterLock(context.Background(), nil, cfg, "")
+	if err != nil {
+		t.Fatalf("acquireClusterLock returned error: %v", err)
+	}
+	defer releaseClusterLock(clusterLock)
+
+	result := &clusterApplyResult{}
+	if err := applyClusterPlan(context.Background(), store, clusterLock, cfg, "", state, plans, 5*time.Minute, result); err != nil {
+		t.Fatalf("applyClusterPlan returned error: %v", err)
+	}
+
+	want := []string{
+		"add production-control-plane-02",
+		"remove production-worker-03",
+		"remove production-worker-02",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if len(result.Added) != 1 || len(result.Removed) != 2 {
+		t.Fatalf("result added=%v removed=%v", result.Added, result.Removed)
+	}
+}
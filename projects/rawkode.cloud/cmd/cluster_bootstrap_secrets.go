@@ -50,6 +50,10 @@ type clusterBootstrapSecretsOptions struct {
 	ProjectSlug         string
 	EnvironmentSlug     string
 	StoreSecretsPath    string
+
+	// Operation, when set, records rollback actions for Infisical resources
+	// created on behalf of an in-flight cluster create.
+	Operation *operation.Operation
 }
 
 var (
@@ -91,6 +95,12 @@ func runClusterBootstrapSecrets(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("load config %s: %w", materials.ConfigPath, err)
 	}
 
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
 	kubeconfigPath, cleanup, err := writeTemporaryKubeconfig(materials.KubeconfigYAML)
 	if err != nil {
 		return err
@@ -116,7 +126,7 @@ func phaseBootstrapSecrets(ctx context.Context, op *operation.Operation, cfg *co
 	}
 	defer cleanup()
 
-	return clusterBootstrapSecretsExecuteFn(ctx, cfg, kubeconfigPath, clusterBootstrapSecretsOptions{})
+	return clusterBootstrapSecretsExecuteFn(ctx, cfg, kubeconfigPath, clusterBootstrapSecretsOptions{Operation: op})
 }
 
 func clusterBootstrapSecretsOptionsFromCommand(cmd *cobra.Command) clusterBootstrapSecretsOptions {
@@ -204,7 +214,7 @@ func clusterBootstrapSecretsExecute(
 		return err
 	}
 
-	identity, err := ensureBootstrapMachineIdentity(
+	identity, created, err := ensureBootstrapMachineIdentity(
 		ctx,
 		infClient,
 		project.OrgID,
@@ -217,6 +227,14 @@ func clusterBootstrapSecretsExecute(
 	if err != nil {
 		return err
 	}
+	if created && options.Operation != nil {
+		if err := options.Operation.AddCleanup(cleanupActionDeleteInfisicalIdentity, cleanupDeleteInfisicalIdentity{
+			IdentityID: identity.ID,
+			Name:       identity.Name,
+		}); err != nil {
+			return err
+		}
+	}
 
 	if err := ensureBootstrapIdentityMembership(ctx, infClient, projectID, identity.ID, role.Slug); err != nil {
 		return err
@@ -377,10 +395,10 @@ func ensureBootstrapMachineIdentity(
 	clusterName,
 	environmentSlug string,
 	currentAnnotations map[string]string,
-) (*infisical.MachineIdentity, error) {
+) (*infisical.MachineIdentity, bool, error) {
 	identities, err := client.ListMachineIdentities(ctx, orgID)
 	if err != nil {
-		return nil, fmt.Errorf("list infisical machine identities: %w", err)
+		return nil, false, fmt.Errorf("list infisical machine identities: %w", err)
 	}
 
 	desiredName := bootstrapMachineIdentityName(scopeName, clusterName)
@@ -397,17 +415,17 @@ func ensureBootstrapMachineIdentity(
 	if existing == nil {
 		identity, err := client.CreateMachineIdentity(ctx, orgID, desiredName, true, metadata)
 		if err != nil {
-			return nil, fmt.Errorf("create infisical machine identity %q: %w", desiredName, err)
+			return nil, false, fmt.Errorf("create infisical machine identity %q: %w", desiredName, err)
 		}
-		return identity, nil
+		return identity, true, nil
 	}
 
 	existing, err = client.GetMachineIdentity(ctx, existing.ID)
 	if err != nil {
-		return nil, fmt.Errorf("load infisical machine identity %q: %w", desiredName, err)
+		return nil, false, fmt.Errorf("load infisical machine identity %q: %w", desiredName, err)
 	}
 	if !bootstrapMachineIdentityMatchesMetadata(existing, projectSlug, clusterName, environmentSlug) {
-		return nil, fmt.Errorf(
+		return nil, false, fmt.Errorf(
 			"refusing to manage infisical machine identity %q: metadata does not match rawkode-cloud bootstrap ownership",
 			strings.TrimSpace(existing.Name),
 		)
@@ -415,10 +433,10 @@ func ensureBootstrapMachineIdentity(
 
 	identity, err := client.UpdateMachineIdentity(ctx, existing.ID, desiredName, true, metadata)
 	if err != nil {
-		return nil, fmt.Errorf("update infisical machine identity %q: %w", desiredName, err)
+		return nil, false, fmt.Errorf("update infisical machine identity %q: %w", desiredName, err)
 	}
 
-	return identity, nil
+	return identity, false, nil
 }
 
 func ensureBootstrapIdentityMembership(
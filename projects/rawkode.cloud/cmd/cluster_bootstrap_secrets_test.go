@@ -207,7 +207,8 @@ func TestPhaseBootstrapSecretsExecutesWhenConfigured(t *testing.T) {
 	}
 
 	cfg := bootstrapSecretsTestConfig()
-	if err := phaseBootstrapSecrets(context.Background(), newPostBootstrapOperation(), cfg); err != nil {
+	op := newPostBootstrapOperation()
+	if err := phaseBootstrapSecrets(context.Background(), op, cfg); err != nil {
 		t.Fatalf("phaseBootstrapSecrets returned error: %v", err)
 	}
 
@@ -217,14 +218,15 @@ func TestPhaseBootstrapSecretsExecutesWhenConfigured(t *testing.T) {
 	if gotKubeconfig != kubeconfigPath {
 		t.Fatalf("kubeconfig = %q, want %q", gotKubeconfig, kubeconfigPath)
 	}
-	if gotOptions != (clusterBootstrapSecretsOptions{}) {
-		t.Fatalf("options = %+v, want zero-value defaults", gotOptions)
+	if gotOptions != (clusterBootstrapSecretsOptions{Operation: op}) {
+		t.Fatalf("options = %+v, want zero-value defaults with the phase operation", gotOptions)
 	}
 }
 
 func TestRunClusterBootstrapSecretsUsesOverridesAndWritesTempKubeconfig(t *testing.T) {
 	restoreClusterBootstrapSecretsFns()
 	t.Cleanup(restoreClusterBootstrapSecretsFns)
+	useLocalClusterLock(t)
 
 	clusterBootstrapSecretsBuildAccessMaterialsFn = func(_ context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
 		if clusterName != "production" {
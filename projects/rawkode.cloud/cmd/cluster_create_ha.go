@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+)
+
+const (
+	createControlPlanePhasePrefix = "control-plane/"
+	createWorkerPhasePrefix       = "worker/"
+	createPhaseWaitEtcd           = "wait-etcd"
+
+	// createNodeOperationContextPrefix keys the add-node operation of a
+	// slot in the create operation's context.
+	createNodeOperationContextPrefix = "nodeOperation/"
+)
+
+var (
+	createLoadNodeStateFn  = loadNodeState
+	createAddNodeFn        = addNodeToPool
+	createTalosconfigFn    = createClusterTalosconfig
+	createEtcdTimeout      = 20 * time.Minute
+	createEtcdPollInterval = 10 * time.Second
+	createEtcdMemberFn     = waitCreateEtcdMember
+	createNodeReadyFn      = waitCreateNodeReady
+	createNodeTimeout      = 30 * time.Minute
+)
+
+// createClusterPhasesForPool returns the create-cluster phases for a
+// control-plane pool. Slot 1 is bootstrapped by the fixed phases; every
+// further slot gets its own phase, run in parallel after bootstrap, and
+// post-bootstrap waits until all of them are healthy etcd members. Once the
+// control plane is verified, every worker pool node gets its own phase too.
+func createClusterPhasesForPool(cfg *config.Config, pool *config.NodePoolConfig) ([]string, error) {
+	size := pool.DesiredSize()
+	phases := make([]string, 0, len(createClusterPhases)+size)
+	for _, phase := range createClusterPhases {
+		phases = append(phases, phase)
+		switch {
+		case phase == "bootstrap" && size > 1:
+			for slot := 2; slot <= size; slot++ {
+				if _, err := controlPlaneReservedIPForSlot(pool, slot); err != nil {
+					return nil, err
+				}
+				phases = append(phases, createControlPlanePhasePrefix+controlPlaneNodeName(cfg.Environment, pool.Name, slot))
+			}
+			phases = append(phases, createPhaseWaitEtcd)
+		case phase == "verify":
+			for i := range cfg.NodePools {
+				worker := &cfg.NodePools[i]
+				if worker.EffectiveType() != config.NodeTypeWorker {
+					continue
+				}
+				for slot := 1; slot <= worker.DesiredSize(); slot++ {
+					phases = append(phases, createWorkerPhasePrefix+worker.Name+"/"+pooledNodeName(cfg.Environment, worker.Name, slot))
+				}
+			}
+		}
+	}
+	return phases, nil
+}
+
+// createNodePhaseGroup reports which group of per-node phases a phase
+// belongs to. Phases of one group run concurrently.
+func createNodePhaseGroup(phase string) (string, bool) {
+	for _, prefix := range []string{createControlPlanePhasePrefix, createWorkerPhasePrefix} {
+		if strings.HasPrefix(phase, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// createNodePhaseTarget resolves the pool and node name a per-node phase
+// provisions. Control-plane phases belong to the operation's pool; worker
+// phases name their pool.
+func createNodePhaseTarget(cfg *config.Config, op *operation.Operation, phase string) (*config.NodePoolConfig, string, error) {
+	if name, ok := strings.CutPrefix(phase, createControlPlanePhasePrefix); ok {
+		pool, err := poolForOperation(cfg, op)
+		if err != nil {
+			return nil, "", fmt.Errorf("resolve node pool: %w", err)
+		}
+		return pool, name, nil
+	}
+
+	target, _ := strings.CutPrefix(phase, createWorkerPhasePrefix)
+	poolName, name, ok := strings.Cut(target, "/")
+	if !ok {
+		return nil, "", fmt.Errorf("malformed worker phase %q", phase)
+	}
+	pool, err := cfg.FindNodePool(poolName)
+	if err != nil {
+		return nil, "", err
+	}
+	return pool, name, nil
+}
+
+// runCreateNodePhases provisions every pending node of a phase group
+// concurrently, recording each phase as it finishes.
+func runCreateNodePhases(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config, group string) error {
+	state, err := createLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	var batch []string
+	for _, phase := range op.PhaseOrder {
+		status := op.Phases[phase].Status
+		if !strings.HasPrefix(phase, group) || status == operation.PhaseCompleted || status == operation.PhaseSkipped {
+			continue
+		}
+		batch = append(batch, phase)
+		slog.Info("executing phase", "phase", phase, "operation", op.ID)
+		if err := op.StartPhase(phase); err != nil {
+			return fmt.Errorf("start phase %s: %w", phase, err)
+		}
+	}
+	if err := saveOperation(ctx, store, op); err != nil {
+		return err
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(batch))
+	)
+	for i, phase := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			phaseErr := joinCreateNode(ctx, store, op, cfg, state, phase, &mu)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if phaseErr != nil {
+				errs[i] = failOperationPhase(ctx, store, op, phase, phaseErr)
+				return
+			}
+			if err := op.CompletePhase(phase, nil); err != nil {
+				errs[i] = fmt.Errorf("complete phase %s: %w", phase, err)
+				return
+			}
+			errs[i] = saveOperation(ctx, store, op)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// joinCreateNode provisions one pool slot as its own add-node operation and
+// only reports success once the node has joined the cluster. The add-node
+// operation is linked to the create operation before any server is ordered,
+// so aborting the create rolls it back even if this run dies mid-add, and a
+// resumed create rolls back an interrupted add before ordering again. A slot
+// the inventory already lists is not ordered again, but must still join.
+func joinCreateNode(
+	ctx context.Context,
+	store operation.Store,
+	op *operation.Operation,
+	cfg *config.Config,
+	state *clusterstate.NodesState,
+	phase string,
+	mu *sync.Mutex,
+) error {
+	mu.Lock()
+	pool, name, err := createNodePhaseTarget(cfg, op, phase)
+	addID := op.GetContextString(createNodeOperationContextPrefix + name)
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	provision, state, err := createNodeNeedsProvisioning(ctx, store, cfg, state, name, addID)
+	if err != nil {
+		return err
+	}
+
+	if provision {
+		slot, ok := parsePooledNodeSlot(cfg.Environment, pool.Name, name)
+		if !ok {
+			return fmt.Errorf("node %q does not occupy a slot of pool %q", name, pool.Name)
+		}
+
+		addID = operation.GenerateID()
+		mu.Lock()
+		op.SetContext(createNodeOperationContextPrefix+name, addID)
+		err = op.AddCleanup(cleanupActionAbortOperation, cleanupAbortOperation{OperationID: addID})
+		if err == nil {
+			err = saveOperation(ctx, store, op)
+		}
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if _, err := createAddNodeFn(ctx, store, addID, cfg, "", state, pool, slot); err != nil {
+			return fmt.Errorf("join %s %q: %w", pool.EffectiveType(), name, err)
+		}
+	}
+
+	// Nodes stay NotReady until post-bootstrap installs the CNI, so a control
+	// plane has joined once it is an etcd member; verify waits for it to
+	// become Ready.
+	if pool.EffectiveType() == config.NodeTypeControlPlane {
+		return createEtcdMemberFn(ctx, op, cfg, name)
+	}
+	return createNodeReadyFn(ctx, op, cfg, name)
+}
+
+// createNodeNeedsProvisioning reports whether a slot still needs a server.
+// An add-node operation an earlier run left unfinished is rolled back first,
+// returning the inventory as it stands afterwards.
+func createNodeNeedsProvisioning(
+	ctx context.Context,
+	store operation.Store,
+	cfg *config.Config,
+	state *clusterstate.NodesState,
+	name string,
+	addID string,
+) (bool, *clusterstate.NodesState, error) {
+	if addID == "" {
+		if existing, ok := findNodeByName(state, name); ok && existing.Status != clusterstate.NodeStatusDeleted && existing.Status != clusterstate.NodeStatusFailed {
+			slog.Info("node already provisioned", "node", name, "server_id", existing.ServerID)
+			return false, state, nil
+		}
+		return true, state, nil
+	}
+
+	add, err := store.Load(ctx, cfg.Environment, addID)
+	switch {
+	case errors.Is(err, operation.ErrNotFound):
+		return true, state, nil
+	case err != nil:
+		return false, nil, fmt.Errorf("load add-node operation %s: %w", addID, err)
+	case add.IsAborted():
+		return true, state, nil
+	case add.IsComplete():
+		slog.Info("node already provisioned", "node", name, "operation", addID, "server_id", add.GetContextString("serverId"))
+		return false, state, nil
+	}
+
+	slog.Warn("rolling back interrupted node add", "node", name, "operation", addID, "phase", add.ResumePhase())
+	if err := abortOperation(ctx, store, add, operationCleanupRegistryFn(cfg)); err != nil {
+		return false, nil, fmt.Errorf("roll back add-node operation %s: %w", addID, err)
+	}
+	state, err = createLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, state, nil
+}
+
+// waitCreateEtcdMember waits until a control plane is listed as an etcd
+// member. It may still be a learner; wait-etcd waits for it to vote.
+func waitCreateEtcdMember(ctx context.Context, op *operation.Operation, cfg *config.Config, name string) error {
+	talosconfig, err := createTalosconfigFn(ctx, op, cfg)
+	if err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, createEtcdTimeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		var members map[string]bool
+		health, err := createEtcdClient(waitCtx, cfg, talosconfig)
+		if err == nil {
+			members, err = createEtcdMembers(waitCtx, health)
+		}
+		if err == nil {
+			if _, ok := members[name]; ok {
+				return nil
+			}
+			err = fmt.Errorf("%s is not an etcd member yet", name)
+		}
+		lastErr = err
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("node %s did not join etcd: %w", name, lastErr)
+		case <-time.After(createEtcdPollInterval):
+		}
+	}
+}
+
+// waitCreateNodeReady waits until the Kubernetes node of the given name
+// reports Ready.
+func waitCreateNodeReady(ctx context.Context, op *operation.Operation, cfg *config.Config, name string) error {
+	kubeconfigPath, cleanup, err := postBootstrapKubeconfigPathFn(ctx, op, cfg)
+	if err != nil {
+		return fmt.Errorf("prepare kubeconfig: %w", err)
+	}
+	defer cleanup()
+
+	kubeconfig, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("read kubeconfig: %w", err)
+	}
+	client, err := kube.NewClient(kubeconfig)
+	if err != nil {
+		return err
+	}
+	return kube.WaitForNodeReady(ctx, client, name, createNodeTimeout, createEtcdPollInterval)
+}
+
+// phaseWaitEtcd waits until every control-plane slot of the pool is a
+// voting etcd member and every member is healthy.
+func phaseWaitEtcd(ctx context.Context, op *operation.Operation, cfg *config.Config) error {
+	pool, err := poolForOperation(cfg, op)
+	if err != nil {
+		return fmt.Errorf("resolve node pool: %w", err)
+	}
+	talosconfig, err := createTalosconfigFn(ctx, op, cfg)
+	if err != nil {
+		return err
+	}
+
+	var expected []string
+	for slot := 1; slot <= pool.DesiredSize(); slot++ {
+		expected = append(expected, controlPlaneNodeName(cfg.Environment, pool.Name, slot))
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, createEtcdTimeout)
+	defer cancel()
+
+	slog.Info("phase wait-etcd: waiting for control planes to join etcd", "members", len(expected))
+
+	var lastErr error
+	for {
+		lastErr = checkCreateEtcd(waitCtx, cfg, talosconfig, expected)
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("etcd did not become healthy: %w", lastErr)
+		case <-time.After(createEtcdPollInterval):
+		}
+	}
+}
+
+func checkCreateEtcd(ctx context.Context, cfg *config.Config, talosconfig []byte, expected []string) error {
+	health, err := createEtcdClient(ctx, cfg, talosconfig)
+	if err != nil {
+		return err
+	}
+	voting, err := createEtcdMembers(ctx, health)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, name := range expected {
+		if !voting[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not yet voting etcd members: %s", strings.Join(missing, ", "))
+	}
+
+	return health.checkEtcdHealthy(ctx)
+}
+
+// createEtcdClient reaches etcd through the control planes the inventory
+// currently lists.
+func createEtcdClient(ctx context.Context, cfg *config.Config, talosconfig []byte) (*rollingTalosUpgrade, error) {
+	state, err := createLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	health := &rollingTalosUpgrade{
+		talosconfig:   talosconfig,
+		controlPlanes: controlPlaneNodes(upgradeCandidateNodes(state)),
+	}
+	if len(health.controlPlanes) == 0 {
+		return nil, fmt.Errorf("no control-plane nodes known")
+	}
+	return health, nil
+}
+
+// createEtcdMembers lists the etcd members by hostname, reporting whether
+// each one votes.
+func createEtcdMembers(ctx context.Context, health *rollingTalosUpgrade) (map[string]bool, error) {
+	voting := map[string]bool{}
+	err := health.withTalosClient(health.controlPlanes[0], func(client talosUpgradeClient) error {
+		members, err := client.EtcdMembers(ctx)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			voting[member.Hostname] = !member.IsLearner
+		}
+		return nil
+	})
+	return voting, err
+}
+
+// createClusterTalosconfig returns the talosconfig of the cluster being
+// created.
+func createClusterTalosconfig(ctx context.Context, op *operation.Operation, cfg *config.Config) ([]byte, error) {
+	endpoint := op.GetContextString("controlPlaneEndpoint")
+	if endpoint == "" {
+		endpoint = controlPlaneEndpoint(op.GetContextString("privateIP"), op.GetContextString("publicIP"))
+	}
+
+	client, err := newInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := ensureTalosAssets(ctx, cfg, endpoint, client)
+	if err != nil {
+		return nil, err
+	}
+	return assets.Talosconfig, nil
+}
@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/scaleway"
+)
+
+func restoreCreateNodeFns() func() {
+	loadNodeState := createLoadNodeStateFn
+	addNode := createAddNodeFn
+	etcdMember := createEtcdMemberFn
+	nodeReady := createNodeReadyFn
+	cleanupRegistry := operationCleanupRegistryFn
+
+	return func() {
+		createLoadNodeStateFn = loadNodeState
+		createAddNodeFn = addNode
+		createEtcdMemberFn = etcdMember
+		createNodeReadyFn = nodeReady
+		operationCleanupRegistryFn = cleanupRegistry
+	}
+}
+
+// stubCreateJoinChecks records every join check, failing those of the
+// nodes in notJoined.
+func stubCreateJoinChecks(notJoined map[string]bool) *[]string {
+	var (
+		mu     sync.Mutex
+		checks []string
+	)
+	check := func(kind string) func(context.Context, *operation.Operation, *config.Config, string) error {
+		return func(ctx context.Context, op *operation.Operation, cfg *config.Config, name string) error {
+			mu.Lock()
+			checks = append(checks, kind+" "+name)
+			mu.Unlock()
+			if notJoined[name] {
+				return fmt.Errorf("%s has not joined", name)
+			}
+			return nil
+		}
+	}
+	createEtcdMemberFn = check("etcd")
+	createNodeReadyFn = check("ready")
+	return &checks
+}
+
+func haCreateTestConfig() *config.Config {
+	return &config.Config{
+		Environment: "production",
+		NodePools: []config.NodePoolConfig{{
+			Name:               "control-plane",
+			Type:               config.NodeTypeControlPlane,
+			Zone:               "fr-par-2",
+			Size:               3,
+			ReservedPrivateIPs: []string{"172.16.0.10", "172.16.0.11", "172.16.0.12"},
+		}},
+	}
+}
+
+func TestCreateClusterPhasesForPoolJoinsRemainingSlotsBeforePostBootstrap(t *testing.T) {
+	cfg := haCreateTestConfig()
+
+	phases, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	got := strings.Join(phases, ",")
+	want := "bootstrap,control-plane/production-control-plane-02,control-plane/production-control-plane-03,wait-etcd,post-bootstrap"
+	if !strings.Contains(got, want) {
+		t.Fatalf("phases = %s, want sequence %s", got, want)
+	}
+
+	cfg.NodePools[0].Size = 1
+	phases, err = createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	if strings.Join(phases, ",") != strings.Join(createClusterPhases, ",") {
+		t.Fatalf("single control-plane phases = %v, want %v", phases, createClusterPhases)
+	}
+
+	cfg.NodePools[0].Size = 4
+	if _, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0]); err == nil {
+		t.Fatal("expected an error when the pool has fewer reserved IPs than slots")
+	}
+}
+
+func TestRunCreateNodePhasesJoinsPendingControlPlaneSlots(t *testing.T) {
+	t.Cleanup(restoreCreateNodeFns())
+	joinChecks := stubCreateJoinChecks(nil)
+
+	createLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Environment: cfg.Environment, Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+			{Name: "production-control-plane-02", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "2.2.2.2", ServerID: "srv-2"},
+		}}, nil
+	}
+
+	var (
+		mu     sync.Mutex
+		joined []string
+	)
+	createAddNodeFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		name := controlPlaneNodeName(cfg.Environment, pool.Name, slot)
+		mu.Lock()
+		joined = append(joined, name)
+		mu.Unlock()
+		return &nodeAddResult{Name: name, ServerID: "srv-new-" + name[len(name)-2:]}, nil
+	}
+
+	cfg := haCreateTestConfig()
+	phases, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	op := operation.New("op-create", operation.TypeCreateCluster, "production", phases)
+	op.SetContext("poolName", "control-plane")
+
+	if err := runCreateNodePhases(context.Background(), store, op, cfg, createControlPlanePhasePrefix); err != nil {
+		t.Fatalf("runCreateNodePhases returned error: %v", err)
+	}
+
+	sort.Strings(joined)
+	if strings.Join(joined, ",") != "production-control-plane-03" {
+		t.Fatalf("joined = %v, want only the missing slot", joined)
+	}
+	for _, phase := range []string{"control-plane/production-control-plane-02", "control-plane/production-control-plane-03"} {
+		if status := op.Phases[phase].Status; status != operation.PhaseCompleted {
+			t.Fatalf("%s status = %s, want completed", phase, status)
+		}
+	}
+	addID := op.GetContextString(createNodeOperationContextPrefix + "production-control-plane-03")
+	if len(op.Cleanup) != 1 || op.Cleanup[0].Type != cleanupActionAbortOperation || !strings.Contains(string(op.Cleanup[0].Data), addID) {
+		t.Fatalf("cleanup = %+v, want abort of the add-node operation %q", op.Cleanup, addID)
+	}
+
+	sort.Strings(*joinChecks)
+	want := "etcd production-control-plane-02,etcd production-control-plane-03"
+	if got := strings.Join(*joinChecks, ","); got != want {
+		t.Fatalf("join checks = %s, want %s", got, want)
+	}
+}
+
+func TestRunCreateNodePhasesProvisionsWorkerPoolsConcurrently(t *testing.T) {
+	t.Cleanup(restoreCreateNodeFns())
+	joinChecks := stubCreateJoinChecks(nil)
+
+	createLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Environment: cfg.Environment, Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+		}}, nil
+	}
+
+	// Every add blocks until all three are in flight, so a sequential
+	// runner would never finish.
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	go func() {
+		for range 3 {
+			<-started
+		}
+		close(release)
+	}()
+
+	var (
+		mu     sync.Mutex
+		joined []string
+	)
+	createAddNodeFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		started <- struct{}{}
+		<-release
+		name := pooledNodeName(cfg.Environment, pool.Name, slot)
+		mu.Lock()
+		joined = append(joined, name)
+		mu.Unlock()
+		return &nodeAddResult{Name: name, ServerID: "srv-" + name}, nil
+	}
+
+	cfg := haCreateTestConfig()
+	cfg.NodePools[0].Size = 1
+	cfg.NodePools = append(cfg.NodePools,
+		config.NodePoolConfig{Name: "worker", Type: config.NodeTypeWorker, Zone: "fr-par-1", Size: 2},
+		config.NodePoolConfig{Name: "gpu", Type: config.NodeTypeWorker, Zone: "fr-par-2"},
+	)
+	phases, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	want := "verify,worker/worker/production-worker-01,worker/worker/production-worker-02,worker/gpu/production-gpu-01,restrict-talos-api"
+	if got := strings.Join(phases, ","); !strings.Contains(got, want) {
+		t.Fatalf("phases = %s, want sequence %s", got, want)
+	}
+
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	op := operation.New("op-create", operation.TypeCreateCluster, "production", phases)
+	op.SetContext("poolName", "control-plane")
+
+	if err := runCreateNodePhases(context.Background(), store, op, cfg, createWorkerPhasePrefix); err != nil {
+		t.Fatalf("runCreateNodePhases returned error: %v", err)
+	}
+
+	sort.Strings(joined)
+	if got := strings.Join(joined, ","); got != "production-gpu-01,production-worker-01,production-worker-02" {
+		t.Fatalf("joined = %s", got)
+	}
+	if status := op.Phases["verify"].Status; status != operation.PhasePending {
+		t.Fatalf("verify status = %s, want untouched", status)
+	}
+	if len(op.Cleanup) != 3 {
+		t.Fatalf("cleanup = %+v, want one per worker", op.Cleanup)
+	}
+	sort.Strings(*joinChecks)
+	if got := strings.Join(*joinChecks, ","); got != "ready production-gpu-01,ready production-worker-01,ready production-worker-02" {
+		t.Fatalf("join checks = %s", got)
+	}
+}
+
+func TestRunCreateNodePhasesFailsSlotThatNeverJoined(t *testing.T) {
+	t.Cleanup(restoreCreateNodeFns())
+	stubCreateJoinChecks(map[string]bool{"production-control-plane-02": true})
+
+	// Slot 2 is in the inventory but never became an etcd member.
+	createLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Environment: cfg.Environment, Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+			{Name: "production-control-plane-02", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "2.2.2.2", ServerID: "srv-2"},
+		}}, nil
+	}
+	createAddNodeFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		return &nodeAddResult{}, nil
+	}
+
+	cfg := haCreateTestConfig()
+	phases, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	op := operation.New("op-create", operation.TypeCreateCluster, "production", phases)
+	op.SetContext("poolName", "control-plane")
+
+	err = runCreateNodePhases(context.Background(), store, op, cfg, createControlPlanePhasePrefix)
+	if err == nil || !strings.Contains(err.Error(), "has not joined") {
+		t.Fatalf("runCreateNodePhases error = %v, want join failure", err)
+	}
+	if status := op.Phases["control-plane/production-control-plane-02"].Status; status != operation.PhaseFailed {
+		t.Fatalf("slot 2 status = %s, want failed", status)
+	}
+	if status := op.Phases["control-plane/production-control-plane-03"].Status; status != operation.PhaseCompleted {
+		t.Fatalf("slot 3 status = %s, want completed", status)
+	}
+}
+
+func TestRunCreateNodePhasesRollsBackInterruptedAdd(t *testing.T) {
+	t.Cleanup(restoreCreateNodeFns())
+	stubCreateJoinChecks(nil)
+
+	var deleted []string
+	operationCleanupRegistryFn = func(cfg *config.Config) *operation.CleanupRegistry {
+		registry := operation.NewCleanupRegistry()
+		registry.Register(cleanupActionDeleteServer, func(ctx context.Context, data json.RawMessage) error {
+			var payload cleanupDeleteServer
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return err
+			}
+			deleted = append(deleted, payload.ServerID)
+			return nil
+		})
+		return registry
+	}
+
+	nodes := []clusterstate.NodeState{
+		{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+	}
+	createLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Environment: cfg.Environment, Nodes: nodes}, nil
+	}
+
+	// The first add dies after ordering its server, as a killed run would.
+	var adds []string
+	errInterrupted := errors.New("interrupted")
+	createAddNodeFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		adds = append(adds, operationID)
+		add := operation.New(operationID, operation.TypeAddNode, cfg.Environment, nodeAddPhases)
+		serverID := fmt.Sprintf("srv-%d", len(adds))
+		add.SetContext("serverId", serverID)
+		if err := add.AddCleanup(cleanupActionDeleteServer, cleanupDeleteServer{ServerID: serverID, Zone: "fr-par-2"}); err != nil {
+			return nil, err
+		}
+		if len(adds) == 1 {
+			if err := add.StartPhase(nodeAddPhases[0]); err != nil {
+				return nil, err
+			}
+			if err := store.Save(ctx, add); err != nil {
+				return nil, err
+			}
+			return nil, errInterrupted
+		}
+		for _, phase := range nodeAddPhases {
+			if err := add.CompletePhase(phase, nil); err != nil {
+				return nil, err
+			}
+		}
+		return &nodeAddResult{ServerID: serverID}, store.Save(ctx, add)
+	}
+
+	cfg := haCreateTestConfig()
+	cfg.NodePools[0].Size = 2
+	phases, err := createClusterPhasesForPool(cfg, &cfg.NodePools[0])
+	if err != nil {
+		t.Fatalf("createClusterPhasesForPool returned error: %v", err)
+	}
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	op := operation.New("op-create", operation.TypeCreateCluster, "production", phases)
+	op.SetContext("poolName", "control-plane")
+
+	if err := runCreateNodePhases(context.Background(), store, op, cfg, createControlPlanePhasePrefix); !errors.Is(err, errInterrupted) {
+		t.Fatalf("first run error = %v, want interruption", err)
+	}
+	// The interrupted server shows up in the inventory.
+	nodes = append(nodes, clusterstate.NodeState{Name: "production-control-plane-02", Role: config.NodeTypeControlPlane, Pool: "control-plane", ServerID: "srv-1"})
+
+	saved, err := store.Load(context.Background(), "production", op.ID)
+	if err != nil {
+		t.Fatalf("load create operation: %v", err)
+	}
+	if err := runCreateNodePhases(context.Background(), store, saved, cfg, createControlPlanePhasePrefix); err != nil {
+		t.Fatalf("resumed run returned error: %v", err)
+	}
+	if len(adds) != 2 || strings.Join(deleted, ",") != "srv-1" {
+		t.Fatalf("adds = %v, deleted = %v; want the interrupted add rolled back and the slot ordered again", adds, deleted)
+	}
+
+	// Aborting the create rolls back the completed add as well.
+	if err := abortOperation(context.Background(), store, saved, operationCleanupRegistryFn(cfg)); err != nil {
+		t.Fatalf("abortOperation returned error: %v", err)
+	}
+	if strings.Join(deleted, ",") != "srv-1,srv-2" {
+		t.Fatalf("deleted = %v, want both servers", deleted)
+	}
+}
+
+func TestPhaseOrderServerAdoptsServerFromInventory(t *testing.T) {
+	loadNodeState, newClient := orderServerLoadNodeStateFn, scalewayNewClientFn
+	t.Cleanup(func() {
+		orderServerLoadNodeStateFn, scalewayNewClientFn = loadNodeState, newClient
+	})
+
+	orderServerLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Environment: cfg.Environment, Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", ServerID: "srv-1", Status: clusterstate.NodeStatusProvisioning},
+		}}, nil
+	}
+	errOrdered := errors.New("ordered a server")
+	scalewayNewClientFn = func(string, string, string, string) (*scaleway.Client, error) {
+		return nil, errOrdered
+	}
+
+	op := operation.New("op-1", operation.TypeCreateCluster, "production", createClusterPhases)
+	op.SetContext("poolName", "control-plane")
+	op.SetContext("nodeName", "production-control-plane-01")
+	if err := phaseOrderServer(context.Background(), op, haCreateTestConfig()); err != nil {
+		t.Fatalf("phaseOrderServer returned error: %v", err)
+	}
+	if got := op.GetContextString("serverId"); got != "srv-1" {
+		t.Fatalf("serverId = %q, want the inventory's server", got)
+	}
+	if len(op.Cleanup) != 1 || op.Cleanup[0].Type != cleanupActionDeleteServer {
+		t.Fatalf("cleanup = %+v, want the adopted server released on abort", op.Cleanup)
+	}
+
+	// A replacement keeps the node's name; the server it replaces must not
+	// be adopted.
+	op = operation.New("op-2", operation.TypeReplaceNode, "production", createClusterPhases)
+	op.SetContext("poolName", "control-plane")
+	op.SetContext("nodeName", "production-control-plane-01")
+	op.SetContext("replacedServerID", "srv-1")
+	if err := phaseOrderServer(context.Background(), op, haCreateTestConfig()); !errors.Is(err, errOrdered) {
+		t.Fatalf("phaseOrderServer error = %v, want a new server ordered", err)
+	}
+}
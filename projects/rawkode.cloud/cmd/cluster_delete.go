@@ -46,67 +46,115 @@ func runClusterDelete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
 	state, err := clusterDeleteLoadNodeStateFn(ctx, cfg)
 	if err != nil {
 		return err
 	}
 
+	result := clusterDeleteResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Nodes:      []clusterDeleteNodeResult{},
+	}
 	deletedCount := 0
 	alreadyDeletedCount := 0
 	var errs []error
 
-	if len(state.Nodes) == 0 {
-		fmt.Printf("No nodes found in Scaleway inventory for cluster %q (config=%s)\n", cfg.Environment, cfgPath)
-	} else {
-		for _, node := range state.Nodes {
-			if node.Status == clusterstate.NodeStatusDeleted {
-				alreadyDeletedCount++
-				continue
-			}
+	for _, node := range state.Nodes {
+		nodeResult := clusterDeleteNodeResult{
+			Name:     node.Name,
+			Role:     node.Role,
+			ServerID: node.ServerID,
+			PublicIP: node.PublicIP,
+			Status:   "deleted",
+		}
 
-			if serverID := strings.TrimSpace(node.ServerID); serverID != "" {
-				zone, err := resolveClusterDeleteZoneForNode(cfg, node)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("resolve cleanup zone for node %q: %w", node.Name, err))
-					continue
-				}
-
-				payload := cleanupDeleteServer{
-					ServerID: serverID,
-					Zone:     zone,
-				}
-
-				if err := clusterDeleteServerCleanupFn(ctx, cfg, payload); err != nil {
-					errs = append(errs, fmt.Errorf("cleanup server %s for node %q: %w", serverID, node.Name, err))
-					continue
-				}
-			}
+		if node.Status == clusterstate.NodeStatusDeleted {
+			alreadyDeletedCount++
+			nodeResult.Status = "already-deleted"
+			result.Nodes = append(result.Nodes, nodeResult)
+			continue
+		}
 
-			deletedCount++
+		if serverID := strings.TrimSpace(node.ServerID); serverID != "" {
+			if err := deleteClusterNodeServer(ctx, cfg, node, serverID); err != nil {
+				errs = append(errs, err)
+				nodeResult.Status = "failed"
+				nodeResult.Error = err.Error()
+				result.Nodes = append(result.Nodes, nodeResult)
+				continue
+			}
 		}
+
+		deletedCount++
+		result.Nodes = append(result.Nodes, nodeResult)
 	}
 
 	if err := clusterDeleteInfisicalCleanupFn(ctx, cfg); err != nil {
 		errs = append(errs, fmt.Errorf("cleanup infisical resources for cluster %q: %w", cfg.Environment, err))
 	}
 
-	if len(state.Nodes) > 0 && deletedCount == 0 && alreadyDeletedCount == len(state.Nodes) && len(errs) == 0 {
-		fmt.Printf("All discovered nodes for cluster %q are already deleting/deleted (config=%s)\n", cfg.Environment, cfgPath)
-		return nil
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	if len(errs) > 0 {
+		return failWithResult(cmd, result, fmt.Errorf("cluster delete completed with errors: %w", errors.Join(errs...)))
 	}
 
-	if len(state.Nodes) > 0 {
-		fmt.Printf(
-			"Cluster delete processed %d nodes for cluster %q (already_deleted=%d, config=%s)\n",
-			deletedCount,
-			cfg.Environment,
-			alreadyDeletedCount,
-			cfgPath,
-		)
+	return emitResult(cmd, result, func() {
+		switch {
+		case len(state.Nodes) == 0:
+			fmt.Printf("No nodes found in Scaleway inventory for cluster %q (config=%s)\n", cfg.Environment, cfgPath)
+		case deletedCount == 0 && alreadyDeletedCount == len(state.Nodes):
+			fmt.Printf("All discovered nodes for cluster %q are already deleting/deleted (config=%s)\n", cfg.Environment, cfgPath)
+		default:
+			fmt.Printf(
+				"Cluster delete processed %d nodes for cluster %q (already_deleted=%d, config=%s)\n",
+				deletedCount,
+				cfg.Environment,
+				alreadyDeletedCount,
+				cfgPath,
+			)
+		}
+	})
+}
+
+// clusterDeleteResult is the structured output of `cluster delete`.
+type clusterDeleteResult struct {
+	Cluster    string                    `json:"cluster"`
+	ConfigPath string                    `json:"configPath"`
+	Nodes      []clusterDeleteNodeResult `json:"nodes"`
+	Errors     []string                  `json:"errors,omitempty"`
+}
+
+// clusterDeleteNodeResult records what happened to one node's server.
+type clusterDeleteNodeResult struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	ServerID string `json:"serverId,omitempty"`
+	PublicIP string `json:"publicIp,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+func deleteClusterNodeServer(ctx context.Context, cfg *config.Config, node clusterstate.NodeState, serverID string) error {
+	zone, err := resolveClusterDeleteZoneForNode(cfg, node)
+	if err != nil {
+		return fmt.Errorf("resolve cleanup zone for node %q: %w", node.Name, err)
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("cluster delete completed with errors: %w", errors.Join(errs...))
+	payload := cleanupDeleteServer{
+		ServerID: serverID,
+		Zone:     zone,
+	}
+	if err := clusterDeleteServerCleanupFn(ctx, cfg, payload); err != nil {
+		return fmt.Errorf("cleanup server %s for node %q: %w", serverID, node.Name, err)
 	}
 
 	return nil
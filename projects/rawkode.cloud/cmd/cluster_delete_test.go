@@ -53,6 +53,7 @@ func clusterDeleteTestConfig() *config.Config {
 func TestRunClusterDeleteCleansUpInfisicalWhenNoNodesRemain(t *testing.T) {
 	restoreClusterDeleteFns()
 	t.Cleanup(restoreClusterDeleteFns)
+	useLocalClusterLock(t)
 
 	clusterDeleteLoadConfigFn = func(clusterName, cfgFile string) (*config.Config, string, error) {
 		return clusterDeleteTestConfig(), cfgFile, nil
@@ -82,6 +83,7 @@ func TestRunClusterDeleteCleansUpInfisicalWhenNoNodesRemain(t *testing.T) {
 func TestRunClusterDeleteStillCleansUpInfisicalWhenServerCleanupFails(t *testing.T) {
 	restoreClusterDeleteFns()
 	t.Cleanup(restoreClusterDeleteFns)
+	useLocalClusterLock(t)
 
 	clusterDeleteLoadConfigFn = func(clusterName, cfgFile string) (*config.Config, string, error) {
 		return clusterDeleteTestConfig(), cfgFile, nil
@@ -0,0 +1,701 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cilium"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/flux"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+)
+
+var clusterRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Recover the control plane from an etcd snapshot",
+	Long: `Recover a cluster's etcd from a snapshot. This is destructive: the etcd
+data of every control plane is wiped.
+
+The restore runs as a resumable operation:
+
+  pre-flight     verify the snapshot and reach every control plane
+  reset/<node>   wipe EPHEMERAL on each control plane and wait for etcd to
+                 wait for bootstrap
+  bootstrap      upload the snapshot to the first control plane and
+                 bootstrap etcd from it
+  rejoin         wait for the other control planes to join etcd
+  etcd-health    wait for every member to report healthy
+  verify-addons  wait for Cilium and Flux to report healthy
+
+The snapshot is verified as by etcd verify, so an encrypted one is briefly
+decrypted to a private temporary file that is deleted once checked; the
+upload decrypts it again as it streams.
+
+An interrupted restore continues with ` + "`operation resume`" + ` or by running
+the command again with the same snapshot.`,
+	RunE: runClusterRestore,
+}
+
+const (
+	clusterRestorePhasePreFlight    = "pre-flight"
+	clusterRestorePhaseBootstrap    = "bootstrap"
+	clusterRestorePhaseRejoin       = "rejoin"
+	clusterRestorePhaseEtcdHealth   = "etcd-health"
+	clusterRestorePhaseVerifyAddons = "verify-addons"
+	clusterRestoreResetPhasePrefix  = "reset/"
+
+	etcdServiceID = "etcd"
+)
+
+func init() {
+	clusterRestoreCmd.Flags().String("cluster", "", "Cluster/environment name")
+	clusterRestoreCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	clusterRestoreCmd.Flags().String("snapshot", "", "Snapshot file to restore (required)")
+	clusterRestoreCmd.Flags().String("manifest", "", "Manifest to verify the snapshot against (defaults to the .json next to the snapshot, if any)")
+	clusterRestoreCmd.Flags().StringArray("identity", nil, "age identity file to decrypt an encrypted snapshot, in addition to backup.encryption.infisicalKey (repeatable)")
+	clusterRestoreCmd.Flags().Duration("node-timeout", 20*time.Minute, "Maximum time to wait for each node and health check")
+}
+
+// talosClusterRestoreClient is the part of the Talos client a cluster
+// restore uses.
+type talosClusterRestoreClient interface {
+	Services(ctx context.Context) ([]talos.ServiceStatus, error)
+	ResetEphemeral(ctx context.Context) error
+	EtcdRestoreFrom(ctx context.Context, r io.Reader) error
+	BootstrapFromSnapshot(ctx context.Context, skipHashCheck bool) error
+	EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error)
+	EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error)
+	Close() error
+}
+
+var (
+	restoreLoadNodeStateFn = loadNodeState
+	restoreTalosconfigFn   = func(ctx context.Context, cfg *config.Config) ([]byte, error) {
+		infClient, err := newInfisicalClient(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return loadTalosconfigFromInfisical(ctx, cfg, infClient)
+	}
+	restoreTalosClientFn = func(endpoint string, talosconfig []byte) (talosClusterRestoreClient, error) {
+		return talos.NewClient(endpoint, talosconfig)
+	}
+	restoreAccessMaterialsFn = buildClusterAccessMaterials
+	restoreCiliumStatusFn    = cilium.Status
+	restoreFluxStatusFn      = flux.Status
+	restorePollInterval      = 10 * time.Second
+)
+
+// clusterRestorePhaseData is recorded on completed restore phases.
+type clusterRestorePhaseData struct {
+	Skipped  bool   `json:"skipped,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+	Members  int    `json:"members,omitempty"`
+	Node     string `json:"node,omitempty"`
+}
+
+// clusterRestorePhases returns the phases of restoring onto controlPlanes,
+// the first of which is bootstrapped from the snapshot.
+func clusterRestorePhases(controlPlanes []cluster.NodeState) []string {
+	phases := []string{clusterRestorePhasePreFlight}
+	for _, node := range controlPlanes {
+		phases = append(phases, clusterRestoreResetPhasePrefix+node.Name)
+	}
+	return append(phases,
+		clusterRestorePhaseBootstrap,
+		clusterRestorePhaseRejoin,
+		clusterRestorePhaseEtcdHealth,
+		clusterRestorePhaseVerifyAddons,
+	)
+}
+
+// runClusterRestore starts a restore-cluster operation, or continues the
+// in-flight one for the same snapshot.
+func runClusterRestore(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	snapshotPath, _ := cmd.Flags().GetString("snapshot")
+	manifestPath, _ := cmd.Flags().GetString("manifest")
+	identityFiles, _ := cmd.Flags().GetStringArray("identity")
+	nodeTimeout, _ := cmd.Flags().GetDuration("node-timeout")
+
+	if strings.TrimSpace(snapshotPath) == "" {
+		return fmt.Errorf("--snapshot is required")
+	}
+	snapshotPath, err := filepath.Abs(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("resolve snapshot path: %w", err)
+	}
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, operation.TypeRestoreCluster)
+	if err != nil {
+		return fmt.Errorf("look up in-flight %s operation: %w", operation.TypeRestoreCluster, err)
+	}
+	if op != nil && op.GetContextString("snapshotPath") != snapshotPath {
+		return fmt.Errorf(
+			"operation %s is still restoring from %s; resume or abort it before restoring from %s",
+			op.ID, op.GetContextString("snapshotPath"), snapshotPath,
+		)
+	}
+	if op == nil {
+		// Check the snapshot before anything is recorded or touched.
+		verified, err := verifyRestoreSnapshot(ctx, cfg, snapshotPath, manifestPath, identityFiles)
+		if err != nil {
+			return err
+		}
+
+		state, err := restoreLoadNodeStateFn(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		controlPlanes := restoreControlPlanes(state)
+		if len(controlPlanes) == 0 {
+			return fmt.Errorf("no active control-plane nodes found to restore")
+		}
+
+		op = newClusterRestoreOperation(cfg, cfgPath, controlPlanes, snapshotPath, manifestPath, identityFiles, verified.SHA256, nodeTimeout)
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
+	} else {
+		slog.Info("resuming in-flight cluster restore", "operation", op.ID, "resume_from", op.ResumePhase())
+	}
+	if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+		return fmt.Errorf("record operation on cluster lock: %w", err)
+	}
+
+	slog.Info("starting cluster restore",
+		"operation", op.ID,
+		"cluster", cfg.Environment,
+		"snapshot", snapshotPath,
+		"resume_from", op.ResumePhase(),
+	)
+
+	result, err := executeClusterRestore(ctx, store, op, cfg)
+	if err != nil {
+		err = fmt.Errorf("cluster restore aborted (resume with `operation resume --id %s`): %w", op.ID, err)
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Restored cluster %q from %s at revision %d (bootstrapped on %s, config=%s)\n",
+			result.Cluster, result.Snapshot, result.Revision, result.BootstrapNode, result.ConfigPath,
+		)
+	})
+}
+
+func newClusterRestoreOperation(
+	cfg *config.Config,
+	cfgPath string,
+	controlPlanes []cluster.NodeState,
+	snapshotPath, manifestPath string,
+	identityFiles []string,
+	snapshotSHA256 string,
+	nodeTimeout time.Duration,
+) *operation.Operation {
+	names := make([]string, 0, len(controlPlanes))
+	for _, node := range controlPlanes {
+		names = append(names, node.Name)
+	}
+
+	op := operation.New(operation.GenerateID(), operation.TypeRestoreCluster, cfg.Environment, clusterRestorePhases(controlPlanes))
+	op.SetContext("configPath", cfgPath)
+	op.SetContext("snapshotPath", snapshotPath)
+	op.SetContext("snapshotSha256", snapshotSHA256)
+	op.SetContext("manifestPath", manifestPath)
+	// Only the paths of identity files are recorded, never their keys.
+	op.SetContext("identityFiles", strings.Join(identityFiles, "\n"))
+	op.SetContext("controlPlanes", strings.Join(names, ","))
+	op.SetContext("nodeTimeout", nodeTimeout.String())
+	return op
+}
+
+// restoreControlPlanes returns the active control planes ordered by name;
+// the first is the one bootstrapped from the snapshot.
+func restoreControlPlanes(state *cluster.NodesState) []cluster.NodeState {
+	var nodes []cluster.NodeState
+	for _, node := range activeNodesByRole(state, config.NodeTypeControlPlane) {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// verifyRestoreSnapshot checks the snapshot as `etcd verify` does.
+func verifyRestoreSnapshot(ctx context.Context, cfg *config.Config, snapshotPath, manifestPath string, identityFiles []string) (*etcdVerifyResult, error) {
+	verified, err := verifyEtcdSnapshot(ctx, cfg, snapshotPath, manifestPath, identityFiles)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to restore: %w", err)
+	}
+	return verified, nil
+}
+
+// clusterRestoreResult is the structured output of `cluster restore`.
+type clusterRestoreResult struct {
+	Cluster       string   `json:"cluster"`
+	ConfigPath    string   `json:"configPath"`
+	OperationID   string   `json:"operationId"`
+	Snapshot      string   `json:"snapshot"`
+	Revision      int64    `json:"revision,omitempty"`
+	BootstrapNode string   `json:"bootstrapNode,omitempty"`
+	ControlPlanes []string `json:"controlPlanes"`
+	Error         string   `json:"error,omitempty"`
+}
+
+func clusterRestoreResultFromOperation(op *operation.Operation, cfg *config.Config) *clusterRestoreResult {
+	result := &clusterRestoreResult{
+		Cluster:       cfg.Environment,
+		ConfigPath:    op.GetContextString("configPath"),
+		OperationID:   op.ID,
+		Snapshot:      op.GetContextString("snapshotPath"),
+		ControlPlanes: clusterRestoreControlPlaneNames(op),
+	}
+	if len(result.ControlPlanes) > 0 {
+		result.BootstrapNode = result.ControlPlanes[0]
+	}
+	var data clusterRestorePhaseData
+	if err := op.PhaseData(clusterRestorePhasePreFlight, &data); err == nil {
+		result.Revision = data.Revision
+	}
+	return result
+}
+
+func clusterRestoreControlPlaneNames(op *operation.Operation) []string {
+	value := op.GetContextString("controlPlanes")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// clusterRestoreExecution holds what a restore-cluster operation needs to
+// run its remaining phases. It is rebuilt from the operation on every run.
+type clusterRestoreExecution struct {
+	store operation.Store
+	op    *operation.Operation
+	cfg   *config.Config
+
+	// controlPlanes are in restore order; the first is bootstrapped.
+	controlPlanes []cluster.NodeState
+	talosconfig   []byte
+	nodeTimeout   time.Duration
+}
+
+// executeClusterRestore runs the remaining phases of a restore-cluster
+// operation.
+func executeClusterRestore(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*clusterRestoreResult, error) {
+	exec, err := newClusterRestoreExecution(ctx, store, op, cfg)
+	if err != nil {
+		result := clusterRestoreResultFromOperation(op, cfg)
+		result.Error = err.Error()
+		return result, err
+	}
+
+	for {
+		phase := op.ResumePhase()
+		if phase == "" {
+			return clusterRestoreResultFromOperation(op, cfg), nil
+		}
+		if err := exec.runPhase(ctx, phase); err != nil {
+			result := clusterRestoreResultFromOperation(op, cfg)
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+}
+
+func newClusterRestoreExecution(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*clusterRestoreExecution, error) {
+	names := clusterRestoreControlPlaneNames(op)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("operation %s does not record the control planes to restore", op.ID)
+	}
+
+	nodeTimeout := 20 * time.Minute
+	if value := op.GetContextString("nodeTimeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse nodeTimeout from operation context: %w", err)
+		}
+		nodeTimeout = parsed
+	}
+
+	state, err := restoreLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	controlPlanes := make([]cluster.NodeState, 0, len(names))
+	for _, name := range names {
+		node, ok := findNodeByName(state, name)
+		if !ok || strings.TrimSpace(node.PublicIP) == "" {
+			return nil, fmt.Errorf("control plane %q is no longer in the Scaleway inventory", name)
+		}
+		controlPlanes = append(controlPlanes, *node)
+	}
+
+	talosconfig, err := restoreTalosconfigFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterRestoreExecution{
+		store:         store,
+		op:            op,
+		cfg:           cfg,
+		controlPlanes: controlPlanes,
+		talosconfig:   talosconfig,
+		nodeTimeout:   nodeTimeout,
+	}, nil
+}
+
+func (e *clusterRestoreExecution) runPhase(ctx context.Context, phase string) error {
+	slog.Info("executing phase", "phase", phase, "operation", e.op.ID)
+
+	if err := startOperationPhase(ctx, e.store, e.op, phase); err != nil {
+		return err
+	}
+
+	var (
+		data     clusterRestorePhaseData
+		phaseErr error
+	)
+	switch {
+	case phase == clusterRestorePhasePreFlight:
+		data, phaseErr = e.preFlight(ctx)
+	case strings.HasPrefix(phase, clusterRestoreResetPhasePrefix):
+		data.Node = strings.TrimPrefix(phase, clusterRestoreResetPhasePrefix)
+		phaseErr = e.reset(ctx, data.Node)
+	case phase == clusterRestorePhaseBootstrap:
+		data.Node = e.controlPlanes[0].Name
+		data.Skipped, phaseErr = e.bootstrap(ctx)
+	case phase == clusterRestorePhaseRejoin:
+		phaseErr = e.rejoin(ctx)
+	case phase == clusterRestorePhaseEtcdHealth:
+		phaseErr = e.waitUntil(ctx, "etcd to become healthy", e.checkEtcdHealthy)
+	case phase == clusterRestorePhaseVerifyAddons:
+		phaseErr = e.verifyAddons(ctx)
+	default:
+		phaseErr = fmt.Errorf("unknown phase %q", phase)
+	}
+	if phaseErr != nil {
+		return failOperationPhase(ctx, e.store, e.op, phase, phaseErr)
+	}
+
+	if err := e.op.CompletePhase(phase, data); err != nil {
+		return fmt.Errorf("complete phase %s: %w", phase, err)
+	}
+	return saveOperation(ctx, e.store, e.op)
+}
+
+// preFlight re-verifies the snapshot, which must still be the file the
+// operation was started with, and checks every control plane answers.
+func (e *clusterRestoreExecution) preFlight(ctx context.Context) (clusterRestorePhaseData, error) {
+	var data clusterRestorePhaseData
+	verified, err := e.verifySnapshot(ctx)
+	if err != nil {
+		return data, err
+	}
+	data.Revision, data.Members = verified.Revision, verified.Members
+
+	var errs []error
+	for _, node := range e.controlPlanes {
+		if _, err := e.etcdService(ctx, node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return data, fmt.Errorf("control planes unreachable: %w", errors.Join(errs...))
+	}
+	return data, nil
+}
+
+// reset wipes the node's etcd data and waits for it to come back with etcd
+// waiting for bootstrap. Resetting a node that is already waiting is
+// harmless, so an interrupted reset is simply repeated.
+func (e *clusterRestoreExecution) reset(ctx context.Context, name string) error {
+	node, err := e.controlPlane(name)
+	if err != nil {
+		return err
+	}
+
+	if err := e.withTalosClient(node, func(client talosClusterRestoreClient) error {
+		return client.ResetEphemeral(ctx)
+	}); err != nil {
+		return err
+	}
+
+	return e.waitUntil(ctx, fmt.Sprintf("etcd on %s to wait for bootstrap", name), func(ctx context.Context) error {
+		service, err := e.etcdService(ctx, node)
+		if err != nil {
+			return err
+		}
+		if !etcdWaitingForBootstrap(service) {
+			return fmt.Errorf("etcd is %s: %s", defaultString(service.State, "unknown"), defaultString(service.LastEvent, "-"))
+		}
+		return nil
+	})
+}
+
+// bootstrap uploads the snapshot to the first control plane and bootstraps
+// etcd from it. A node whose etcd already runs was bootstrapped by an
+// earlier attempt and is skipped.
+func (e *clusterRestoreExecution) bootstrap(ctx context.Context) (bool, error) {
+	node := e.controlPlanes[0]
+
+	service, err := e.etcdService(ctx, node)
+	if err != nil {
+		return false, err
+	}
+	if etcdServiceRunning(service) {
+		slog.Info("etcd already running on bootstrap node; skipping", "node", node.Name)
+		return true, nil
+	}
+	if !etcdWaitingForBootstrap(service) {
+		return false, fmt.Errorf("etcd on %s is not waiting for bootstrap (state %s)", node.Name, defaultString(service.State, "unknown"))
+	}
+
+	verified, err := e.verifySnapshot(ctx)
+	if err != nil {
+		return false, err
+	}
+	err = e.withTalosClient(node, func(client talosClusterRestoreClient) error {
+		if err := streamVerifiedEtcdSnapshot(ctx, e.cfg, e.op.GetContextString("snapshotPath"), e.identityFiles(), verified, func(snapshot io.Reader) error {
+			return client.EtcdRestoreFrom(ctx, snapshot)
+		}); err != nil {
+			return err
+		}
+		// Only snapshots streamed from etcd carry its integrity hash.
+		return client.BootstrapFromSnapshot(ctx, !verified.IntegrityHash)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, e.waitUntil(ctx, fmt.Sprintf("etcd on %s to start", node.Name), func(ctx context.Context) error {
+		service, err := e.etcdService(ctx, node)
+		if err != nil {
+			return err
+		}
+		if !etcdServiceRunning(service) {
+			return fmt.Errorf("etcd is %s", defaultString(service.State, "unknown"))
+		}
+		return nil
+	})
+}
+
+// rejoin waits for the other control planes, whose etcd was waiting to
+// join, to become members of the restored cluster.
+func (e *clusterRestoreExecution) rejoin(ctx context.Context) error {
+	return e.waitUntil(ctx, "control planes to rejoin etcd", func(ctx context.Context) error {
+		members, err := e.etcdMembers(ctx)
+		if err != nil {
+			return err
+		}
+		joined := make(map[string]bool, len(members))
+		for _, member := range members {
+			joined[member.Hostname] = !member.IsLearner
+		}
+
+		var missing []string
+		for _, node := range e.controlPlanes[1:] {
+			if !joined[node.Name] {
+				missing = append(missing, node.Name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("not yet voting members: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// checkEtcdHealthy requires one member per control plane and every member
+// to report a status free of errors.
+func (e *clusterRestoreExecution) checkEtcdHealthy(ctx context.Context) error {
+	members, err := e.etcdMembers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(members) != len(e.controlPlanes) {
+		return fmt.Errorf("%d etcd members, want %d", len(members), len(e.controlPlanes))
+	}
+
+	var errs []error
+	for _, node := range e.controlPlanes {
+		err := e.withTalosClient(node, func(client talosClusterRestoreClient) error {
+			status, err := client.EtcdStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if len(status.Errors) > 0 {
+				return fmt.Errorf("member reports errors: %v", status.Errors)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifyAddons waits for the Kubernetes API to serve the restored state
+// and for Cilium and Flux to report healthy against it.
+func (e *clusterRestoreExecution) verifyAddons(ctx context.Context) error {
+	return e.waitUntil(ctx, "Cilium and Flux to become healthy", func(ctx context.Context) error {
+		materials, err := restoreAccessMaterialsFn(ctx, "", e.op.GetContextString("configPath"))
+		if err != nil {
+			return fmt.Errorf("load cluster access: %w", err)
+		}
+		kubeconfigPath, cleanup, err := writeTemporaryKubeconfig(materials.KubeconfigYAML)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		var errs []error
+		if err := restoreCiliumStatusFn(ctx, kubeconfigPath); err != nil {
+			errs = append(errs, fmt.Errorf("cilium: %w", err))
+		}
+		if err := restoreFluxStatusFn(ctx, kubeconfigPath); err != nil {
+			errs = append(errs, fmt.Errorf("flux: %w", err))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// verifySnapshot verifies the recorded snapshot and refuses a file that
+// changed since the operation started.
+func (e *clusterRestoreExecution) verifySnapshot(ctx context.Context) (*etcdVerifyResult, error) {
+	path := e.op.GetContextString("snapshotPath")
+	verified, err := verifyEtcdSnapshot(ctx, e.cfg, path, e.op.GetContextString("manifestPath"), e.identityFiles())
+	if err != nil {
+		return nil, fmt.Errorf("refusing to restore: %w", err)
+	}
+	if want := e.op.GetContextString("snapshotSha256"); verified.SHA256 != want {
+		return nil, fmt.Errorf("refusing to restore: %s changed since the operation started (sha256 %s, want %s)", path, verified.SHA256, want)
+	}
+	return verified, nil
+}
+
+// identityFiles returns the age identity files the operation was started
+// with.
+func (e *clusterRestoreExecution) identityFiles() []string {
+	value := e.op.GetContextString("identityFiles")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+func (e *clusterRestoreExecution) controlPlane(name string) (cluster.NodeState, error) {
+	for _, node := range e.controlPlanes {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+	return cluster.NodeState{}, fmt.Errorf("control plane %q is not part of operation %s", name, e.op.ID)
+}
+
+func (e *clusterRestoreExecution) etcdService(ctx context.Context, node cluster.NodeState) (talos.ServiceStatus, error) {
+	var services []talos.ServiceStatus
+	if err := e.withTalosClient(node, func(client talosClusterRestoreClient) error {
+		var err error
+		services, err = client.Services(ctx)
+		return err
+	}); err != nil {
+		return talos.ServiceStatus{}, err
+	}
+	for _, service := range services {
+		if service.ID == etcdServiceID {
+			return service, nil
+		}
+	}
+	return talos.ServiceStatus{}, fmt.Errorf("etcd service not found")
+}
+
+// etcdMembers lists members through the bootstrap node, the one member
+// certain to exist once it is bootstrapped.
+func (e *clusterRestoreExecution) etcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	var members []talos.EtcdMember
+	err := e.withTalosClient(e.controlPlanes[0], func(client talosClusterRestoreClient) error {
+		var err error
+		members, err = client.EtcdMembers(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list etcd members: %w", err)
+	}
+	return members, nil
+}
+
+// waitUntil retries check until it succeeds or the node timeout passes.
+func (e *clusterRestoreExecution) waitUntil(ctx context.Context, what string, check func(ctx context.Context) error) error {
+	waitCtx, cancel := context.WithTimeout(ctx, e.nodeTimeout)
+	defer cancel()
+
+	for {
+		err := check(waitCtx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("timed out after %s waiting for %s: %w", e.nodeTimeout, what, err)
+		case <-time.After(restorePollInterval):
+		}
+	}
+}
+
+// withTalosClient opens a fresh client per call; control planes reboot
+// while they are reset.
+func (e *clusterRestoreExecution) withTalosClient(node cluster.NodeState, fn func(client talosClusterRestoreClient) error) error {
+	client, err := restoreTalosClientFn(node.PublicIP, e.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
+
+// etcdWaitingForBootstrap reports whether the etcd service is held in its
+// pre-start step until it is bootstrapped or can join a cluster, which is
+// where Talos leaves it on a control plane without etcd data.
+func etcdWaitingForBootstrap(service talos.ServiceStatus) bool {
+	return strings.EqualFold(service.State, "Preparing") &&
+		strings.Contains(strings.ToLower(service.LastEvent), "waiting to join")
+}
+
+func etcdServiceRunning(service talos.ServiceStatus) bool {
+	return strings.EqualFold(service.State, "Running")
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+)
+
+func restoreClusterRestoreFns() func() {
+	loadNodeState := restoreLoadNodeStateFn
+	talosconfig := restoreTalosconfigFn
+	talosClient := restoreTalosClientFn
+	accessMaterials := restoreAccessMaterialsFn
+	ciliumStatus := restoreCiliumStatusFn
+	fluxStatus := restoreFluxStatusFn
+	pollInterval := restorePollInterval
+
+	return func() {
+		restoreLoadNodeStateFn = loadNodeState
+		restoreTalosconfigFn = talosconfig
+		restoreTalosClientFn = talosClient
+		restoreAccessMaterialsFn = accessMaterials
+		restoreCiliumStatusFn = ciliumStatus
+		restoreFluxStatusFn = fluxStatus
+		restorePollInterval = pollInterval
+	}
+}
+
+// fakeRestoreCluster models the etcd service of each control plane: reset
+// leaves it waiting to join, bootstrapping one node starts it and lets the
+// waiting nodes join.
+type fakeRestoreCluster struct {
+	mu            sync.Mutex
+	waiting       map[string]bool
+	members       map[string]bool
+	failBootstrap bool
+	restored      int
+	skipHashCheck bool
+	events        []string
+}
+
+func (f *fakeRestoreCluster) record(event string) {
+	f.events = append(f.events, event)
+}
+
+type fakeTalosClusterRestoreClient struct {
+	cluster *fakeRestoreCluster
+	name    string
+}
+
+func (c *fakeTalosClusterRestoreClient) Services(ctx context.Context) ([]talos.ServiceStatus, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	if c.cluster.waiting[c.name] {
+		return []talos.ServiceStatus{{ID: "etcd", State: "Preparing", LastEvent: "Running pre state: waiting to join or bootstrap"}}, nil
+	}
+	return []talos.ServiceStatus{{ID: "etcd", State: "Running", Healthy: true}}, nil
+}
+
+func (c *fakeTalosClusterRestoreClient) ResetEphemeral(ctx context.Context) error {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	c.cluster.record("reset " + c.name)
+	c.cluster.waiting[c.name] = true
+	delete(c.cluster.members, c.name)
+	return nil
+}
+
+func (c *fakeTalosClusterRestoreClient) EtcdRestoreFrom(ctx context.Context, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	c.cluster.record("upload " + c.name)
+	c.cluster.restored = len(data)
+	return nil
+}
+
+func (c *fakeTalosClusterRestoreClient) BootstrapFromSnapshot(ctx context.Context, skipHashCheck bool) error {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	c.cluster.record("bootstrap " + c.name)
+	if c.cluster.failBootstrap {
+		return errors.New("connection refused")
+	}
+	c.cluster.skipHashCheck = skipHashCheck
+	for name := range c.cluster.waiting {
+		c.cluster.members[name] = true
+	}
+	c.cluster.waiting = map[string]bool{}
+	return nil
+}
+
+func (c *fakeTalosClusterRestoreClient) EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	var members []talos.EtcdMember
+	for name := range c.cluster.members {
+		members = append(members, talos.EtcdMember{Hostname: name})
+	}
+	return members, nil
+}
+
+func (c *fakeTalosClusterRestoreClient) EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error) {
+	return &talos.EtcdMemberStatus{}, nil
+}
+
+func (c *fakeTalosClusterRestoreClient) Close() error {
+	return nil
+}
+
+// newClusterRestoreTest stubs every seam of a cluster restore and records
+// an operation restoring the snapshot at snapshotPath.
+func newClusterRestoreTest(t *testing.T, etcd *fakeRestoreCluster, snapshotPath string) (operation.Store, *operation.Operation, *[]string) {
+	t.Helper()
+	t.Cleanup(restoreClusterRestoreFns())
+
+	nodes := nodeRemoveTestNodes()
+	names := map[string]string{}
+	for _, node := range nodes {
+		names[node.PublicIP] = node.Name
+	}
+
+	restorePollInterval = time.Millisecond
+	restoreLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*cluster.NodesState, error) {
+		return &cluster.NodesState{Environment: cfg.Environment, Nodes: nodes}, nil
+	}
+	restoreTalosconfigFn = func(ctx context.Context, cfg *config.Config) ([]byte, error) {
+		return []byte("talosconfig"), nil
+	}
+	restoreTalosClientFn = func(endpoint string, talosconfig []byte) (talosClusterRestoreClient, error) {
+		return &fakeTalosClusterRestoreClient{cluster: etcd, name: names[endpoint]}, nil
+	}
+	restoreAccessMaterialsFn = func(ctx context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
+		return &clusterAccessMaterials{ConfigPath: cfgFile, KubeconfigYAML: []byte("apiVersion: v1\n")}, nil
+	}
+	var addonChecks []string
+	restoreCiliumStatusFn = func(ctx context.Context, kubeconfigPath string) error {
+		addonChecks = append(addonChecks, "cilium")
+		return nil
+	}
+	restoreFluxStatusFn = func(ctx context.Context, kubeconfigPath string) error {
+		addonChecks = append(addonChecks, "flux")
+		return nil
+	}
+
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	info, err := etcdbackup.InspectFile(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Environment: "production"}
+	op := newClusterRestoreOperation(cfg, "production.yaml", restoreControlPlanes(&cluster.NodesState{Nodes: nodes}), snapshotPath, "", nil, info.SHA256, time.Second)
+	if err := store.Save(context.Background(), op); err != nil {
+		t.Fatalf("save operation: %v", err)
+	}
+	return store, op, &addonChecks
+}
+
+func testRestoreCluster() *fakeRestoreCluster {
+	return &fakeRestoreCluster{
+		waiting: map[string]bool{},
+		members: map[string]bool{
+			"production-control-plane-01": true,
+			"production-control-plane-02": true,
+			"production-control-plane-03": true,
+		},
+	}
+}
+
+func TestClusterRestorePhasesResetEveryControlPlane(t *testing.T) {
+	nodes := restoreControlPlanes(&cluster.NodesState{Nodes: nodeRemoveTestNodes()})
+	want := []string{
+		"pre-flight",
+		"reset/production-control-plane-01",
+		"reset/production-control-plane-02",
+		"reset/production-control-plane-03",
+		"bootstrap",
+		"rejoin",
+		"etcd-health",
+		"verify-addons",
+	}
+	if got := clusterRestorePhases(nodes); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("phases = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteClusterRestoreOrder(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	writeEtcdTestSnapshot(t, snapshotPath, 42)
+	etcd := testRestoreCluster()
+	store, op, addonChecks := newClusterRestoreTest(t, etcd, snapshotPath)
+
+	result, err := executeClusterRestore(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err != nil {
+		t.Fatalf("executeClusterRestore returned error: %v", err)
+	}
+
+	want := []string{
+		"reset production-control-plane-01",
+		"reset production-control-plane-02",
+		"reset production-control-plane-03",
+		"upload production-control-plane-01",
+		"bootstrap production-control-plane-01",
+	}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+	if etcd.restored == 0 || !etcd.skipHashCheck {
+		t.Fatalf("restored %d bytes, skipHashCheck = %t; want upload without an integrity hash", etcd.restored, etcd.skipHashCheck)
+	}
+	if strings.Join(*addonChecks, ",") != "cilium,flux" {
+		t.Fatalf("addon checks = %v", *addonChecks)
+	}
+	if !op.IsComplete() || result.Revision != 42 || result.BootstrapNode != "production-control-plane-01" {
+		t.Fatalf("operation complete = %t, result = %+v", op.IsComplete(), result)
+	}
+}
+
+func TestExecuteClusterRestoreResumesWithoutResettingAgain(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	writeEtcdTestSnapshot(t, snapshotPath, 42)
+	etcd := testRestoreCluster()
+	etcd.failBootstrap = true
+	store, op, _ := newClusterRestoreTest(t, etcd, snapshotPath)
+
+	if _, err := executeClusterRestore(context.Background(), store, op, &config.Config{Environment: "production"}); err == nil {
+		t.Fatal("expected bootstrap failure")
+	}
+	if got := op.ResumePhase(); got != clusterRestorePhaseBootstrap {
+		t.Fatalf("ResumePhase() = %q, want %q", got, clusterRestorePhaseBootstrap)
+	}
+
+	etcd.events = nil
+	etcd.failBootstrap = false
+	if _, err := executeClusterRestore(context.Background(), store, op, &config.Config{Environment: "production"}); err != nil {
+		t.Fatalf("resumed executeClusterRestore returned error: %v", err)
+	}
+	want := []string{"upload production-control-plane-01", "bootstrap production-control-plane-01"}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+}
+
+func TestExecuteClusterRestoreRefusesChangedSnapshot(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	writeEtcdTestSnapshot(t, snapshotPath, 42)
+	etcd := testRestoreCluster()
+	store, op, _ := newClusterRestoreTest(t, etcd, snapshotPath)
+
+	if err := os.Remove(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+	writeEtcdTestSnapshot(t, snapshotPath, 43)
+	_, err := executeClusterRestore(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err == nil || !strings.Contains(err.Error(), "changed since the operation started") {
+		t.Fatalf("executeClusterRestore error = %v, want changed snapshot refusal", err)
+	}
+	if len(etcd.events) != 0 {
+		t.Fatalf("cluster was touched despite failed pre-flight: %v", etcd.events)
+	}
+}
@@ -0,0 +1,470 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cilium"
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/flux"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const clusterStatusNodeProbeTimeout = 15 * time.Second
+
+var clusterStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show cluster health and drift detection",
+	Long: `Inspect the live cluster: Talos services and versions on every node,
+Kubernetes node readiness, Cilium and Flux health, and drift between the
+Scaleway inventory, the running cluster, and the versions in the config.`,
+	RunE: runClusterStatus,
+}
+
+var (
+	clusterStatusLoadConfigFn      = loadConfigForClusterOrFile
+	clusterStatusLoadNodeStateFn   = loadNodeState
+	clusterStatusAccessMaterialsFn = buildClusterAccessMaterials
+	clusterStatusTalosProbeFn      = probeTalosNodeStatus
+	clusterStatusKubernetesNodesFn = listKubernetesNodes
+	clusterStatusCiliumFn          = cilium.Status
+	clusterStatusFluxFn            = flux.Status
+)
+
+var talosOSImageVersionPattern = regexp.MustCompile(`v\d+\.\d+\.\d+\S*`)
+
+// clusterStatusReport is everything `cluster status` learned about a cluster.
+type clusterStatusReport struct {
+	Cluster                  string                    `json:"cluster"`
+	ConfigPath               string                    `json:"configPath"`
+	DesiredTalosVersion      string                    `json:"desiredTalosVersion"`
+	DesiredKubernetesVersion string                    `json:"desiredKubernetesVersion"`
+	Nodes                    []nodeStatus              `json:"nodes"`
+	Drift                    *clusterstate.DriftResult `json:"drift,omitempty"`
+	KubernetesError          string                    `json:"kubernetesError,omitempty"`
+	Cilium                   componentStatus           `json:"cilium"`
+	Flux                     componentStatus           `json:"flux"`
+}
+
+// nodeStatus combines the inventory entry for a node with what Talos and
+// Kubernetes report about it.
+type nodeStatus struct {
+	Name              string                `json:"name"`
+	Role              string                `json:"role"`
+	Pool              string                `json:"pool,omitempty"`
+	ServerID          string                `json:"serverId,omitempty"`
+	PublicIP          string                `json:"publicIp,omitempty"`
+	PrivateIP         string                `json:"privateIp,omitempty"`
+	InventoryStatus   string                `json:"inventoryStatus"`
+	TalosVersion      string                `json:"talosVersion,omitempty"`
+	KubernetesVersion string                `json:"kubernetesVersion,omitempty"`
+	Ready             string                `json:"ready"`
+	Services          []talos.ServiceStatus `json:"services,omitempty"`
+	TalosError        string                `json:"talosError,omitempty"`
+	VersionDrift      []string              `json:"versionDrift,omitempty"`
+}
+
+// componentStatus is the outcome of a cluster add-on health check.
+type componentStatus struct {
+	Checked bool   `json:"checked"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+func init() {
+	clusterStatusCmd.Flags().String("cluster", "", "Cluster/environment name")
+	clusterStatusCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	clusterStatusCmd.Flags().String("config", "", "Path to cluster config YAML")
+	_ = clusterStatusCmd.Flags().MarkDeprecated("config", "use --file instead")
+	clusterStatusCmd.Flags().Duration("timeout", 2*time.Minute, "Maximum time to spend on Cilium and Flux health checks")
+}
+
+func runClusterStatus(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	if legacyFile, _ := cmd.Flags().GetString("config"); strings.TrimSpace(cfgFile) == "" {
+		cfgFile = legacyFile
+	}
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	report, err := collectClusterStatus(ctx, clusterName, cfgFile, timeout)
+	if err != nil {
+		return err
+	}
+
+	return emitResult(cmd, report, func() {
+		printClusterStatus(report)
+	})
+}
+
+func collectClusterStatus(ctx context.Context, clusterName, cfgFile string, timeout time.Duration) (*clusterStatusReport, error) {
+	cfg, cfgPath, err := clusterStatusLoadConfigFn(clusterName, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := clusterStatusLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &clusterStatusReport{
+		Cluster:                  cfg.Environment,
+		ConfigPath:               cfgPath,
+		DesiredTalosVersion:      cfg.Cluster.TalosVersion,
+		DesiredKubernetesVersion: cfg.Cluster.KubernetesVersion,
+	}
+
+	known := make([]clusterstate.NodeState, 0, len(state.Nodes))
+	for _, node := range state.Nodes {
+		if node.Status == clusterstate.NodeStatusDeleted {
+			continue
+		}
+		known = append(known, node)
+		report.Nodes = append(report.Nodes, nodeStatus{
+			Name:            node.Name,
+			Role:            node.Role,
+			Pool:            node.Pool,
+			ServerID:        node.ServerID,
+			PublicIP:        node.PublicIP,
+			PrivateIP:       node.PrivateIP,
+			InventoryStatus: string(node.Status),
+			Ready:           "Unknown",
+		})
+	}
+
+	materials, err := clusterStatusAccessMaterialsFn(ctx, clusterName, cfgPath)
+	if err != nil {
+		report.KubernetesError = fmt.Sprintf("cluster unreachable: %v", err)
+		report.Cilium = componentStatus{Message: "skipped: cluster unreachable"}
+		report.Flux = componentStatus{Message: "skipped: cluster unreachable"}
+		for i := range report.Nodes {
+			report.Nodes[i].TalosError = "skipped: cluster unreachable"
+			report.Nodes[i].VersionDrift = nodeVersionDrift(report.Nodes[i], report.DesiredTalosVersion, report.DesiredKubernetesVersion)
+		}
+		return report, nil
+	}
+
+	probeTalosNodes(ctx, report.Nodes, known, materials.TalosconfigYAML)
+
+	kubeNodes, err := clusterStatusKubernetesNodesFn(ctx, materials.KubeconfigYAML)
+	if err != nil {
+		report.KubernetesError = fmt.Sprintf("list kubernetes nodes: %v", err)
+	} else {
+		mergeKubernetesNodes(report.Nodes, kubeNodes)
+		report.Drift = clusterstate.DetectDrift(known, kubernetesNodeAddresses(known, kubeNodes))
+	}
+
+	for i := range report.Nodes {
+		report.Nodes[i].VersionDrift = nodeVersionDrift(report.Nodes[i], report.DesiredTalosVersion, report.DesiredKubernetesVersion)
+	}
+
+	kubeconfigPath, cleanup, err := writeTemporaryKubeconfig(materials.KubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	report.Cilium = checkClusterComponent(ctx, timeout, kubeconfigPath, clusterStatusCiliumFn)
+	report.Flux = checkClusterComponent(ctx, timeout, kubeconfigPath, clusterStatusFluxFn)
+
+	return report, nil
+}
+
+// probeTalosNodes queries every node concurrently. nodes and known are
+// index-aligned.
+func probeTalosNodes(ctx context.Context, nodes []nodeStatus, known []clusterstate.NodeState, talosconfig []byte) {
+	var wg sync.WaitGroup
+	for i := range nodes {
+		node := &nodes[i]
+		endpoints := talosAccessEndpoints(&known[i])
+		if len(endpoints) == 0 {
+			node.TalosError = "no Talos endpoint in inventory"
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			version, services, err := clusterStatusTalosProbeFn(ctx, endpoints, talosconfig)
+			if err != nil {
+				slog.Debug("talos status probe failed", "node", node.Name, "error", err)
+				node.TalosError = err.Error()
+			}
+			node.TalosVersion = version
+			node.Services = services
+		}()
+	}
+	wg.Wait()
+}
+
+// probeTalosNodeStatus reads the Talos version and service list from the
+// first endpoint that answers.
+func probeTalosNodeStatus(ctx context.Context, endpoints []string, talosconfig []byte) (string, []talos.ServiceStatus, error) {
+	var lastErr error
+	for _, endpoint := range endpoints {
+		version, services, err := queryTalosNodeStatus(ctx, endpoint, talosconfig)
+		if err == nil {
+			return version, services, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", endpoint, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return "", nil, lastErr
+}
+
+func queryTalosNodeStatus(ctx context.Context, endpoint string, talosconfig []byte) (string, []talos.ServiceStatus, error) {
+	client, err := talos.NewClient(endpoint, talosconfig)
+	if err != nil {
+		return "", nil, fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	probeCtx, cancel := context.WithTimeout(ctx, clusterStatusNodeProbeTimeout)
+	defer cancel()
+
+	version, err := client.Version(probeCtx)
+	if err != nil {
+		return "", nil, err
+	}
+	services, err := client.Services(probeCtx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return version, services, nil
+}
+
+func listKubernetesNodes(ctx context.Context, kubeconfigYAML []byte) ([]corev1.Node, error) {
+	client, err := kube.NewClient(kubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+func mergeKubernetesNodes(nodes []nodeStatus, kubeNodes []corev1.Node) {
+	byName := make(map[string]*corev1.Node, len(kubeNodes))
+	for i := range kubeNodes {
+		byName[kubeNodes[i].Name] = &kubeNodes[i]
+	}
+
+	for i := range nodes {
+		kubeNode, ok := byName[nodes[i].Name]
+		if !ok {
+			nodes[i].Ready = "NotRegistered"
+			continue
+		}
+
+		nodes[i].Ready = kubernetesNodeReady(kubeNode)
+		nodes[i].KubernetesVersion = kubeNode.Status.NodeInfo.KubeletVersion
+		if nodes[i].TalosVersion == "" {
+			nodes[i].TalosVersion = talosOSImageVersionPattern.FindString(kubeNode.Status.NodeInfo.OSImage)
+		}
+	}
+}
+
+func kubernetesNodeReady(node *corev1.Node) string {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		switch condition.Status {
+		case corev1.ConditionTrue:
+			return "Ready"
+		case corev1.ConditionFalse:
+			return "NotReady"
+		}
+	}
+
+	return "Unknown"
+}
+
+// kubernetesNodeAddresses maps each Kubernetes node to the address drift
+// detection should compare. Nodes may register with either their public or
+// private IP, so a node reporting either inventory address maps to the
+// inventory public IP; anything else is reported as found.
+func kubernetesNodeAddresses(known []clusterstate.NodeState, kubeNodes []corev1.Node) map[string]string {
+	knownByName := make(map[string]clusterstate.NodeState, len(known))
+	for _, node := range known {
+		knownByName[node.Name] = node
+	}
+
+	actual := make(map[string]string, len(kubeNodes))
+	for _, kubeNode := range kubeNodes {
+		var addresses []string
+		for _, address := range kubeNode.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP || address.Type == corev1.NodeExternalIP {
+				addresses = append(addresses, strings.TrimSpace(address.Address))
+			}
+		}
+
+		resolved := ""
+		if len(addresses) > 0 {
+			resolved = addresses[0]
+		}
+		if node, ok := knownByName[kubeNode.Name]; ok {
+			for _, address := range addresses {
+				if address != "" && (address == node.PublicIP || address == node.PrivateIP) {
+					resolved = node.PublicIP
+					break
+				}
+			}
+		}
+
+		actual[kubeNode.Name] = resolved
+	}
+
+	return actual
+}
+
+func nodeVersionDrift(node nodeStatus, desiredTalos, desiredKubernetes string) []string {
+	var drift []string
+
+	if want := normalizeVersionTag(desiredTalos); want != "" {
+		if got := normalizeVersionTag(node.TalosVersion); got != "" && got != want {
+			drift = append(drift, fmt.Sprintf("talos %s (want %s)", got, want))
+		}
+	}
+	if want := normalizeVersionTag(desiredKubernetes); want != "" {
+		if got := normalizeVersionTag(node.KubernetesVersion); got != "" && got != want {
+			drift = append(drift, fmt.Sprintf("kubernetes %s (want %s)", got, want))
+		}
+	}
+
+	return drift
+}
+
+func normalizeVersionTag(version string) string {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return ""
+	}
+	return "v" + strings.TrimPrefix(version, "v")
+}
+
+func checkClusterComponent(
+	ctx context.Context,
+	timeout time.Duration,
+	kubeconfigPath string,
+	check func(context.Context, string) error,
+) componentStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := check(checkCtx, kubeconfigPath); err != nil {
+		return componentStatus{Checked: true, Message: err.Error()}
+	}
+
+	return componentStatus{Checked: true, Healthy: true, Message: "healthy"}
+}
+
+// summarizeServices reports "ok (N)" when every service is running and not
+// failing its health check, otherwise the offending services.
+func summarizeServices(node nodeStatus) string {
+	if node.TalosError != "" {
+		return "unreachable"
+	}
+	if len(node.Services) == 0 {
+		return "-"
+	}
+
+	var problems []string
+	for _, service := range node.Services {
+		switch {
+		case !strings.EqualFold(service.State, "running"):
+			problems = append(problems, fmt.Sprintf("%s:%s", service.ID, defaultString(service.State, "unknown")))
+		case !service.Healthy && !service.Unknown:
+			problems = append(problems, service.ID+":unhealthy")
+		}
+	}
+	if len(problems) == 0 {
+		return fmt.Sprintf("ok (%d)", len(node.Services))
+	}
+
+	sort.Strings(problems)
+	return strings.Join(problems, ",")
+}
+
+func printClusterStatus(report *clusterStatusReport) {
+	fmt.Printf("Cluster: %s (config=%s)\n", report.Cluster, report.ConfigPath)
+	fmt.Printf("Desired: talos=%s kubernetes=%s\n\n",
+		defaultString(report.DesiredTalosVersion, "-"),
+		defaultString(report.DesiredKubernetesVersion, "-"),
+	)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tPOOL\tINVENTORY\tTALOS\tKUBERNETES\tREADY\tSERVICES\tDRIFT")
+	for _, node := range report.Nodes {
+		drift := "-"
+		if len(node.VersionDrift) > 0 {
+			drift = strings.Join(node.VersionDrift, "; ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			node.Name,
+			node.Role,
+			defaultString(node.Pool, "-"),
+			defaultString(node.InventoryStatus, "-"),
+			defaultString(node.TalosVersion, "-"),
+			defaultString(node.KubernetesVersion, "-"),
+			node.Ready,
+			summarizeServices(node),
+			drift,
+		)
+	}
+	_ = w.Flush()
+
+	for _, node := range report.Nodes {
+		if node.TalosError != "" {
+			fmt.Printf("  %s: talos: %s\n", node.Name, node.TalosError)
+		}
+	}
+
+	fmt.Println()
+	if report.KubernetesError != "" {
+		fmt.Printf("Kubernetes: %s\n", report.KubernetesError)
+	}
+	fmt.Printf("Cilium:     %s\n", formatComponentStatus(report.Cilium))
+	fmt.Printf("Flux:       %s\n", formatComponentStatus(report.Flux))
+
+	if report.Drift != nil {
+		fmt.Printf("\n%s", report.Drift.String())
+	}
+}
+
+func formatComponentStatus(status componentStatus) string {
+	if !status.Checked {
+		return defaultString(status.Message, "not checked")
+	}
+	if status.Healthy {
+		return "healthy"
+	}
+	return "unhealthy: " + status.Message
+}
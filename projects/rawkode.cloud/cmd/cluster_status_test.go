@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func restoreClusterStatusFns() func() {
+	loadConfig := clusterStatusLoadConfigFn
+	loadNodeState := clusterStatusLoadNodeStateFn
+	accessMaterials := clusterStatusAccessMaterialsFn
+	talosProbe := clusterStatusTalosProbeFn
+	kubernetesNodes := clusterStatusKubernetesNodesFn
+	ciliumStatus := clusterStatusCiliumFn
+	fluxStatus := clusterStatusFluxFn
+
+	return func() {
+		clusterStatusLoadConfigFn = loadConfig
+		clusterStatusLoadNodeStateFn = loadNodeState
+		clusterStatusAccessMaterialsFn = accessMaterials
+		clusterStatusTalosProbeFn = talosProbe
+		clusterStatusKubernetesNodesFn = kubernetesNodes
+		clusterStatusCiliumFn = ciliumStatus
+		clusterStatusFluxFn = fluxStatus
+	}
+}
+
+func kubernetesTestNode(name, ip, kubeletVersion string, ready corev1.ConditionStatus) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}},
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion: kubeletVersion,
+				OSImage:        "Talos (v1.9.5)",
+			},
+		},
+	}
+}
+
+func TestCollectClusterStatusReportsHealthAndDrift(t *testing.T) {
+	defer restoreClusterStatusFns()()
+
+	clusterStatusLoadConfigFn = func(clusterName, filePath string) (*config.Config, string, error) {
+		return &config.Config{
+			Environment: "production",
+			Cluster: config.ClusterConfig{
+				TalosVersion:      "v1.9.5",
+				KubernetesVersion: "1.32.2",
+			},
+		}, "clusters/production.yaml", nil
+	}
+	clusterStatusLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, PublicIP: "1.1.1.1", PrivateIP: "10.0.0.1", Status: clusterstate.NodeStatusReady},
+			{Name: "production-worker-01", Role: config.NodeTypeWorker, PublicIP: "2.2.2.2", Status: clusterstate.NodeStatusReady},
+			{Name: "production-worker-02", Role: config.NodeTypeWorker, PublicIP: "3.3.3.3", Status: clusterstate.NodeStatusReady},
+		}}, nil
+	}
+	clusterStatusAccessMaterialsFn = func(ctx context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
+		return &clusterAccessMaterials{TalosconfigYAML: []byte("talos"), KubeconfigYAML: []byte("kube")}, nil
+	}
+	clusterStatusTalosProbeFn = func(ctx context.Context, endpoints []string, talosconfig []byte) (string, []talos.ServiceStatus, error) {
+		if endpoints[len(endpoints)-1] == "3.3.3.3" {
+			return "", nil, errors.New("connection refused")
+		}
+		return "v1.9.5", []talos.ServiceStatus{
+			{ID: "etcd", State: "Running", Healthy: true},
+			{ID: "kubelet", State: "Running", Healthy: false},
+		}, nil
+	}
+	clusterStatusKubernetesNodesFn = func(ctx context.Context, kubeconfigYAML []byte) ([]corev1.Node, error) {
+		return []corev1.Node{
+			// Registered with the private IP, which is not drift.
+			kubernetesTestNode("production-control-plane-01", "10.0.0.1", "v1.32.2", corev1.ConditionTrue),
+			kubernetesTestNode("production-worker-01", "9.9.9.9", "v1.31.4", corev1.ConditionFalse),
+			kubernetesTestNode("production-worker-99", "4.4.4.4", "v1.32.2", corev1.ConditionTrue),
+		}, nil
+	}
+	clusterStatusCiliumFn = func(ctx context.Context, kubeconfig string) error { return nil }
+	clusterStatusFluxFn = func(ctx context.Context, kubeconfig string) error {
+		return errors.New("kustomization flux-system not ready")
+	}
+
+	report, err := collectClusterStatus(context.Background(), "production", "", time.Second)
+	if err != nil {
+		t.Fatalf("collectClusterStatus returned error: %v", err)
+	}
+
+	controlPlane, worker, unreachable := report.Nodes[0], report.Nodes[1], report.Nodes[2]
+	if controlPlane.Ready != "Ready" || len(controlPlane.VersionDrift) != 0 {
+		t.Fatalf("control plane = %+v, want Ready without version drift", controlPlane)
+	}
+	if got := summarizeServices(controlPlane); got != "kubelet:unhealthy" {
+		t.Fatalf("summarizeServices() = %q, want %q", got, "kubelet:unhealthy")
+	}
+	if worker.Ready != "NotReady" {
+		t.Fatalf("worker Ready = %q, want NotReady", worker.Ready)
+	}
+	if len(worker.VersionDrift) != 1 || !strings.Contains(worker.VersionDrift[0], "kubernetes v1.31.4 (want v1.32.2)") {
+		t.Fatalf("worker VersionDrift = %v, want kubernetes drift", worker.VersionDrift)
+	}
+	if unreachable.Ready != "NotRegistered" || unreachable.TalosError == "" {
+		t.Fatalf("unreachable node = %+v, want NotRegistered with a Talos error", unreachable)
+	}
+	if got := summarizeServices(unreachable); got != "unreachable" {
+		t.Fatalf("summarizeServices() = %q, want unreachable", got)
+	}
+
+	drift := report.Drift
+	if drift == nil {
+		t.Fatal("expected drift result")
+	}
+	if len(drift.MissingNodes) != 1 || drift.MissingNodes[0] != "production-worker-02" {
+		t.Fatalf("MissingNodes = %v, want [production-worker-02]", drift.MissingNodes)
+	}
+	if len(drift.ExtraNodes) != 1 || drift.ExtraNodes[0] != "production-worker-99" {
+		t.Fatalf("ExtraNodes = %v, want [production-worker-99]", drift.ExtraNodes)
+	}
+	if len(drift.IPMismatches) != 1 || drift.IPMismatches[0].NodeName != "production-worker-01" {
+		t.Fatalf("IPMismatches = %+v, want production-worker-01", drift.IPMismatches)
+	}
+
+	if !report.Cilium.Healthy {
+		t.Fatalf("Cilium = %+v, want healthy", report.Cilium)
+	}
+	if report.Flux.Healthy || !strings.Contains(report.Flux.Message, "flux-system") {
+		t.Fatalf("Flux = %+v, want unhealthy with message", report.Flux)
+	}
+}
+
+func TestCollectClusterStatusKeepsInventoryWhenClusterUnreachable(t *testing.T) {
+	defer restoreClusterStatusFns()()
+
+	clusterStatusLoadConfigFn = func(clusterName, filePath string) (*config.Config, string, error) {
+		return &config.Config{Environment: "production"}, "clusters/production.yaml", nil
+	}
+	clusterStatusLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*clusterstate.NodesState, error) {
+		return &clusterstate.NodesState{Nodes: []clusterstate.NodeState{
+			{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, PublicIP: "1.1.1.1", Status: clusterstate.NodeStatusReady},
+		}}, nil
+	}
+	clusterStatusAccessMaterialsFn = func(ctx context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
+		return nil, errors.New("no control-plane endpoint reachable")
+	}
+
+	report, err := collectClusterStatus(context.Background(), "production", "", time.Second)
+	if err != nil {
+		t.Fatalf("collectClusterStatus returned error: %v", err)
+	}
+	if len(report.Nodes) != 1 || report.Nodes[0].Ready != "Unknown" {
+		t.Fatalf("Nodes = %+v, want one node with Unknown readiness", report.Nodes)
+	}
+	if report.Cilium.Checked || report.Flux.Checked {
+		t.Fatal("expected Cilium and Flux checks to be skipped")
+	}
+	if !strings.Contains(report.KubernetesError, "unreachable") {
+		t.Fatalf("KubernetesError = %q, want unreachable", report.KubernetesError)
+	}
+}
@@ -206,6 +206,18 @@ func findNodeByName(state *clusterstate.NodesState, name string) (*clusterstate.
 }
 
 func firstActiveNodeByRole(state *clusterstate.NodesState, role string) (*clusterstate.NodeState, error) {
+	nodes := activeNodesByRole(state, role)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no active %s node with reachable IP found in Scaleway inventory", role)
+	}
+
+	return nodes[0], nil
+}
+
+// activeNodesByRole returns the nodes of a role that are neither deleted nor
+// failed and have an IP to reach them on, in inventory order.
+func activeNodesByRole(state *clusterstate.NodesState, role string) []*clusterstate.NodeState {
+	var nodes []*clusterstate.NodeState
 	for i := range state.Nodes {
 		node := &state.Nodes[i]
 		if node.Role != role {
@@ -217,10 +229,10 @@ func firstActiveNodeByRole(state *clusterstate.NodesState, role string) (*cluste
 		if strings.TrimSpace(node.PublicIP) == "" && strings.TrimSpace(node.PrivateIP) == "" {
 			continue
 		}
-		return node, nil
+		nodes = append(nodes, node)
 	}
 
-	return nil, fmt.Errorf("no active %s node with reachable IP found in Scaleway inventory", role)
+	return nodes
 }
 
 func loadTalosconfigFromInfisical(ctx context.Context, cfg *config.Config, client *infisical.Client) ([]byte, error) {
@@ -317,35 +329,132 @@ func renderNodeTalosConfig(cfg *config.Config, machineConfig []byte, nodeName, n
 		return nil, err
 	}
 
-	certSANs := nodeTalosCertSANs(nodeName)
-	if len(certSANs) == 0 {
-		return rendered, nil
+	pool, _ := nodePoolForNode(cfg, nodeName, nodeRole)
+	if pool != nil {
+		rendered, err = withNodePoolMetadata(rendered, pool)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if certSANs := nodeTalosCertSANs(nodeName); len(certSANs) > 0 {
+		rendered, err = talos.WithCertSANs(rendered, certSANs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg != nil && cfg.MayastorEnabled() {
+		rendered, err = talos.WithMayastorNode(rendered)
+		if err != nil {
+			return nil, err
+		}
+		if nodeRole == config.NodeTypeControlPlane {
+			rendered, err = talos.WithMayastorControlPlane(rendered)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	rendered, err = talos.WithCertSANs(rendered, certSANs...)
+	// User patches go last so they can override anything rendered above.
+	patches, err := nodeConfigPatches(cfg, pool)
 	if err != nil {
 		return nil, err
 	}
-
-	if cfg == nil || !cfg.MayastorEnabled() {
+	if len(patches) == 0 {
 		return rendered, nil
 	}
 
-	rendered, err = talos.WithMayastorNode(rendered)
-	if err != nil {
+	return talos.ApplyConfigPatches(rendered, patches)
+}
+
+// nodeConfigPatches returns the machine-config patches for a node: the
+// cluster-level patches followed by its pool's, each in file order.
+func nodeConfigPatches(cfg *config.Config, pool *config.NodePoolConfig) ([]talos.ConfigPatch, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var patches []talos.ConfigPatch
+	add := func(scope string, configured []config.ConfigPatch) error {
+		for i, patch := range configured {
+			name := strings.TrimSpace(patch.Name)
+			if name == "" {
+				name = fmt.Sprintf("%d", i+1)
+			}
+			converted := talos.ConfigPatch{Name: scope + "/" + name}
+
+			switch {
+			case len(patch.StrategicMerge) > 0 && len(patch.JSONPatch) > 0:
+				return fmt.Errorf("config patch %s sets both strategicMerge and jsonPatch", converted.Name)
+			case len(patch.StrategicMerge) > 0:
+				data, err := yaml.Marshal(patch.StrategicMerge)
+				if err != nil {
+					return fmt.Errorf("encode config patch %s: %w", converted.Name, err)
+				}
+				converted.StrategicMerge = data
+			case len(patch.JSONPatch) > 0:
+				data, err := yaml.Marshal(patch.JSONPatch)
+				if err != nil {
+					return fmt.Errorf("encode config patch %s: %w", converted.Name, err)
+				}
+				converted.JSON6902 = data
+			default:
+				return fmt.Errorf("config patch %s sets neither strategicMerge nor jsonPatch", converted.Name)
+			}
+
+			patches = append(patches, converted)
+		}
+		return nil
+	}
+
+	if err := add("cluster", cfg.Cluster.ConfigPatches); err != nil {
 		return nil, err
 	}
+	if pool != nil {
+		if err := add("pool "+pool.Name, pool.ConfigPatches); err != nil {
+			return nil, err
+		}
+	}
 
-	if nodeRole != config.NodeTypeControlPlane {
-		return rendered, nil
+	return patches, nil
+}
+
+// nodePoolForNode finds the pool whose slot naming a node follows.
+func nodePoolForNode(cfg *config.Config, nodeName, nodeRole string) (*config.NodePoolConfig, bool) {
+	if cfg == nil {
+		return nil, false
 	}
+	for i := range cfg.NodePools {
+		pool := &cfg.NodePools[i]
+		if nodeRole != "" && pool.EffectiveType() != nodeRole {
+			continue
+		}
+		if _, ok := parsePooledNodeSlot(cfg.Environment, pool.Name, nodeName); ok {
+			return pool, true
+		}
+	}
+	return nil, false
+}
 
-	rendered, err = talos.WithMayastorControlPlane(rendered)
+// withNodePoolMetadata applies the pool's Kubernetes labels, taints and
+// annotations to a machine config.
+func withNodePoolMetadata(machineConfig []byte, pool *config.NodePoolConfig) ([]byte, error) {
+	taints, err := pool.TalosNodeTaints()
 	if err != nil {
 		return nil, err
 	}
 
-	return rendered, nil
+	rendered, err := talos.WithNodeLabels(machineConfig, pool.Labels)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err = talos.WithNodeTaints(rendered, taints)
+	if err != nil {
+		return nil, err
+	}
+	return talos.WithNodeAnnotations(rendered, pool.Annotations)
 }
 
 func nodeTalosCertSANs(nodeName string) []string {
@@ -584,6 +693,21 @@ func controlPlaneReservedIPForSlot(pool *config.NodePoolConfig, slot int) (strin
 	return strings.TrimSpace(pool.ReservedPrivateIPs[slot-1]), nil
 }
 
+// nextNodePoolSlots returns the count lowest free slots of a pool.
+func nextNodePoolSlots(state *clusterstate.NodesState, environment, poolName, role string, count int) ([]int, error) {
+	planned := &clusterstate.NodesState{Environment: state.Environment, Nodes: append([]clusterstate.NodeState(nil), state.Nodes...)}
+	slots := make([]int, 0, count)
+	for range count {
+		slot := nextNodePoolSlot(planned, environment, poolName, role)
+		if slot > 99 {
+			return nil, fmt.Errorf("no available naming slot for pool %q", poolName)
+		}
+		planned.Nodes = append(planned.Nodes, clusterstate.NodeState{Name: pooledNodeName(environment, poolName, slot), Role: role, Pool: poolName})
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
 func nextNodePoolSlot(state *clusterstate.NodesState, environment, poolName, role string) int {
 	occupied := make(map[int]struct{})
 	unknownNamedNodes := 0
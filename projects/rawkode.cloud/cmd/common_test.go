@@ -271,3 +271,73 @@ func TestTalosAPIAllowedSubnetsEnv(t *testing.T) {
 		t.Fatalf("talosAPIAllowedSubnets() = %v, want [100.64.0.0/10 fd00::/8]", got)
 	}
 }
+
+func TestRenderNodeTalosConfigAppliesPoolMetadata(t *testing.T) {
+	cfg := &config.Config{
+		Environment: "production",
+		NodePools: []config.NodePoolConfig{
+			{Name: "worker", Type: config.NodeTypeWorker},
+			{
+				Name:        "worker-gpu",
+				Type:        config.NodeTypeWorker,
+				Labels:      map[string]string{"node.rawkode.cloud/gpu": "true"},
+				Taints:      []config.NodeTaint{{Key: "nvidia.com/gpu", Value: "present", Effect: "NoSchedule"}},
+				Annotations: map[string]string{"rawkode.cloud/owner": "ml"},
+			},
+		},
+	}
+
+	rendered, err := renderNodeTalosConfig(cfg, []byte("machine:\n  type: worker\n"), "production-worker-gpu-02", config.NodeTypeWorker)
+	if err != nil {
+		t.Fatalf("renderNodeTalosConfig returned error: %v", err)
+	}
+	for _, want := range []string{"node.rawkode.cloud/gpu: \"true\"", "nvidia.com/gpu: present:NoSchedule", "rawkode.cloud/owner: ml"} {
+		if !strings.Contains(string(rendered), want) {
+			t.Fatalf("rendered config missing %q:\n%s", want, rendered)
+		}
+	}
+
+	rendered, err = renderNodeTalosConfig(cfg, []byte("machine:\n  type: worker\n"), "production-worker-01", config.NodeTypeWorker)
+	if err != nil {
+		t.Fatalf("renderNodeTalosConfig returned error: %v", err)
+	}
+	if strings.Contains(string(rendered), "nvidia.com/gpu") {
+		t.Fatalf("worker pool node received gpu pool taints:\n%s", rendered)
+	}
+}
+
+func TestRenderNodeTalosConfigAppliesClusterThenPoolPatches(t *testing.T) {
+	cfg := &config.Config{
+		Environment: "production",
+		Cluster: config.ClusterConfig{ConfigPatches: []config.ConfigPatch{{
+			Name: "somaxconn",
+			StrategicMerge: map[string]any{
+				"machine": map[string]any{"sysctls": map[string]any{"net.core.somaxconn": "65535"}},
+			},
+		}}},
+		NodePools: []config.NodePoolConfig{{
+			Name: "worker",
+			Type: config.NodeTypeWorker,
+			ConfigPatches: []config.ConfigPatch{{
+				Name: "somaxconn-override",
+				JSONPatch: []config.JSONPatchOperation{
+					{Op: "replace", Path: "/machine/sysctls/net.core.somaxconn", Value: "4096"},
+				},
+			}},
+		}},
+	}
+
+	rendered, err := renderNodeTalosConfig(cfg, []byte("machine:\n  type: worker\n"), "production-worker-01", config.NodeTypeWorker)
+	if err != nil {
+		t.Fatalf("renderNodeTalosConfig returned error: %v", err)
+	}
+	if !strings.Contains(string(rendered), `net.core.somaxconn: "4096"`) {
+		t.Fatalf("rendered config does not carry the pool override:\n%s", rendered)
+	}
+
+	cfg.NodePools[0].ConfigPatches = append(cfg.NodePools[0].ConfigPatches, config.ConfigPatch{Name: "empty"})
+	_, err = renderNodeTalosConfig(cfg, []byte("machine:\n  type: worker\n"), "production-worker-01", config.NodeTypeWorker)
+	if err == nil || !strings.Contains(err.Error(), "pool worker/empty") {
+		t.Fatalf("error = %v, want empty pool patch rejected by name", err)
+	}
+}
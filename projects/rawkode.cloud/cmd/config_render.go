@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the Talos machine config nodes receive",
+}
+
+var configRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the machine config a pool slot would receive",
+	Long: `Render the full multi-document Talos machine config for a pool slot:
+the generated base config, node-specific settings, pool metadata, config
+patches and the Netbird extension service. Control planes also get the
+Talos API ingress restriction cluster create applies when Netbird is set
+up. Secrets are redacted unless --show-secrets is set.
+
+Rendering only reads the cluster's existing Talos secrets bundle from
+Infisical and never writes to it; it fails if the cluster has none yet.`,
+	RunE: runConfigRender,
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare a node's running machine config with the rendered one",
+	RunE:  runConfigDiff,
+}
+
+// configRenderResult is the structured output of `config render`.
+type configRenderResult struct {
+	Cluster  string `json:"cluster"`
+	Node     string `json:"node"`
+	Pool     string `json:"pool"`
+	Role     string `json:"role"`
+	Redacted bool   `json:"redacted"`
+	Config   string `json:"config"`
+}
+
+// configDiffResult is the structured output of `config diff`.
+type configDiffResult struct {
+	Cluster  string `json:"cluster"`
+	Node     string `json:"node"`
+	Address  string `json:"address"`
+	Redacted bool   `json:"redacted"`
+	Changed  bool   `json:"changed"`
+	Diff     string `json:"diff,omitempty"`
+}
+
+// renderedNodeConfig is the machine config of one pool slot and what was
+// needed to produce it.
+type renderedNodeConfig struct {
+	cfg         *config.Config
+	pool        *config.NodePoolConfig
+	name        string
+	machine     []byte
+	talosconfig []byte
+}
+
+func init() {
+	configCmd.AddCommand(configRenderCmd)
+	configCmd.AddCommand(configDiffCmd)
+
+	for _, cmd := range []*cobra.Command{configRenderCmd, configDiffCmd} {
+		cmd.Flags().String("cluster", "", "Cluster/environment name")
+		cmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+		cmd.Flags().String("pool", "", "Node pool name (defaults to the first pool of --role)")
+		cmd.Flags().Int("slot", 1, "Pool slot of the node")
+		cmd.Flags().String("role", "", "Node role (control-plane or worker) used to pick the pool when --pool is not set")
+		cmd.Flags().Bool("show-secrets", false, "Print secrets instead of redacting them")
+	}
+	configRenderCmd.Flags().String("endpoint", "", "Control-plane endpoint for the generated config (defaults to the first control-plane slot's reserved IP, then the inventory)")
+}
+
+func runConfigRender(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+	endpoint, _ := cmd.Flags().GetString("endpoint")
+
+	rendered, err := renderConfigForCommand(ctx, cmd, endpoint)
+	if err != nil {
+		return err
+	}
+
+	out := rendered.machine
+	if !showSecrets {
+		out, err = talos.RedactSecrets(out)
+		if err != nil {
+			return err
+		}
+	}
+
+	result := &configRenderResult{
+		Cluster:  rendered.cfg.Environment,
+		Node:     rendered.name,
+		Pool:     rendered.pool.Name,
+		Role:     rendered.pool.EffectiveType(),
+		Redacted: !showSecrets,
+		Config:   string(out),
+	}
+	return emitResult(cmd, result, func() {
+		fmt.Print(result.Config)
+	})
+}
+
+func runConfigDiff(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+
+	rendered, err := renderConfigForCommand(ctx, cmd, "")
+	if err != nil {
+		return err
+	}
+
+	state, err := loadNodeState(ctx, rendered.cfg)
+	if err != nil {
+		return err
+	}
+	node, ok := findNodeByName(state, rendered.name)
+	if !ok || node.Status == clusterstate.NodeStatusDeleted || strings.TrimSpace(node.PublicIP) == "" {
+		return fmt.Errorf("node %q is not running in cluster %q", rendered.name, rendered.cfg.Environment)
+	}
+
+	client, err := talos.NewClient(node.PublicIP, rendered.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client for node %s: %w", node.Name, err)
+	}
+	defer client.Close()
+
+	live, err := client.MachineConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("read machine config of node %s: %w", node.Name, err)
+	}
+
+	diff, changed, err := diffMachineConfigs(live, rendered.machine, node.Name, !showSecrets)
+	if err != nil {
+		return err
+	}
+
+	result := &configDiffResult{
+		Cluster:  rendered.cfg.Environment,
+		Node:     node.Name,
+		Address:  node.PublicIP,
+		Redacted: !showSecrets,
+		Changed:  changed,
+		Diff:     diff,
+	}
+	return emitResult(cmd, result, func() {
+		if !result.Changed {
+			fmt.Printf("Node %q runs the rendered machine config.\n", node.Name)
+			return
+		}
+		fmt.Print(result.Diff)
+	})
+}
+
+// renderConfigForCommand renders the machine config for the pool slot
+// selected by the command's flags.
+func renderConfigForCommand(ctx context.Context, cmd *cobra.Command, endpoint string) (*renderedNodeConfig, error) {
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	poolName, _ := cmd.Flags().GetString("pool")
+	slot, _ := cmd.Flags().GetInt("slot")
+	roleRaw, _ := cmd.Flags().GetString("role")
+
+	cfg, _, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := resolveConfigRenderPool(cfg, poolName, roleRaw)
+	if err != nil {
+		return nil, err
+	}
+	if slot < 1 || slot > 99 {
+		return nil, fmt.Errorf("--slot must be between 1 and 99")
+	}
+	if pool.EffectiveType() == config.NodeTypeControlPlane {
+		if _, err := controlPlaneReservedIPForSlot(pool, slot); err != nil {
+			return nil, err
+		}
+	}
+
+	infClient, err := newInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		endpoint, err = configRenderEndpoint(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	assets, err := renderTalosAssets(ctx, cfg, endpoint, infClient)
+	if err != nil {
+		return nil, err
+	}
+	netbirdSetupKey, err := loadOptionalNetbirdSetupKeyFromInfisical(ctx, cfg, infClient)
+	if err != nil {
+		return nil, fmt.Errorf("load netbird setup key: %w", err)
+	}
+
+	name := pooledNodeName(cfg.Environment, pool.Name, slot)
+	machine, err := renderPoolSlotConfig(cfg, assets, name, pool.EffectiveType(), netbirdSetupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &renderedNodeConfig{
+		cfg:         cfg,
+		pool:        pool,
+		name:        name,
+		machine:     machine,
+		talosconfig: assets.Talosconfig,
+	}, nil
+}
+
+func resolveConfigRenderPool(cfg *config.Config, poolName, roleRaw string) (*config.NodePoolConfig, error) {
+	role := ""
+	if strings.TrimSpace(roleRaw) != "" {
+		role = config.NormalizeNodePoolType(roleRaw)
+		if role == "" {
+			return nil, fmt.Errorf("--role must be one of: control-plane, worker")
+		}
+	}
+
+	if strings.TrimSpace(poolName) == "" && role == "" {
+		return nil, fmt.Errorf("--pool or --role is required")
+	}
+	if role == "" {
+		return cfg.FindNodePool(poolName)
+	}
+	return resolveAddPool(cfg, poolName, role)
+}
+
+// configRenderEndpoint returns the control-plane endpoint cluster create
+// would use: the first control-plane slot's reserved IP, or the first
+// active control plane in the inventory.
+func configRenderEndpoint(ctx context.Context, cfg *config.Config) (string, error) {
+	if pool, err := cfg.FirstNodePoolByType(config.NodeTypeControlPlane); err == nil {
+		if reserved, err := controlPlaneReservedIPForSlot(pool, 1); err == nil && reserved != "" {
+			return reserved, nil
+		}
+	}
+
+	state, err := loadNodeState(ctx, cfg)
+	if err != nil {
+		return "", fmt.Errorf("resolve control-plane endpoint (set --endpoint to skip the inventory): %w", err)
+	}
+	return controlPlaneEndpointFromState(state)
+}
+
+// renderPoolSlotConfig builds the complete machine config a node is given:
+// the rendered node config, the Netbird extension service and, for control
+// planes with Netbird, the Talos API ingress restriction.
+func renderPoolSlotConfig(cfg *config.Config, assets *talos.GenConfigResult, name, role, netbirdSetupKey string) ([]byte, error) {
+	nodeConfig, err := renderNodeMachineConfig(cfg, assets, name, role, netbirdSetupKey)
+	if err != nil {
+		return nil, err
+	}
+	if role != config.NodeTypeControlPlane || strings.TrimSpace(netbirdSetupKey) == "" {
+		return nodeConfig, nil
+	}
+
+	nodeConfig, err = appendTalosAPIIngressRestriction(nodeConfig, talosAPIAllowedSubnets())
+	if err != nil {
+		return nil, fmt.Errorf("append Talos API ingress restriction: %w", err)
+	}
+	return nodeConfig, nil
+}
+
+// renderNodeMachineConfig renders the base config for the node's role and
+// appends the Netbird extension service.
+func renderNodeMachineConfig(cfg *config.Config, assets *talos.GenConfigResult, name, role, netbirdSetupKey string) ([]byte, error) {
+	baseConfig := assets.Worker
+	if role == config.NodeTypeControlPlane {
+		baseConfig = assets.ControlPlane
+	}
+
+	nodeConfig, err := renderNodeTalosConfig(cfg, baseConfig, name, role)
+	if err != nil {
+		return nil, fmt.Errorf("render node-specific Talos config for %q: %w", name, err)
+	}
+	nodeConfig, err = appendNetbirdExtensionServiceConfig(nodeConfig, netbirdSetupKey)
+	if err != nil {
+		return nil, fmt.Errorf("append netbird extension service config: %w", err)
+	}
+	return nodeConfig, nil
+}
+
+// secretsOnlyDiff stands in for a redacted diff that would be empty because
+// only secret values differ.
+const secretsOnlyDiff = "# Only secret values differ; pass --show-secrets to see them.\n"
+
+// diffMachineConfigs reports whether the live config differs from the
+// rendered one and returns a unified diff between them. Both sides are
+// normalised document by document, so key order and comments do not show
+// up as changes. Whether they differ is decided on the unredacted configs;
+// redact only hides secret values in the returned diff.
+func diffMachineConfigs(live, rendered []byte, nodeName string, redact bool) (string, bool, error) {
+	from, err := normaliseMachineConfig(live)
+	if err != nil {
+		return "", false, fmt.Errorf("normalise live config: %w", err)
+	}
+	to, err := normaliseMachineConfig(rendered)
+	if err != nil {
+		return "", false, fmt.Errorf("normalise rendered config: %w", err)
+	}
+	if from == to {
+		return "", false, nil
+	}
+
+	if redact {
+		redactedLive, err := talos.RedactSecrets(live)
+		if err != nil {
+			return "", true, fmt.Errorf("redact live config: %w", err)
+		}
+		redactedRendered, err := talos.RedactSecrets(rendered)
+		if err != nil {
+			return "", true, fmt.Errorf("redact rendered config: %w", err)
+		}
+		if from, err = normaliseMachineConfig(redactedLive); err != nil {
+			return "", true, fmt.Errorf("normalise live config: %w", err)
+		}
+		if to, err = normaliseMachineConfig(redactedRendered); err != nil {
+			return "", true, fmt.Errorf("normalise rendered config: %w", err)
+		}
+		if from == to {
+			return secretsOnlyDiff, true, nil
+		}
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live/" + nodeName,
+		ToFile:   "rendered/" + nodeName,
+		Context:  3,
+	})
+	return diff, true, err
+}
+
+func normaliseMachineConfig(data []byte) (string, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	for {
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+		if doc == nil {
+			continue
+		}
+		if err := encoder.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+)
+
+func TestRenderPoolSlotConfigAddsNetbirdAndControlPlaneRestriction(t *testing.T) {
+	t.Setenv(envTalosAllowedSubnets, "")
+	cfg := &config.Config{
+		Environment: "production",
+		NodePools: []config.NodePoolConfig{
+			{Name: "control-plane", Type: config.NodeTypeControlPlane},
+			{Name: "worker", Type: config.NodeTypeWorker},
+		},
+	}
+	assets := &talos.GenConfigResult{
+		ControlPlane: []byte("machine:\n  type: controlplane\n"),
+		Worker:       []byte("machine:\n  type: worker\n"),
+	}
+
+	controlPlane, err := renderPoolSlotConfig(cfg, assets, "production-control-plane-02", config.NodeTypeControlPlane, "setup-key")
+	if err != nil {
+		t.Fatalf("renderPoolSlotConfig returned error: %v", err)
+	}
+	for _, want := range []string{"type: controlplane", "hostname: production-control-plane-02", "kind: ExtensionServiceConfig", "kind: NetworkRuleConfig"} {
+		if !strings.Contains(string(controlPlane), want) {
+			t.Fatalf("control-plane config missing %q:\n%s", want, controlPlane)
+		}
+	}
+
+	worker, err := renderPoolSlotConfig(cfg, assets, "production-worker-01", config.NodeTypeWorker, "setup-key")
+	if err != nil {
+		t.Fatalf("renderPoolSlotConfig returned error: %v", err)
+	}
+	if !strings.Contains(string(worker), "type: worker") || strings.Contains(string(worker), "NetworkRuleConfig") {
+		t.Fatalf("worker config = \n%s\nwant worker base without the ingress restriction", worker)
+	}
+}
+
+func TestDiffMachineConfigsIgnoresOrderingAndRedactsSecrets(t *testing.T) {
+	live := []byte(`# generated by Talos
+machine:
+  token: live-token
+  type: worker
+  sysctls:
+    vm.nr_hugepages: "1024"
+`)
+	reordered := []byte(`machine:
+  sysctls:
+    vm.nr_hugepages: "1024"
+  type: worker
+  token: live-token
+`)
+
+	diff, changed, err := diffMachineConfigs(live, reordered, "production-worker-01", true)
+	if err != nil {
+		t.Fatalf("diffMachineConfigs returned error: %v", err)
+	}
+	if changed || diff != "" {
+		t.Fatalf("diff = %q, changed = %t; want none for reordered keys", diff, changed)
+	}
+
+	changedConfig := []byte(`machine:
+  token: rendered-token
+  type: worker
+  sysctls:
+    vm.nr_hugepages: "2048"
+`)
+	diff, _, err = diffMachineConfigs(live, changedConfig, "production-worker-01", true)
+	if err != nil {
+		t.Fatalf("diffMachineConfigs returned error: %v", err)
+	}
+	for _, want := range []string{"--- live/production-worker-01", "+++ rendered/production-worker-01", `-    vm.nr_hugepages: "1024"`, `+    vm.nr_hugepages: "2048"`} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "live-token") || strings.Contains(diff, "rendered-token") {
+		t.Fatalf("diff shows the redacted token change:\n%s", diff)
+	}
+}
+
+func TestDiffMachineConfigsReportsSecretOnlyDrift(t *testing.T) {
+	live := []byte("machine:\n  type: worker\n  token: live-token\n")
+	rendered := []byte("machine:\n  type: worker\n  token: rendered-token\n")
+
+	diff, changed, err := diffMachineConfigs(live, rendered, "production-worker-01", true)
+	if err != nil {
+		t.Fatalf("diffMachineConfigs returned error: %v", err)
+	}
+	if !changed || diff != secretsOnlyDiff {
+		t.Fatalf("diff = %q, changed = %t; want the secrets-only note", diff, changed)
+	}
+
+	diff, changed, err = diffMachineConfigs(live, rendered, "production-worker-01", false)
+	if err != nil {
+		t.Fatalf("diffMachineConfigs returned error: %v", err)
+	}
+	if !changed || !strings.Contains(diff, "+  token: rendered-token") {
+		t.Fatalf("diff = %q, changed = %t; want the token change shown", diff, changed)
+	}
+}
+
+// fakeSecretsReader serves secrets by path; it has no way to write.
+type fakeSecretsReader map[string]map[string]string
+
+func (f fakeSecretsReader) GetSecrets(ctx context.Context, projectID, environment, secretPath string) (map[string]string, error) {
+	return f[secretPath], nil
+}
+
+func TestRenderTalosAssetsNeedsExistingSecretsBundle(t *testing.T) {
+	cfg := &config.Config{Environment: "production", Infisical: config.InfisicalConfig{SecretPath: "/production"}}
+	secrets := fakeSecretsReader{}
+
+	if _, err := renderTalosAssets(context.Background(), cfg, "10.0.0.10", secrets); err == nil || !strings.Contains(err.Error(), "no Talos secrets bundle") {
+		t.Fatalf("renderTalosAssets error = %v, want missing bundle", err)
+	}
+
+	bundle, err := talos.GenerateSecretsYAML(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets[infisicalSecretPathForCluster(cfg)] = map[string]string{infisicalTalosSecretsKey: string(bundle)}
+
+	assets, err := renderTalosAssets(context.Background(), cfg, "10.0.0.10", secrets)
+	if err != nil {
+		t.Fatalf("renderTalosAssets returned error: %v", err)
+	}
+	if len(assets.ControlPlane) == 0 || len(assets.Worker) == 0 || len(assets.Talosconfig) == 0 {
+		t.Fatal("renderTalosAssets returned empty configs")
+	}
+}
@@ -2,10 +2,18 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"strings"
 
+	"filippo.io/age"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
 	"github.com/spf13/cobra"
 )
@@ -24,7 +32,16 @@ var etcdSnapshotCmd = &cobra.Command{
 var etcdRestoreCmd = &cobra.Command{
 	Use:   "restore",
 	Short: "Restore etcd from a snapshot",
-	RunE:  runEtcdRestore,
+	Long: `Restore etcd from a snapshot file. The snapshot is checked as by etcd
+verify first, and the restore is refused if it is empty, truncated, corrupt
+or does not match its manifest. An encrypted snapshot is decrypted again as
+it is uploaded; the only plaintext copy on disk is the private temporary
+file etcd verify reads, which is deleted once it has been checked.
+
+This only uploads the snapshot to the first control plane and recovers its
+etcd member; it does not reset the other members. Use cluster restore to
+recover a cluster.`,
+	RunE: runEtcdRestore,
 }
 
 func runEtcdSnapshot(cmd *cobra.Command, args []string) error {
@@ -32,10 +49,11 @@ func runEtcdSnapshot(cmd *cobra.Command, args []string) error {
 
 	clusterName, _ := cmd.Flags().GetString("cluster")
 	cfgFile, _ := cmd.Flags().GetString("file")
-	output, _ := cmd.Flags().GetString("output")
+	output, _ := cmd.Flags().GetString("output-file")
+	extraRecipients, _ := cmd.Flags().GetStringArray("recipient")
 
 	if strings.TrimSpace(output) == "" {
-		return fmt.Errorf("--output is required")
+		return fmt.Errorf("--output-file is required")
 	}
 
 	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
@@ -43,36 +61,99 @@ func runEtcdSnapshot(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	state, err := loadNodeState(ctx, cfg)
+	recipients, err := snapshotRecipients(ctx, cfg, extraRecipients)
 	if err != nil {
 		return err
 	}
-	controlPlane, err := firstActiveNodeByRole(state, config.NodeTypeControlPlane)
+
+	controlPlane, err := writeEtcdSnapshotFile(ctx, cfg, output, recipients)
 	if err != nil {
 		return err
 	}
 
+	result := etcdSnapshotResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Node:       controlPlane.Name,
+		Path:       output,
+		Encrypted:  len(recipients) > 0,
+	}
+	return emitResult(cmd, result, func() {
+		state := "unencrypted"
+		if result.Encrypted {
+			state = fmt.Sprintf("encrypted to %d age recipient(s)", len(recipients))
+		}
+		fmt.Printf("Saved %s etcd snapshot for cluster %q to %s (config=%s, node=%s)\n", state, cfg.Environment, output, cfgPath, controlPlane.Name)
+	})
+}
+
+// writeEtcdSnapshotFile saves a snapshot to outputPath, encrypted while it
+// streams when there are recipients, so the plaintext never reaches disk.
+// A partial file is removed on failure.
+func writeEtcdSnapshotFile(ctx context.Context, cfg *config.Config, outputPath string, recipients []age.Recipient) (*clusterstate.NodeState, error) {
+	file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot output file: %w", err)
+	}
+
+	node, err := func() (*clusterstate.NodeState, error) {
+		if len(recipients) == 0 {
+			return takeEtcdSnapshot(ctx, cfg, file)
+		}
+		encrypted, err := etcdbackup.Encrypt(file, recipients)
+		if err != nil {
+			return nil, err
+		}
+		node, err := takeEtcdSnapshot(ctx, cfg, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		if err := encrypted.Close(); err != nil {
+			return nil, fmt.Errorf("finish snapshot encryption: %w", err)
+		}
+		return node, nil
+	}()
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("write snapshot output file: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(outputPath)
+		return nil, err
+	}
+	return node, nil
+}
+
+// takeEtcdSnapshot streams a snapshot from the first active control plane
+// to w and returns the node it was taken from.
+func takeEtcdSnapshot(ctx context.Context, cfg *config.Config, w io.Writer) (*clusterstate.NodeState, error) {
+	state, err := loadNodeState(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	controlPlane, err := firstActiveNodeByRole(state, config.NodeTypeControlPlane)
+	if err != nil {
+		return nil, err
+	}
+
 	infClient, err := newInfisicalClient(ctx, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	talosconfig, err := loadTalosconfigFromInfisical(ctx, cfg, infClient)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	client, err := talos.NewClient(controlPlane.PublicIP, talosconfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer client.Close()
 
-	if err := client.EtcdSnapshot(ctx, output); err != nil {
-		return err
+	if err := client.EtcdSnapshotTo(ctx, w); err != nil {
+		return nil, err
 	}
-
-	fmt.Printf("Saved etcd snapshot for cluster %q to %s (config=%s, node=%s)\n", cfg.Environment, output, cfgPath, controlPlane.Name)
-	return nil
+	return controlPlane, nil
 }
 
 func runEtcdRestore(cmd *cobra.Command, args []string) error {
@@ -81,6 +162,8 @@ func runEtcdRestore(cmd *cobra.Command, args []string) error {
 	clusterName, _ := cmd.Flags().GetString("cluster")
 	cfgFile, _ := cmd.Flags().GetString("file")
 	input, _ := cmd.Flags().GetString("input")
+	identityFiles, _ := cmd.Flags().GetStringArray("identity")
+	manifestPath, _ := cmd.Flags().GetString("manifest")
 
 	if strings.TrimSpace(input) == "" {
 		return fmt.Errorf("--input is required")
@@ -91,6 +174,20 @@ func runEtcdRestore(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Verify before touching the cluster: a truncated or mismatched
+	// snapshot must never reach etcd.
+	verified, err := verifyEtcdSnapshot(ctx, cfg, input, manifestPath, identityFiles)
+	if err != nil {
+		return fmt.Errorf("refusing to restore: %w", err)
+	}
+	slog.Info("verified etcd snapshot", "path", input, "revision", verified.Revision, "members", verified.Members, "manifest", verified.Manifest)
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
 	state, err := loadNodeState(ctx, cfg)
 	if err != nil {
 		return err
@@ -115,23 +212,81 @@ func runEtcdRestore(cmd *cobra.Command, args []string) error {
 	}
 	defer client.Close()
 
-	if err := client.EtcdRestore(ctx, input); err != nil {
+	if err := streamVerifiedEtcdSnapshot(ctx, cfg, input, identityFiles, verified, func(snapshot io.Reader) error {
+		return client.EtcdRestoreFrom(ctx, snapshot)
+	}); err != nil {
 		return err
 	}
 
-	fmt.Printf("Restored etcd for cluster %q from %s (config=%s, node=%s)\n", cfg.Environment, input, cfgPath, controlPlane.Name)
-	return nil
+	result := etcdSnapshotResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Node:       controlPlane.Name,
+		Path:       input,
+		Encrypted:  verified.Encrypted,
+	}
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Restored etcd for cluster %q from %s (config=%s, node=%s)\n", cfg.Environment, input, cfgPath, controlPlane.Name)
+	})
+}
+
+// openEtcdSnapshot returns the plaintext of a snapshot, decrypting it
+// while it is read when it is age-encrypted.
+func openEtcdSnapshot(ctx context.Context, cfg *config.Config, r io.Reader, identityFiles []string) (io.Reader, bool, error) {
+	snapshot, encrypted, err := etcdbackup.DetectEncryption(r)
+	if err != nil || !encrypted {
+		return snapshot, false, err
+	}
+
+	identities, err := snapshotIdentities(ctx, cfg, identityFiles)
+	if err != nil {
+		return nil, true, err
+	}
+	plaintext, err := etcdbackup.Decrypt(snapshot, identities)
+	if errors.Is(err, etcdbackup.ErrNoIdentity) {
+		return nil, true, fmt.Errorf("%w: pass --identity or set backup.encryption.infisicalKey", err)
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return plaintext, true, nil
+}
+
+// etcdSnapshotResult is the structured output of `etcd snapshot` and `etcd restore`.
+type etcdSnapshotResult struct {
+	Cluster    string `json:"cluster"`
+	ConfigPath string `json:"configPath"`
+	Node       string `json:"node"`
+	Path       string `json:"path"`
+	Encrypted  bool   `json:"encrypted,omitempty"`
 }
 
 func init() {
 	etcdCmd.AddCommand(etcdSnapshotCmd)
 	etcdCmd.AddCommand(etcdRestoreCmd)
+	etcdCmd.AddCommand(etcdBackupCmd)
+	etcdCmd.AddCommand(etcdVerifyCmd)
 
 	etcdSnapshotCmd.Flags().String("cluster", "", "Cluster/environment name")
 	etcdSnapshotCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
-	etcdSnapshotCmd.Flags().String("output", "etcd-snapshot.db", "Output file path")
+	etcdSnapshotCmd.Flags().String("output-file", "etcd-snapshot.db", "Snapshot file path")
+	etcdSnapshotCmd.Flags().StringArray("recipient", nil, "Encrypt the snapshot to this age public key, in addition to backup.encryption (repeatable)")
 
 	etcdRestoreCmd.Flags().String("cluster", "", "Cluster/environment name")
 	etcdRestoreCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 	etcdRestoreCmd.Flags().String("input", "", "Snapshot file path")
+	etcdRestoreCmd.Flags().StringArray("identity", nil, "age identity file to decrypt an encrypted snapshot, in addition to backup.encryption.infisicalKey (repeatable)")
+	etcdRestoreCmd.Flags().String("manifest", "", "Manifest to verify the snapshot against (defaults to the .json next to the snapshot, if any)")
+
+	etcdBackupCmd.Flags().String("cluster", "", "Cluster/environment name")
+	etcdBackupCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	etcdBackupCmd.Flags().String("bucket", "", "Bucket to upload to (defaults to backup.bucket)")
+	etcdBackupCmd.Flags().String("endpoint", "", "S3-compatible endpoint URL (defaults to backup.endpoint, then Scaleway Object Storage)")
+	etcdBackupCmd.Flags().Bool("prune", true, "Delete snapshots the retention policy no longer keeps")
+	etcdBackupCmd.Flags().StringArray("recipient", nil, "Encrypt the snapshot to this age public key, in addition to backup.encryption (repeatable)")
+
+	etcdVerifyCmd.Flags().String("cluster", "", "Cluster/environment name, to decrypt with its Infisical identity")
+	etcdVerifyCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML, to decrypt with its Infisical identity")
+	etcdVerifyCmd.Flags().String("manifest", "", "Manifest to verify the snapshot against (defaults to the .json next to the snapshot, if any)")
+	etcdVerifyCmd.Flags().StringArray("identity", nil, "age identity file to decrypt an encrypted snapshot (repeatable)")
 }
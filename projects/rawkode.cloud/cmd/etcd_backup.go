@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/objectstore"
+	"github.com/spf13/cobra"
+)
+
+var etcdBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Upload an etcd snapshot to object storage and prune old ones",
+	Long: `Take an etcd snapshot and upload it to the S3-compatible bucket set in
+the config's backup section (Scaleway Object Storage by default), as
+<prefix>/<cluster>/<timestamp>.db with a manifest recording its sha256 and
+etcd revision. Snapshots the retention policy no longer keeps are deleted;
+only snapshots with a manifest count towards it. Snapshots left without a
+manifest by an upload that did not finish are deleted once an hour old.
+
+When backup.encryption lists age recipients (or --recipient is passed) the
+snapshot is encrypted as it streams from etcd and stored as
+<timestamp>.db.age; the manifest then records the checksums of both the
+stored and the plaintext snapshot. Reading the etcd revision needs random
+access, so the plaintext is also spooled to a file only the current user can
+read, in a private temporary directory, and deleted before the upload starts.
+
+Credentials default to the cluster's Scaleway keys; AWS_ACCESS_KEY_ID and
+AWS_SECRET_ACCESS_KEY override them for other stores. Run the command from
+a scheduler (cron, a CI schedule) for periodic backups.`,
+	RunE: runEtcdBackup,
+}
+
+var (
+	etcdBackupSnapshotFn = func(ctx context.Context, cfg *config.Config, w io.Writer) (string, error) {
+		node, err := takeEtcdSnapshot(ctx, cfg, w)
+		if err != nil {
+			return "", err
+		}
+		return node.Name, nil
+	}
+	etcdBackupNow = time.Now
+	// etcdBackupOrphanAge is how old a snapshot without a manifest must be
+	// before it is taken for an abandoned upload rather than one still
+	// running.
+	etcdBackupOrphanAge = time.Hour
+)
+
+// etcdBackupResult is the structured output of `etcd backup`.
+type etcdBackupResult struct {
+	Cluster    string   `json:"cluster"`
+	ConfigPath string   `json:"configPath"`
+	Node       string   `json:"node"`
+	Bucket     string   `json:"bucket"`
+	Snapshot   string   `json:"snapshot"`
+	Manifest   string   `json:"manifest"`
+	Size       int64    `json:"size"`
+	SHA256     string   `json:"sha256"`
+	Revision   int64    `json:"revision"`
+	Encrypted  bool     `json:"encrypted,omitempty"`
+	Pruned     []string `json:"pruned,omitempty"`
+	Orphans    []string `json:"orphans,omitempty"`
+}
+
+func runEtcdBackup(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	bucket, _ := cmd.Flags().GetString("bucket")
+	endpoint, _ := cmd.Flags().GetString("endpoint")
+	prune, _ := cmd.Flags().GetBool("prune")
+	extraRecipients, _ := cmd.Flags().GetStringArray("recipient")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	store, err := newBackupStore(cfg, bucket, endpoint)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := snapshotRecipients(ctx, cfg, extraRecipients)
+	if err != nil {
+		return err
+	}
+
+	result, err := backupEtcd(ctx, cfg, store, recipients, prune)
+	if err != nil {
+		return err
+	}
+	result.ConfigPath = cfgPath
+
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Uploaded etcd snapshot of cluster %q to s3://%s/%s (node=%s, revision=%d, sha256=%s)\n",
+			result.Cluster, result.Bucket, result.Snapshot, result.Node, result.Revision, result.SHA256,
+		)
+		for _, key := range result.Pruned {
+			fmt.Printf("Pruned s3://%s/%s\n", result.Bucket, key)
+		}
+		for _, key := range result.Orphans {
+			fmt.Printf("Deleted incomplete upload s3://%s/%s\n", result.Bucket, key)
+		}
+	})
+}
+
+// newBackupStore returns a client for the configured backup bucket. The
+// bucket and endpoint flags override the config.
+func newBackupStore(cfg *config.Config, bucket, endpoint string) (*objectstore.Client, error) {
+	if strings.TrimSpace(bucket) == "" {
+		bucket = cfg.Backup.Bucket
+	}
+	if strings.TrimSpace(bucket) == "" {
+		return nil, fmt.Errorf("backup.bucket is not set in the config (or pass --bucket)")
+	}
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = cfg.Backup.Endpoint
+	}
+
+	accessKey, secretKey := cfg.ScalewayCredentials()
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		accessKey, secretKey = id, secret
+	}
+
+	store, err := objectstore.NewClient(objectstore.Config{
+		Endpoint:  endpoint,
+		Region:    cfg.Backup.Region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create object store client: %w", err)
+	}
+	return store, nil
+}
+
+// backupRetention returns the configured retention, or the default when
+// none of its counts is set.
+func backupRetention(cfg *config.Config) etcdbackup.Retention {
+	configured := cfg.Backup.Retention
+	if configured.Hourly == 0 && configured.Daily == 0 && configured.Weekly == 0 {
+		return etcdbackup.DefaultRetention
+	}
+	return etcdbackup.Retention{Hourly: configured.Hourly, Daily: configured.Daily, Weekly: configured.Weekly}
+}
+
+// backupEtcd streams a snapshot to a temporary file, encrypted when there
+// are recipients, uploads it with its manifest and, when prune is set,
+// applies the retention policy. The manifest is written last, so a snapshot
+// without one is an upload that did not finish.
+func backupEtcd(ctx context.Context, cfg *config.Config, store *objectstore.Client, recipients []age.Recipient, prune bool) (*etcdBackupResult, error) {
+	dir, err := os.MkdirTemp("", "etcd-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot spool directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	createdAt := etcdBackupNow().UTC()
+	encrypted := len(recipients) > 0
+	spooled, err := spoolEtcdBackup(ctx, cfg, dir, recipients)
+	if err != nil {
+		return nil, err
+	}
+	node, info, stored := spooled.node, spooled.plaintext, spooled.stored
+
+	snapshotKey := etcdbackup.SnapshotKey(cfg.Backup.Prefix, cfg.Environment, createdAt, encrypted)
+	slog.Info("uploading etcd snapshot", "bucket", store.Bucket(), "key", snapshotKey, "size", stored.Size, "revision", info.Revision, "encrypted", encrypted)
+
+	file, err := os.Open(spooled.path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	err = store.Put(ctx, snapshotKey, file, stored.Size, stored.SHA256)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("upload snapshot: %w", err)
+	}
+
+	record := &etcdbackup.Manifest{
+		Cluster:   cfg.Environment,
+		Snapshot:  snapshotKey,
+		Node:      node,
+		CreatedAt: createdAt,
+		Size:      stored.Size,
+		SHA256:    stored.SHA256,
+		Revision:  info.Revision,
+	}
+	if encrypted {
+		record.Encryption = etcdbackup.EncryptionAge
+		record.PlaintextSize = info.Size
+		record.PlaintextSHA256 = info.SHA256
+	}
+	manifest, err := etcdbackup.MarshalManifest(record)
+	if err != nil {
+		return nil, err
+	}
+	manifestKey := etcdbackup.ManifestKey(snapshotKey)
+	if err := store.PutBytes(ctx, manifestKey, manifest); err != nil {
+		return nil, fmt.Errorf("upload manifest: %w", err)
+	}
+
+	result := &etcdBackupResult{
+		Cluster:   cfg.Environment,
+		Node:      node,
+		Bucket:    store.Bucket(),
+		Snapshot:  snapshotKey,
+		Manifest:  manifestKey,
+		Size:      stored.Size,
+		SHA256:    stored.SHA256,
+		Revision:  info.Revision,
+		Encrypted: encrypted,
+	}
+	if !prune {
+		return result, nil
+	}
+
+	prefix := etcdbackup.ClusterPrefix(cfg.Backup.Prefix, cfg.Environment)
+	result.Pruned, err = pruneEtcdBackups(ctx, store, prefix, backupRetention(cfg))
+	if err != nil {
+		return result, fmt.Errorf("snapshot uploaded, but pruning old snapshots failed: %w", err)
+	}
+	result.Orphans, err = deleteOrphanEtcdSnapshots(ctx, store, prefix, createdAt.Add(-etcdBackupOrphanAge))
+	if err != nil {
+		return result, fmt.Errorf("snapshot uploaded, but deleting incomplete uploads failed: %w", err)
+	}
+	return result, nil
+}
+
+// etcdBackupSpool is a snapshot spooled for upload.
+type etcdBackupSpool struct {
+	node      string
+	path      string
+	plaintext *etcdbackup.SnapshotInfo
+	stored    *etcdbackup.SnapshotInfo
+}
+
+// spoolEtcdBackup streams a snapshot into dir, which the caller removes.
+// With recipients the snapshot is encrypted as it streams; the plaintext
+// spool is still needed to read the revision, so it is created readable
+// only by the current user and deleted as soon as it has been read.
+func spoolEtcdBackup(ctx context.Context, cfg *config.Config, dir string, recipients []age.Recipient) (*etcdBackupSpool, error) {
+	spool := filepath.Join(dir, "snapshot.db")
+	plaintext, err := os.OpenFile(spool, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot spool: %w", err)
+	}
+	defer plaintext.Close()
+
+	// The digest checks etcd's integrity hash as the snapshot streams past.
+	digest := etcdbackup.NewDigest()
+	writers := []io.Writer{plaintext, digest}
+
+	upload := spool
+	var ciphertext *os.File
+	var encryptor io.WriteCloser
+	if len(recipients) > 0 {
+		upload = spool + ".age"
+		ciphertext, err = os.OpenFile(upload, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("create encrypted snapshot: %w", err)
+		}
+		defer ciphertext.Close()
+		encryptor, err = etcdbackup.Encrypt(ciphertext, recipients)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, encryptor)
+	}
+
+	node, err := etcdBackupSnapshotFn(ctx, cfg, io.MultiWriter(writers...))
+	if err != nil {
+		return nil, err
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, fmt.Errorf("write snapshot spool: %w", err)
+	}
+	if encryptor != nil {
+		if err := encryptor.Close(); err != nil {
+			return nil, fmt.Errorf("finish snapshot encryption: %w", err)
+		}
+		if err := ciphertext.Close(); err != nil {
+			return nil, fmt.Errorf("write encrypted snapshot: %w", err)
+		}
+	}
+
+	info, err := digest.Info()
+	if err != nil {
+		return nil, err
+	}
+	if err := etcdbackup.InspectDatabase(spool, info); err != nil {
+		return nil, err
+	}
+	if encryptor == nil {
+		return &etcdBackupSpool{node: node, path: upload, plaintext: info, stored: info}, nil
+	}
+
+	if err := os.Remove(spool); err != nil {
+		return nil, fmt.Errorf("remove snapshot spool: %w", err)
+	}
+	stored, err := etcdbackup.InspectFile(upload)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdBackupSpool{node: node, path: upload, plaintext: info, stored: stored}, nil
+}
+
+// pruneEtcdBackups deletes the snapshots and manifests under prefix that
+// the retention policy no longer keeps and returns the deleted snapshots.
+// Only complete backups count towards retention.
+func pruneEtcdBackups(ctx context.Context, store *objectstore.Client, prefix string, retention etcdbackup.Retention) ([]string, error) {
+	keys, err := listBackupKeys(ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		pruned []string
+		errs   []error
+	)
+	for _, backup := range retention.Expired(etcdbackup.Backups(keys)) {
+		// The manifest goes first so a half-pruned backup is not mistaken
+		// for a complete one.
+		if err := store.Delete(ctx, backup.Manifest); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := store.Delete(ctx, backup.Snapshot); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("pruned etcd snapshot", "key", backup.Snapshot, "created_at", backup.CreatedAt)
+		pruned = append(pruned, backup.Snapshot)
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// deleteOrphanEtcdSnapshots deletes the snapshots under prefix that have no
+// manifest and were taken before cutoff, and returns them. Newer ones may
+// still be uploading.
+func deleteOrphanEtcdSnapshots(ctx context.Context, store *objectstore.Client, prefix string, cutoff time.Time) ([]string, error) {
+	keys, err := listBackupKeys(ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		deleted []string
+		errs    []error
+	)
+	for _, orphan := range etcdbackup.Orphans(keys) {
+		if !orphan.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, orphan.Snapshot); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("deleted etcd snapshot without manifest", "key", orphan.Snapshot, "created_at", orphan.CreatedAt)
+		deleted = append(deleted, orphan.Snapshot)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func listBackupKeys(ctx context.Context, store *objectstore.Client, prefix string) ([]string, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
+}
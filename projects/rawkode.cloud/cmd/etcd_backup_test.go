@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/objectstore/objectstoretest"
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeEtcdTestSnapshot writes a bbolt database shaped like an etcd
+// snapshot whose newest revision is revision.
+func writeEtcdTestSnapshot(t *testing.T, path string, revision uint64) {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("open bolt: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("key"))
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 17)
+		binary.BigEndian.PutUint64(key, revision)
+		key[8] = '_'
+		return bucket.Put(key, []byte("value"))
+	}); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+}
+
+func TestBackupEtcdUploadsSnapshotAndPrunes(t *testing.T) {
+	originalSnapshot, originalNow := etcdBackupSnapshotFn, etcdBackupNow
+	t.Cleanup(func() {
+		etcdBackupSnapshotFn = originalSnapshot
+		etcdBackupNow = originalNow
+	})
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	etcdBackupNow = func() time.Time { return now }
+	etcdBackupSnapshotFn = func(ctx context.Context, cfg *config.Config, w io.Writer) (string, error) {
+		path := filepath.Join(t.TempDir(), "snapshot.db")
+		writeEtcdTestSnapshot(t, path, 1234)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = w.Write(data)
+		return "production-control-plane-01", err
+	}
+
+	server := objectstoretest.NewServer(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", objectstoretest.AccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", objectstoretest.SecretKey)
+
+	// Two earlier backups in the same hour; only the newest of the hour
+	// survives an hourly-only policy.
+	for _, age := range []time.Duration{20 * time.Minute, 40 * time.Minute} {
+		key := etcdbackup.SnapshotKey("", "production", now.Add(-age), false)
+		server.SetObject(key, []byte("old"))
+		server.SetObject(etcdbackup.ManifestKey(key), []byte("{}"))
+	}
+	server.SetObject("etcd/staging/20261016T110000Z.db", []byte("other cluster"))
+
+	cfg := &config.Config{
+		Environment: "production",
+		Backup: config.BackupConfig{
+			Bucket:    objectstoretest.Bucket,
+			Endpoint:  server.URL,
+			Retention: config.BackupRetention{Hourly: 1},
+		},
+	}
+	store, err := newBackupStore(cfg, "", "")
+	if err != nil {
+		t.Fatalf("newBackupStore returned error: %v", err)
+	}
+
+	result, err := backupEtcd(context.Background(), cfg, store, nil, true)
+	if err != nil {
+		t.Fatalf("backupEtcd returned error: %v", err)
+	}
+
+	if result.Snapshot != "etcd/production/20261016T120000Z.db" || result.Revision != 1234 {
+		t.Fatalf("result = %+v", result)
+	}
+	uploaded, err := store.GetBytes(context.Background(), result.Snapshot)
+	if err != nil {
+		t.Fatalf("download snapshot: %v", err)
+	}
+	sum := sha256.Sum256(uploaded)
+	if hex.EncodeToString(sum[:]) != result.SHA256 {
+		t.Fatal("uploaded snapshot does not match the recorded sha256")
+	}
+
+	data, err := store.GetBytes(context.Background(), result.Manifest)
+	if err != nil {
+		t.Fatalf("download manifest: %v", err)
+	}
+	manifest, err := etcdbackup.UnmarshalManifest(data)
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.SHA256 != result.SHA256 || manifest.Revision != 1234 || manifest.Node != "production-control-plane-01" {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+
+	want := []string{
+		"etcd/production/20261016T120000Z.db",
+		"etcd/production/20261016T120000Z.json",
+		"etcd/staging/20261016T110000Z.db",
+	}
+	if got := server.Keys(); !slices.Equal(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+	if len(result.Pruned) != 2 {
+		t.Fatalf("pruned = %v, want the two older snapshots", result.Pruned)
+	}
+}
+
+func TestBackupEtcdRetentionIgnoresSnapshotsWithoutManifest(t *testing.T) {
+	originalSnapshot, originalNow := etcdBackupSnapshotFn, etcdBackupNow
+	t.Cleanup(func() {
+		etcdBackupSnapshotFn = originalSnapshot
+		etcdBackupNow = originalNow
+	})
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	etcdBackupNow = func() time.Time { return now }
+	etcdBackupSnapshotFn = func(ctx context.Context, cfg *config.Config, w io.Writer) (string, error) {
+		path := filepath.Join(t.TempDir(), "snapshot.db")
+		writeEtcdTestSnapshot(t, path, 1234)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = w.Write(data)
+		return "production-control-plane-01", err
+	}
+
+	server := objectstoretest.NewServer(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", objectstoretest.AccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", objectstoretest.SecretKey)
+
+	// A complete backup and a newer abandoned upload in hour 10, and an
+	// upload from hour 11 that may still be running.
+	complete := etcdbackup.SnapshotKey("", "production", now.Add(-100*time.Minute), false)
+	server.SetObject(complete, []byte("complete"))
+	server.SetObject(etcdbackup.ManifestKey(complete), []byte("{}"))
+	abandoned := etcdbackup.SnapshotKey("", "production", now.Add(-70*time.Minute), false)
+	server.SetObject(abandoned, []byte("abandoned"))
+	running := etcdbackup.SnapshotKey("", "production", now.Add(-20*time.Minute), false)
+	server.SetObject(running, []byte("running"))
+
+	cfg := &config.Config{
+		Environment: "production",
+		Backup: config.BackupConfig{
+			Bucket:    objectstoretest.Bucket,
+			Endpoint:  server.URL,
+			Retention: config.BackupRetention{Hourly: 2},
+		},
+	}
+	store, err := newBackupStore(cfg, "", "")
+	if err != nil {
+		t.Fatalf("newBackupStore returned error: %v", err)
+	}
+
+	result, err := backupEtcd(context.Background(), cfg, store, nil, true)
+	if err != nil {
+		t.Fatalf("backupEtcd returned error: %v", err)
+	}
+
+	if len(result.Pruned) != 0 || !slices.Equal(result.Orphans, []string{abandoned}) {
+		t.Fatalf("pruned = %v, orphans = %v; want only %s deleted", result.Pruned, result.Orphans, abandoned)
+	}
+	want := []string{
+		complete,
+		etcdbackup.ManifestKey(complete),
+		running,
+		result.Snapshot,
+		result.Manifest,
+	}
+	if got := server.Keys(); !slices.Equal(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestBackupEtcdEncryptsForRecipients(t *testing.T) {
+	originalSnapshot, originalNow := etcdBackupSnapshotFn, etcdBackupNow
+	t.Cleanup(func() {
+		etcdBackupSnapshotFn = originalSnapshot
+		etcdBackupNow = originalNow
+	})
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	etcdBackupNow = func() time.Time { return now }
+	plaintextPath := filepath.Join(t.TempDir(), "plaintext.db")
+	writeEtcdTestSnapshot(t, plaintextPath, 99)
+	plaintext, err := os.ReadFile(plaintextPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcdBackupSnapshotFn = func(ctx context.Context, cfg *config.Config, w io.Writer) (string, error) {
+		_, err := w.Write(plaintext)
+		return "production-control-plane-01", err
+	}
+
+	server := objectstoretest.NewServer(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", objectstoretest.AccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", objectstoretest.SecretKey)
+
+	cfg := &config.Config{
+		Environment: "production",
+		Backup:      config.BackupConfig{Bucket: objectstoretest.Bucket, Endpoint: server.URL},
+	}
+	store, err := newBackupStore(cfg, "", "")
+	if err != nil {
+		t.Fatalf("newBackupStore returned error: %v", err)
+	}
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := backupEtcd(context.Background(), cfg, store, []age.Recipient{identity.Recipient()}, false)
+	if err != nil {
+		t.Fatalf("backupEtcd returned error: %v", err)
+	}
+	if result.Snapshot != "etcd/production/20261016T120000Z.db.age" || !result.Encrypted || result.Revision != 99 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	data, err := store.GetBytes(context.Background(), result.Manifest)
+	if err != nil {
+		t.Fatalf("download manifest: %v", err)
+	}
+	manifest, err := etcdbackup.UnmarshalManifest(data)
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	plainSum := sha256.Sum256(plaintext)
+	if manifest.Encryption != etcdbackup.EncryptionAge || manifest.PlaintextSHA256 != hex.EncodeToString(plainSum[:]) || manifest.SHA256 == manifest.PlaintextSHA256 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+
+	// Restore detects the encryption and decrypts with the Infisical
+	// identity while reading.
+	originalIdentity := etcdInfisicalAgeIdentityFn
+	t.Cleanup(func() { etcdInfisicalAgeIdentityFn = originalIdentity })
+	etcdInfisicalAgeIdentityFn = func(ctx context.Context, cfg *config.Config) (*age.X25519Identity, error) {
+		return identity, nil
+	}
+
+	uploaded, err := store.GetBytes(context.Background(), result.Snapshot)
+	if err != nil {
+		t.Fatalf("download snapshot: %v", err)
+	}
+	reader, encrypted, err := openEtcdSnapshot(context.Background(), cfg, bytes.NewReader(uploaded), nil)
+	if err != nil || !encrypted {
+		t.Fatalf("openEtcdSnapshot = %v, %v", encrypted, err)
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !slices.Equal(decrypted, plaintext) {
+		t.Fatal("decrypted snapshot does not match the plaintext")
+	}
+}
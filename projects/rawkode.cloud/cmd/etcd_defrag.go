@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/spf13/cobra"
+)
+
+var etcdDefragCmd = &cobra.Command{
+	Use:   "defrag",
+	Short: "Defragment etcd one member at a time",
+	Long: `Defragment the etcd member of each control plane in turn, reclaiming the
+space freed by compaction. A member stops serving while it is defragmented,
+so every member must be healthy before the next one starts, and the leader
+goes last to avoid an extra election mid-run.`,
+	RunE: runEtcdDefrag,
+}
+
+// etcdDefragNode reports the defragmentation of one member.
+type etcdDefragNode struct {
+	Name         string `json:"name"`
+	DBSizeBefore int64  `json:"dbSizeBefore"`
+	DBSizeAfter  int64  `json:"dbSizeAfter,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// etcdDefragResult is the structured output of `etcd defrag`.
+type etcdDefragResult struct {
+	Cluster    string           `json:"cluster"`
+	ConfigPath string           `json:"configPath"`
+	Nodes      []etcdDefragNode `json:"nodes"`
+	Error      string           `json:"error,omitempty"`
+}
+
+func runEtcdDefrag(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	only, _ := cmd.Flags().GetStringArray("node")
+	nodeTimeout, _ := cmd.Flags().GetDuration("node-timeout")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+	result := &etcdDefragResult{Cluster: cfg.Environment, ConfigPath: cfgPath}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	controlPlanes, err := loadEtcdControlPlanes(ctx, cfg)
+	if err == nil {
+		err = defragEtcd(ctx, controlPlanes, only, nodeTimeout, result)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		for _, node := range result.Nodes {
+			fmt.Printf("Defragmented %s: %s -> %s\n", node.Name, formatBytes(node.DBSizeBefore), formatBytes(node.DBSizeAfter))
+		}
+	})
+}
+
+// defragEtcd defragments the selected members, followers first and the
+// leader last, stopping at the first member that fails or does not come
+// back healthy.
+func defragEtcd(ctx context.Context, controlPlanes *etcdControlPlanes, only []string, nodeTimeout time.Duration, result *etcdDefragResult) error {
+	for _, name := range only {
+		if !slices.ContainsFunc(controlPlanes.nodes, func(node cluster.NodeState) bool { return node.Name == name }) {
+			return fmt.Errorf("%s is not an active control plane", name)
+		}
+	}
+
+	statuses, statusErrs := controlPlanes.statuses(ctx)
+	if len(statusErrs) > 0 {
+		return fmt.Errorf("refusing to defragment: %w", etcdStatusErrors(statusErrs))
+	}
+
+	var nodes, leader []cluster.NodeState
+	for _, node := range controlPlanes.nodes {
+		if len(only) > 0 && !slices.Contains(only, node.Name) {
+			continue
+		}
+		if status := statuses[node.Name]; status.MemberID == status.Leader {
+			leader = append(leader, node)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	nodes = append(nodes, leader...)
+
+	for _, node := range nodes {
+		if err := checkClusterLock(); err != nil {
+			return err
+		}
+		entry := etcdDefragNode{Name: node.Name, DBSizeBefore: statuses[node.Name].DBSize}
+		err := defragEtcdMember(ctx, controlPlanes, node, nodeTimeout, &entry)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		result.Nodes = append(result.Nodes, entry)
+		if err != nil {
+			return fmt.Errorf("defragment %s: %w", node.Name, err)
+		}
+	}
+	return nil
+}
+
+func defragEtcdMember(ctx context.Context, controlPlanes *etcdControlPlanes, node cluster.NodeState, nodeTimeout time.Duration, entry *etcdDefragNode) error {
+	// Taking a member out of service is only safe while every other one
+	// is serving.
+	if err := waitForEtcdMembersHealthy(ctx, controlPlanes, nodeTimeout); err != nil {
+		return err
+	}
+
+	slog.Info("defragmenting etcd member", "node", node.Name, "db_size", entry.DBSizeBefore)
+	if err := controlPlanes.withTalosClient(node, func(client talosEtcdClient) error {
+		return client.EtcdDefragment(ctx)
+	}); err != nil {
+		return err
+	}
+
+	if err := waitForEtcdMembersHealthy(ctx, controlPlanes, nodeTimeout); err != nil {
+		return err
+	}
+	statuses, statusErrs := controlPlanes.statuses(ctx)
+	if err := statusErrs[node.Name]; err != nil {
+		return err
+	}
+	entry.DBSizeAfter = statuses[node.Name].DBSize
+	return nil
+}
+
+// waitForEtcdMembersHealthy waits until every control plane's member
+// answers with a status free of errors.
+func waitForEtcdMembersHealthy(ctx context.Context, controlPlanes *etcdControlPlanes, timeout time.Duration) error {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		statuses, errs := controlPlanes.statuses(waitCtx)
+		for name, status := range statuses {
+			if len(status.Errors) > 0 {
+				errs[name] = fmt.Errorf("member reports errors: %v", status.Errors)
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("etcd did not become healthy: %w", etcdStatusErrors(errs))
+		case <-time.After(etcdPollInterval):
+		}
+	}
+}
+
+func etcdStatusErrors(errs map[string]error) error {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	joined := make([]error, 0, len(names))
+	for _, name := range names {
+		joined = append(joined, fmt.Errorf("%s: %w", name, errs[name]))
+	}
+	return errors.Join(joined...)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/infisical"
+)
+
+var etcdInfisicalAgeIdentityFn = loadInfisicalAgeIdentity
+
+// snapshotRecipients returns the age recipients snapshots are encrypted to:
+// the configured and --recipient keys plus the recipient of the identity
+// kept in Infisical. No recipients means snapshots stay unencrypted.
+func snapshotRecipients(ctx context.Context, cfg *config.Config, extra []string) ([]age.Recipient, error) {
+	recipients, err := etcdbackup.ParseRecipients(append(append([]string(nil), cfg.Backup.Encryption.Recipients...), extra...))
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := etcdInfisicalAgeIdentityFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		recipients = append(recipients, identity.Recipient())
+	}
+	return recipients, nil
+}
+
+// snapshotIdentities returns the age identities that can decrypt a
+// snapshot: those in the --identity files and, when cfg is set, the one
+// kept in Infisical.
+func snapshotIdentities(ctx context.Context, cfg *config.Config, identityFiles []string) ([]age.Identity, error) {
+	var identities []age.Identity
+	for _, path := range identityFiles {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open age identity file: %w", err)
+		}
+		parsed, err := etcdbackup.ParseIdentities(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		identities = append(identities, parsed...)
+	}
+	if cfg == nil {
+		return identities, nil
+	}
+
+	identity, err := etcdInfisicalAgeIdentityFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+// loadInfisicalAgeIdentity reads the age identity named by
+// backup.encryption.infisicalKey, or returns nil when none is configured.
+func loadInfisicalAgeIdentity(ctx context.Context, cfg *config.Config) (*age.X25519Identity, error) {
+	key := strings.TrimSpace(cfg.Backup.Encryption.InfisicalKey)
+	if key == "" {
+		return nil, nil
+	}
+
+	client, err := newInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	secretPath := infisicalSecretPathForCluster(cfg)
+	value, err := client.GetSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, secretPath, key)
+	if err != nil {
+		if infisical.IsNotFound(err) {
+			return nil, fmt.Errorf("backup encryption key %s was not found in infisical path %s", key, secretPath)
+		}
+		return nil, fmt.Errorf("load %s from infisical path %s: %w", key, secretPath, err)
+	}
+
+	identity, err := etcdbackup.ParseIdentity(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s in infisical path %s: %w", key, secretPath, err)
+	}
+	return identity, nil
+}
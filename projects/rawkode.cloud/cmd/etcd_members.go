@@ -0,0 +1,518 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+)
+
+var etcdMembersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "List etcd members with their status",
+	Long: `List the etcd members: ID, name, peer URLs, learner status, database size
+and which member leads. Sizes and leadership come from each control plane's
+own etcd status; a control plane that does not answer is reported with its
+error.`,
+	RunE: runEtcdMembers,
+}
+
+var etcdRemoveMemberCmd = &cobra.Command{
+	Use:   "remove-member <name|id>",
+	Short: "Remove a member from etcd",
+	Long: `Remove an etcd member by node name or hex member ID, as listed by etcd
+members. To take a node out of the cluster use node remove instead, which
+drains and resets it first; removing the member of a control plane that is
+still in the inventory needs --force.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEtcdRemoveMember,
+}
+
+var etcdAlarmsCmd = &cobra.Command{
+	Use:   "alarms",
+	Short: "List and disarm etcd alarms",
+}
+
+var etcdAlarmsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the alarms raised in etcd",
+	RunE:  runEtcdAlarmsList,
+}
+
+var etcdAlarmsDisarmCmd = &cobra.Command{
+	Use:   "disarm",
+	Short: "Disarm every etcd alarm",
+	Long: `Disarm every alarm raised in etcd. A NOSPACE alarm is raised again unless
+the database was brought back under its quota first, for example with etcd
+defrag.`,
+	RunE: runEtcdAlarmsDisarm,
+}
+
+// talosEtcdClient is the part of the Talos client the etcd member
+// commands use.
+type talosEtcdClient interface {
+	EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error)
+	EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error)
+	EtcdRemoveMember(ctx context.Context, memberID string) error
+	EtcdDefragment(ctx context.Context) error
+	EtcdAlarms(ctx context.Context) ([]talos.EtcdAlarm, error)
+	EtcdAlarmDisarm(ctx context.Context) ([]talos.EtcdAlarm, error)
+	Close() error
+}
+
+var (
+	etcdLoadNodeStateFn = loadNodeState
+	etcdTalosconfigFn   = func(ctx context.Context, cfg *config.Config) ([]byte, error) {
+		infClient, err := newInfisicalClient(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return loadTalosconfigFromInfisical(ctx, cfg, infClient)
+	}
+	etcdTalosClientFn = func(endpoint string, talosconfig []byte) (talosEtcdClient, error) {
+		return talos.NewClient(endpoint, talosconfig)
+	}
+	etcdPollInterval = 10 * time.Second
+)
+
+func init() {
+	etcdCmd.AddCommand(etcdMembersCmd)
+	etcdCmd.AddCommand(etcdRemoveMemberCmd)
+	etcdCmd.AddCommand(etcdDefragCmd)
+	etcdCmd.AddCommand(etcdAlarmsCmd)
+	etcdAlarmsCmd.AddCommand(etcdAlarmsListCmd)
+	etcdAlarmsCmd.AddCommand(etcdAlarmsDisarmCmd)
+
+	for _, c := range []*cobra.Command{etcdMembersCmd, etcdRemoveMemberCmd, etcdDefragCmd, etcdAlarmsListCmd, etcdAlarmsDisarmCmd} {
+		c.Flags().String("cluster", "", "Cluster/environment name")
+		c.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	}
+	etcdRemoveMemberCmd.Flags().Bool("force", false, "Remove the member even if its control plane is still in the inventory")
+	etcdDefragCmd.Flags().StringArray("node", nil, "Only defragment this control plane (repeatable; defaults to all)")
+	etcdDefragCmd.Flags().Duration("node-timeout", 5*time.Minute, "Maximum time to wait for etcd to be healthy before and after each member")
+}
+
+// etcdControlPlanes reaches the etcd members of a cluster through the
+// Talos API of its active control planes.
+type etcdControlPlanes struct {
+	nodes       []cluster.NodeState
+	talosconfig []byte
+}
+
+func loadEtcdControlPlanes(ctx context.Context, cfg *config.Config) (*etcdControlPlanes, error) {
+	state, err := etcdLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []cluster.NodeState
+	for _, node := range activeNodesByRole(state, config.NodeTypeControlPlane) {
+		nodes = append(nodes, *node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no active control-plane nodes found for cluster %q", cfg.Environment)
+	}
+
+	talosconfig, err := etcdTalosconfigFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdControlPlanes{nodes: nodes, talosconfig: talosconfig}, nil
+}
+
+func (c *etcdControlPlanes) withTalosClient(node cluster.NodeState, fn func(client talosEtcdClient) error) error {
+	client, err := etcdTalosClientFn(node.PublicIP, c.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
+
+// withAnyControlPlane runs fn against the first control plane that
+// answers, skipping the named node.
+func (c *etcdControlPlanes) withAnyControlPlane(skip string, fn func(client talosEtcdClient) error) error {
+	var errs []error
+	for _, node := range c.nodes {
+		if node.Name == skip {
+			continue
+		}
+		err := c.withTalosClient(node, fn)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", node.Name, err))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no other control plane available")
+	}
+	return errors.Join(errs...)
+}
+
+func (c *etcdControlPlanes) members(ctx context.Context) ([]talos.EtcdMember, error) {
+	var members []talos.EtcdMember
+	err := c.withAnyControlPlane("", func(client talosEtcdClient) error {
+		var err error
+		members, err = client.EtcdMembers(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list etcd members: %w", err)
+	}
+	return members, nil
+}
+
+// statuses returns the etcd status of each control plane's member by node
+// name, and the error of each control plane that did not answer.
+func (c *etcdControlPlanes) statuses(ctx context.Context) (map[string]*talos.EtcdMemberStatus, map[string]error) {
+	statuses := map[string]*talos.EtcdMemberStatus{}
+	errs := map[string]error{}
+	for _, node := range c.nodes {
+		err := c.withTalosClient(node, func(client talosEtcdClient) error {
+			status, err := client.EtcdStatus(ctx)
+			if err != nil {
+				return err
+			}
+			statuses[node.Name] = status
+			return nil
+		})
+		if err != nil {
+			errs[node.Name] = err
+		}
+	}
+	return statuses, errs
+}
+
+// formatEtcdMemberID formats a member ID the way etcdctl and talosctl do.
+func formatEtcdMemberID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// etcdMemberEntry is one member in the output of `etcd members`.
+type etcdMemberEntry struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	PeerURLs    []string `json:"peerUrls,omitempty"`
+	Learner     bool     `json:"learner"`
+	Leader      bool     `json:"leader"`
+	DBSize      int64    `json:"dbSize,omitempty"`
+	DBSizeInUse int64    `json:"dbSizeInUse,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// etcdMembersResult is the structured output of `etcd members`.
+type etcdMembersResult struct {
+	Cluster    string            `json:"cluster"`
+	ConfigPath string            `json:"configPath"`
+	Leader     string            `json:"leader,omitempty"`
+	Members    []etcdMemberEntry `json:"members"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func runEtcdMembers(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+	result := &etcdMembersResult{Cluster: cfg.Environment, ConfigPath: cfgPath}
+
+	controlPlanes, err := loadEtcdControlPlanes(ctx, cfg)
+	if err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+	if err := collectEtcdMembers(ctx, controlPlanes, result); err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPEER URLS\tLEARNER\tLEADER\tDB SIZE\tIN USE\tERRORS")
+		for _, member := range result.Members {
+			dbSize, inUse := "-", "-"
+			if member.DBSize > 0 {
+				dbSize, inUse = formatBytes(member.DBSize), formatBytes(member.DBSizeInUse)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\t%s\t%s\n",
+				member.ID,
+				member.Name,
+				defaultString(strings.Join(member.PeerURLs, ","), "-"),
+				member.Learner,
+				member.Leader,
+				dbSize,
+				inUse,
+				defaultString(strings.Join(member.Errors, "; "), "-"),
+			)
+		}
+		_ = w.Flush()
+	})
+}
+
+// collectEtcdMembers fills result with the member list and each member's
+// own status.
+func collectEtcdMembers(ctx context.Context, controlPlanes *etcdControlPlanes, result *etcdMembersResult) error {
+	members, err := controlPlanes.members(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, statusErrs := controlPlanes.statuses(ctx)
+
+	byID := map[uint64]*talos.EtcdMemberStatus{}
+	var leader uint64
+	for _, status := range statuses {
+		byID[status.MemberID] = status
+		if status.Leader != 0 {
+			leader = status.Leader
+		}
+	}
+
+	for _, member := range members {
+		entry := etcdMemberEntry{
+			ID:       formatEtcdMemberID(member.ID),
+			Name:     member.Hostname,
+			PeerURLs: member.PeerURLs,
+			Learner:  member.IsLearner,
+			Leader:   member.ID == leader,
+		}
+		if status, ok := byID[member.ID]; ok {
+			entry.DBSize, entry.DBSizeInUse = status.DBSize, status.DBSizeInUse
+			entry.Errors = status.Errors
+		} else if err, ok := statusErrs[member.Hostname]; ok {
+			entry.Errors = []string{err.Error()}
+		}
+		if entry.Leader {
+			result.Leader = entry.Name
+		}
+		result.Members = append(result.Members, entry)
+	}
+	return nil
+}
+
+// formatBytes formats a size in bytes with a binary unit.
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// etcdRemoveMemberResult is the structured output of `etcd remove-member`.
+type etcdRemoveMemberResult struct {
+	Cluster    string `json:"cluster"`
+	ConfigPath string `json:"configPath"`
+	ID         string `json:"id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func runEtcdRemoveMember(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	force, _ := cmd.Flags().GetBool("force")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+	result := &etcdRemoveMemberResult{Cluster: cfg.Environment, ConfigPath: cfgPath}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	controlPlanes, err := loadEtcdControlPlanes(ctx, cfg)
+	if err == nil {
+		err = removeEtcdMember(ctx, controlPlanes, args[0], force, result)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Removed etcd member %s (%s) from cluster %q\n", result.ID, defaultString(result.Name, "unnamed"), result.Cluster)
+	})
+}
+
+// removeEtcdMember removes the member named or identified by ref, asking a
+// control plane other than the member's own.
+func removeEtcdMember(ctx context.Context, controlPlanes *etcdControlPlanes, ref string, force bool, result *etcdRemoveMemberResult) error {
+	members, err := controlPlanes.members(ctx)
+	if err != nil {
+		return err
+	}
+	member, err := findEtcdMember(members, ref)
+	if err != nil {
+		return err
+	}
+	result.ID, result.Name = formatEtcdMemberID(member.ID), member.Hostname
+
+	if len(members) == 1 {
+		return fmt.Errorf("refusing to remove %s: it is the only etcd member", ref)
+	}
+	if !force {
+		for _, node := range controlPlanes.nodes {
+			if node.Name == member.Hostname {
+				return fmt.Errorf("refusing to remove the etcd member of active control plane %s; use `node remove` or pass --force", node.Name)
+			}
+		}
+	}
+
+	return controlPlanes.withAnyControlPlane(member.Hostname, func(client talosEtcdClient) error {
+		return client.EtcdRemoveMember(ctx, strconv.FormatUint(member.ID, 10))
+	})
+}
+
+// findEtcdMember matches ref against member names first, then hex IDs.
+func findEtcdMember(members []talos.EtcdMember, ref string) (talos.EtcdMember, error) {
+	ref = strings.TrimSpace(ref)
+	for _, member := range members {
+		if member.Hostname == ref {
+			return member, nil
+		}
+	}
+	if id, err := strconv.ParseUint(ref, 16, 64); err == nil {
+		for _, member := range members {
+			if member.ID == id {
+				return member, nil
+			}
+		}
+	}
+	return talos.EtcdMember{}, fmt.Errorf("no etcd member named or with ID %q", ref)
+}
+
+// etcdAlarmEntry is one alarm in the output of `etcd alarms`.
+type etcdAlarmEntry struct {
+	MemberID string `json:"memberId"`
+	Member   string `json:"member,omitempty"`
+	Alarm    string `json:"alarm"`
+}
+
+// etcdAlarmsResult is the structured output of `etcd alarms list` and
+// `etcd alarms disarm`.
+type etcdAlarmsResult struct {
+	Cluster    string           `json:"cluster"`
+	ConfigPath string           `json:"configPath"`
+	Alarms     []etcdAlarmEntry `json:"alarms"`
+	Error      string           `json:"error,omitempty"`
+}
+
+func runEtcdAlarmsList(cmd *cobra.Command, args []string) error {
+	return runEtcdAlarms(cmd, false)
+}
+
+func runEtcdAlarmsDisarm(cmd *cobra.Command, args []string) error {
+	return runEtcdAlarms(cmd, true)
+}
+
+func runEtcdAlarms(cmd *cobra.Command, disarm bool) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+	result := &etcdAlarmsResult{Cluster: cfg.Environment, ConfigPath: cfgPath, Alarms: []etcdAlarmEntry{}}
+
+	if disarm {
+		clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+		if err != nil {
+			return err
+		}
+		defer releaseClusterLock(clusterLock)
+	}
+
+	controlPlanes, err := loadEtcdControlPlanes(ctx, cfg)
+	if err == nil {
+		result.Alarms, err = etcdAlarms(ctx, controlPlanes, disarm)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		switch {
+		case len(result.Alarms) == 0 && disarm:
+			fmt.Printf("No etcd alarms to disarm in cluster %q\n", result.Cluster)
+			return
+		case len(result.Alarms) == 0:
+			fmt.Printf("No etcd alarms in cluster %q\n", result.Cluster)
+			return
+		case disarm:
+			fmt.Printf("Disarmed %d etcd alarm(s) in cluster %q:\n", len(result.Alarms), result.Cluster)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MEMBER ID\tMEMBER\tALARM")
+		for _, alarm := range result.Alarms {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", alarm.MemberID, defaultString(alarm.Member, "-"), alarm.Alarm)
+		}
+		_ = w.Flush()
+	})
+}
+
+// etcdAlarms lists, or disarms, the cluster's alarms and names the member
+// that raised each one.
+func etcdAlarms(ctx context.Context, controlPlanes *etcdControlPlanes, disarm bool) ([]etcdAlarmEntry, error) {
+	members, err := controlPlanes.members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint64]string, len(members))
+	for _, member := range members {
+		names[member.ID] = member.Hostname
+	}
+
+	var alarms []talos.EtcdAlarm
+	err = controlPlanes.withAnyControlPlane("", func(client talosEtcdClient) error {
+		var err error
+		if disarm {
+			alarms, err = client.EtcdAlarmDisarm(ctx)
+		} else {
+			alarms, err = client.EtcdAlarms(ctx)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []etcdAlarmEntry{}
+	for _, alarm := range alarms {
+		entries = append(entries, etcdAlarmEntry{
+			MemberID: formatEtcdMemberID(alarm.MemberID),
+			Member:   names[alarm.MemberID],
+			Alarm:    alarm.Alarm,
+		})
+	}
+	return entries, nil
+}
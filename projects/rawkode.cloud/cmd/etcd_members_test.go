@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+)
+
+func restoreEtcdMemberFns() func() {
+	loadNodeState := etcdLoadNodeStateFn
+	talosconfig := etcdTalosconfigFn
+	talosClient := etcdTalosClientFn
+	pollInterval := etcdPollInterval
+
+	return func() {
+		etcdLoadNodeStateFn = loadNodeState
+		etcdTalosconfigFn = talosconfig
+		etcdTalosClientFn = talosClient
+		etcdPollInterval = pollInterval
+	}
+}
+
+type fakeTalosEtcdClient struct {
+	etcd *fakeEtcdCluster
+	name string
+}
+
+func (c *fakeTalosEtcdClient) EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	client := &fakeTalosNodeRemovalClient{cluster: c.etcd, name: c.name}
+	return client.EtcdMembers(ctx)
+}
+
+func (c *fakeTalosEtcdClient) EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error) {
+	c.etcd.mu.Lock()
+	defer c.etcd.mu.Unlock()
+	status := &talos.EtcdMemberStatus{
+		MemberID: c.etcd.members[c.name],
+		Leader:   c.etcd.members["production-control-plane-01"],
+		DBSize:   int64(c.etcd.members[c.name]) << 20,
+	}
+	if c.etcd.unhealthy[c.name] {
+		status.Errors = []string{"NOSPACE"}
+	}
+	return status, nil
+}
+
+func (c *fakeTalosEtcdClient) EtcdRemoveMember(ctx context.Context, memberID string) error {
+	c.etcd.record("remove-member " + memberID + " via " + c.name)
+	client := &fakeTalosNodeRemovalClient{cluster: c.etcd, name: c.name}
+	return client.EtcdRemoveMember(ctx, memberID)
+}
+
+func (c *fakeTalosEtcdClient) EtcdDefragment(ctx context.Context) error {
+	c.etcd.record("defrag " + c.name)
+	return nil
+}
+
+func (c *fakeTalosEtcdClient) EtcdAlarms(ctx context.Context) ([]talos.EtcdAlarm, error) {
+	return []talos.EtcdAlarm{{MemberID: 2, Alarm: "NOSPACE"}}, nil
+}
+
+func (c *fakeTalosEtcdClient) EtcdAlarmDisarm(ctx context.Context) ([]talos.EtcdAlarm, error) {
+	c.etcd.record("disarm via " + c.name)
+	return c.EtcdAlarms(ctx)
+}
+
+func (c *fakeTalosEtcdClient) Close() error {
+	return nil
+}
+
+// newEtcdMembersTest stubs the seams of the etcd member commands with the
+// control planes of nodeRemoveTestNodes.
+func newEtcdMembersTest(t *testing.T, etcd *fakeEtcdCluster) *etcdControlPlanes {
+	t.Helper()
+	t.Cleanup(restoreEtcdMemberFns())
+
+	nodes := nodeRemoveTestNodes()
+	names := map[string]string{}
+	for _, node := range nodes {
+		names[node.PublicIP] = node.Name
+	}
+
+	etcdPollInterval = time.Millisecond
+	etcdLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*cluster.NodesState, error) {
+		return &cluster.NodesState{Environment: cfg.Environment, Nodes: nodes}, nil
+	}
+	etcdTalosconfigFn = func(ctx context.Context, cfg *config.Config) ([]byte, error) {
+		return []byte("talosconfig"), nil
+	}
+	etcdTalosClientFn = func(endpoint string, talosconfig []byte) (talosEtcdClient, error) {
+		return &fakeTalosEtcdClient{etcd: etcd, name: names[endpoint]}, nil
+	}
+
+	controlPlanes, err := loadEtcdControlPlanes(context.Background(), &config.Config{Environment: "production"})
+	if err != nil {
+		t.Fatalf("loadEtcdControlPlanes: %v", err)
+	}
+	return controlPlanes
+}
+
+func TestCollectEtcdMembersReportsLeaderAndSizes(t *testing.T) {
+	controlPlanes := newEtcdMembersTest(t, testEtcdCluster())
+
+	result := &etcdMembersResult{}
+	if err := collectEtcdMembers(context.Background(), controlPlanes, result); err != nil {
+		t.Fatalf("collectEtcdMembers returned error: %v", err)
+	}
+	if len(result.Members) != 3 || result.Leader != "production-control-plane-01" {
+		t.Fatalf("result = %+v", result)
+	}
+	for _, member := range result.Members {
+		id, err := strconv.ParseUint(member.ID, 16, 64)
+		if err != nil {
+			t.Fatalf("member ID %q is not hex: %v", member.ID, err)
+		}
+		if member.DBSize != int64(id)<<20 || member.Leader != (id == 1) {
+			t.Fatalf("member = %+v", member)
+		}
+	}
+}
+
+func TestRemoveEtcdMemberResolvesIDAndGuardsActiveControlPlanes(t *testing.T) {
+	etcd := testEtcdCluster()
+	etcd.members["production-control-plane-04"] = 4
+	controlPlanes := newEtcdMembersTest(t, etcd)
+
+	result := &etcdRemoveMemberResult{}
+	err := removeEtcdMember(context.Background(), controlPlanes, "production-control-plane-02", false, result)
+	if err == nil || !strings.Contains(err.Error(), "node remove") {
+		t.Fatalf("removeEtcdMember error = %v, want active control plane refusal", err)
+	}
+
+	if err := removeEtcdMember(context.Background(), controlPlanes, formatEtcdMemberID(4), false, result); err != nil {
+		t.Fatalf("removeEtcdMember returned error: %v", err)
+	}
+	if result.Name != "production-control-plane-04" || len(etcd.members) != 3 {
+		t.Fatalf("result = %+v, members = %v", result, etcd.members)
+	}
+
+	if err := removeEtcdMember(context.Background(), controlPlanes, "production-control-plane-01", true, result); err != nil {
+		t.Fatalf("forced removeEtcdMember returned error: %v", err)
+	}
+	want := []string{"remove-member 4 via production-control-plane-01", "remove-member 1 via production-control-plane-02"}
+	if got := filterEvents(etcd.events, "remove-member 1 via", "remove-member 4 via"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestDefragEtcdDefragmentsLeaderLast(t *testing.T) {
+	etcd := testEtcdCluster()
+	controlPlanes := newEtcdMembersTest(t, etcd)
+
+	result := &etcdDefragResult{}
+	if err := defragEtcd(context.Background(), controlPlanes, nil, time.Second, result); err != nil {
+		t.Fatalf("defragEtcd returned error: %v", err)
+	}
+	want := []string{
+		"defrag production-control-plane-02",
+		"defrag production-control-plane-03",
+		"defrag production-control-plane-01",
+	}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+	if len(result.Nodes) != 3 || result.Nodes[2].DBSizeAfter != 1<<20 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	if err := defragEtcd(context.Background(), controlPlanes, []string{"production-worker-01"}, time.Second, result); err == nil {
+		t.Fatal("expected refusal to defragment a worker")
+	}
+}
+
+func TestDefragEtcdStopsWhenMembersUnhealthy(t *testing.T) {
+	etcd := testEtcdCluster()
+	controlPlanes := newEtcdMembersTest(t, etcd)
+	etcd.unhealthy = map[string]bool{"production-control-plane-03": true}
+
+	err := defragEtcd(context.Background(), controlPlanes, []string{"production-control-plane-02"}, 10*time.Millisecond, &etcdDefragResult{})
+	if err == nil || !strings.Contains(err.Error(), "did not become healthy") {
+		t.Fatalf("defragEtcd error = %v, want unhealthy refusal", err)
+	}
+	if len(etcd.events) != 0 {
+		t.Fatalf("members were defragmented while unhealthy: %v", etcd.events)
+	}
+}
+
+func TestEtcdAlarmsNamesMembers(t *testing.T) {
+	etcd := testEtcdCluster()
+	controlPlanes := newEtcdMembersTest(t, etcd)
+
+	alarms, err := etcdAlarms(context.Background(), controlPlanes, true)
+	if err != nil {
+		t.Fatalf("etcdAlarms returned error: %v", err)
+	}
+	if len(alarms) != 1 || alarms[0].Member != "production-control-plane-02" || alarms[0].Alarm != "NOSPACE" {
+		t.Fatalf("alarms = %+v", alarms)
+	}
+	if strings.Join(etcd.events, ",") != "disarm via production-control-plane-01" {
+		t.Fatalf("events = %v", etcd.events)
+	}
+}
+
+func filterEvents(events []string, prefixes ...string) []string {
+	var filtered []string
+	for _, event := range events {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(event, prefix) {
+				filtered = append(filtered, event)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+	"github.com/spf13/cobra"
+)
+
+var etcdVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Check an etcd snapshot before restoring it",
+	Long: `Check that a snapshot file is a complete etcd database: refuse empty and
+truncated files, check the integrity hash etcd appends to snapshots and
+every database page, and report the revision and member count.
+
+When a manifest is found (--manifest, or the .json written next to the
+snapshot by etcd backup) the file's sha256 and revision must match it.
+Encrypted snapshots are decrypted with the --identity files or, with
+--cluster/--file, the Infisical identity. Reading the database needs random
+access, so the decrypted snapshot is written to a file only the current
+user can read, in a private temporary directory, and deleted as soon as it
+has been checked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEtcdVerify,
+}
+
+// etcdVerifyResult is the structured output of `etcd verify`.
+type etcdVerifyResult struct {
+	Path            string `json:"path"`
+	Manifest        string `json:"manifest,omitempty"`
+	Size            int64  `json:"size"`
+	SHA256          string `json:"sha256"`
+	Encrypted       bool   `json:"encrypted,omitempty"`
+	PlaintextSHA256 string `json:"plaintextSha256,omitempty"`
+	Revision        int64  `json:"revision"`
+	Members         int    `json:"members"`
+	IntegrityHash   bool   `json:"integrityHash"`
+	Error           string `json:"error,omitempty"`
+}
+
+func runEtcdVerify(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	manifestPath, _ := cmd.Flags().GetString("manifest")
+	identityFiles, _ := cmd.Flags().GetStringArray("identity")
+
+	// The config is only needed for the Infisical identity.
+	var cfg *config.Config
+	if strings.TrimSpace(clusterName) != "" || strings.TrimSpace(cfgFile) != "" {
+		loaded, _, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+		if err != nil {
+			return err
+		}
+		cfg = loaded
+	}
+
+	result, err := verifyEtcdSnapshot(ctx, cfg, args[0], manifestPath, identityFiles)
+	if err != nil {
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Snapshot %s is valid: revision %d, %d member(s), sha256 %s\n", result.Path, result.Revision, result.Members, result.SHA256)
+		if result.Encrypted {
+			fmt.Printf("Encrypted with age; plaintext sha256 %s\n", result.PlaintextSHA256)
+		}
+		if !result.IntegrityHash {
+			fmt.Println("Warning: the snapshot carries no etcd integrity hash")
+		}
+		if result.Manifest != "" {
+			fmt.Printf("Matches manifest %s\n", result.Manifest)
+		} else {
+			fmt.Println("No manifest found; checksum not compared")
+		}
+	})
+}
+
+// verifyEtcdSnapshot checks the snapshot at path and, when one is found,
+// compares it with its manifest.
+func verifyEtcdSnapshot(ctx context.Context, cfg *config.Config, path, manifestPath string, identityFiles []string) (*etcdVerifyResult, error) {
+	result := &etcdVerifyResult{Path: path}
+
+	file, err := etcdbackup.InspectFile(path)
+	if err != nil {
+		return result, err
+	}
+	result.Size, result.SHA256 = file.Size, file.SHA256
+	if file.Size == 0 {
+		return result, fmt.Errorf("%s: %w", path, etcdbackup.ErrEmptySnapshot)
+	}
+
+	plaintext, encrypted, err := inspectEtcdSnapshot(ctx, cfg, path, identityFiles)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", path, err)
+	}
+	result.Encrypted = encrypted
+	result.Revision, result.Members, result.IntegrityHash = plaintext.Revision, plaintext.Members, plaintext.IntegrityHash
+	if encrypted {
+		result.PlaintextSHA256 = plaintext.SHA256
+	}
+
+	manifest, manifestPath, err := readSnapshotManifest(path, manifestPath)
+	if err != nil {
+		return result, err
+	}
+	if manifest != nil {
+		if err := manifest.Verify(file, encrypted, plaintext); err != nil {
+			return result, fmt.Errorf("%s: %w", manifestPath, err)
+		}
+		result.Manifest = manifestPath
+	}
+	return result, nil
+}
+
+// inspectEtcdSnapshot inspects the plaintext of the snapshot at path. A
+// plaintext snapshot is read in place; an age-encrypted one is decrypted
+// into a private spool file, deleted before returning, because reading
+// the database needs random access.
+func inspectEtcdSnapshot(ctx context.Context, cfg *config.Config, path string, identityFiles []string) (*etcdbackup.SnapshotInfo, bool, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("open snapshot file: %w", err)
+	}
+	defer in.Close()
+
+	snapshot, encrypted, err := openEtcdSnapshot(ctx, cfg, in, identityFiles)
+	if err != nil {
+		return nil, encrypted, err
+	}
+	if !encrypted {
+		info, err := etcdbackup.InspectSnapshot(path)
+		return info, false, err
+	}
+
+	spoolDir, err := os.MkdirTemp("", "etcd-verify-")
+	if err != nil {
+		return nil, true, fmt.Errorf("create snapshot spool directory: %w", err)
+	}
+	defer os.RemoveAll(spoolDir)
+
+	spool := filepath.Join(spoolDir, "snapshot.db")
+	out, err := os.OpenFile(spool, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, true, fmt.Errorf("create decrypted snapshot: %w", err)
+	}
+	defer out.Close()
+
+	// The integrity hash is checked as the plaintext streams past; only
+	// the database pages are read back from the spool.
+	digest := etcdbackup.NewDigest()
+	if _, err := io.Copy(io.MultiWriter(out, digest), snapshot); err != nil {
+		return nil, true, fmt.Errorf("decrypt snapshot: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, true, fmt.Errorf("write decrypted snapshot: %w", err)
+	}
+	info, err := digest.Info()
+	if err != nil {
+		return nil, true, err
+	}
+	if err := etcdbackup.InspectDatabase(spool, info); err != nil {
+		return nil, true, err
+	}
+	return info, true, nil
+}
+
+// streamVerifiedEtcdSnapshot passes the plaintext of the snapshot at path
+// to fn, decrypting it again as fn reads so it never reaches disk, and
+// fails if what fn read is not the plaintext verified earlier.
+func streamVerifiedEtcdSnapshot(ctx context.Context, cfg *config.Config, path string, identityFiles []string, verified *etcdVerifyResult, fn func(io.Reader) error) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open snapshot file: %w", err)
+	}
+	defer in.Close()
+
+	snapshot, _, err := openEtcdSnapshot(ctx, cfg, in, identityFiles)
+	if err != nil {
+		return err
+	}
+
+	digest := etcdbackup.NewDigest()
+	if err := fn(io.TeeReader(snapshot, digest)); err != nil {
+		return err
+	}
+	info, err := digest.Info()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	want := verified.SHA256
+	if verified.Encrypted {
+		want = verified.PlaintextSHA256
+	}
+	if info.SHA256 != want {
+		return fmt.Errorf("%s changed after it was verified (sha256 %s, want %s)", path, info.SHA256, want)
+	}
+	return nil
+}
+
+// readSnapshotManifest reads the manifest at manifestPath or, when that is
+// empty, the one etcd backup names after the snapshot, if it exists.
+func readSnapshotManifest(snapshotPath, manifestPath string) (*etcdbackup.Manifest, string, error) {
+	explicit := strings.TrimSpace(manifestPath) != ""
+	if !explicit {
+		manifestPath = etcdbackup.ManifestKey(snapshotPath)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("read manifest: %w", err)
+	}
+	manifest, err := etcdbackup.UnmarshalManifest(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	return manifest, manifestPath, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/etcdbackup"
+)
+
+func writeTestManifest(t *testing.T, path string, manifest *etcdbackup.Manifest) {
+	t.Helper()
+	data, err := etcdbackup.MarshalManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyEtcdSnapshotChecksSidecarManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "20261016T120000Z.db")
+	writeEtcdTestSnapshot(t, path, 77)
+	info, err := etcdbackup.InspectSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifestPath := filepath.Join(dir, "20261016T120000Z.json")
+	writeTestManifest(t, manifestPath, &etcdbackup.Manifest{Size: info.Size, SHA256: info.SHA256, Revision: 77})
+
+	result, err := verifyEtcdSnapshot(context.Background(), nil, path, "", nil)
+	if err != nil {
+		t.Fatalf("verifyEtcdSnapshot returned error: %v", err)
+	}
+	if result.Manifest != manifestPath || result.Revision != 77 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	writeTestManifest(t, manifestPath, &etcdbackup.Manifest{Size: info.Size, SHA256: "0000", Revision: 77})
+	if _, err := verifyEtcdSnapshot(context.Background(), nil, path, "", nil); !errors.Is(err, etcdbackup.ErrChecksumMismatch) {
+		t.Fatalf("error = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestVerifyEtcdSnapshotDecryptsWithIdentityFile(t *testing.T) {
+	dir := t.TempDir()
+	plaintext := filepath.Join(dir, "plaintext.db")
+	writeEtcdTestSnapshot(t, plaintext, 5)
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(identityFile, []byte("# test key\n"+identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := filepath.Join(dir, "snapshot.db.age")
+	if err := encryptSnapshotFile(plaintext, encrypted, []age.Recipient{identity.Recipient()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifyEtcdSnapshot(context.Background(), nil, encrypted, "", nil); !errors.Is(err, etcdbackup.ErrNoIdentity) {
+		t.Fatalf("error = %v, want ErrNoIdentity", err)
+	}
+
+	result, err := verifyEtcdSnapshot(context.Background(), nil, encrypted, "", []string{identityFile})
+	if err != nil {
+		t.Fatalf("verifyEtcdSnapshot returned error: %v", err)
+	}
+	if !result.Encrypted || result.Revision != 5 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// The restore decrypts again while streaming and checks it read the
+	// plaintext that was verified.
+	want, err := os.ReadFile(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []byte
+	if err := streamVerifiedEtcdSnapshot(context.Background(), nil, encrypted, []string{identityFile}, result, func(r io.Reader) error {
+		streamed, err = io.ReadAll(r)
+		return err
+	}); err != nil {
+		t.Fatalf("streamVerifiedEtcdSnapshot returned error: %v", err)
+	}
+	if string(streamed) != string(want) {
+		t.Fatal("streamed snapshot does not match the plaintext")
+	}
+
+	result.PlaintextSHA256 = "0000"
+	err = streamVerifiedEtcdSnapshot(context.Background(), nil, encrypted, []string{identityFile}, result, func(r io.Reader) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "changed after it was verified") {
+		t.Fatalf("error = %v, want changed snapshot refusal", err)
+	}
+}
+
+func TestVerifyEtcdSnapshotRefusesEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyEtcdSnapshot(context.Background(), nil, path, "", nil); !errors.Is(err, etcdbackup.ErrEmptySnapshot) {
+		t.Fatalf("error = %v, want ErrEmptySnapshot", err)
+	}
+}
+
+// encryptSnapshotFile writes the age encryption of the snapshot at src to dst.
+func encryptSnapshotFile(src, dst string, recipients []age.Recipient) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create encrypted snapshot: %w", err)
+	}
+	defer out.Close()
+
+	encrypted, err := etcdbackup.Encrypt(out, recipients)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(encrypted, in); err != nil {
+		return fmt.Errorf("encrypt snapshot: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("encrypt snapshot: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("write encrypted snapshot: %w", err)
+	}
+	return nil
+}
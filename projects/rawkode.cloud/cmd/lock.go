@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/infisical"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+const (
+	lockBackendAuto       = "auto"
+	lockBackendInfisical  = "infisical"
+	lockBackendKubernetes = "kubernetes"
+	lockBackendLocal      = "local"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Inspect and break the per-cluster mutation lock",
+}
+
+var lockShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show who holds the mutation lock for a cluster",
+	RunE:  runLockShow,
+}
+
+var lockBreakCmd = &cobra.Command{
+	Use:   "break",
+	Short: "Break a stale mutation lock",
+	RunE:  runLockBreak,
+}
+
+var (
+	clusterLockBackendFn = newClusterLockBackend
+	lockLoadConfigFn     = loadConfigForClusterOrFile
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("lock-backend", lockBackendAuto, "Where the per-cluster mutation lock lives (auto, infisical, kubernetes, local); auto follows --state-store")
+
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockShowCmd)
+	lockCmd.AddCommand(lockBreakCmd)
+
+	for _, c := range []*cobra.Command{lockShowCmd, lockBreakCmd} {
+		c.Flags().String("cluster", "", "Cluster/environment name")
+		c.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	}
+	lockBreakCmd.Flags().Bool("force", false, "Break the lock even if its holder is still renewing it")
+}
+
+// acquireClusterLock takes the mutation lock for cfg's cluster on behalf of cmd.
+// Callers must release it with releaseClusterLock.
+func acquireClusterLock(ctx context.Context, cmd *cobra.Command, cfg *config.Config, operationID string) (*lock.Lock, error) {
+	backend, err := clusterLockBackendFn(ctx, cmd, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open cluster lock backend: %w", err)
+	}
+
+	command := ""
+	if cmd != nil {
+		command = cmd.CommandPath()
+	}
+
+	held, err := lock.Acquire(ctx, backend, lock.Request{
+		Cluster:     cfg.Environment,
+		Command:     command,
+		OperationID: operationID,
+	})
+	if err != nil {
+		if errors.Is(err, lock.ErrHeld) {
+			return nil, fmt.Errorf("%w; wait for it to finish or run `lock break --cluster %s` if it is stale", err, cfg.Environment)
+		}
+		return nil, fmt.Errorf("acquire cluster lock: %w", err)
+	}
+
+	slog.Info("acquired cluster lock", "cluster", cfg.Environment, "holder", held.Lease().Holder)
+
+	heldClusterLocks.Lock()
+	heldClusterLocks.lock = held
+	heldClusterLocks.Unlock()
+
+	return held, nil
+}
+
+// heldClusterLocks is the lock this process holds, so operation state is
+// only persisted while it is still held.
+var heldClusterLocks struct {
+	sync.Mutex
+	lock *lock.Lock
+}
+
+// checkClusterLock returns an error once the held cluster lock has been
+// lost. Operations check it every time they persist a phase, so a command
+// stops changing the cluster at the next phase boundary after another
+// holder took over.
+func checkClusterLock() error {
+	heldClusterLocks.Lock()
+	held := heldClusterLocks.lock
+	heldClusterLocks.Unlock()
+
+	if held == nil {
+		return nil
+	}
+	if err := held.Err(); err != nil {
+		return fmt.Errorf("stopping: %w", err)
+	}
+	return nil
+}
+
+func releaseClusterLock(held *lock.Lock) {
+	if held == nil {
+		return
+	}
+
+	heldClusterLocks.Lock()
+	if heldClusterLocks.lock == held {
+		heldClusterLocks.lock = nil
+	}
+	heldClusterLocks.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := held.Release(ctx); err != nil {
+		slog.Warn("release cluster lock", "cluster", held.Lease().Cluster, "error", err)
+	}
+}
+
+func newClusterLockBackend(ctx context.Context, cmd *cobra.Command, cfg *config.Config) (lock.Backend, error) {
+	kind := lockBackendAuto
+	if cmd != nil {
+		if value, err := cmd.Flags().GetString("lock-backend"); err == nil && strings.TrimSpace(value) != "" {
+			kind = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+
+	storeKind, stateDir := operationStoreFlags(cmd)
+	if kind == lockBackendAuto {
+		kind = lockBackendInfisical
+		if storeKind == operationStoreLocal {
+			kind = lockBackendLocal
+		}
+	}
+
+	switch kind {
+	case lockBackendLocal:
+		resolved, err := expandLocalPath(stateDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolve --state-dir: %w", err)
+		}
+		return lock.NewFileBackend(resolved)
+	case lockBackendInfisical:
+		return newInfisicalLockBackend(ctx, cfg)
+	case lockBackendKubernetes:
+		// No fallback: a client that quietly locked elsewhere would not
+		// exclude the clients holding the Lease. A cluster that has no
+		// Kubernetes API yet needs --lock-backend infisical.
+		backend, err := newKubernetesLockBackend(ctx, cmd, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes lock backend unavailable (use --lock-backend infisical while the cluster has no API): %w", err)
+		}
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("--lock-backend must be one of: %s, %s, %s, %s", lockBackendAuto, lockBackendInfisical, lockBackendKubernetes, lockBackendLocal)
+	}
+}
+
+func newInfisicalLockBackend(ctx context.Context, cfg *config.Config) (lock.Backend, error) {
+	client, err := getOrCreateInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create infisical client: %w", err)
+	}
+
+	return infisical.NewLockBackend(client, cfg.Infisical.ProjectID, cfg.Infisical.Environment, infisicalOperationsPath(cfg))
+}
+
+func newKubernetesLockBackend(ctx context.Context, cmd *cobra.Command, cfg *config.Config) (lock.Backend, error) {
+	cfgFile := ""
+	if cmd != nil {
+		cfgFile, _ = cmd.Flags().GetString("file")
+	}
+
+	materials, err := buildClusterAccessMaterials(ctx, cfg.Environment, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kube.NewClient(materials.KubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	return lock.NewKubernetesBackend(client, lock.DefaultLeaseNamespace)
+}
+
+func runLockShow(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, backend, err := lockCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	lease, err := backend.Get(ctx, cfg.Environment)
+	if err != nil {
+		return err
+	}
+	if lease == nil {
+		fmt.Printf("Cluster %q is not locked\n", cfg.Environment)
+		return nil
+	}
+
+	printLease(lease)
+	return nil
+}
+
+func runLockBreak(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	force, _ := cmd.Flags().GetBool("force")
+
+	cfg, backend, err := lockCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	lease, err := backend.Get(ctx, cfg.Environment)
+	if err != nil {
+		return err
+	}
+	if lease == nil {
+		fmt.Printf("Cluster %q is not locked\n", cfg.Environment)
+		return nil
+	}
+	if !lease.Expired(time.Now()) && !force {
+		return fmt.Errorf("%w; pass --force to break a lock that is still being renewed", &lock.HeldError{Lease: lease})
+	}
+
+	broken, err := lock.Break(ctx, backend, cfg.Environment)
+	if err != nil {
+		return fmt.Errorf("break cluster lock: %w", err)
+	}
+	if broken != nil {
+		fmt.Printf("Broke lock on cluster %q held by %s (operation=%s)\n", cfg.Environment, broken.Holder, defaultString(broken.OperationID, "none"))
+	}
+
+	return nil
+}
+
+func lockCommandContext(ctx context.Context, cmd *cobra.Command) (*config.Config, lock.Backend, error) {
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+
+	cfg, _, err := lockLoadConfigFn(clusterName, cfgFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backend, err := clusterLockBackendFn(ctx, cmd, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open cluster lock backend: %w", err)
+	}
+
+	return cfg, backend, nil
+}
+
+func printLease(lease *lock.Lease) {
+	state := "active"
+	if lease.Expired(time.Now()) {
+		state = "stale"
+	}
+
+	fmt.Printf("Cluster:   %s\n", lease.Cluster)
+	fmt.Printf("State:     %s\n", state)
+	fmt.Printf("Holder:    %s\n", lease.Holder)
+	fmt.Printf("Command:   %s\n", defaultString(lease.Command, "-"))
+	fmt.Printf("Operation: %s\n", defaultString(lease.OperationID, "-"))
+	fmt.Printf("Acquired:  %s\n", lease.AcquiredAt.Local().Format(time.RFC3339))
+	fmt.Printf("Renewed:   %s\n", lease.RenewedAt.Local().Format(time.RFC3339))
+	fmt.Printf("Expires:   %s\n", lease.ExpiresAt.Local().Format(time.RFC3339))
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+// useLocalClusterLock points mutating commands at a throwaway file lock.
+func useLocalClusterLock(t *testing.T) lock.Backend {
+	t.Helper()
+
+	backend, err := lock.NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileBackend returned error: %v", err)
+	}
+
+	original := clusterLockBackendFn
+	clusterLockBackendFn = func(context.Context, *cobra.Command, *config.Config) (lock.Backend, error) {
+		return backend, nil
+	}
+	t.Cleanup(func() { clusterLockBackendFn = original })
+
+	return backend
+}
+
+func TestAcquireClusterLockRejectsConcurrentHolder(t *testing.T) {
+	useLocalClusterLock(t)
+	cfg := &config.Config{Environment: "production"}
+
+	first, err := acquireClusterLock(context.Background(), nil, cfg, "op-1")
+	if err != nil {
+		t.Fatalf("first acquireClusterLock returned error: %v", err)
+	}
+	defer releaseClusterLock(first)
+
+	_, err = acquireClusterLock(context.Background(), nil, cfg, "op-2")
+	if !errors.Is(err, lock.ErrHeld) {
+		t.Fatalf("second acquireClusterLock error = %v, want ErrHeld", err)
+	}
+	if !strings.Contains(err.Error(), "op-1") || !strings.Contains(err.Error(), "lock break") {
+		t.Fatalf("error %q should name the holding operation and the break command", err.Error())
+	}
+}
+
+func TestRunLockBreakRequiresForceForLiveLock(t *testing.T) {
+	backend := useLocalClusterLock(t)
+	originalLoad := lockLoadConfigFn
+	lockLoadConfigFn = func(string, string) (*config.Config, string, error) {
+		return &config.Config{Environment: "production"}, "./clusters/production.yaml", nil
+	}
+	t.Cleanup(func() { lockLoadConfigFn = originalLoad })
+
+	held, err := acquireClusterLock(context.Background(), nil, &config.Config{Environment: "production"}, "op-1")
+	if err != nil {
+		t.Fatalf("acquireClusterLock returned error: %v", err)
+	}
+	defer releaseClusterLock(held)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("cluster", "production", "")
+	cmd.Flags().StringP("file", "f", "", "")
+	cmd.Flags().Bool("force", false, "")
+
+	if err := runLockBreak(cmd, nil); err == nil {
+		t.Fatal("expected live lock break to require --force")
+	}
+
+	_ = cmd.Flags().Set("force", "true")
+	if err := runLockBreak(cmd, nil); err != nil {
+		t.Fatalf("runLockBreak returned error: %v", err)
+	}
+
+	lease, err := backend.Get(context.Background(), "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if lease != nil {
+		t.Fatalf("lease = %+v, want nil after break", lease)
+	}
+}
+
+func TestSaveOperationStopsAfterLockIsLost(t *testing.T) {
+	backend := useLocalClusterLock(t)
+	cfg := &config.Config{Environment: "production"}
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+	op := operation.New("op-1", operation.TypeRemoveNode, "production", []string{"drain"})
+
+	held, err := acquireClusterLock(context.Background(), nil, cfg, op.ID)
+	if err != nil {
+		t.Fatalf("acquireClusterLock returned error: %v", err)
+	}
+	defer releaseClusterLock(held)
+
+	if err := saveOperation(context.Background(), store, op); err != nil {
+		t.Fatalf("saveOperation while holding the lock returned error: %v", err)
+	}
+
+	if _, err := lock.Break(context.Background(), backend, "production"); err != nil {
+		t.Fatalf("Break returned error: %v", err)
+	}
+	_ = held.SetOperationID(context.Background(), op.ID)
+
+	if err := saveOperation(context.Background(), store, op); !errors.Is(err, lock.ErrLost) {
+		t.Fatalf("saveOperation after losing the lock error = %v, want ErrLost", err)
+	}
+}
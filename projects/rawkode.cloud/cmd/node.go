@@ -2,13 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"strings"
 	"time"
 
 	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/scaleway"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
 	scw "github.com/scaleway/scaleway-sdk-go/scw"
@@ -32,6 +35,13 @@ var nodeRemoveCmd = &cobra.Command{
 	RunE:  runNodeRemove,
 }
 
+var nodeAddPhases = []string{
+	"order-server",
+	"wait-server",
+	"wait-talos",
+	"apply-config",
+}
+
 func runNodeAdd(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
@@ -40,6 +50,8 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 	clusterName, _ := cmd.Flags().GetString("cluster")
 	cfgFile, _ := cmd.Flags().GetString("file")
 	poolName, _ := cmd.Flags().GetString("pool")
+	count, _ := cmd.Flags().GetInt("count")
+	parallel, _ := cmd.Flags().GetInt("parallel")
 
 	role := config.NormalizeNodePoolType(roleRaw)
 	if role == "" {
@@ -51,6 +63,15 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Hold the lock before reading inventory so concurrent adds cannot be
+	// handed the same pool slot.
+	operationID := operation.GenerateID()
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, operationID)
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
 	state, err := loadNodeState(ctx, cfg)
 	if err != nil {
 		return err
@@ -61,32 +82,145 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var (
-		name      string
-		privateIP string
-	)
-
 	if strings.TrimSpace(nameFlag) != "" {
 		return fmt.Errorf("--name is no longer supported; names are auto-generated from pool %q", pool.Name)
 	}
 
-	slot := nextNodePoolSlot(state, cfg.Environment, pool.Name, role)
-	if slot > 99 {
-		return fmt.Errorf("no available naming slot for pool %q", pool.Name)
+	if count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	if parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
 	}
-	name = pooledNodeName(cfg.Environment, pool.Name, slot)
 
+	slots, err := nextNodePoolSlots(state, cfg.Environment, pool.Name, role, count)
+	if err != nil {
+		return err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	if count > 1 {
+		operationIDs := make([]string, len(slots))
+		operationIDs[0] = operationID
+		for i := 1; i < len(operationIDs); i++ {
+			operationIDs[i] = operation.GenerateID()
+		}
+		return runNodeAddBatch(ctx, cmd, store, cfg, cfgPath, state, pool, slots, operationIDs, parallel)
+	}
+
+	result, err := addNodeToPool(ctx, store, operationID, cfg, cfgPath, state, pool, slots[0])
+	if err != nil {
+		if result == nil {
+			return err
+		}
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		fmt.Printf("Added %s node %q to cluster %q (config=%s, server=%s, public_ip=%s, private_ip=%s)\n",
+			result.Role, result.Name, result.Cluster, result.ConfigPath,
+			result.ServerID, result.PublicIP, result.PrivateIP,
+		)
+	})
+}
+
+// addNodeToPool provisions the node for a pool slot as an add-node
+// operation. Node add is not resumable, so a failure rolls back whatever was
+// created. The result is nil if the slot was refused before any operation
+// was recorded.
+func addNodeToPool(
+	ctx context.Context,
+	store operation.Store,
+	operationID string,
+	cfg *config.Config,
+	cfgPath string,
+	state *clusterstate.NodesState,
+	pool *config.NodePoolConfig,
+	slot int,
+) (*nodeAddResult, error) {
+	role := pool.EffectiveType()
+	name := pooledNodeName(cfg.Environment, pool.Name, slot)
+
+	var privateIP string
 	if role == config.NodeTypeControlPlane {
-		privateIP, err = controlPlaneReservedIPForSlot(pool, slot)
+		reserved, err := controlPlaneReservedIPForSlot(pool, slot)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		privateIP = reserved
 	}
 
 	if existing, ok := findNodeByName(state, name); ok && existing.Status != clusterstate.NodeStatusDeleted {
-		return fmt.Errorf("node %q already exists in Scaleway inventory with status=%s", name, existing.Status)
+		return nil, fmt.Errorf("node %q already exists in Scaleway inventory with status=%s", name, existing.Status)
+	}
+
+	op := operation.New(operationID, operation.TypeAddNode, cfg.Environment, nodeAddPhases)
+	op.SetContext("nodeName", name)
+	op.SetContext("role", role)
+	op.SetContext("poolName", pool.Name)
+	if err := saveOperation(ctx, store, op); err != nil {
+		return nil, err
 	}
 
+	if err := executeNodeAdd(ctx, store, op, cfg, pool, state, name, role, privateIP); err != nil {
+		slog.Warn("node add failed; rolling back", "operation", op.ID, "node", name, "error", err)
+		if rollbackErr := abortOperation(ctx, store, op, operationCleanupRegistryFn(cfg)); rollbackErr != nil {
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		result := newNodeAddResult(cfg, cfgPath, op)
+		result.Error = err.Error()
+		return &result, err
+	}
+
+	result := newNodeAddResult(cfg, cfgPath, op)
+	return &result, nil
+}
+
+// nodeAddResult is the structured output of `node add`.
+type nodeAddResult struct {
+	Cluster     string `json:"cluster"`
+	ConfigPath  string `json:"configPath"`
+	OperationID string `json:"operationId"`
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Pool        string `json:"pool"`
+	ServerID    string `json:"serverId,omitempty"`
+	PublicIP    string `json:"publicIp,omitempty"`
+	PrivateIP   string `json:"privateIp,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func newNodeAddResult(cfg *config.Config, cfgPath string, op *operation.Operation) nodeAddResult {
+	return nodeAddResult{
+		Cluster:     cfg.Environment,
+		ConfigPath:  cfgPath,
+		OperationID: op.ID,
+		Name:        op.GetContextString("nodeName"),
+		Role:        op.GetContextString("role"),
+		Pool:        op.GetContextString("poolName"),
+		ServerID:    op.GetContextString("serverId"),
+		PublicIP:    op.GetContextString("publicIP"),
+		PrivateIP:   op.GetContextString("privateIP"),
+	}
+}
+
+// executeNodeAdd provisions a single node, recording each step and the
+// rollback actions for anything it creates on the add-node operation.
+func executeNodeAdd(
+	ctx context.Context,
+	store operation.Store,
+	op *operation.Operation,
+	cfg *config.Config,
+	pool *config.NodePoolConfig,
+	state *clusterstate.NodesState,
+	name string,
+	role string,
+	privateIP string,
+) error {
 	accessKey, secretKey := cfg.ScalewayCredentials()
 	scwClient, err := scaleway.NewClient(accessKey, secretKey, cfg.Scaleway.ProjectID, cfg.Scaleway.OrganizationID)
 	if err != nil {
@@ -98,24 +232,28 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("node pool %q must define zone", pool.Name)
 	}
 	zone := scw.Zone(zoneValue)
+
+	if err := startNodeAddPhase(ctx, store, op, "order-server"); err != nil {
+		return err
+	}
 	offerID, _, err := scaleway.ResolveOfferForBillingCycle(ctx, scwClient, zone, pool.Offer, pool.BillingCycle)
 	if err != nil {
-		return fmt.Errorf("resolve offer: %w", err)
+		return failOperationPhase(ctx, store, op, "order-server", fmt.Errorf("resolve offer: %w", err))
 	}
 
 	osID, err := scaleway.ResolveUbuntuOSID(ctx, scwClient, zone, offerID)
 	if err != nil {
-		return fmt.Errorf("resolve ubuntu OS: %w", err)
+		return failOperationPhase(ctx, store, op, "order-server", fmt.Errorf("resolve ubuntu OS: %w", err))
 	}
 
 	region, _ := zone.Region()
 	vpcName, err := cfg.ScalewayVPCName()
 	if err != nil {
-		return err
+		return failOperationPhase(ctx, store, op, "order-server", err)
 	}
 	privateNetworkName, err := cfg.ScalewayPrivateNetworkName()
 	if err != nil {
-		return err
+		return failOperationPhase(ctx, store, op, "order-server", err)
 	}
 	network, err := scaleway.EnsureNetworkFoundation(ctx, scwClient, scaleway.NetworkFoundationParams{
 		Region:             region,
@@ -123,7 +261,7 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		PrivateNetworkName: privateNetworkName,
 	})
 	if err != nil {
-		return fmt.Errorf("ensure network: %w", err)
+		return failOperationPhase(ctx, store, op, "order-server", fmt.Errorf("ensure network: %w", err))
 	}
 
 	cloudInit := talos.BuildCloudInit(talos.PivotParams{
@@ -146,12 +284,27 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		PivotDataDisk:            pool.Disks.Data,
 	})
 	if err != nil {
-		return fmt.Errorf("order server: %w", err)
+		return failOperationPhase(ctx, store, op, "order-server", fmt.Errorf("order server: %w", err))
+	}
+
+	op.SetContext("serverId", server.ID)
+	if err := op.AddCleanup(cleanupActionDeleteServer, cleanupDeleteServer{
+		ServerID: server.ID,
+		Zone:     zoneValue,
+	}); err != nil {
+		return failOperationPhase(ctx, store, op, "order-server", err)
+	}
+	if err := completeOperationPhase(ctx, store, op, "order-server"); err != nil {
+		return err
+	}
+
+	if err := startNodeAddPhase(ctx, store, op, "wait-server"); err != nil {
+		return err
 	}
 
 	serverReady, err := scaleway.WaitForReady(ctx, scwClient, server.ID, zone)
 	if err != nil {
-		return fmt.Errorf("wait for server ready: %w", err)
+		return failOperationPhase(ctx, store, op, "wait-server", fmt.Errorf("wait for server ready: %w", err))
 	}
 
 	var publicIP string
@@ -167,13 +320,44 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		}
 	}
 	if strings.TrimSpace(publicIP) == "" {
-		return fmt.Errorf("server %s has no public IPv4", server.ID)
+		return failOperationPhase(ctx, store, op, "wait-server", fmt.Errorf("server %s has no public IPv4", server.ID))
+	}
+	op.SetContext("publicIP", publicIP)
+	if strings.TrimSpace(privateIP) != "" {
+		op.SetContext("privateIP", privateIP)
+	}
+	if err := completeOperationPhase(ctx, store, op, "wait-server"); err != nil {
+		return err
 	}
 
+	if err := startNodeAddPhase(ctx, store, op, "wait-talos"); err != nil {
+		return err
+	}
 	if err := talos.WaitForMaintenance(ctx, publicIP, 30*time.Minute); err != nil {
-		return fmt.Errorf("wait for talos maintenance: %w", err)
+		return failOperationPhase(ctx, store, op, "wait-talos", fmt.Errorf("wait for talos maintenance: %w", err))
 	}
+	if err := completeOperationPhase(ctx, store, op, "wait-talos"); err != nil {
+		return err
+	}
+
+	if err := startNodeAddPhase(ctx, store, op, "apply-config"); err != nil {
+		return err
+	}
+	if err := applyNodeAddConfig(ctx, cfg, state, name, role, publicIP); err != nil {
+		return failOperationPhase(ctx, store, op, "apply-config", err)
+	}
+
+	return completeOperationPhase(ctx, store, op, "apply-config")
+}
+
+// startNodeAddPhase starts an add-node phase, logging it against the node
+// so concurrent adds can be told apart.
+func startNodeAddPhase(ctx context.Context, store operation.Store, op *operation.Operation, phase string) error {
+	slog.Info("executing phase", "phase", phase, "node", op.GetContextString("nodeName"), "operation", op.ID)
+	return startOperationPhase(ctx, store, op, phase)
+}
 
+func applyNodeAddConfig(ctx context.Context, cfg *config.Config, state *clusterstate.NodesState, name, role, publicIP string) error {
 	infClient, err := newInfisicalClient(ctx, cfg)
 	if err != nil {
 		return err
@@ -188,21 +372,13 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	nodeConfig := assets.Worker
-	if role == config.NodeTypeControlPlane {
-		nodeConfig = assets.ControlPlane
-	}
-	nodeConfig, err = renderNodeTalosConfig(cfg, nodeConfig, name, role)
-	if err != nil {
-		return fmt.Errorf("render node-specific Talos config for %q: %w", name, err)
-	}
 	netbirdSetupKey, err := loadOptionalNetbirdSetupKeyFromInfisical(ctx, cfg, infClient)
 	if err != nil {
 		return fmt.Errorf("load netbird setup key: %w", err)
 	}
-	nodeConfig, err = appendNetbirdExtensionServiceConfig(nodeConfig, netbirdSetupKey)
+	nodeConfig, err := renderNodeMachineConfig(cfg, assets, name, role, netbirdSetupKey)
 	if err != nil {
-		return fmt.Errorf("append netbird extension service config: %w", err)
+		return err
 	}
 
 	talosClient, err := talos.NewInsecureClient(publicIP)
@@ -215,62 +391,6 @@ func runNodeAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("apply node config: %w", err)
 	}
 
-	fmt.Printf("Added %s node %q to cluster %q (config=%s, server=%s, public_ip=%s, private_ip=%s)\n", role, name, cfg.Environment, cfgPath, server.ID, publicIP, privateIP)
-	return nil
-}
-
-func runNodeRemove(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
-
-	name, _ := cmd.Flags().GetString("name")
-	clusterName, _ := cmd.Flags().GetString("cluster")
-	cfgFile, _ := cmd.Flags().GetString("file")
-
-	if strings.TrimSpace(name) == "" {
-		return fmt.Errorf("--name is required")
-	}
-
-	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
-	if err != nil {
-		return err
-	}
-
-	state, err := loadNodeState(ctx, cfg)
-	if err != nil {
-		return err
-	}
-
-	node, ok := findNodeByName(state, name)
-	if !ok {
-		return fmt.Errorf("node %q not found in Scaleway inventory", name)
-	}
-	if node.Status == clusterstate.NodeStatusDeleted {
-		fmt.Printf("Node %q is already marked deleted.\n", name)
-		return nil
-	}
-
-	if strings.TrimSpace(node.ServerID) != "" {
-		accessKey, secretKey := cfg.ScalewayCredentials()
-		scwClient, err := scaleway.NewClient(accessKey, secretKey, cfg.Scaleway.ProjectID, cfg.Scaleway.OrganizationID)
-		if err != nil {
-			return fmt.Errorf("create scaleway client: %w", err)
-		}
-
-		pool, err := cfg.FindNodePool(node.Pool)
-		if err != nil {
-			return fmt.Errorf("resolve node pool %q for node %q: %w", node.Pool, node.Name, err)
-		}
-		zoneValue := pool.EffectiveZone()
-		if zoneValue == "" {
-			return fmt.Errorf("node pool %q must define zone", pool.Name)
-		}
-
-		if err := scaleway.CleanupProvisionedServer(ctx, scwClient, node.ServerID, scw.Zone(zoneValue)); err != nil {
-			return fmt.Errorf("cleanup server %s: %w", node.ServerID, err)
-		}
-	}
-
-	fmt.Printf("Removed node %q from cluster %q (config=%s)\n", name, cfg.Environment, cfgPath)
 	return nil
 }
 
@@ -292,14 +412,38 @@ func resolveAddPool(cfg *config.Config, poolName, role string) (*config.NodePool
 func init() {
 	nodeCmd.AddCommand(nodeAddCmd)
 	nodeCmd.AddCommand(nodeRemoveCmd)
+	nodeCmd.AddCommand(nodeReplaceCmd)
+	nodeCmd.AddCommand(nodeReconfigureCmd)
 
 	nodeAddCmd.Flags().String("cluster", "", "Cluster/environment name")
 	nodeAddCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 	nodeAddCmd.Flags().String("name", "", "Node name (unsupported; names are auto-generated from pool slots)")
 	nodeAddCmd.Flags().String("pool", "", "Node pool name (optional)")
 	nodeAddCmd.Flags().String("role", "worker", "Node role (control-plane or worker)")
+	nodeAddCmd.Flags().Int("count", 1, "Number of nodes to add to the pool")
+	nodeAddCmd.Flags().Int("parallel", 3, "Maximum number of nodes provisioned at the same time when --count is greater than 1")
 
 	nodeRemoveCmd.Flags().String("cluster", "", "Cluster/environment name")
 	nodeRemoveCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 	nodeRemoveCmd.Flags().String("name", "", "Node name")
+	nodeRemoveCmd.Flags().Duration("drain-timeout", 10*time.Minute, "Maximum time to wait for the node to drain, including PodDisruptionBudget retries")
+	nodeRemoveCmd.Flags().Bool("force", false, "Continue past drain and reset failures of an unreachable node (last control plane and etcd quorum guards still apply)")
+
+	nodeReplaceCmd.Flags().String("cluster", "", "Cluster/environment name")
+	nodeReplaceCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	nodeReplaceCmd.Flags().String("name", "", "Name of the node to replace")
+	nodeReplaceCmd.Flags().Duration("drain-timeout", 10*time.Minute, "Maximum time to wait for the old node to drain, including PodDisruptionBudget retries")
+	nodeReplaceCmd.Flags().Duration("node-timeout", 30*time.Minute, "Maximum time to wait for the new node to become Ready and join etcd")
+	nodeReplaceCmd.Flags().Bool("force", false, "Continue past drain and reset failures of an unreachable node (last control plane and etcd quorum guards still apply)")
+
+	nodeReconfigureCmd.Flags().String("cluster", "", "Cluster/environment name")
+	nodeReconfigureCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	nodeReconfigureCmd.Flags().String("name", "", "Reconfigure only this node")
+	nodeReconfigureCmd.Flags().String("pool", "", "Reconfigure every node of this pool")
+	nodeReconfigureCmd.Flags().Bool("all", false, "Reconfigure every node of the cluster")
+	nodeReconfigureCmd.Flags().String("mode", string(talos.ApplyModeAuto), "Talos apply mode: auto, no-reboot, reboot or staged")
+	nodeReconfigureCmd.Flags().Bool("dry-run", false, "Show which nodes drifted and the mode they would be applied with, without applying")
+	nodeReconfigureCmd.Flags().Bool("show-secrets", false, "Print secrets in --dry-run diffs instead of redacting them")
+	nodeReconfigureCmd.Flags().Duration("drain-timeout", 10*time.Minute, "Maximum time to wait for a node that must reboot to drain")
+	nodeReconfigureCmd.Flags().Duration("node-timeout", 20*time.Minute, "Maximum time to wait for a reconfigured node to become healthy")
 }
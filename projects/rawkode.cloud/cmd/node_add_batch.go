@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+var nodeAddToPoolFn = addNodeToPool
+
+// nodeAddBatchResult is the structured output of `node add --count N`.
+type nodeAddBatchResult struct {
+	Cluster    string          `json:"cluster"`
+	ConfigPath string          `json:"configPath"`
+	Pool       string          `json:"pool"`
+	Parallel   int             `json:"parallel"`
+	Nodes      []nodeAddResult `json:"nodes"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func runNodeAddBatch(
+	ctx context.Context,
+	cmd *cobra.Command,
+	store operation.Store,
+	cfg *config.Config,
+	cfgPath string,
+	state *clusterstate.NodesState,
+	pool *config.NodePoolConfig,
+	slots []int,
+	operationIDs []string,
+	parallel int,
+) error {
+	result := &nodeAddBatchResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Pool:       pool.Name,
+		Parallel:   parallel,
+	}
+
+	nodes, err := addNodesToPool(ctx, store, operationIDs, cfg, cfgPath, state, pool, slots, parallel)
+	result.Nodes = nodes
+	if err != nil {
+		result.Error = err.Error()
+		if format, _ := outputFormat(cmd); format == outputFormatText {
+			printNodeAddBatch(result)
+		}
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printNodeAddBatch(result)
+		fmt.Printf("Added %d %s nodes to pool %q of cluster %q (config=%s)\n",
+			len(nodes), pool.EffectiveType(), pool.Name, cfg.Environment, cfgPath,
+		)
+	})
+}
+
+// addNodesToPool provisions one node per slot, at most parallel at a time.
+// Every node is its own add-node operation: a failure rolls back only that
+// node and does not stop the others. Results are returned in slot order.
+func addNodesToPool(
+	ctx context.Context,
+	store operation.Store,
+	operationIDs []string,
+	cfg *config.Config,
+	cfgPath string,
+	state *clusterstate.NodesState,
+	pool *config.NodePoolConfig,
+	slots []int,
+	parallel int,
+) ([]nodeAddResult, error) {
+	results := make([]nodeAddResult, len(slots))
+	errs := make([]error, len(slots))
+	sem := make(chan struct{}, max(parallel, 1))
+
+	var wg sync.WaitGroup
+	for i, slot := range slots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			name := pooledNodeName(cfg.Environment, pool.Name, slot)
+			slog.Info("adding node", "pool", pool.Name, "node", name, "operation", operationIDs[i])
+
+			result, err := nodeAddToPoolFn(ctx, store, operationIDs[i], cfg, cfgPath, state, pool, slot)
+			if result == nil {
+				result = &nodeAddResult{
+					Cluster:    cfg.Environment,
+					ConfigPath: cfgPath,
+					Name:       name,
+					Role:       pool.EffectiveType(),
+					Pool:       pool.Name,
+				}
+			}
+			if err != nil {
+				slog.Error("node add failed", "node", name, "operation", operationIDs[i], "error", err)
+				result.Error = err.Error()
+				errs[i] = fmt.Errorf("add node %q: %w", name, err)
+			} else {
+				slog.Info("node added", "node", name, "server_id", result.ServerID, "public_ip", result.PublicIP)
+			}
+			results[i] = *result
+		}()
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+func printNodeAddBatch(result *nodeAddBatchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tSERVER\tPUBLIC IP\tPRIVATE IP\tERROR")
+	for _, node := range result.Nodes {
+		status := "added"
+		if node.Error != "" {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			node.Name,
+			status,
+			defaultString(node.ServerID, "-"),
+			defaultString(node.PublicIP, "-"),
+			defaultString(node.PrivateIP, "-"),
+			defaultString(node.Error, "-"),
+		)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	clusterstate "github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+)
+
+func TestNextNodePoolSlotsFillsGaps(t *testing.T) {
+	state := &clusterstate.NodesState{Environment: "production", Nodes: []clusterstate.NodeState{
+		{Name: "production-worker-01", Role: config.NodeTypeWorker, Pool: "worker"},
+		{Name: "production-worker-03", Role: config.NodeTypeWorker, Pool: "worker"},
+	}}
+
+	slots, err := nextNodePoolSlots(state, "production", "worker", config.NodeTypeWorker, 3)
+	if err != nil {
+		t.Fatalf("nextNodePoolSlots returned error: %v", err)
+	}
+	if len(slots) != 3 || slots[0] != 2 || slots[1] != 4 || slots[2] != 5 {
+		t.Fatalf("slots = %v, want [2 4 5]", slots)
+	}
+	if len(state.Nodes) != 2 {
+		t.Fatalf("state was modified: %d nodes", len(state.Nodes))
+	}
+}
+
+func TestAddNodesToPoolBoundsConcurrencyAndReportsEachNode(t *testing.T) {
+	original := nodeAddToPoolFn
+	t.Cleanup(func() { nodeAddToPoolFn = original })
+
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	nodeAddToPoolFn = func(ctx context.Context, store operation.Store, operationID string, cfg *config.Config, cfgPath string, state *clusterstate.NodesState, pool *config.NodePoolConfig, slot int) (*nodeAddResult, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		name := pooledNodeName(cfg.Environment, pool.Name, slot)
+		if slot == 3 {
+			return &nodeAddResult{Name: name, OperationID: operationID}, errors.New("no stock")
+		}
+		return &nodeAddResult{Name: name, OperationID: operationID, ServerID: "srv-" + name}, nil
+	}
+
+	cfg := &config.Config{Environment: "production"}
+	pool := &config.NodePoolConfig{Name: "worker", Type: config.NodeTypeWorker}
+	state := &clusterstate.NodesState{Environment: "production"}
+	slots := []int{1, 2, 3, 4, 5}
+	operationIDs := []string{"op-1", "op-2", "op-3", "op-4", "op-5"}
+
+	results, err := addNodesToPool(context.Background(), nil, operationIDs, cfg, "", state, pool, slots, 2)
+	if err == nil || !strings.Contains(err.Error(), `add node "production-worker-03": no stock`) {
+		t.Fatalf("error = %v, want failure of production-worker-03", err)
+	}
+	if peak > 2 {
+		t.Fatalf("peak concurrency = %d, want at most 2", peak)
+	}
+	if len(results) != 5 {
+		t.Fatalf("results = %d, want 5", len(results))
+	}
+	for i, result := range results {
+		if result.OperationID != operationIDs[i] {
+			t.Fatalf("results[%d].OperationID = %q, want %q", i, result.OperationID, operationIDs[i])
+		}
+		if failed := result.Error != ""; failed != (slots[i] == 3) {
+			t.Fatalf("results[%d] error = %q", i, result.Error)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	nodeReconfigureUnchanged  = "unchanged"
+	nodeReconfigureWouldApply = "would-apply"
+	nodeReconfigureApplied    = "applied"
+	nodeReconfigureStaged     = "staged"
+	nodeReconfigureFailed     = "failed"
+)
+
+var nodeReconfigureCmd = &cobra.Command{
+	Use:   "reconfigure",
+	Short: "Re-apply the rendered machine config to nodes that drifted",
+	Long: `Regenerate the machine config of each selected node, compare it with the
+config the node runs and apply it where they differ. Nodes are handled one
+at a time, control planes first. With --mode auto Talos is asked which mode
+the change needs; nodes that must reboot are drained first. Before the next
+node is touched the changed one must pass the Talos health check, report
+Ready and, for control planes, keep every etcd member healthy. Staged
+changes take effect on the node's next reboot and are not health-gated.`,
+	RunE: runNodeReconfigure,
+}
+
+// talosReconfigureClient is the part of the Talos client node reconfigure
+// uses.
+type talosReconfigureClient interface {
+	MachineConfig(ctx context.Context) ([]byte, error)
+	ApplyConfigWithMode(ctx context.Context, configYAML []byte, mode talos.ApplyMode, dryRun bool) (*talos.ApplyResult, error)
+	HealthCheck(ctx context.Context) error
+	Close() error
+}
+
+var (
+	reconfigureLoadNodeStateFn = loadNodeState
+	reconfigurePrepareFn       = prepareNodeReconfigure
+	reconfigureKubeClientFn    = nodeReconfigureKubeClient
+	reconfigureTalosClientFn   = func(endpoint string, talosconfig []byte) (talosReconfigureClient, error) {
+		return talos.NewClient(endpoint, talosconfig)
+	}
+)
+
+// nodeReconfigureResult is the structured output of `node reconfigure`.
+type nodeReconfigureResult struct {
+	Cluster    string                      `json:"cluster"`
+	ConfigPath string                      `json:"configPath"`
+	Mode       string                      `json:"mode"`
+	DryRun     bool                        `json:"dryRun,omitempty"`
+	Nodes      []nodeReconfigureNodeResult `json:"nodes"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// nodeReconfigureNodeResult reports what happened to one node.
+type nodeReconfigureNodeResult struct {
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	Mode    string `json:"mode,omitempty"`
+	Diff    string `json:"diff,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// nodeReconfigureAssets is what reconfiguring needs from the cluster's
+// secrets: the talosconfig and a renderer for each node's machine config.
+type nodeReconfigureAssets struct {
+	talosconfig []byte
+	render      func(node cluster.NodeState) ([]byte, error)
+}
+
+func runNodeReconfigure(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	name, _ := cmd.Flags().GetString("name")
+	poolName, _ := cmd.Flags().GetString("pool")
+	all, _ := cmd.Flags().GetBool("all")
+	modeRaw, _ := cmd.Flags().GetString("mode")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+	drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+	nodeTimeout, _ := cmd.Flags().GetDuration("node-timeout")
+
+	mode, err := talos.ParseApplyMode(modeRaw)
+	if err != nil {
+		return fmt.Errorf("--mode: %w", err)
+	}
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+		if err != nil {
+			return err
+		}
+		defer releaseClusterLock(clusterLock)
+	}
+
+	state, err := reconfigureLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	nodes, err := selectReconfigureNodes(state, name, poolName, all)
+	if err != nil {
+		return err
+	}
+
+	assets, err := reconfigurePrepareFn(ctx, cfg, state)
+	if err != nil {
+		return err
+	}
+
+	r := &nodeReconfigure{
+		assets: assets,
+		mode:   mode,
+		dryRun: dryRun,
+		redact: !showSecrets,
+		health: &rollingTalosUpgrade{
+			talosconfig:   assets.talosconfig,
+			controlPlanes: controlPlaneNodes(upgradeCandidateNodes(state)),
+			opts: rollingUpgradeOptions{
+				DrainTimeout: drainTimeout,
+				NodeTimeout:  nodeTimeout,
+			},
+		},
+	}
+	if !dryRun {
+		r.health.kube, err = reconfigureKubeClientFn(ctx, cfgPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	result := &nodeReconfigureResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Mode:       string(mode),
+		DryRun:     dryRun,
+	}
+	result.Nodes, err = r.run(ctx, nodes)
+	if err != nil {
+		result.Error = err.Error()
+		if format, _ := outputFormat(cmd); format == outputFormatText {
+			printNodeReconfigure(result)
+		}
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printNodeReconfigure(result)
+		for _, node := range result.Nodes {
+			if node.Diff != "" && dryRun {
+				fmt.Print(node.Diff)
+			}
+		}
+	})
+}
+
+// selectReconfigureNodes returns the active nodes picked by exactly one of
+// --name, --pool and --all, control planes first.
+func selectReconfigureNodes(state *cluster.NodesState, name, poolName string, all bool) ([]cluster.NodeState, error) {
+	name = strings.TrimSpace(name)
+	poolName = strings.TrimSpace(poolName)
+
+	selectors := 0
+	for _, set := range []bool{name != "", poolName != "", all} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return nil, fmt.Errorf("exactly one of --name, --pool or --all is required")
+	}
+
+	var nodes []cluster.NodeState
+	for _, node := range upgradeCandidateNodes(state) {
+		switch {
+		case all:
+		case name != "" && node.Name != name:
+			continue
+		case poolName != "" && node.Pool != poolName:
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		switch {
+		case name != "":
+			return nil, fmt.Errorf("node %q is not running in cluster %q", name, state.Environment)
+		case poolName != "":
+			return nil, fmt.Errorf("pool %q has no running nodes in cluster %q", poolName, state.Environment)
+		default:
+			return nil, fmt.Errorf("no active nodes found in cluster %q", state.Environment)
+		}
+	}
+	return nodes, nil
+}
+
+// prepareNodeReconfigure loads the cluster's Talos assets and Netbird key
+// so every node is rendered exactly as `config render` would.
+func prepareNodeReconfigure(ctx context.Context, cfg *config.Config, state *cluster.NodesState) (*nodeReconfigureAssets, error) {
+	infClient, err := newInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := configRenderEndpoint(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := renderTalosAssets(ctx, cfg, endpoint, infClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets.Talosconfig) == 0 {
+		return nil, fmt.Errorf("generated talosconfig is empty")
+	}
+	netbirdSetupKey, err := loadOptionalNetbirdSetupKeyFromInfisical(ctx, cfg, infClient)
+	if err != nil {
+		return nil, fmt.Errorf("load netbird setup key: %w", err)
+	}
+
+	return &nodeReconfigureAssets{
+		talosconfig: assets.Talosconfig,
+		render: func(node cluster.NodeState) ([]byte, error) {
+			return renderPoolSlotConfig(cfg, assets, node.Name, node.Role, netbirdSetupKey)
+		},
+	}, nil
+}
+
+func nodeReconfigureKubeClient(ctx context.Context, cfgPath string) (kubernetes.Interface, error) {
+	materials, err := buildClusterAccessMaterials(ctx, "", cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("load cluster access: %w", err)
+	}
+	return kube.NewClient(materials.KubeconfigYAML)
+}
+
+// nodeReconfigure rolls rendered machine configs through nodes one at a
+// time. The drain, etcd and readiness gates are those of a rolling upgrade.
+type nodeReconfigure struct {
+	assets *nodeReconfigureAssets
+	mode   talos.ApplyMode
+	dryRun bool
+	redact bool
+	health *rollingTalosUpgrade
+}
+
+// run reconfigures the nodes in order and stops at the first failure, so a
+// bad config never reaches more than one node.
+func (r *nodeReconfigure) run(ctx context.Context, nodes []cluster.NodeState) ([]nodeReconfigureNodeResult, error) {
+	results := make([]nodeReconfigureNodeResult, 0, len(nodes))
+	for _, node := range nodes {
+		result := nodeReconfigureNodeResult{Name: node.Name, Role: node.Role, Address: node.PublicIP}
+		if err := r.reconfigureNode(ctx, node, &result); err != nil {
+			result.Status = nodeReconfigureFailed
+			result.Error = err.Error()
+			results = append(results, result)
+			return results, fmt.Errorf("reconfigure node %q: %w", node.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (r *nodeReconfigure) reconfigureNode(ctx context.Context, node cluster.NodeState, result *nodeReconfigureNodeResult) error {
+	rendered, err := r.assets.render(node)
+	if err != nil {
+		return err
+	}
+
+	var live []byte
+	if err := r.withTalosClient(node, func(client talosReconfigureClient) error {
+		live, err = client.MachineConfig(ctx)
+		return err
+	}); err != nil {
+		return fmt.Errorf("read machine config: %w", err)
+	}
+
+	// Drift in secrets alone still needs applying, even though the diff
+	// shown for it is redacted.
+	var changed bool
+	result.Diff, changed, err = diffMachineConfigs(live, rendered, node.Name, r.redact)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		slog.Info("node runs the rendered machine config", "node", node.Name)
+		result.Status = nodeReconfigureUnchanged
+		return nil
+	}
+
+	mode := r.mode
+	if mode == talos.ApplyModeAuto {
+		if err := r.withTalosClient(node, func(client talosReconfigureClient) error {
+			planned, err := client.ApplyConfigWithMode(ctx, rendered, talos.ApplyModeAuto, true)
+			if err != nil {
+				return err
+			}
+			mode = planned.Mode
+			return nil
+		}); err != nil {
+			return fmt.Errorf("plan apply mode: %w", err)
+		}
+		// Without an answer from Talos, assume the change needs a reboot.
+		if mode == talos.ApplyModeAuto {
+			mode = talos.ApplyModeReboot
+		}
+	}
+	result.Mode = string(mode)
+
+	if r.dryRun {
+		result.Status = nodeReconfigureWouldApply
+		return nil
+	}
+
+	if node.Role == config.NodeTypeControlPlane && mode != talos.ApplyModeStaged {
+		if err := r.health.checkEtcdHealthy(ctx); err != nil {
+			return fmt.Errorf("etcd is not healthy; refusing to reconfigure a control plane: %w", err)
+		}
+	}
+
+	drained := false
+	if mode == talos.ApplyModeReboot {
+		if err := r.health.drainNode(ctx, node); err != nil {
+			return err
+		}
+		drained = true
+	}
+
+	slog.Info("reconfiguring node", "node", node.Name, "mode", mode)
+	if err := r.withTalosClient(node, func(client talosReconfigureClient) error {
+		applied, err := client.ApplyConfigWithMode(ctx, rendered, mode, false)
+		if err != nil {
+			return err
+		}
+		for _, warning := range applied.Warnings {
+			slog.Warn("machine config warning", "node", node.Name, "warning", warning)
+		}
+		return nil
+	}); err != nil {
+		if drained {
+			if uncordonErr := r.health.uncordonNode(ctx, node); uncordonErr != nil {
+				return errors.Join(fmt.Errorf("apply machine config: %w", err), uncordonErr)
+			}
+		}
+		return fmt.Errorf("apply machine config: %w", err)
+	}
+
+	if mode == talos.ApplyModeStaged {
+		slog.Info("machine config staged for the next reboot", "node", node.Name)
+		result.Status = nodeReconfigureStaged
+		return nil
+	}
+
+	if err := r.verifyNode(ctx, node); err != nil {
+		return err
+	}
+	if drained {
+		if err := r.health.uncordonNode(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("node reconfigured", "node", node.Name, "mode", mode)
+	result.Status = nodeReconfigureApplied
+	return nil
+}
+
+// verifyNode waits until Talos services are healthy, etcd is healthy when
+// the node is a control plane, and Kubernetes reports the node Ready.
+func (r *nodeReconfigure) verifyNode(ctx context.Context, node cluster.NodeState) error {
+	verifyCtx, cancel := context.WithTimeout(ctx, r.health.nodeTimeout())
+	defer cancel()
+
+	if err := r.waitForTalosHealthy(verifyCtx, node); err != nil {
+		return err
+	}
+
+	if node.Role == config.NodeTypeControlPlane {
+		if err := r.health.waitForEtcdHealthy(verifyCtx); err != nil {
+			return err
+		}
+	}
+
+	deadline, _ := verifyCtx.Deadline()
+	return upgradeWaitNodeReadyFn(verifyCtx, r.health.kube, node.Name, time.Until(deadline), upgradePollInterval)
+}
+
+// waitForTalosHealthy polls the Talos health check, which fails while a
+// rebooting node is unreachable.
+func (r *nodeReconfigure) waitForTalosHealthy(ctx context.Context, node cluster.NodeState) error {
+	var lastErr error
+	for {
+		lastErr = r.withTalosClient(node, func(client talosReconfigureClient) error {
+			return client.HealthCheck(ctx)
+		})
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("talos health check: %w", lastErr)
+		case <-time.After(upgradePollInterval):
+		}
+	}
+}
+
+func (r *nodeReconfigure) withTalosClient(node cluster.NodeState, fn func(client talosReconfigureClient) error) error {
+	client, err := reconfigureTalosClientFn(node.PublicIP, r.assets.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
+
+func printNodeReconfigure(result *nodeReconfigureResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tADDRESS\tSTATUS\tMODE\tERROR")
+	for _, node := range result.Nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			node.Name,
+			node.Role,
+			node.Address,
+			node.Status,
+			defaultString(node.Mode, "-"),
+			defaultString(node.Error, "-"),
+		)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeTalosReconfigureClient serves the live config of a node and records
+// applies in the shared fakeTalosCluster event log.
+type fakeTalosReconfigureClient struct {
+	cluster  *fakeTalosCluster
+	endpoint string
+	live     map[string]string
+	modes    map[string]talos.ApplyMode
+	failNode map[string]bool
+}
+
+func (c *fakeTalosReconfigureClient) MachineConfig(ctx context.Context) ([]byte, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	return []byte(c.live[c.endpoint]), nil
+}
+
+func (c *fakeTalosReconfigureClient) ApplyConfigWithMode(ctx context.Context, configYAML []byte, mode talos.ApplyMode, dryRun bool) (*talos.ApplyResult, error) {
+	if dryRun {
+		return &talos.ApplyResult{Mode: c.modes[c.endpoint]}, nil
+	}
+	c.cluster.record("apply " + string(mode) + " " + c.endpoint)
+	if c.failNode[c.endpoint] {
+		return nil, errors.New("config validation failed")
+	}
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	c.live[c.endpoint] = string(configYAML)
+	return &talos.ApplyResult{Mode: mode}, nil
+}
+
+func (c *fakeTalosReconfigureClient) HealthCheck(ctx context.Context) error { return nil }
+
+func (c *fakeTalosReconfigureClient) Close() error { return nil }
+
+func newNodeReconfigureTest(t *testing.T, talosCluster *fakeTalosCluster, client *fakeTalosReconfigureClient) *nodeReconfigure {
+	t.Helper()
+	newRollingUpgradeTest(t, talosCluster)
+
+	original := reconfigureTalosClientFn
+	t.Cleanup(func() { reconfigureTalosClientFn = original })
+	reconfigureTalosClientFn = func(endpoint string, talosconfig []byte) (talosReconfigureClient, error) {
+		copied := *client
+		copied.endpoint = endpoint
+		return &copied, nil
+	}
+
+	nodes := rollingUpgradeTestNodes()
+	return &nodeReconfigure{
+		assets: &nodeReconfigureAssets{
+			render: func(node cluster.NodeState) ([]byte, error) {
+				return []byte("machine:\n  type: " + node.Role + "\n  network:\n    hostname: " + node.Name + "\n"), nil
+			},
+		},
+		mode:   talos.ApplyModeAuto,
+		redact: true,
+		health: &rollingTalosUpgrade{
+			kube:          fake.NewSimpleClientset(readyTestNode(nodes[0].Name), readyTestNode(nodes[1].Name), readyTestNode(nodes[2].Name)),
+			controlPlanes: controlPlaneNodes(nodes),
+			opts:          rollingUpgradeOptions{NodeTimeout: 5 * time.Second},
+		},
+	}
+}
+
+func TestNodeReconfigureAppliesDriftOneNodeAtATime(t *testing.T) {
+	talosCluster := &fakeTalosCluster{}
+	client := &fakeTalosReconfigureClient{
+		cluster: talosCluster,
+		live: map[string]string{
+			"1.1.1.1": "machine:\n  type: control-plane\n  network:\n    hostname: production-control-plane-01\n",
+			"2.2.2.2": "machine:\n  type: worker\n",
+			"3.3.3.3": "machine:\n  type: worker\n",
+		},
+		modes: map[string]talos.ApplyMode{
+			"2.2.2.2": talos.ApplyModeNoReboot,
+			"3.3.3.3": talos.ApplyModeReboot,
+		},
+	}
+	r := newNodeReconfigureTest(t, talosCluster, client)
+
+	results, err := r.run(context.Background(), rollingUpgradeTestNodes())
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.Name+"="+result.Status+"/"+result.Mode)
+	}
+	want := "production-control-plane-01=unchanged/,production-worker-01=applied/no-reboot,production-worker-02=applied/reboot"
+	if got := strings.Join(statuses, ","); got != want {
+		t.Fatalf("results = %s, want %s", got, want)
+	}
+
+	wantEvents := "apply no-reboot 2.2.2.2,cordon production-worker-02,drain production-worker-02,apply reboot 3.3.3.3,uncordon production-worker-02"
+	if got := strings.Join(talosCluster.events, ","); got != wantEvents {
+		t.Fatalf("events = %s, want %s", got, wantEvents)
+	}
+}
+
+func TestNodeReconfigureAppliesDriftInSecretsOnly(t *testing.T) {
+	extension := func(setupKey string) string {
+		return "machine:\n  type: worker\n---\napiVersion: v1alpha1\nkind: ExtensionServiceConfig\nname: netbird\nenvironment:\n  - NB_SETUP_KEY=" + setupKey + "\n"
+	}
+	talosCluster := &fakeTalosCluster{}
+	client := &fakeTalosReconfigureClient{
+		cluster: talosCluster,
+		live:    map[string]string{"2.2.2.2": extension("old-setup-key")},
+		modes:   map[string]talos.ApplyMode{"2.2.2.2": talos.ApplyModeNoReboot},
+	}
+	r := newNodeReconfigureTest(t, talosCluster, client)
+	r.assets.render = func(node cluster.NodeState) ([]byte, error) {
+		return []byte(extension("rotated-setup-key")), nil
+	}
+
+	results, err := r.run(context.Background(), rollingUpgradeTestNodes()[1:2])
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != nodeReconfigureApplied {
+		t.Fatalf("results = %+v, want the rotated setup key applied", results)
+	}
+	if results[0].Diff != secretsOnlyDiff {
+		t.Fatalf("diff = %q, want the redacted secrets-only note", results[0].Diff)
+	}
+	if got := strings.Join(talosCluster.events, ","); got != "apply no-reboot 2.2.2.2" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestNodeReconfigureStopsAtFirstFailure(t *testing.T) {
+	talosCluster := &fakeTalosCluster{}
+	client := &fakeTalosReconfigureClient{
+		cluster: talosCluster,
+		live: map[string]string{
+			"1.1.1.1": "machine: {}\n",
+			"2.2.2.2": "machine: {}\n",
+			"3.3.3.3": "machine: {}\n",
+		},
+		failNode: map[string]bool{"2.2.2.2": true},
+	}
+	r := newNodeReconfigureTest(t, talosCluster, client)
+	r.mode = talos.ApplyModeNoReboot
+
+	results, err := r.run(context.Background(), rollingUpgradeTestNodes())
+	if err == nil || !strings.Contains(err.Error(), `reconfigure node "production-worker-01"`) {
+		t.Fatalf("error = %v, want failure of production-worker-01", err)
+	}
+	if len(results) != 2 || results[1].Status != nodeReconfigureFailed {
+		t.Fatalf("results = %+v, want control plane applied and worker-01 failed", results)
+	}
+	for _, event := range talosCluster.events {
+		if strings.Contains(event, "3.3.3.3") {
+			t.Fatalf("events = %v, production-worker-02 must not be touched", talosCluster.events)
+		}
+	}
+}
+
+func TestNodeReconfigureDryRunAppliesNothing(t *testing.T) {
+	talosCluster := &fakeTalosCluster{}
+	client := &fakeTalosReconfigureClient{
+		cluster: talosCluster,
+		live:    map[string]string{"2.2.2.2": "machine:\n  type: worker\n"},
+		modes:   map[string]talos.ApplyMode{"2.2.2.2": talos.ApplyModeNoReboot},
+	}
+	r := newNodeReconfigureTest(t, talosCluster, client)
+	r.dryRun = true
+
+	results, err := r.run(context.Background(), rollingUpgradeTestNodes()[1:2])
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if results[0].Status != nodeReconfigureWouldApply || results[0].Mode != string(talos.ApplyModeNoReboot) {
+		t.Fatalf("result = %+v, want would-apply with no-reboot", results[0])
+	}
+	if !strings.Contains(results[0].Diff, "+    hostname: production-worker-01") {
+		t.Fatalf("diff = %q", results[0].Diff)
+	}
+	if len(talosCluster.events) != 0 {
+		t.Fatalf("events = %v, want nothing applied", talosCluster.events)
+	}
+}
+
+func TestSelectReconfigureNodesRequiresOneSelector(t *testing.T) {
+	state := &cluster.NodesState{Environment: "production", Nodes: []cluster.NodeState{
+		{Name: "production-worker-01", Role: config.NodeTypeWorker, Pool: "worker", PublicIP: "2.2.2.2"},
+		{Name: "production-gpu-01", Role: config.NodeTypeWorker, Pool: "gpu", PublicIP: "3.3.3.3"},
+		{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, Pool: "control-plane", PublicIP: "1.1.1.1"},
+		{Name: "production-worker-02", Role: config.NodeTypeWorker, Pool: "worker", Status: cluster.NodeStatusDeleted},
+	}}
+
+	if _, err := selectReconfigureNodes(state, "", "", false); err == nil {
+		t.Fatal("expected an error without a selector")
+	}
+	if _, err := selectReconfigureNodes(state, "production-worker-01", "", true); err == nil {
+		t.Fatal("expected an error with two selectors")
+	}
+	if _, err := selectReconfigureNodes(state, "production-worker-02", "", false); err == nil {
+		t.Fatal("expected an error for a deleted node")
+	}
+
+	nodes, err := selectReconfigureNodes(state, "", "", true)
+	if err != nil {
+		t.Fatalf("selectReconfigureNodes returned error: %v", err)
+	}
+	if len(nodes) != 3 || nodes[0].Name != "production-control-plane-01" {
+		t.Fatalf("nodes = %+v, want control plane first", nodes)
+	}
+
+	nodes, err = selectReconfigureNodes(state, "", "worker", false)
+	if err != nil {
+		t.Fatalf("selectReconfigureNodes returned error: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "production-worker-01" {
+		t.Fatalf("nodes = %+v, want only production-worker-01", nodes)
+	}
+}
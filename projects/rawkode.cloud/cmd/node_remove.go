@@ -0,0 +1,555 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	nodeRemovePhasePreFlight        = "pre-flight"
+	nodeRemovePhaseDrain            = "drain"
+	nodeRemovePhaseReset            = "reset"
+	nodeRemovePhaseRemoveEtcdMember = "remove-etcd-member"
+	nodeRemovePhaseDeleteNode       = "delete-node"
+	nodeRemovePhaseReleaseServer    = "release-server"
+)
+
+// talosNodeRemovalClient is the part of the Talos client node removal uses.
+type talosNodeRemovalClient interface {
+	Reset(ctx context.Context) error
+	EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error)
+	EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error)
+	EtcdRemoveMember(ctx context.Context, memberID string) error
+	Close() error
+}
+
+var (
+	nodeRemoveAccessMaterialsFn = buildClusterAccessMaterialsExcluding
+	nodeRemoveKubeClientFn      = kube.NewClient
+	nodeRemoveTalosClientFn     = func(endpoint string, talosconfig []byte) (talosNodeRemovalClient, error) {
+		return talos.NewClient(endpoint, talosconfig)
+	}
+	nodeRemoveLoadNodeStateFn = loadNodeState
+	nodeRemoveCordonFn        = kube.Cordon
+	nodeRemoveDrainFn         = kube.Drain
+	nodeRemoveReleaseServerFn = deleteClusterNodeServer
+)
+
+// nodeRemovePhaseData is recorded on every completed node removal phase.
+type nodeRemovePhaseData struct {
+	Skipped bool `json:"skipped,omitempty"`
+	// Forced holds the error --force continued past, if any.
+	Forced string `json:"forced,omitempty"`
+}
+
+// nodeRemovePhases returns the phases removing a node of the given role.
+// Only control planes have an etcd member to remove.
+func nodeRemovePhases(role string) []string {
+	phases := []string{nodeRemovePhasePreFlight, nodeRemovePhaseDrain, nodeRemovePhaseReset}
+	if role == config.NodeTypeControlPlane {
+		phases = append(phases, nodeRemovePhaseRemoveEtcdMember)
+	}
+	return append(phases, nodeRemovePhaseDeleteNode, nodeRemovePhaseReleaseServer)
+}
+
+// runNodeRemove starts a remove-node operation, or continues the in-flight
+// one for the same node.
+func runNodeRemove(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	name, _ := cmd.Flags().GetString("name")
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+	force, _ := cmd.Flags().GetBool("force")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, operation.TypeRemoveNode)
+	if err != nil {
+		return fmt.Errorf("look up in-flight %s operation: %w", operation.TypeRemoveNode, err)
+	}
+	if op != nil && op.GetContextString("nodeName") != name {
+		return fmt.Errorf(
+			"operation %s is still removing node %q; resume or abort it before removing %q",
+			op.ID, op.GetContextString("nodeName"), name,
+		)
+	}
+	if op == nil {
+		state, err := nodeRemoveLoadNodeStateFn(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		node, ok := findNodeByName(state, name)
+		if !ok {
+			return fmt.Errorf("node %q not found in Scaleway inventory", name)
+		}
+		if node.Status == cluster.NodeStatusDeleted {
+			result := nodeRemoveResultFromNode(cfg, cfgPath, *node)
+			result.AlreadyDeleted = true
+			return emitResult(cmd, result, func() {
+				fmt.Printf("Node %q is already marked deleted.\n", name)
+			})
+		}
+
+		op = newNodeRemoveOperation(cfg, cfgPath, *node, drainTimeout, force)
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
+	} else {
+		slog.Info("resuming in-flight node removal", "operation", op.ID, "node", name, "resume_from", op.ResumePhase())
+		if force && op.GetContextString("force") != "true" {
+			op.SetContext("force", "true")
+			if err := saveOperation(ctx, store, op); err != nil {
+				return err
+			}
+		}
+	}
+	if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+		return fmt.Errorf("record operation on cluster lock: %w", err)
+	}
+
+	slog.Info("starting node removal",
+		"operation", op.ID,
+		"cluster", cfg.Environment,
+		"node", name,
+		"role", op.GetContextString("role"),
+		"resume_from", op.ResumePhase(),
+	)
+
+	result, err := executeNodeRemove(ctx, store, op, cfg)
+	if err != nil {
+		err = fmt.Errorf("node removal aborted (resume with `operation resume --id %s`): %w", op.ID, err)
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printForcedNodeRemovePhases(op, result.ForcedPhases)
+		fmt.Printf("Removed node %q from cluster %q (config=%s)\n", name, cfg.Environment, result.ConfigPath)
+	})
+}
+
+func newNodeRemoveOperation(cfg *config.Config, cfgPath string, node cluster.NodeState, drainTimeout time.Duration, force bool) *operation.Operation {
+	op := operation.New(operation.GenerateID(), operation.TypeRemoveNode, cfg.Environment, nodeRemovePhases(node.Role))
+	op.SetContext("nodeName", node.Name)
+	op.SetContext("role", node.Role)
+	op.SetContext("pool", node.Pool)
+	op.SetContext("serverID", node.ServerID)
+	op.SetContext("publicIP", node.PublicIP)
+	op.SetContext("privateIP", node.PrivateIP)
+	op.SetContext("configPath", cfgPath)
+	op.SetContext("drainTimeout", drainTimeout.String())
+	if force {
+		op.SetContext("force", "true")
+	}
+	return op
+}
+
+// nodeRemoveResult is the structured output of `node remove`.
+type nodeRemoveResult struct {
+	Cluster        string   `json:"cluster"`
+	ConfigPath     string   `json:"configPath"`
+	OperationID    string   `json:"operationId,omitempty"`
+	Name           string   `json:"name"`
+	Role           string   `json:"role"`
+	Pool           string   `json:"pool,omitempty"`
+	ServerID       string   `json:"serverId,omitempty"`
+	PublicIP       string   `json:"publicIp,omitempty"`
+	PrivateIP      string   `json:"privateIp,omitempty"`
+	AlreadyDeleted bool     `json:"alreadyDeleted,omitempty"`
+	ForcedPhases   []string `json:"forcedPhases,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+func nodeRemoveResultFromNode(cfg *config.Config, cfgPath string, node cluster.NodeState) nodeRemoveResult {
+	return nodeRemoveResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: cfgPath,
+		Name:       node.Name,
+		Role:       node.Role,
+		Pool:       node.Pool,
+		ServerID:   node.ServerID,
+		PublicIP:   node.PublicIP,
+		PrivateIP:  node.PrivateIP,
+	}
+}
+
+func nodeRemoveResultFromOperation(op *operation.Operation, cfg *config.Config) *nodeRemoveResult {
+	result := nodeRemoveResultFromNode(cfg, op.GetContextString("configPath"), nodeRemoveTarget(op))
+	result.OperationID = op.ID
+	result.ForcedPhases = forcedNodeRemovePhases(op)
+	return &result
+}
+
+// forcedNodeRemovePhases returns the phases --force continued past.
+func forcedNodeRemovePhases(op *operation.Operation) []string {
+	var phases []string
+	for _, phase := range op.PhaseOrder {
+		var data nodeRemovePhaseData
+		if err := op.PhaseData(phase, &data); err == nil && data.Forced != "" {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+func printForcedNodeRemovePhases(op *operation.Operation, phases []string) {
+	for _, phase := range phases {
+		var data nodeRemovePhaseData
+		_ = op.PhaseData(phase, &data)
+		fmt.Printf("Warning: continued past failed %s phase: %s\n", phase, data.Forced)
+	}
+}
+
+// nodeRemoveTarget rebuilds the node being removed from the operation, as
+// the inventory stops listing it once its server is released.
+func nodeRemoveTarget(op *operation.Operation) cluster.NodeState {
+	return cluster.NodeState{
+		Name:      op.GetContextString("nodeName"),
+		Role:      op.GetContextString("role"),
+		Pool:      op.GetContextString("pool"),
+		ServerID:  op.GetContextString("serverID"),
+		PublicIP:  op.GetContextString("publicIP"),
+		PrivateIP: op.GetContextString("privateIP"),
+	}
+}
+
+// nodeRemoveExecution holds what a remove-node operation needs to run its
+// remaining phases. It is rebuilt from the operation on every run.
+type nodeRemoveExecution struct {
+	store operation.Store
+	op    *operation.Operation
+	cfg   *config.Config
+	node  cluster.NodeState
+
+	// controlPlanes are the active control planes other than node.
+	controlPlanes []cluster.NodeState
+	kube          kubernetes.Interface
+	talosconfig   []byte
+	drainTimeout  time.Duration
+}
+
+// executeNodeRemove runs the remaining phases of a remove-node operation.
+func executeNodeRemove(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*nodeRemoveResult, error) {
+	exec, err := newNodeRemoveExecution(ctx, store, op, cfg, nodeRemoveTarget(op))
+	if err != nil {
+		result := nodeRemoveResultFromOperation(op, cfg)
+		result.Error = err.Error()
+		return result, err
+	}
+
+	for {
+		phase := op.ResumePhase()
+		if phase == "" {
+			return nodeRemoveResultFromOperation(op, cfg), nil
+		}
+		if err := exec.runPhase(ctx, phase); err != nil {
+			result := nodeRemoveResultFromOperation(op, cfg)
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+}
+
+// newNodeRemoveExecution prepares the removal of node. Operations that do
+// more than remove a node record it under their own context keys.
+func newNodeRemoveExecution(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config, node cluster.NodeState) (*nodeRemoveExecution, error) {
+	if node.Name == "" {
+		return nil, fmt.Errorf("operation %s does not record the node to remove", op.ID)
+	}
+
+	drainTimeout := 10 * time.Minute
+	if value := op.GetContextString("drainTimeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse drainTimeout from operation context: %w", err)
+		}
+		drainTimeout = parsed
+	}
+
+	state, err := nodeRemoveLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var controlPlanes []cluster.NodeState
+	for _, controlPlane := range activeNodesByRole(state, config.NodeTypeControlPlane) {
+		if controlPlane.Name != node.Name {
+			controlPlanes = append(controlPlanes, *controlPlane)
+		}
+	}
+
+	// Reach the cluster through another control plane: the API server and
+	// Talos endpoint of the node being removed go away with it.
+	materials, err := nodeRemoveAccessMaterialsFn(ctx, "", op.GetContextString("configPath"), node.Name)
+	if err != nil {
+		return nil, fmt.Errorf("load cluster access: %w", err)
+	}
+	kubeClient, err := nodeRemoveKubeClientFn(materials.KubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nodeRemoveExecution{
+		store:         store,
+		op:            op,
+		cfg:           cfg,
+		node:          node,
+		controlPlanes: controlPlanes,
+		kube:          kubeClient,
+		talosconfig:   materials.TalosconfigYAML,
+		drainTimeout:  drainTimeout,
+	}, nil
+}
+
+func (e *nodeRemoveExecution) force() bool {
+	return e.op.GetContextString("force") == "true"
+}
+
+// runPhase runs a single phase. With --force, a failed drain or reset is
+// recorded on the phase and removal continues, so an unreachable node can
+// still be removed; the pre-flight guards are never bypassed.
+func (e *nodeRemoveExecution) runPhase(ctx context.Context, phase string) error {
+	slog.Info("executing phase", "phase", phase, "operation", e.op.ID, "node", e.node.Name)
+
+	if err := startOperationPhase(ctx, e.store, e.op, phase); err != nil {
+		return err
+	}
+
+	var (
+		data     nodeRemovePhaseData
+		phaseErr error
+	)
+	switch phase {
+	case nodeRemovePhasePreFlight:
+		phaseErr = e.preFlight(ctx)
+	case nodeRemovePhaseDrain:
+		data.Skipped, phaseErr = e.drain(ctx)
+	case nodeRemovePhaseReset:
+		phaseErr = e.reset(ctx)
+	case nodeRemovePhaseRemoveEtcdMember:
+		data.Skipped, phaseErr = e.removeEtcdMember(ctx)
+	case nodeRemovePhaseDeleteNode:
+		data.Skipped, phaseErr = e.deleteNode(ctx)
+	case nodeRemovePhaseReleaseServer:
+		data.Skipped, phaseErr = e.releaseServer(ctx)
+	default:
+		phaseErr = fmt.Errorf("unknown phase %q", phase)
+	}
+	if phaseErr != nil && e.force() && (phase == nodeRemovePhaseDrain || phase == nodeRemovePhaseReset) {
+		slog.Warn("continuing past failed phase", "phase", phase, "node", e.node.Name, "error", phaseErr)
+		data.Forced = phaseErr.Error()
+		phaseErr = nil
+	}
+	if phaseErr != nil {
+		return failOperationPhase(ctx, e.store, e.op, phase, phaseErr)
+	}
+
+	if err := e.op.CompletePhase(phase, data); err != nil {
+		return fmt.Errorf("complete phase %s: %w", phase, err)
+	}
+	return saveOperation(ctx, e.store, e.op)
+}
+
+// preFlight refuses to remove the last control plane, or a control plane
+// whose removal would leave etcd without a healthy quorum.
+func (e *nodeRemoveExecution) preFlight(ctx context.Context) error {
+	if e.node.Role != config.NodeTypeControlPlane {
+		return nil
+	}
+	if len(e.controlPlanes) == 0 {
+		return fmt.Errorf("refusing to remove %q: it is the last control plane", e.node.Name)
+	}
+
+	members, err := e.etcdMembers(ctx)
+	if err != nil {
+		return err
+	}
+	remaining := make(map[string]bool, len(members))
+	for _, member := range members {
+		if member.Hostname != e.node.Name {
+			remaining[member.Hostname] = true
+		}
+	}
+	if len(remaining) == 0 {
+		return fmt.Errorf("refusing to remove %q: it is the only etcd member", e.node.Name)
+	}
+
+	healthy := 0
+	var errs []error
+	for _, controlPlane := range e.controlPlanes {
+		if !remaining[controlPlane.Name] {
+			continue
+		}
+		err := e.withTalosClient(controlPlane, func(client talosNodeRemovalClient) error {
+			status, err := client.EtcdStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if len(status.Errors) > 0 {
+				return fmt.Errorf("member reports errors: %v", status.Errors)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", controlPlane.Name, err))
+			continue
+		}
+		healthy++
+	}
+
+	quorum := len(remaining)/2 + 1
+	if healthy < quorum {
+		return fmt.Errorf(
+			"refusing to remove %q: %d/%d remaining etcd members healthy, quorum needs %d: %w",
+			e.node.Name, healthy, len(remaining), quorum, errors.Join(errs...),
+		)
+	}
+	return nil
+}
+
+// drain cordons the node and evicts its pods, respecting
+// PodDisruptionBudgets. A node Kubernetes does not know is skipped.
+func (e *nodeRemoveExecution) drain(ctx context.Context) (bool, error) {
+	if _, err := e.kube.CoreV1().Nodes().Get(ctx, e.node.Name, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			slog.Info("node not registered in Kubernetes; skipping drain", "node", e.node.Name)
+			return true, nil
+		}
+		return false, fmt.Errorf("get node %s: %w", e.node.Name, err)
+	}
+
+	if err := nodeRemoveCordonFn(ctx, e.kube, e.node.Name, true); err != nil {
+		return false, err
+	}
+	if err := nodeRemoveDrainFn(ctx, e.kube, e.node.Name, kube.DrainOptions{Timeout: e.drainTimeout}); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// reset gracefully resets the node. For a control plane Talos leaves etcd
+// as part of a graceful reset.
+func (e *nodeRemoveExecution) reset(ctx context.Context) error {
+	return e.withTalosClient(e.node, func(client talosNodeRemovalClient) error {
+		return client.Reset(ctx)
+	})
+}
+
+// removeEtcdMember removes the node's etcd member if it is still listed,
+// which happens when the reset was forced past an unreachable node.
+func (e *nodeRemoveExecution) removeEtcdMember(ctx context.Context) (bool, error) {
+	members, err := e.etcdMembers(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, member := range members {
+		if member.Hostname != e.node.Name {
+			continue
+		}
+		memberID := strconv.FormatUint(member.ID, 10)
+		slog.Info("removing etcd member", "node", e.node.Name, "member_id", memberID)
+		return false, e.withAnyControlPlane(func(client talosNodeRemovalClient) error {
+			return client.EtcdRemoveMember(ctx, memberID)
+		})
+	}
+
+	slog.Info("etcd member already gone", "node", e.node.Name)
+	return true, nil
+}
+
+// deleteNode deletes the Kubernetes Node object.
+func (e *nodeRemoveExecution) deleteNode(ctx context.Context) (bool, error) {
+	err := e.kube.CoreV1().Nodes().Delete(ctx, e.node.Name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("delete node %s: %w", e.node.Name, err)
+	}
+	return false, nil
+}
+
+// releaseServer releases the node's Scaleway server.
+func (e *nodeRemoveExecution) releaseServer(ctx context.Context) (bool, error) {
+	if strings.TrimSpace(e.node.ServerID) == "" {
+		return true, nil
+	}
+	return false, nodeRemoveReleaseServerFn(ctx, e.cfg, e.node, e.node.ServerID)
+}
+
+// etcdMembers lists etcd members through the first control plane other than
+// the node being removed that answers.
+func (e *nodeRemoveExecution) etcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	var members []talos.EtcdMember
+	err := e.withAnyControlPlane(func(client talosNodeRemovalClient) error {
+		var err error
+		members, err = client.EtcdMembers(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list etcd members: %w", err)
+	}
+	return members, nil
+}
+
+func (e *nodeRemoveExecution) withAnyControlPlane(fn func(client talosNodeRemovalClient) error) error {
+	if len(e.controlPlanes) == 0 {
+		return fmt.Errorf("no other control plane available")
+	}
+
+	var errs []error
+	for _, controlPlane := range e.controlPlanes {
+		err := e.withTalosClient(controlPlane, fn)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", controlPlane.Name, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (e *nodeRemoveExecution) withTalosClient(node cluster.NodeState, fn func(client talosNodeRemovalClient) error) error {
+	client, err := nodeRemoveTalosClientFn(node.PublicIP, e.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restoreNodeRemoveFns() func() {
+	accessMaterials := nodeRemoveAccessMaterialsFn
+	kubeClient := nodeRemoveKubeClientFn
+	talosClient := nodeRemoveTalosClientFn
+	loadNodeState := nodeRemoveLoadNodeStateFn
+	cordon := nodeRemoveCordonFn
+	drain := nodeRemoveDrainFn
+	releaseServer := nodeRemoveReleaseServerFn
+
+	return func() {
+		nodeRemoveAccessMaterialsFn = accessMaterials
+		nodeRemoveKubeClientFn = kubeClient
+		nodeRemoveTalosClientFn = talosClient
+		nodeRemoveLoadNodeStateFn = loadNodeState
+		nodeRemoveCordonFn = cordon
+		nodeRemoveDrainFn = drain
+		nodeRemoveReleaseServerFn = releaseServer
+	}
+}
+
+// fakeEtcdCluster tracks etcd membership by node name and records every
+// call made while removing a node.
+type fakeEtcdCluster struct {
+	mu        sync.Mutex
+	members   map[string]uint64
+	unhealthy map[string]bool
+	failReset bool
+	events    []string
+}
+
+func (f *fakeEtcdCluster) record(event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
+type fakeTalosNodeRemovalClient struct {
+	cluster  *fakeEtcdCluster
+	endpoint string
+	name     string
+}
+
+func (c *fakeTalosNodeRemovalClient) Reset(ctx context.Context) error {
+	c.cluster.record("reset " + c.name)
+	if c.cluster.failReset {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c *fakeTalosNodeRemovalClient) EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	var members []talos.EtcdMember
+	for name, id := range c.cluster.members {
+		members = append(members, talos.EtcdMember{ID: id, Hostname: name})
+	}
+	return members, nil
+}
+
+func (c *fakeTalosNodeRemovalClient) EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	if c.cluster.unhealthy[c.name] {
+		return nil, errors.New("context deadline exceeded")
+	}
+	return &talos.EtcdMemberStatus{}, nil
+}
+
+func (c *fakeTalosNodeRemovalClient) EtcdRemoveMember(ctx context.Context, memberID string) error {
+	c.cluster.record("remove-member " + memberID)
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	for name, id := range c.cluster.members {
+		if memberID == strconv.FormatUint(id, 10) {
+			delete(c.cluster.members, name)
+		}
+	}
+	return nil
+}
+
+func (c *fakeTalosNodeRemovalClient) Close() error {
+	return nil
+}
+
+func nodeRemoveTestNodes() []cluster.NodeState {
+	return []cluster.NodeState{
+		{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, PublicIP: "1.1.1.1", ServerID: "srv-1"},
+		{Name: "production-control-plane-02", Role: config.NodeTypeControlPlane, PublicIP: "2.2.2.2", ServerID: "srv-2"},
+		{Name: "production-control-plane-03", Role: config.NodeTypeControlPlane, PublicIP: "3.3.3.3", ServerID: "srv-3"},
+		{Name: "production-worker-01", Role: config.NodeTypeWorker, PublicIP: "4.4.4.4", ServerID: "srv-4"},
+	}
+}
+
+// newNodeRemoveTest stubs every seam of node removal and records an
+// operation removing the named node.
+func newNodeRemoveTest(t *testing.T, nodes []cluster.NodeState, etcd *fakeEtcdCluster, kubeClient kubernetes.Interface, name string) (operation.Store, *operation.Operation) {
+	t.Helper()
+	t.Cleanup(restoreNodeRemoveFns())
+
+	names := map[string]string{}
+	for _, node := range nodes {
+		names[node.PublicIP] = node.Name
+	}
+
+	nodeRemoveLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*cluster.NodesState, error) {
+		return &cluster.NodesState{Environment: cfg.Environment, Nodes: nodes}, nil
+	}
+	nodeRemoveAccessMaterialsFn = func(ctx context.Context, clusterName, cfgFile, excludeNode string) (*clusterAccessMaterials, error) {
+		if excludeNode != name {
+			t.Errorf("access materials exclude %q, want %q", excludeNode, name)
+		}
+		return &clusterAccessMaterials{ConfigPath: cfgFile}, nil
+	}
+	nodeRemoveKubeClientFn = func(kubeconfigYAML []byte) (kubernetes.Interface, error) {
+		return kubeClient, nil
+	}
+	nodeRemoveTalosClientFn = func(endpoint string, talosconfig []byte) (talosNodeRemovalClient, error) {
+		return &fakeTalosNodeRemovalClient{cluster: etcd, endpoint: endpoint, name: names[endpoint]}, nil
+	}
+	nodeRemoveCordonFn = func(ctx context.Context, client kubernetes.Interface, nodeName string, unschedulable bool) error {
+		etcd.record("cordon " + nodeName)
+		return nil
+	}
+	nodeRemoveDrainFn = func(ctx context.Context, client kubernetes.Interface, nodeName string, opts kube.DrainOptions) error {
+		etcd.record("drain " + nodeName)
+		return nil
+	}
+	nodeRemoveReleaseServerFn = func(ctx context.Context, cfg *config.Config, node cluster.NodeState, serverID string) error {
+		etcd.record("release " + serverID)
+		return nil
+	}
+
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	node, ok := findNodeByName(&cluster.NodesState{Nodes: nodes}, name)
+	if !ok {
+		t.Fatalf("node %q not in test inventory", name)
+	}
+	op := operation.New("op-remove", operation.TypeRemoveNode, "production", nodeRemovePhases(node.Role))
+	op.SetContext("nodeName", node.Name)
+	op.SetContext("role", node.Role)
+	op.SetContext("serverID", node.ServerID)
+	op.SetContext("publicIP", node.PublicIP)
+	op.SetContext("drainTimeout", "1s")
+	if err := store.Save(context.Background(), op); err != nil {
+		t.Fatalf("save operation: %v", err)
+	}
+
+	return store, op
+}
+
+func testEtcdCluster() *fakeEtcdCluster {
+	return &fakeEtcdCluster{members: map[string]uint64{
+		"production-control-plane-01": 1,
+		"production-control-plane-02": 2,
+		"production-control-plane-03": 3,
+	}}
+}
+
+func TestExecuteNodeRemoveControlPlaneOrder(t *testing.T) {
+	etcd := testEtcdCluster()
+	nodes := nodeRemoveTestNodes()
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "production-control-plane-02"}})
+	store, op := newNodeRemoveTest(t, nodes, etcd, kubeClient, "production-control-plane-02")
+
+	result, err := executeNodeRemove(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err != nil {
+		t.Fatalf("executeNodeRemove returned error: %v", err)
+	}
+
+	want := []string{
+		"cordon production-control-plane-02",
+		"drain production-control-plane-02",
+		"reset production-control-plane-02",
+		"remove-member 2",
+		"release srv-2",
+	}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+	if _, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "production-control-plane-02", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("Node object still present: %v", err)
+	}
+	if !op.IsComplete() || result.OperationID != "op-remove" {
+		t.Fatalf("operation complete = %t, result operation = %q", op.IsComplete(), result.OperationID)
+	}
+}
+
+func TestExecuteNodeRemoveRefusesLastControlPlaneAndQuorumLoss(t *testing.T) {
+	nodes := nodeRemoveTestNodes()
+
+	etcd := &fakeEtcdCluster{members: map[string]uint64{"production-control-plane-01": 1}}
+	store, op := newNodeRemoveTest(t, nodes[:1], etcd, fake.NewSimpleClientset(), "production-control-plane-01")
+	_, err := executeNodeRemove(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err == nil || !strings.Contains(err.Error(), "last control plane") {
+		t.Fatalf("executeNodeRemove error = %v, want last control plane refusal", err)
+	}
+
+	etcd = testEtcdCluster()
+	etcd.unhealthy = map[string]bool{"production-control-plane-03": true}
+	store, op = newNodeRemoveTest(t, nodes, etcd, fake.NewSimpleClientset(), "production-control-plane-02")
+	op.SetContext("force", "true")
+	_, err = executeNodeRemove(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err == nil || !strings.Contains(err.Error(), "quorum needs 2") {
+		t.Fatalf("executeNodeRemove error = %v, want quorum refusal", err)
+	}
+	if len(etcd.events) != 0 {
+		t.Fatalf("node was touched despite failed pre-flight: %v", etcd.events)
+	}
+}
+
+func TestExecuteNodeRemoveResumesFromFailedPhase(t *testing.T) {
+	etcd := testEtcdCluster()
+	etcd.failReset = true
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "production-worker-01"}})
+	store, op := newNodeRemoveTest(t, nodeRemoveTestNodes(), etcd, kubeClient, "production-worker-01")
+
+	if _, err := executeNodeRemove(context.Background(), store, op, &config.Config{Environment: "production"}); err == nil {
+		t.Fatal("expected reset failure")
+	}
+	if got := op.ResumePhase(); got != nodeRemovePhaseReset {
+		t.Fatalf("ResumePhase() = %q, want %q", got, nodeRemovePhaseReset)
+	}
+
+	etcd.events = nil
+	op.SetContext("force", "true")
+	result, err := executeNodeRemove(context.Background(), store, op, &config.Config{Environment: "production"})
+	if err != nil {
+		t.Fatalf("resumed executeNodeRemove returned error: %v", err)
+	}
+
+	want := []string{"reset production-worker-01", "release srv-4"}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+	if len(result.ForcedPhases) != 1 || result.ForcedPhases[0] != nodeRemovePhaseReset {
+		t.Fatalf("ForcedPhases = %v, want [reset]", result.ForcedPhases)
+	}
+}
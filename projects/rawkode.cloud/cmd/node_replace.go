@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+const (
+	nodeReplacePhaseOrderServer = "order-server"
+	nodeReplacePhaseWaitServer  = "wait-server"
+	nodeReplacePhaseWaitTalos   = "wait-talos"
+	nodeReplacePhaseApplyConfig = "apply-config"
+	nodeReplacePhaseWaitReady   = "wait-ready"
+)
+
+var nodeReplaceCmd = &cobra.Command{
+	Use:   "replace",
+	Short: "Replace a node's server, keeping its pool slot",
+	RunE:  runNodeReplace,
+}
+
+var (
+	nodeReplaceOrderServerFn   = phaseOrderServer
+	nodeReplaceWaitServerFn    = phaseWaitServer
+	nodeReplaceWaitTalosFn     = phaseWaitTalos
+	nodeReplaceApplyConfigFn   = applyNodeAddConfig
+	nodeReplaceWaitNodeReadyFn = kube.WaitForNodeReady
+	nodeReplacePollInterval    = 10 * time.Second
+)
+
+// nodeReplacePhases returns the phases replacing a node of the given role.
+// The old server is removed before the new one is ordered: the slot's node
+// name and reserved private IP can only belong to one machine at a time.
+func nodeReplacePhases(role string) []string {
+	return append(nodeRemovePhases(role),
+		nodeReplacePhaseOrderServer,
+		nodeReplacePhaseWaitServer,
+		nodeReplacePhaseWaitTalos,
+		nodeReplacePhaseApplyConfig,
+		nodeReplacePhaseWaitReady,
+	)
+}
+
+// runNodeReplace starts a replace-node operation, or continues the in-flight
+// one for the same node.
+func runNodeReplace(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	name, _ := cmd.Flags().GetString("name")
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+	nodeTimeout, _ := cmd.Flags().GetDuration("node-timeout")
+	force, _ := cmd.Flags().GetBool("force")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, operation.TypeReplaceNode)
+	if err != nil {
+		return fmt.Errorf("look up in-flight %s operation: %w", operation.TypeReplaceNode, err)
+	}
+	if op != nil && op.GetContextString("nodeName") != name {
+		return fmt.Errorf(
+			"operation %s is still replacing node %q; resume or abort it before replacing %q",
+			op.ID, op.GetContextString("nodeName"), name,
+		)
+	}
+	if op == nil {
+		state, err := nodeRemoveLoadNodeStateFn(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		node, ok := findNodeByName(state, name)
+		if !ok || node.Status == cluster.NodeStatusDeleted {
+			return fmt.Errorf("node %q not found in Scaleway inventory", name)
+		}
+
+		op, err = newNodeReplaceOperation(cfg, cfgPath, *node)
+		if err != nil {
+			return err
+		}
+		op.SetContext("drainTimeout", drainTimeout.String())
+		op.SetContext("nodeTimeout", nodeTimeout.String())
+		if force {
+			op.SetContext("force", "true")
+		}
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
+	} else {
+		slog.Info("resuming in-flight node replacement", "operation", op.ID, "node", name, "resume_from", op.ResumePhase())
+		if force && op.GetContextString("force") != "true" {
+			op.SetContext("force", "true")
+			if err := saveOperation(ctx, store, op); err != nil {
+				return err
+			}
+		}
+	}
+	if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+		return fmt.Errorf("record operation on cluster lock: %w", err)
+	}
+
+	slog.Info("starting node replacement",
+		"operation", op.ID,
+		"cluster", cfg.Environment,
+		"node", name,
+		"role", op.GetContextString("role"),
+		"resume_from", op.ResumePhase(),
+	)
+
+	result, err := executeNodeReplace(ctx, store, op, cfg)
+	if err != nil {
+		err = fmt.Errorf("node replacement aborted (resume with `operation resume --id %s`): %w", op.ID, err)
+		result.Error = err.Error()
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printForcedNodeRemovePhases(op, result.ForcedPhases)
+		fmt.Printf("Replaced node %q in cluster %q (config=%s, old_server=%s, server=%s, public_ip=%s, private_ip=%s)\n",
+			result.Name, result.Cluster, result.ConfigPath,
+			result.ReplacedServerID, result.ServerID, result.PublicIP, result.PrivateIP,
+		)
+	})
+}
+
+// newNodeReplaceOperation records the node being replaced and the pool slot
+// its replacement is provisioned into. The context keys read by the shared
+// provisioning phases (nodeName, role, poolName, privateIP, serverId,
+// publicIP) describe the new server; the old one is kept under replaced*.
+func newNodeReplaceOperation(cfg *config.Config, cfgPath string, node cluster.NodeState) (*operation.Operation, error) {
+	var (
+		pool *config.NodePoolConfig
+		err  error
+	)
+	if strings.TrimSpace(node.Pool) != "" {
+		pool, err = cfg.FindNodePool(node.Pool)
+	} else {
+		pool, err = cfg.FirstNodePoolByType(node.Role)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve node pool for node %q: %w", node.Name, err)
+	}
+
+	slot, ok := parsePooledNodeSlot(cfg.Environment, pool.Name, node.Name)
+	if !ok {
+		return nil, fmt.Errorf("node %q does not occupy a slot of pool %q; use `node remove` and `node add` instead", node.Name, pool.Name)
+	}
+
+	op := operation.New(operation.GenerateID(), operation.TypeReplaceNode, cfg.Environment, nodeReplacePhases(node.Role))
+	op.SetContext("nodeName", node.Name)
+	op.SetContext("role", node.Role)
+	op.SetContext("poolName", pool.Name)
+	op.SetContext("configPath", cfgPath)
+	op.SetContext("replacedServerID", node.ServerID)
+	op.SetContext("replacedPublicIP", node.PublicIP)
+	op.SetContext("replacedPrivateIP", node.PrivateIP)
+
+	if node.Role == config.NodeTypeControlPlane {
+		privateIP, err := controlPlaneReservedIPForSlot(pool, slot)
+		if err != nil {
+			return nil, err
+		}
+		if privateIP != "" {
+			op.SetContext("privateIP", privateIP)
+		}
+	}
+
+	return op, nil
+}
+
+// replacedNode rebuilds the node being replaced from the operation.
+func replacedNode(op *operation.Operation) cluster.NodeState {
+	return cluster.NodeState{
+		Name:      op.GetContextString("nodeName"),
+		Role:      op.GetContextString("role"),
+		Pool:      op.GetContextString("poolName"),
+		ServerID:  op.GetContextString("replacedServerID"),
+		PublicIP:  op.GetContextString("replacedPublicIP"),
+		PrivateIP: op.GetContextString("replacedPrivateIP"),
+	}
+}
+
+// replacementNode rebuilds the node's new server from the operation.
+func replacementNode(op *operation.Operation) cluster.NodeState {
+	return cluster.NodeState{
+		Name:      op.GetContextString("nodeName"),
+		Role:      op.GetContextString("role"),
+		Pool:      op.GetContextString("poolName"),
+		ServerID:  op.GetContextString("serverId"),
+		PublicIP:  op.GetContextString("publicIP"),
+		PrivateIP: op.GetContextString("privateIP"),
+	}
+}
+
+// nodeReplaceResult is the structured output of `node replace`.
+type nodeReplaceResult struct {
+	Cluster          string   `json:"cluster"`
+	ConfigPath       string   `json:"configPath"`
+	OperationID      string   `json:"operationId"`
+	Name             string   `json:"name"`
+	Role             string   `json:"role"`
+	Pool             string   `json:"pool,omitempty"`
+	ReplacedServerID string   `json:"replacedServerId,omitempty"`
+	ServerID         string   `json:"serverId,omitempty"`
+	PublicIP         string   `json:"publicIp,omitempty"`
+	PrivateIP        string   `json:"privateIp,omitempty"`
+	ForcedPhases     []string `json:"forcedPhases,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+func nodeReplaceResultFromOperation(op *operation.Operation, cfg *config.Config) *nodeReplaceResult {
+	node := replacementNode(op)
+	return &nodeReplaceResult{
+		Cluster:          cfg.Environment,
+		ConfigPath:       op.GetContextString("configPath"),
+		OperationID:      op.ID,
+		Name:             node.Name,
+		Role:             node.Role,
+		Pool:             node.Pool,
+		ReplacedServerID: op.GetContextString("replacedServerID"),
+		ServerID:         node.ServerID,
+		PublicIP:         node.PublicIP,
+		PrivateIP:        node.PrivateIP,
+		ForcedPhases:     forcedNodeRemovePhases(op),
+	}
+}
+
+// nodeReplaceExecution runs a replace-node operation: the removal phases
+// are delegated to a node removal of the old server.
+type nodeReplaceExecution struct {
+	remove      *nodeRemoveExecution
+	nodeTimeout time.Duration
+}
+
+// executeNodeReplace runs the remaining phases of a replace-node operation.
+func executeNodeReplace(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*nodeReplaceResult, error) {
+	exec, err := newNodeReplaceExecution(ctx, store, op, cfg)
+	if err != nil {
+		result := nodeReplaceResultFromOperation(op, cfg)
+		result.Error = err.Error()
+		return result, err
+	}
+
+	for {
+		phase := op.ResumePhase()
+		if phase == "" {
+			return nodeReplaceResultFromOperation(op, cfg), nil
+		}
+		if err := exec.runPhase(ctx, phase); err != nil {
+			result := nodeReplaceResultFromOperation(op, cfg)
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+}
+
+func newNodeReplaceExecution(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*nodeReplaceExecution, error) {
+	remove, err := newNodeRemoveExecution(ctx, store, op, cfg, replacedNode(op))
+	if err != nil {
+		return nil, err
+	}
+
+	nodeTimeout := 30 * time.Minute
+	if value := op.GetContextString("nodeTimeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse nodeTimeout from operation context: %w", err)
+		}
+		if parsed > 0 {
+			nodeTimeout = parsed
+		}
+	}
+
+	return &nodeReplaceExecution{remove: remove, nodeTimeout: nodeTimeout}, nil
+}
+
+// runPhase runs a single phase, handing removal phases to the node removal.
+func (e *nodeReplaceExecution) runPhase(ctx context.Context, phase string) error {
+	switch phase {
+	case nodeRemovePhasePreFlight, nodeRemovePhaseDrain, nodeRemovePhaseReset,
+		nodeRemovePhaseRemoveEtcdMember, nodeRemovePhaseDeleteNode, nodeRemovePhaseReleaseServer:
+		return e.remove.runPhase(ctx, phase)
+	}
+
+	store, op, cfg := e.remove.store, e.remove.op, e.remove.cfg
+	slog.Info("executing phase", "phase", phase, "operation", op.ID, "node", e.remove.node.Name)
+
+	if err := startOperationPhase(ctx, store, op, phase); err != nil {
+		return err
+	}
+
+	var phaseErr error
+	switch phase {
+	case nodeReplacePhaseOrderServer:
+		phaseErr = nodeReplaceOrderServerFn(ctx, op, cfg)
+	case nodeReplacePhaseWaitServer:
+		phaseErr = nodeReplaceWaitServerFn(ctx, op, cfg)
+	case nodeReplacePhaseWaitTalos:
+		phaseErr = nodeReplaceWaitTalosFn(ctx, op, cfg)
+	case nodeReplacePhaseApplyConfig:
+		phaseErr = e.applyConfig(ctx)
+	case nodeReplacePhaseWaitReady:
+		phaseErr = e.waitReady(ctx)
+	default:
+		phaseErr = fmt.Errorf("unknown phase %q", phase)
+	}
+	if phaseErr != nil {
+		return failOperationPhase(ctx, store, op, phase, phaseErr)
+	}
+
+	return completeOperationPhase(ctx, store, op, phase)
+}
+
+// applyConfig joins the new server to the cluster. The join endpoint is
+// resolved from the other nodes, as the inventory lists the new server
+// under the replaced node's name.
+func (e *nodeReplaceExecution) applyConfig(ctx context.Context) error {
+	node := replacementNode(e.remove.op)
+	if node.PublicIP == "" {
+		return fmt.Errorf("no public IP in operation context")
+	}
+
+	state, err := nodeRemoveLoadNodeStateFn(ctx, e.remove.cfg)
+	if err != nil {
+		return err
+	}
+	others := &cluster.NodesState{Environment: state.Environment}
+	for _, existing := range state.Nodes {
+		if existing.Name != node.Name {
+			others.Nodes = append(others.Nodes, existing)
+		}
+	}
+
+	return nodeReplaceApplyConfigFn(ctx, e.remove.cfg, others, node.Name, node.Role, node.PublicIP)
+}
+
+// waitReady waits for the new node to report Ready and, for a control
+// plane, to have joined etcd with every member healthy.
+func (e *nodeReplaceExecution) waitReady(ctx context.Context) error {
+	node := replacementNode(e.remove.op)
+
+	if err := nodeReplaceWaitNodeReadyFn(ctx, e.remove.kube, node.Name, e.nodeTimeout, nodeReplacePollInterval); err != nil {
+		return err
+	}
+	if node.Role != config.NodeTypeControlPlane {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, e.nodeTimeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		lastErr = e.checkEtcdJoined(waitCtx, node)
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("node %s did not join etcd: %w", node.Name, lastErr)
+		case <-time.After(nodeReplacePollInterval):
+		}
+	}
+}
+
+func (e *nodeReplaceExecution) checkEtcdJoined(ctx context.Context, node cluster.NodeState) error {
+	members, err := e.remove.etcdMembers(ctx)
+	if err != nil {
+		return err
+	}
+
+	joined := false
+	for _, member := range members {
+		if member.Hostname == node.Name {
+			joined = !member.IsLearner
+		}
+	}
+	if !joined {
+		return fmt.Errorf("%s is not a voting etcd member yet", node.Name)
+	}
+
+	controlPlanes := append([]cluster.NodeState{node}, e.remove.controlPlanes...)
+	healthy := 0
+	var errs []error
+	for _, controlPlane := range controlPlanes {
+		err := e.remove.withTalosClient(controlPlane, func(client talosNodeRemovalClient) error {
+			status, err := client.EtcdStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if len(status.Errors) > 0 {
+				return fmt.Errorf("member reports errors: %v", status.Errors)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", controlPlane.Name, err))
+			continue
+		}
+		healthy++
+	}
+	if healthy < len(members) {
+		return fmt.Errorf("%d/%d etcd members healthy: %w", healthy, len(members), errors.Join(errs...))
+	}
+
+	return nil
+}
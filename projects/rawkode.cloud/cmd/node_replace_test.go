@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restoreNodeReplaceFns() func() {
+	orderServer := nodeReplaceOrderServerFn
+	waitServer := nodeReplaceWaitServerFn
+	waitTalos := nodeReplaceWaitTalosFn
+	applyConfig := nodeReplaceApplyConfigFn
+	waitNodeReady := nodeReplaceWaitNodeReadyFn
+	pollInterval := nodeReplacePollInterval
+
+	return func() {
+		nodeReplaceOrderServerFn = orderServer
+		nodeReplaceWaitServerFn = waitServer
+		nodeReplaceWaitTalosFn = waitTalos
+		nodeReplaceApplyConfigFn = applyConfig
+		nodeReplaceWaitNodeReadyFn = waitNodeReady
+		nodeReplacePollInterval = pollInterval
+	}
+}
+
+func TestExecuteNodeReplaceKeepsSlotAndMigratesEtcd(t *testing.T) {
+	t.Cleanup(restoreNodeReplaceFns())
+	nodeReplacePollInterval = time.Millisecond
+
+	cfg := &config.Config{
+		Environment: "production",
+		NodePools: []config.NodePoolConfig{{
+			Name:               "control-plane",
+			Type:               config.NodeTypeControlPlane,
+			ReservedPrivateIPs: []string{"172.16.0.10", "172.16.0.11", "172.16.0.12"},
+		}},
+	}
+	nodes := nodeRemoveTestNodes()
+	etcd := testEtcdCluster()
+	name := "production-control-plane-02"
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+
+	// The removal seams are shared with node remove; the replace operation
+	// is recorded below in place of the remove-node one.
+	store, _ := newNodeRemoveTest(t, nodes, etcd, kubeClient, name)
+	op, err := newNodeReplaceOperation(cfg, "", nodes[1])
+	if err != nil {
+		t.Fatalf("newNodeReplaceOperation returned error: %v", err)
+	}
+	if got := op.GetContextString("privateIP"); got != "172.16.0.11" {
+		t.Fatalf("privateIP = %q, want slot 2 reserved IP", got)
+	}
+	op.SetContext("nodeTimeout", "1s")
+
+	nodeReplaceOrderServerFn = func(ctx context.Context, op *operation.Operation, cfg *config.Config) error {
+		etcd.record("order " + op.GetContextString("nodeName") + " " + op.GetContextString("privateIP"))
+		op.SetContext("serverId", "srv-new")
+		return nil
+	}
+	nodeReplaceWaitServerFn = func(ctx context.Context, op *operation.Operation, cfg *config.Config) error {
+		op.SetContext("publicIP", "5.5.5.5")
+		return nil
+	}
+	nodeReplaceWaitTalosFn = func(ctx context.Context, op *operation.Operation, cfg *config.Config) error {
+		return nil
+	}
+	nodeReplaceApplyConfigFn = func(ctx context.Context, cfg *config.Config, state *cluster.NodesState, nodeName, role, publicIP string) error {
+		if _, ok := findNodeByName(state, nodeName); ok {
+			t.Errorf("join state still lists %q", nodeName)
+		}
+		etcd.record("join " + nodeName + " " + publicIP)
+		etcd.mu.Lock()
+		etcd.members[nodeName] = 4
+		etcd.mu.Unlock()
+		return nil
+	}
+	nodeReplaceWaitNodeReadyFn = func(ctx context.Context, client kubernetes.Interface, nodeName string, timeout, interval time.Duration) error {
+		etcd.record("ready " + nodeName)
+		return nil
+	}
+
+	result, err := executeNodeReplace(context.Background(), store, op, cfg)
+	if err != nil {
+		t.Fatalf("executeNodeReplace returned error: %v", err)
+	}
+
+	want := []string{
+		"cordon " + name,
+		"drain " + name,
+		"reset " + name,
+		"remove-member 2",
+		"release srv-2",
+		"order " + name + " 172.16.0.11",
+		"join " + name + " 5.5.5.5",
+		"ready " + name,
+	}
+	if strings.Join(etcd.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %v, want %v", etcd.events, want)
+	}
+	if result.ReplacedServerID != "srv-2" || result.ServerID != "srv-new" {
+		t.Fatalf("servers = %q -> %q, want srv-2 -> srv-new", result.ReplacedServerID, result.ServerID)
+	}
+}
@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+var operationCmd = &cobra.Command{
+	Use:   "operation",
+	Short: "Inspect and drive persisted operations",
+}
+
+var operationListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List in-flight and historical operations for a cluster",
+	RunE:  runOperationList,
+}
+
+var operationShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show per-phase status, timings, and errors for an operation",
+	RunE:  runOperationShow,
+}
+
+var operationResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume an interrupted operation from its first unfinished phase",
+	RunE:  runOperationResume,
+}
+
+var operationAbortCmd = &cobra.Command{
+	Use:   "abort",
+	Short: "Abort an operation and run its rollback cleanup actions",
+	RunE:  runOperationAbort,
+}
+
+var (
+	operationLoadConfigFn      = loadConfigForClusterOrFile
+	operationCleanupRegistryFn = newOperationCleanupRegistry
+)
+
+func init() {
+	rootCmd.AddCommand(operationCmd)
+
+	operationCmd.AddCommand(operationListCmd)
+	operationCmd.AddCommand(operationShowCmd)
+	operationCmd.AddCommand(operationResumeCmd)
+	operationCmd.AddCommand(operationAbortCmd)
+
+	for _, c := range []*cobra.Command{operationListCmd, operationShowCmd, operationResumeCmd, operationAbortCmd} {
+		c.Flags().String("cluster", "", "Cluster/environment name")
+		c.Flags().StringP("file", "f", "", "Path to cluster config YAML")
+	}
+
+	operationListCmd.Flags().Bool("active", false, "Only show operations that are still in flight")
+
+	for _, c := range []*cobra.Command{operationShowCmd, operationResumeCmd, operationAbortCmd} {
+		c.Flags().String("id", "", "Operation ID")
+	}
+}
+
+func runOperationList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	activeOnly, _ := cmd.Flags().GetBool("active")
+
+	cfg, store, err := operationCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	ops, err := store.List(ctx, cfg.Environment)
+	if err != nil {
+		return err
+	}
+	operation.SortByUpdated(ops)
+
+	if activeOnly {
+		filtered := ops[:0]
+		for _, op := range ops {
+			if operationInFlight(op) {
+				filtered = append(filtered, op)
+			}
+		}
+		ops = filtered
+	}
+
+	if ops == nil {
+		ops = []*operation.Operation{}
+	}
+
+	var flushErr error
+	err = emitResult(cmd, ops, func() {
+		if len(ops) == 0 {
+			fmt.Printf("No operations recorded for cluster %q\n", cfg.Environment)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTYPE\tSTATUS\tPHASE\tPROGRESS\tUPDATED")
+		for _, op := range ops {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				op.ID,
+				op.Type,
+				op.Status(),
+				defaultString(op.ResumePhase(), "-"),
+				operationProgress(op),
+				op.UpdatedAt.Local().Format(time.RFC3339),
+			)
+		}
+		flushErr = w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	return flushErr
+}
+
+func runOperationShow(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, store, err := operationCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	op, err := loadOperationFromFlags(ctx, cmd, store, cfg)
+	if err != nil {
+		return err
+	}
+
+	return emitResult(cmd, op, func() {
+		printOperation(op)
+	})
+}
+
+func runOperationResume(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, store, err := operationCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	op, clusterLock, err := lockOperationFromFlags(ctx, cmd, store, cfg)
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	if op.IsAborted() {
+		return fmt.Errorf("operation %s was aborted and cannot be resumed", op.ID)
+	}
+	if op.IsComplete() {
+		fmt.Printf("Operation %s already complete.\n", op.ID)
+		return nil
+	}
+
+	return resumeOperation(ctx, store, op, cfg)
+}
+
+// resumeOperation dispatches a persisted operation to the executor for its type.
+func resumeOperation(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) error {
+	switch op.Type {
+	case operation.TypeCreateCluster:
+		return executeCreateCluster(ctx, store, op, cfg)
+	case operation.TypeUpgradeTalos, operation.TypeUpgradeK8s:
+		result, err := executeUpgrade(ctx, store, op, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Upgraded %s to %s on cluster %q (%d nodes)\n", upgradeComponentTitle(op.Type), result.Version, result.Cluster, len(result.Nodes))
+		return nil
+	case operation.TypeRemoveNode:
+		result, err := executeNodeRemove(ctx, store, op, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed node %q from cluster %q\n", result.Name, result.Cluster)
+		return nil
+	case operation.TypeReplaceNode:
+		result, err := executeNodeReplace(ctx, store, op, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Replaced node %q in cluster %q (server=%s)\n", result.Name, result.Cluster, result.ServerID)
+		return nil
+	case operation.TypeRestoreCluster:
+		result, err := executeClusterRestore(ctx, store, op, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored cluster %q from %s at revision %d\n", result.Cluster, result.Snapshot, result.Revision)
+		return nil
+	default:
+		return fmt.Errorf("resuming %s operations is not supported", op.Type)
+	}
+}
+
+func runOperationAbort(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, store, err := operationCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	op, clusterLock, err := lockOperationFromFlags(ctx, cmd, store, cfg)
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	if op.IsComplete() && !op.IsAborted() {
+		return fmt.Errorf("operation %s already completed; nothing to abort", op.ID)
+	}
+
+	return abortOperation(ctx, store, op, operationCleanupRegistryFn(cfg))
+}
+
+// abortOperation marks the operation aborted and unwinds its cleanup stack.
+// Failed cleanup actions stay on the stack so a later abort can retry them.
+func abortOperation(ctx context.Context, store operation.Store, op *operation.Operation, registry *operation.CleanupRegistry) error {
+	registerAbortOperationCleanup(registry, store, op.Cluster)
+
+	op.Abort()
+	if err := saveOperation(ctx, store, op); err != nil {
+		return err
+	}
+
+	actions := op.Cleanup
+	remaining := make([]operation.CleanupAction, 0, len(actions))
+	var errs []error
+	for i := len(actions) - 1; i >= 0; i-- {
+		actionErrs := registry.ExecuteLIFO(ctx, actions[i:i+1])
+		if len(actionErrs) > 0 {
+			errs = append(errs, actionErrs...)
+			remaining = append([]operation.CleanupAction{actions[i]}, remaining...)
+		}
+	}
+
+	op.Cleanup = remaining
+	op.UpdatedAt = time.Now().UTC()
+	if err := saveOperation(ctx, store, op); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf(
+			"operation %s aborted with %d failed cleanup action(s); rerun abort to retry: %w",
+			op.ID, len(remaining), errors.Join(errs...),
+		)
+	}
+
+	fmt.Printf("Aborted operation %s (cleanup actions run: %d)\n", op.ID, len(actions))
+	return nil
+}
+
+func operationCommandContext(ctx context.Context, cmd *cobra.Command) (*config.Config, operation.Store, error) {
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+
+	cfg, _, err := operationLoadConfigFn(clusterName, cfgFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open operation store: %w", err)
+	}
+
+	return cfg, store, nil
+}
+
+func loadOperationFromFlags(ctx context.Context, cmd *cobra.Command, store operation.Store, cfg *config.Config) (*operation.Operation, error) {
+	id, err := operationIDFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Load(ctx, cfg.Environment, id)
+}
+
+// lockOperationFromFlags takes the cluster lock and only then loads the
+// operation, so a run that waited for the lock acts on what the previous
+// holder left behind rather than on a copy read before it finished.
+func lockOperationFromFlags(ctx context.Context, cmd *cobra.Command, store operation.Store, cfg *config.Config) (*operation.Operation, *lock.Lock, error) {
+	id, err := operationIDFromFlags(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op, err := store.Load(ctx, cfg.Environment, id)
+	if err != nil {
+		releaseClusterLock(clusterLock)
+		return nil, nil, err
+	}
+	return op, clusterLock, nil
+}
+
+func operationIDFromFlags(cmd *cobra.Command) (string, error) {
+	id, _ := cmd.Flags().GetString("id")
+	if strings.TrimSpace(id) == "" {
+		return "", fmt.Errorf("--id is required")
+	}
+	return strings.TrimSpace(id), nil
+}
+
+func operationInFlight(op *operation.Operation) bool {
+	return !op.IsAborted() && !op.IsComplete()
+}
+
+func operationProgress(op *operation.Operation) string {
+	done := 0
+	for _, name := range op.PhaseOrder {
+		phase, ok := op.Phases[name]
+		if !ok {
+			continue
+		}
+		if phase.Status == operation.PhaseCompleted || phase.Status == operation.PhaseSkipped {
+			done++
+		}
+	}
+
+	return fmt.Sprintf("%d/%d", done, len(op.PhaseOrder))
+}
+
+func printOperation(op *operation.Operation) {
+	fmt.Printf("Operation: %s\n", op.ID)
+	fmt.Printf("Type:      %s\n", op.Type)
+	fmt.Printf("Cluster:   %s\n", op.Cluster)
+	fmt.Printf("Status:    %s\n", op.Status())
+	fmt.Printf("Created:   %s\n", op.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Printf("Updated:   %s\n", op.UpdatedAt.Local().Format(time.RFC3339))
+	if op.AbortedAt != nil {
+		fmt.Printf("Aborted:   %s\n", op.AbortedAt.Local().Format(time.RFC3339))
+	}
+
+	fmt.Printf("\nPhases:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PHASE\tSTATUS\tSTARTED\tDURATION\tERROR")
+	for _, name := range op.PhaseOrder {
+		phase, ok := op.Phases[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n",
+			name,
+			phase.Status,
+			formatOptionalTime(phase.StartedAt),
+			phaseDuration(phase),
+			defaultString(phase.Error, "-"),
+		)
+	}
+	_ = w.Flush()
+
+	if len(op.Context) > 0 {
+		keys := make([]string, 0, len(op.Context))
+		for key := range op.Context {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("\nContext:\n")
+		for _, key := range keys {
+			fmt.Printf("  %s: %v\n", key, op.Context[key])
+		}
+	}
+
+	if len(op.Cleanup) > 0 {
+		fmt.Printf("\nCleanup stack (runs last to first on abort):\n")
+		for i, action := range op.Cleanup {
+			fmt.Printf("  %d. %s %s\n", i+1, action.Type, string(action.Data))
+		}
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+func phaseDuration(phase *operation.Phase) string {
+	if phase == nil || phase.StartedAt == nil {
+		return "-"
+	}
+
+	end := time.Now().UTC()
+	if phase.CompletedAt != nil {
+		end = *phase.CompletedAt
+	} else if phase.Status != operation.PhaseInProgress {
+		return "-"
+	}
+
+	return end.Sub(*phase.StartedAt).Round(time.Second).String()
+}
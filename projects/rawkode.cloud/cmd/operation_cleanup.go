@@ -2,19 +2,105 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/infisical"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/scaleway"
 	scw "github.com/scaleway/scaleway-sdk-go/scw"
 )
 
+const (
+	cleanupActionDeleteServer            = "delete-server"
+	cleanupActionDeleteInfisicalSecrets  = "delete-infisical-secrets"
+	cleanupActionDeleteInfisicalIdentity = "delete-infisical-identity"
+	cleanupActionAbortOperation          = "abort-operation"
+)
+
+var (
+	operationCleanupDeleteServerFn            = runDeleteServerCleanupAction
+	operationCleanupDeleteInfisicalSecretsFn  = runDeleteInfisicalSecretsCleanupAction
+	operationCleanupDeleteInfisicalIdentityFn = runDeleteInfisicalIdentityCleanupAction
+)
+
+// newOperationCleanupRegistry wires every rollback action type to its handler.
+func newOperationCleanupRegistry(cfg *config.Config) *operation.CleanupRegistry {
+	registry := operation.NewCleanupRegistry()
+
+	registry.Register(cleanupActionDeleteServer, func(ctx context.Context, data json.RawMessage) error {
+		var payload cleanupDeleteServer
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", cleanupActionDeleteServer, err)
+		}
+		return operationCleanupDeleteServerFn(ctx, cfg, payload)
+	})
+	registry.Register(cleanupActionDeleteInfisicalSecrets, func(ctx context.Context, data json.RawMessage) error {
+		var payload cleanupDeleteInfisicalSecrets
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", cleanupActionDeleteInfisicalSecrets, err)
+		}
+		return operationCleanupDeleteInfisicalSecretsFn(ctx, cfg, payload)
+	})
+	registry.Register(cleanupActionDeleteInfisicalIdentity, func(ctx context.Context, data json.RawMessage) error {
+		var payload cleanupDeleteInfisicalIdentity
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", cleanupActionDeleteInfisicalIdentity, err)
+		}
+		return operationCleanupDeleteInfisicalIdentityFn(ctx, cfg, payload)
+	})
+
+	return registry
+}
+
 type cleanupDeleteServer struct {
 	ServerID string `json:"serverId"`
 	Zone     string `json:"zone"`
 }
 
+type cleanupDeleteInfisicalSecrets struct {
+	SecretPath string   `json:"secretPath"`
+	Keys       []string `json:"keys"`
+}
+
+// cleanupAbortOperation rolls back an operation started on behalf of
+// another one, such as the add-node operation of a create-cluster slot.
+type cleanupAbortOperation struct {
+	OperationID string `json:"operationId"`
+}
+
+type cleanupDeleteInfisicalIdentity struct {
+	IdentityID string `json:"identityId"`
+	Name       string `json:"name,omitempty"`
+}
+
+// registerAbortOperationCleanup wires the abort-operation action, which
+// needs the store the linked operation was saved to. A linked operation that
+// was never saved created nothing to roll back.
+func registerAbortOperationCleanup(registry *operation.CleanupRegistry, store operation.Store, cluster string) {
+	registry.Register(cleanupActionAbortOperation, func(ctx context.Context, data json.RawMessage) error {
+		var payload cleanupAbortOperation
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", cleanupActionAbortOperation, err)
+		}
+		if strings.TrimSpace(payload.OperationID) == "" {
+			return fmt.Errorf("cleanup payload missing operationId")
+		}
+
+		linked, err := store.Load(ctx, cluster, payload.OperationID)
+		if errors.Is(err, operation.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load operation %s: %w", payload.OperationID, err)
+		}
+		return abortOperation(ctx, store, linked, registry)
+	})
+}
+
 func runDeleteServerCleanupAction(ctx context.Context, cfg *config.Config, payload cleanupDeleteServer) error {
 	if strings.TrimSpace(payload.ServerID) == "" {
 		return fmt.Errorf("cleanup payload missing serverId")
@@ -31,3 +117,40 @@ func runDeleteServerCleanupAction(ctx context.Context, cfg *config.Config, paylo
 
 	return scaleway.CleanupProvisionedServer(ctx, scwClient, payload.ServerID, scw.Zone(payload.Zone))
 }
+
+func runDeleteInfisicalSecretsCleanupAction(ctx context.Context, cfg *config.Config, payload cleanupDeleteInfisicalSecrets) error {
+	if strings.TrimSpace(payload.SecretPath) == "" {
+		return fmt.Errorf("cleanup payload missing secretPath")
+	}
+
+	client, err := getOrCreateInfisicalClient(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("create infisical client: %w", err)
+	}
+
+	var errs []error
+	for _, key := range payload.Keys {
+		if err := client.DeleteSecret(ctx, cfg.Infisical.ProjectID, cfg.Infisical.Environment, payload.SecretPath, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func runDeleteInfisicalIdentityCleanupAction(ctx context.Context, cfg *config.Config, payload cleanupDeleteInfisicalIdentity) error {
+	if strings.TrimSpace(payload.IdentityID) == "" {
+		return fmt.Errorf("cleanup payload missing identityId")
+	}
+
+	client, err := getOrCreateInfisicalClient(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("create infisical client: %w", err)
+	}
+
+	if err := client.DeleteMachineIdentity(ctx, payload.IdentityID); err != nil && !infisical.IsNotFound(err) {
+		return fmt.Errorf("delete infisical machine identity %s: %w", defaultString(payload.Name, payload.IdentityID), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/infisical"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+const (
+	operationStoreInfisical       = "infisical"
+	operationStoreLocal           = "local"
+	defaultOperationStateDir      = "~/.local/state/rawkode-cloud3/operations"
+	infisicalOperationsFolderName = "operations"
+)
+
+var operationStoreFn = newOperationStore
+
+func init() {
+	rootCmd.PersistentFlags().String("state-store", operationStoreInfisical, "Where operation state is persisted for resume (infisical or local)")
+	rootCmd.PersistentFlags().String("state-dir", defaultOperationStateDir, "Directory for operation state when --state-store=local")
+}
+
+// operationStoreForCommand resolves the operation store selected by the persistent flags.
+func operationStoreForCommand(ctx context.Context, cmd *cobra.Command, cfg *config.Config) (operation.Store, error) {
+	kind, dir := operationStoreFlags(cmd)
+	return operationStoreFn(ctx, cfg, kind, dir)
+}
+
+func operationStoreFlags(cmd *cobra.Command) (kind, dir string) {
+	if cmd != nil {
+		kind, _ = cmd.Flags().GetString("state-store")
+		dir, _ = cmd.Flags().GetString("state-dir")
+	}
+
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		kind = operationStoreInfisical
+	}
+	if strings.TrimSpace(dir) == "" {
+		dir = defaultOperationStateDir
+	}
+
+	return kind, dir
+}
+
+func newOperationStore(ctx context.Context, cfg *config.Config, kind, dir string) (operation.Store, error) {
+	switch kind {
+	case operationStoreLocal:
+		resolved, err := expandLocalPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("resolve --state-dir: %w", err)
+		}
+		return operation.NewFileStore(resolved)
+	case operationStoreInfisical:
+		if cfg == nil {
+			return nil, fmt.Errorf("config is required")
+		}
+		client, err := getOrCreateInfisicalClient(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("create infisical client: %w", err)
+		}
+		return infisical.NewOperationStore(client, cfg.Infisical.ProjectID, cfg.Infisical.Environment, infisicalOperationsPath(cfg))
+	default:
+		return nil, fmt.Errorf("--state-store must be one of: %s, %s", operationStoreInfisical, operationStoreLocal)
+	}
+}
+
+func infisicalOperationsPath(cfg *config.Config) string {
+	base := infisicalSecretPathForCluster(cfg)
+	if base == "" {
+		base = "/"
+	}
+
+	return path.Join(base, infisicalOperationsFolderName)
+}
+
+// saveOperation persists the operation after a state transition.
+func saveOperation(ctx context.Context, store operation.Store, op *operation.Operation) error {
+	if store == nil || op == nil {
+		return nil
+	}
+	// Once the lock is lost another holder may own this operation; writing
+	// it would clobber their progress.
+	if err := checkClusterLock(); err != nil {
+		return fmt.Errorf("save operation %s: %w", op.ID, err)
+	}
+
+	if err := store.Save(ctx, op); err != nil {
+		return fmt.Errorf("save operation %s: %w", op.ID, err)
+	}
+
+	return nil
+}
+
+// startOperationPhase marks a phase in-progress and persists it.
+func startOperationPhase(ctx context.Context, store operation.Store, op *operation.Operation, phase string) error {
+	if err := op.StartPhase(phase); err != nil {
+		return fmt.Errorf("start phase %s: %w", phase, err)
+	}
+
+	return saveOperation(ctx, store, op)
+}
+
+// completeOperationPhase marks a phase completed and persists it.
+func completeOperationPhase(ctx context.Context, store operation.Store, op *operation.Operation, phase string) error {
+	if err := op.CompletePhase(phase, nil); err != nil {
+		return fmt.Errorf("complete phase %s: %w", phase, err)
+	}
+
+	return saveOperation(ctx, store, op)
+}
+
+// failOperationPhase records a phase failure and persists it, keeping the phase error primary.
+func failOperationPhase(ctx context.Context, store operation.Store, op *operation.Operation, phase string, phaseErr error) error {
+	_ = op.FailPhase(phase, phaseErr)
+	wrapped := fmt.Errorf("phase %s failed: %w", phase, phaseErr)
+	if err := saveOperation(ctx, store, op); err != nil {
+		return errors.Join(wrapped, err)
+	}
+
+	return wrapped
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+)
+
+func TestExecuteCreateClusterPersistsFailedPhase(t *testing.T) {
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	op := operation.New("op-test", operation.TypeCreateCluster, "production", []string{"not-a-phase"})
+	if err := executeCreateCluster(context.Background(), store, op, &config.Config{Environment: "production"}); err == nil {
+		t.Fatal("expected unknown phase to fail")
+	}
+
+	loaded, err := store.Load(context.Background(), "production", "op-test")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	phase := loaded.Phases["not-a-phase"]
+	if phase == nil || phase.Status != operation.PhaseFailed {
+		t.Fatalf("persisted phase = %+v, want failed", phase)
+	}
+	if phase.Error == "" {
+		t.Fatal("expected persisted phase error message")
+	}
+	if loaded.ResumePhase() != "not-a-phase" {
+		t.Fatalf("ResumePhase() = %q, want %q", loaded.ResumePhase(), "not-a-phase")
+	}
+}
+
+func TestInfisicalOperationsPath(t *testing.T) {
+	cfg := &config.Config{
+		Environment: "production",
+		Infisical: config.InfisicalConfig{
+			SecretPath: "/projects/rawkode-cloud/",
+		},
+	}
+
+	if got := infisicalOperationsPath(cfg); got != "/projects/rawkode-cloud/operations" {
+		t.Fatalf("infisicalOperationsPath() = %q, want %q", got, "/projects/rawkode-cloud/operations")
+	}
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+func TestAbortOperationRunsCleanupLIFOAndPersists(t *testing.T) {
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	op := operation.New("op-test", operation.TypeCreateCluster, "production", []string{"init"})
+	_ = op.AddCleanup("record", "first")
+	_ = op.AddCleanup("record", "second")
+
+	var ran []string
+	registry := operation.NewCleanupRegistry()
+	registry.Register("record", func(ctx context.Context, data json.RawMessage) error {
+		var value string
+		_ = json.Unmarshal(data, &value)
+		ran = append(ran, value)
+		return nil
+	})
+
+	if err := abortOperation(context.Background(), store, op, registry); err != nil {
+		t.Fatalf("abortOperation returned error: %v", err)
+	}
+	if len(ran) != 2 || ran[0] != "second" || ran[1] != "first" {
+		t.Fatalf("cleanup order = %v, want [second first]", ran)
+	}
+
+	loaded, err := store.Load(context.Background(), "production", "op-test")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if loaded.Status() != operation.StatusAborted {
+		t.Fatalf("Status() = %q, want %q", loaded.Status(), operation.StatusAborted)
+	}
+	if len(loaded.Cleanup) != 0 {
+		t.Fatalf("remaining cleanup = %d, want 0", len(loaded.Cleanup))
+	}
+}
+
+func TestAbortOperationKeepsFailedCleanupForRetry(t *testing.T) {
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	op := operation.New("op-test", operation.TypeCreateCluster, "production", []string{"init"})
+	_ = op.AddCleanup("ok", nil)
+	_ = op.AddCleanup("broken", nil)
+
+	registry := operation.NewCleanupRegistry()
+	registry.Register("ok", func(ctx context.Context, data json.RawMessage) error { return nil })
+	registry.Register("broken", func(ctx context.Context, data json.RawMessage) error {
+		return errors.New("server still provisioning")
+	})
+
+	if err := abortOperation(context.Background(), store, op, registry); err == nil {
+		t.Fatal("expected abortOperation to report the failed cleanup action")
+	}
+
+	loaded, err := store.Load(context.Background(), "production", "op-test")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(loaded.Cleanup) != 1 || loaded.Cleanup[0].Type != "broken" {
+		t.Fatalf("remaining cleanup = %+v, want [broken]", loaded.Cleanup)
+	}
+	if !loaded.IsAborted() {
+		t.Fatal("expected operation to be marked aborted")
+	}
+}
+
+func TestOperationCleanupRegistryDispatchesRecordedActions(t *testing.T) {
+	origServer := operationCleanupDeleteServerFn
+	origSecrets := operationCleanupDeleteInfisicalSecretsFn
+	origIdentity := operationCleanupDeleteInfisicalIdentityFn
+	t.Cleanup(func() {
+		operationCleanupDeleteServerFn = origServer
+		operationCleanupDeleteInfisicalSecretsFn = origSecrets
+		operationCleanupDeleteInfisicalIdentityFn = origIdentity
+	})
+
+	var ran []string
+	operationCleanupDeleteServerFn = func(_ context.Context, _ *config.Config, payload cleanupDeleteServer) error {
+		ran = append(ran, "server:"+payload.ServerID+"@"+payload.Zone)
+		return nil
+	}
+	operationCleanupDeleteInfisicalSecretsFn = func(_ context.Context, _ *config.Config, payload cleanupDeleteInfisicalSecrets) error {
+		ran = append(ran, "secrets:"+payload.SecretPath)
+		return nil
+	}
+	operationCleanupDeleteInfisicalIdentityFn = func(_ context.Context, _ *config.Config, payload cleanupDeleteInfisicalIdentity) error {
+		ran = append(ran, "identity:"+payload.IdentityID)
+		return nil
+	}
+
+	op := operation.New("op-test", operation.TypeCreateCluster, "production", []string{"init"})
+	_ = op.AddCleanup(cleanupActionDeleteInfisicalSecrets, cleanupDeleteInfisicalSecrets{SecretPath: "/production", Keys: []string{infisicalTalosSecretsKey}})
+	_ = op.AddCleanup(cleanupActionDeleteServer, cleanupDeleteServer{ServerID: "server-1", Zone: "fr-par-2"})
+	_ = op.AddCleanup(cleanupActionDeleteInfisicalIdentity, cleanupDeleteInfisicalIdentity{IdentityID: "identity-1"})
+
+	errs := newOperationCleanupRegistry(&config.Config{Environment: "production"}).ExecuteLIFO(context.Background(), op.Cleanup)
+	if len(errs) != 0 {
+		t.Fatalf("ExecuteLIFO returned errors: %v", errs)
+	}
+
+	want := []string{"identity:identity-1", "server:server-1@fr-par-2", "secrets:/production"}
+	if len(ran) != len(want) {
+		t.Fatalf("ran = %v, want %v", ran, want)
+	}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Fatalf("ran[%d] = %q, want %q", i, ran[i], want[i])
+		}
+	}
+}
+
+// racingLockBackend lets another run change the store while this one waits
+// for the lock.
+type racingLockBackend struct {
+	lock.Backend
+	onCreate func()
+}
+
+func (b *racingLockBackend) Create(ctx context.Context, lease *lock.Lease) error {
+	b.onCreate()
+	return b.Backend.Create(ctx, lease)
+}
+
+func TestLockOperationFromFlagsLoadsAfterTakingLock(t *testing.T) {
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+	op := operation.New("op-test", operation.TypeCreateCluster, "production", []string{"init"})
+	if err := store.Save(context.Background(), op); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	backend := useLocalClusterLock(t)
+	clusterLockBackendFn = func(context.Context, *cobra.Command, *config.Config) (lock.Backend, error) {
+		return &racingLockBackend{Backend: backend, onCreate: func() {
+			op.Abort()
+			_ = store.Save(context.Background(), op)
+		}}, nil
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("id", "op-test", "")
+	loaded, held, err := lockOperationFromFlags(context.Background(), cmd, store, &config.Config{Environment: "production"})
+	if err != nil {
+		t.Fatalf("lockOperationFromFlags returned error: %v", err)
+	}
+	defer releaseClusterLock(held)
+
+	if !loaded.IsAborted() {
+		t.Fatal("operation was loaded before the lock was taken")
+	}
+}
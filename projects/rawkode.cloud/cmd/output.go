@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+	outputFormatYAML = "yaml"
+)
+
+var (
+	// commandOutput receives structured results; human text still goes to stdout.
+	commandOutput io.Writer = os.Stdout
+	// resultEmitted records whether the running command already wrote its
+	// structured result, so Execute does not add a second error document.
+	resultEmitted bool
+)
+
+// commandErrorResult is the structured document written when a command fails
+// before producing its own result.
+type commandErrorResult struct {
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringP("output", "o", outputFormatText, "Output format: text, json, or yaml")
+}
+
+func outputFormat(cmd *cobra.Command) (string, error) {
+	value, _ := cmd.Flags().GetString("output")
+	format := strings.ToLower(strings.TrimSpace(value))
+	switch format {
+	case "", outputFormatText:
+		return outputFormatText, nil
+	case outputFormatJSON, outputFormatYAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported --output %q (expected text, json, or yaml)", value)
+	}
+}
+
+// emitResult writes result as JSON or YAML when requested, otherwise it runs
+// text to print the human-readable form.
+func emitResult(cmd *cobra.Command, result any, text func()) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if format == outputFormatText {
+		text()
+		return nil
+	}
+
+	resultEmitted = true
+	return writeStructured(commandOutput, format, result)
+}
+
+// failWithResult emits a partial result that already carries err and returns
+// err, so scripts still see what was done before the failure.
+func failWithResult(cmd *cobra.Command, result any, err error) error {
+	if emitErr := emitResult(cmd, result, func() {}); emitErr != nil {
+		return errors.Join(err, emitErr)
+	}
+	return err
+}
+
+// emitCommandError writes err as a structured document when the command
+// failed without emitting a result of its own.
+func emitCommandError(cmd *cobra.Command, err error) {
+	if cmd == nil || err == nil || resultEmitted {
+		return
+	}
+	format, formatErr := outputFormat(cmd)
+	if formatErr != nil || format == outputFormatText {
+		return
+	}
+
+	_ = writeStructured(commandOutput, format, commandErrorResult{
+		Command: cmd.CommandPath(),
+		Error:   err.Error(),
+	})
+}
+
+func writeStructured(w io.Writer, format string, result any) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s output: %w", format, err)
+	}
+
+	if format == outputFormatYAML {
+		data, err = jsonToYAML(data)
+		if err != nil {
+			return fmt.Errorf("encode yaml output: %w", err)
+		}
+	} else {
+		data = append(data, '\n')
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonToYAML re-encodes JSON as block-style YAML. Going through JSON keeps a
+// single set of struct tags and preserves field order.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
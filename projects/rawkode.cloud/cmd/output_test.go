@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newOutputTestCmd(format string) *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringP("output", "o", outputFormatText, "")
+	_ = cmd.Flags().Set("output", format)
+	return cmd
+}
+
+func captureCommandOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	previousOutput := commandOutput
+	previousEmitted := resultEmitted
+	var buf bytes.Buffer
+	commandOutput = &buf
+	resultEmitted = false
+	t.Cleanup(func() {
+		commandOutput = previousOutput
+		resultEmitted = previousEmitted
+	})
+
+	return &buf
+}
+
+func TestEmitResultWritesJSON(t *testing.T) {
+	buf := captureCommandOutput(t)
+
+	result := nodeAddResult{Cluster: "production", Name: "production-worker-01", ServerID: "server-1"}
+	textCalled := false
+	if err := emitResult(newOutputTestCmd("json"), result, func() { textCalled = true }); err != nil {
+		t.Fatalf("emitResult returned error: %v", err)
+	}
+	if textCalled {
+		t.Fatal("text output should not run for json")
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if decoded["serverId"] != "server-1" || decoded["name"] != "production-worker-01" {
+		t.Fatalf("decoded = %v, want serverId and name", decoded)
+	}
+}
+
+func TestEmitResultWritesBlockYAMLInFieldOrder(t *testing.T) {
+	buf := captureCommandOutput(t)
+
+	result := upgradeResult{
+		Cluster:   "production",
+		Component: "talos",
+		Version:   "v1.9.5",
+		Nodes:     []upgradeNodeResult{{Name: "production-control-plane-01", Role: "controlplane"}},
+	}
+	if err := emitResult(newOutputTestCmd("yaml"), result, func() {}); err != nil {
+		t.Fatalf("emitResult returned error: %v", err)
+	}
+
+	want := `cluster: production
+configPath: ""
+component: talos
+version: v1.9.5
+nodes:
+  - name: production-control-plane-01
+    role: controlplane
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("yaml output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEmitResultRunsTextByDefault(t *testing.T) {
+	buf := captureCommandOutput(t)
+
+	textCalled := false
+	if err := emitResult(newOutputTestCmd(""), nodeRemoveResult{}, func() { textCalled = true }); err != nil {
+		t.Fatalf("emitResult returned error: %v", err)
+	}
+	if !textCalled {
+		t.Fatal("expected text output to run")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("structured output = %q, want empty", buf.String())
+	}
+}
+
+func TestEmitResultRejectsUnknownFormat(t *testing.T) {
+	captureCommandOutput(t)
+
+	if err := emitResult(newOutputTestCmd("xml"), nodeRemoveResult{}, func() {}); err == nil {
+		t.Fatal("expected unsupported --output to fail")
+	}
+}
+
+func TestEmitCommandErrorOnlyWhenNoResultWasEmitted(t *testing.T) {
+	buf := captureCommandOutput(t)
+	cmd := newOutputTestCmd("json")
+
+	emitCommandError(cmd, errors.New("boom"))
+
+	var decoded commandErrorResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if decoded.Error != "boom" || decoded.Command != "test" {
+		t.Fatalf("decoded = %+v, want command=test error=boom", decoded)
+	}
+
+	buf.Reset()
+	if err := failWithResult(cmd, nodeAddResult{Error: "boom"}, errors.New("boom")); err == nil {
+		t.Fatal("failWithResult should return the original error")
+	}
+	buf.Reset()
+	emitCommandError(cmd, errors.New("boom"))
+	if buf.Len() != 0 {
+		t.Fatalf("emitCommandError wrote %q after a result was emitted", buf.String())
+	}
+}
@@ -11,7 +11,12 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	resultEmitted = false
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		emitCommandError(cmd, err)
+	}
+	return err
 }
 
 func init() {
@@ -19,4 +24,5 @@ func init() {
 	rootCmd.AddCommand(nodeCmd)
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(etcdCmd)
+	rootCmd.AddCommand(configCmd)
 }
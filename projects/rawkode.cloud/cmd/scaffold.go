@@ -18,6 +18,18 @@ cluster:
   ciliumVersion: v1.19.0
   fluxVersion: latest
   controlPlaneTaints: true
+  # Optional machine-config patches for every node, applied before pool patches:
+  # configPatches:
+  #   - name: somaxconn
+  #     strategicMerge:
+  #       machine:
+  #         sysctls:
+  #           net.core.somaxconn: "65535"
+  #   - name: max-pods
+  #     jsonPatch:
+  #       - op: add
+  #         path: /machine/kubelet/extraArgs/max-pods
+  #         value: "250"
 
 scaleway:
   projectId: ""
@@ -37,6 +49,15 @@ nodePools:
     disks:
       os: /dev/nvme0n1
       data: /dev/nvme1n1
+    # Optional Kubernetes node metadata, applied through the machine config:
+    # labels:
+    #   rawkode.cloud/pool: main
+    # taints:
+    #   - key: dedicated
+    #     value: main
+    #     effect: NoSchedule
+    # annotations:
+    #   rawkode.cloud/owner: platform
 
 storage:
   mayastor:
@@ -54,6 +75,21 @@ infisical:
 
 flux:
   ociRepo: "oci://ghcr.io/rawkode-academy/rawkode-academy/gitops"
+
+# Optional etcd backups to S3-compatible object storage (etcd backup):
+# backup:
+#   bucket: rawkode-cloud-backups
+#   region: fr-par
+#   # endpoint: https://s3.fr-par.scw.cloud
+#   retention:
+#     hourly: 24
+#     daily: 7
+#     weekly: 4
+#   # Encrypt snapshots with age (also used by etcd snapshot):
+#   encryption:
+#     recipients:
+#       - age1...
+#     infisicalKey: ETCD_BACKUP_AGE_KEY
 `
 
 var clusterScaffoldCmd = &cobra.Command{
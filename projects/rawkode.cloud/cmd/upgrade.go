@@ -3,10 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
 	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
 	"github.com/spf13/cobra"
 )
@@ -29,37 +32,13 @@ var upgradeK8sCmd = &cobra.Command{
 }
 
 func runUpgradeTalos(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
-	clusterName, _ := cmd.Flags().GetString("cluster")
-	cfgFile, _ := cmd.Flags().GetString("file")
-	version, _ := cmd.Flags().GetString("version")
-
-	if strings.TrimSpace(version) == "" {
-		return fmt.Errorf("--version is required")
-	}
-
-	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
-	if err != nil {
-		return err
-	}
-
-	state, err := loadNodeState(ctx, cfg)
-	if err != nil {
-		return err
-	}
-
-	infClient, err := newInfisicalClient(ctx, cfg)
-	if err != nil {
-		return err
-	}
-	talosconfig, err := loadTalosconfigFromInfisical(ctx, cfg, infClient)
-	if err != nil {
-		return err
-	}
-
-	imageURL := fmt.Sprintf("factory.talos.dev/installer/%s/%s", cfg.Cluster.TalosSchematic, version)
+	return runUpgrade(cmd, operation.TypeUpgradeTalos)
+}
 
-	orderedNodes := make([]cluster.NodeState, 0, len(state.Nodes))
+// upgradeCandidateNodes returns the active nodes with a public IP, control
+// planes first and otherwise ordered by name.
+func upgradeCandidateNodes(state *cluster.NodesState) []cluster.NodeState {
+	nodes := make([]cluster.NodeState, 0, len(state.Nodes))
 	for _, node := range state.Nodes {
 		if node.Status == cluster.NodeStatusDeleted || node.Status == cluster.NodeStatusFailed {
 			continue
@@ -67,135 +46,91 @@ func runUpgradeTalos(cmd *cobra.Command, args []string) error {
 		if strings.TrimSpace(node.PublicIP) == "" {
 			continue
 		}
-		orderedNodes = append(orderedNodes, node)
+		nodes = append(nodes, node)
 	}
-	// Control planes first.
-	for i := range orderedNodes {
-		for j := i + 1; j < len(orderedNodes); j++ {
-			if orderedNodes[i].Role == config.NodeTypeWorker && orderedNodes[j].Role == config.NodeTypeControlPlane {
-				orderedNodes[i], orderedNodes[j] = orderedNodes[j], orderedNodes[i]
-			}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		iControlPlane := nodes[i].Role == config.NodeTypeControlPlane
+		jControlPlane := nodes[j].Role == config.NodeTypeControlPlane
+		if iControlPlane != jControlPlane {
+			return iControlPlane
 		}
-	}
+		return nodes[i].Name < nodes[j].Name
+	})
 
-	if len(orderedNodes) == 0 {
-		return fmt.Errorf("no active nodes found to upgrade")
-	}
+	return nodes
+}
 
-	for _, node := range orderedNodes {
-		client, err := talos.NewClient(node.PublicIP, talosconfig)
-		if err != nil {
-			return fmt.Errorf("create talos client for node %s: %w", node.Name, err)
-		}
-		if err := client.Upgrade(ctx, imageURL); err != nil {
-			client.Close()
-			return fmt.Errorf("upgrade node %s: %w", node.Name, err)
-		}
-		if err := client.Close(); err != nil {
-			return fmt.Errorf("close talos client for node %s: %w", node.Name, err)
+func controlPlaneNodes(nodes []cluster.NodeState) []cluster.NodeState {
+	var out []cluster.NodeState
+	for _, node := range nodes {
+		if node.Role == config.NodeTypeControlPlane {
+			out = append(out, node)
 		}
 	}
-
-	fmt.Printf("Upgraded Talos to %s on cluster %q (config=%s)\n", version, cfg.Environment, cfgPath)
-	return nil
+	return out
 }
 
 func runUpgradeK8s(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
-	clusterName, _ := cmd.Flags().GetString("cluster")
-	cfgFile, _ := cmd.Flags().GetString("file")
-	version, _ := cmd.Flags().GetString("version")
-
-	if strings.TrimSpace(version) == "" {
-		return fmt.Errorf("--version is required")
-	}
+	return runUpgrade(cmd, operation.TypeUpgradeK8s)
+}
 
-	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+func applyKubernetesUpgradeConfig(
+	ctx context.Context,
+	cfg *config.Config,
+	assets *talos.GenConfigResult,
+	netbirdSetupKey string,
+	node cluster.NodeState,
+) error {
+	nodeConfig, err := renderNodeMachineConfig(cfg, assets, node.Name, node.Role, netbirdSetupKey)
 	if err != nil {
 		return err
 	}
 
-	state, err := loadNodeState(ctx, cfg)
+	client, err := talos.NewClient(node.PublicIP, assets.Talosconfig)
 	if err != nil {
-		return err
+		return fmt.Errorf("create talos client for node %s: %w", node.Name, err)
 	}
-
-	infClient, err := newInfisicalClient(ctx, cfg)
-	if err != nil {
-		return err
+	if err := client.ApplyConfig(ctx, nodeConfig); err != nil {
+		client.Close()
+		return fmt.Errorf("apply upgraded Kubernetes config to node %s: %w", node.Name, err)
 	}
-
-	endpoint, err := controlPlaneEndpointFromState(state)
-	if err != nil {
-		return err
+	if err := client.Close(); err != nil {
+		return fmt.Errorf("close talos client for node %s: %w", node.Name, err)
 	}
 
-	cfgForUpgrade := *cfg
-	cfgForUpgrade.Cluster = cfg.Cluster
-	cfgForUpgrade.Cluster.KubernetesVersion = version
-
-	assets, err := ensureTalosAssets(ctx, &cfgForUpgrade, endpoint, infClient)
-	if err != nil {
-		return err
-	}
-	if len(assets.Talosconfig) == 0 {
-		return fmt.Errorf("generated talosconfig is empty")
-	}
-	netbirdSetupKey, err := loadOptionalNetbirdSetupKeyFromInfisical(ctx, cfg, infClient)
-	if err != nil {
-		return fmt.Errorf("load netbird setup key: %w", err)
-	}
+	return nil
+}
 
-	orderedNodes := make([]cluster.NodeState, 0, len(state.Nodes))
-	for _, node := range state.Nodes {
-		if node.Status == cluster.NodeStatusDeleted || node.Status == cluster.NodeStatusFailed {
-			continue
-		}
-		if strings.TrimSpace(node.PublicIP) == "" {
-			continue
-		}
-		orderedNodes = append(orderedNodes, node)
-	}
-	for i := range orderedNodes {
-		for j := i + 1; j < len(orderedNodes); j++ {
-			if orderedNodes[i].Role == config.NodeTypeWorker && orderedNodes[j].Role == config.NodeTypeControlPlane {
-				orderedNodes[i], orderedNodes[j] = orderedNodes[j], orderedNodes[i]
-			}
-		}
-	}
-	if len(orderedNodes) == 0 {
-		return fmt.Errorf("no active nodes found to upgrade")
-	}
+// upgradeResult is the structured output of `upgrade talos` and `upgrade k8s`.
+type upgradeResult struct {
+	Cluster       string              `json:"cluster"`
+	ConfigPath    string              `json:"configPath"`
+	Component     string              `json:"component"`
+	Version       string              `json:"version"`
+	Image         string              `json:"image,omitempty"`
+	Preflight     []upgradeCheck      `json:"preflight,omitempty"`
+	Nodes         []upgradeNodeResult `json:"nodes"`
+	ConfigUpdated bool                `json:"configUpdated,omitempty"`
+	Error         string              `json:"error,omitempty"`
+}
 
-	for _, node := range orderedNodes {
-		baseConfig := assets.Worker
-		if node.Role == config.NodeTypeControlPlane {
-			baseConfig = assets.ControlPlane
-		}
-		nodeConfig, err := renderNodeTalosConfig(cfg, baseConfig, node.Name, node.Role)
-		if err != nil {
-			return fmt.Errorf("render node-specific Talos config for node %s: %w", node.Name, err)
-		}
-		nodeConfig, err = appendNetbirdExtensionServiceConfig(nodeConfig, netbirdSetupKey)
-		if err != nil {
-			return fmt.Errorf("append netbird extension service config for node %s: %w", node.Name, err)
-		}
+// upgradeNodeResult identifies a node that was upgraded.
+type upgradeNodeResult struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	ServerID string `json:"serverId,omitempty"`
+	PublicIP string `json:"publicIp,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`
+}
 
-		client, err := talos.NewClient(node.PublicIP, assets.Talosconfig)
-		if err != nil {
-			return fmt.Errorf("create talos client for node %s: %w", node.Name, err)
-		}
-		if err := client.ApplyConfig(ctx, nodeConfig); err != nil {
-			client.Close()
-			return fmt.Errorf("apply upgraded Kubernetes config to node %s: %w", node.Name, err)
-		}
-		if err := client.Close(); err != nil {
-			return fmt.Errorf("close talos client for node %s: %w", node.Name, err)
-		}
+func newUpgradeNodeResult(node cluster.NodeState) upgradeNodeResult {
+	return upgradeNodeResult{
+		Name:     node.Name,
+		Role:     node.Role,
+		ServerID: node.ServerID,
+		PublicIP: node.PublicIP,
 	}
-
-	fmt.Printf("Upgraded Kubernetes to %s on cluster %q (config=%s)\n", version, cfg.Environment, cfgPath)
-	return nil
 }
 
 func init() {
@@ -205,8 +140,22 @@ func init() {
 	upgradeTalosCmd.Flags().String("cluster", "", "Cluster/environment name")
 	upgradeTalosCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 	upgradeTalosCmd.Flags().String("version", "", "Target Talos version (e.g. v1.12.4)")
+	addRollingUpgradeFlags(upgradeTalosCmd)
+	upgradeTalosCmd.Flags().Duration("drain-timeout", 10*time.Minute, "Maximum time to drain a node, including retries blocked by PodDisruptionBudgets")
 
 	upgradeK8sCmd.Flags().String("cluster", "", "Cluster/environment name")
 	upgradeK8sCmd.Flags().StringP("file", "f", "", "Path to cluster config YAML")
 	upgradeK8sCmd.Flags().String("version", "", "Target Kubernetes version (e.g. v1.35.0)")
+	addRollingUpgradeFlags(upgradeK8sCmd)
+}
+
+// addRollingUpgradeFlags registers the flags shared by both upgrade commands.
+// They are recorded on the operation, so a resumed upgrade keeps the values
+// it was started with.
+func addRollingUpgradeFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("force", false, "Upgrade even when pre-flight compatibility checks fail")
+	cmd.Flags().Bool("no-write-config", false, "Do not record the new version in the cluster config file after a successful upgrade")
+	cmd.Flags().Int("max-unavailable", 1, "Maximum worker nodes upgraded at the same time (control planes are always upgraded one at a time)")
+	cmd.Flags().Duration("pause-between", 0, "Time to wait after each node (or worker batch) is healthy before continuing")
+	cmd.Flags().Duration("node-timeout", 20*time.Minute, "Maximum time for an upgraded node to come back healthy")
 }
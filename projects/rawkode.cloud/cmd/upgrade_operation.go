@@ -0,0 +1,761 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	upgradePhasePreFlight   = "pre-flight"
+	upgradePhasePostVerify  = "post-verify"
+	upgradePhaseWriteConfig = "write-config"
+	upgradeNodePhasePrefix  = "node/"
+)
+
+var (
+	upgradeLoadNodeStateFn   = loadNodeState
+	upgradeKubernetesApplyFn = prepareKubernetesUpgradeApply
+)
+
+// upgradeNodePhaseData is recorded on every completed node phase.
+type upgradeNodePhaseData struct {
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// upgradePhases returns the phases of an upgrade operation: pre-flight, one
+// phase per node in upgrade order, post-verify, and optionally write-config.
+func upgradePhases(nodes []cluster.NodeState, writeConfig bool) []string {
+	phases := make([]string, 0, len(nodes)+3)
+	phases = append(phases, upgradePhasePreFlight)
+	for _, node := range nodes {
+		phases = append(phases, upgradeNodePhasePrefix+node.Name)
+	}
+	phases = append(phases, upgradePhasePostVerify)
+	if writeConfig {
+		phases = append(phases, upgradePhaseWriteConfig)
+	}
+	return phases
+}
+
+func upgradeNodeFromPhase(phase string) (string, bool) {
+	return strings.CutPrefix(phase, upgradeNodePhasePrefix)
+}
+
+func upgradeComponent(opType operation.Type) string {
+	if opType == operation.TypeUpgradeK8s {
+		return "kubernetes"
+	}
+	return "talos"
+}
+
+func upgradeComponentTitle(opType operation.Type) string {
+	if opType == operation.TypeUpgradeK8s {
+		return "Kubernetes"
+	}
+	return "Talos"
+}
+
+// runUpgrade starts an upgrade operation, or continues the in-flight one for
+// the same version so an interrupted upgrade only touches remaining nodes.
+func runUpgrade(cmd *cobra.Command, opType operation.Type) error {
+	ctx := context.Background()
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	cfgFile, _ := cmd.Flags().GetString("file")
+	version, _ := cmd.Flags().GetString("version")
+	maxUnavailable, _ := cmd.Flags().GetInt("max-unavailable")
+	pauseBetween, _ := cmd.Flags().GetDuration("pause-between")
+	nodeTimeout, _ := cmd.Flags().GetDuration("node-timeout")
+	// Only `upgrade talos` drains; the flag is absent on `upgrade k8s`.
+	drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+	noWriteConfig, _ := cmd.Flags().GetBool("no-write-config")
+	force, _ := cmd.Flags().GetBool("force")
+
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return fmt.Errorf("--version is required")
+	}
+	if maxUnavailable < 1 {
+		return fmt.Errorf("--max-unavailable must be at least 1")
+	}
+
+	cfg, cfgPath, err := loadConfigForClusterOrFile(clusterName, cfgFile)
+	if err != nil {
+		return err
+	}
+
+	store, err := operationStoreForCommand(ctx, cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("open operation store: %w", err)
+	}
+
+	clusterLock, err := acquireClusterLock(ctx, cmd, cfg, "")
+	if err != nil {
+		return err
+	}
+	defer releaseClusterLock(clusterLock)
+
+	op, err := operation.LatestIncomplete(ctx, store, cfg.Environment, opType)
+	if err != nil {
+		return fmt.Errorf("look up in-flight %s operation: %w", opType, err)
+	}
+	if op != nil && op.GetContextString("version") != version {
+		return fmt.Errorf(
+			"operation %s is still in flight for version %s; resume or abort it before upgrading to %s",
+			op.ID, op.GetContextString("version"), version,
+		)
+	}
+	if op == nil {
+		state, err := upgradeLoadNodeStateFn(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		nodes := upgradeCandidateNodes(state)
+		if len(nodes) == 0 {
+			return fmt.Errorf("no active nodes found to upgrade")
+		}
+
+		op = operation.New(operation.GenerateID(), opType, cfg.Environment, upgradePhases(nodes, !noWriteConfig))
+		op.SetContext("version", version)
+		op.SetContext("configPath", cfgPath)
+		op.SetContext("maxUnavailable", strconv.Itoa(maxUnavailable))
+		op.SetContext("pauseBetween", pauseBetween.String())
+		op.SetContext("nodeTimeout", nodeTimeout.String())
+		if opType == operation.TypeUpgradeTalos {
+			op.SetContext("image", fmt.Sprintf("factory.talos.dev/installer/%s/%s", cfg.Cluster.TalosSchematic, version))
+			op.SetContext("drainTimeout", drainTimeout.String())
+		}
+		if force {
+			op.SetContext("force", "true")
+		}
+		if err := saveOperation(ctx, store, op); err != nil {
+			return err
+		}
+	} else {
+		slog.Info("resuming in-flight upgrade", "operation", op.ID, "resume_from", op.ResumePhase())
+		if force && op.GetContextString("force") != "true" {
+			op.SetContext("force", "true")
+			if err := saveOperation(ctx, store, op); err != nil {
+				return err
+			}
+		}
+	}
+	if err := clusterLock.SetOperationID(ctx, op.ID); err != nil {
+		return fmt.Errorf("record operation on cluster lock: %w", err)
+	}
+
+	slog.Info("starting upgrade operation",
+		"operation", op.ID,
+		"cluster", cfg.Environment,
+		"component", upgradeComponent(opType),
+		"version", version,
+		"resume_from", op.ResumePhase(),
+	)
+
+	result, err := executeUpgrade(ctx, store, op, cfg)
+	if err != nil {
+		err = fmt.Errorf("rolling %s upgrade aborted (resume with `operation resume --id %s`): %w", upgradeComponentTitle(opType), op.ID, err)
+		result.Error = err.Error()
+		if format, _ := outputFormat(cmd); format == outputFormatText {
+			printUpgradePreflight(result.Preflight)
+		}
+		return failWithResult(cmd, result, err)
+	}
+
+	return emitResult(cmd, result, func() {
+		printUpgradePreflight(result.Preflight)
+		fmt.Printf("Upgraded %s to %s on cluster %q (config=%s)\n", upgradeComponentTitle(opType), version, cfg.Environment, result.ConfigPath)
+		if result.ConfigUpdated {
+			fmt.Printf("Recorded %s: %s in %s\n", upgradeVersionKey(opType), version, result.ConfigPath)
+		}
+	})
+}
+
+// upgradeExecution holds what an upgrade operation needs to run its
+// remaining phases. It is rebuilt from the operation on every run.
+type upgradeExecution struct {
+	store   operation.Store
+	op      *operation.Operation
+	cfg     *config.Config
+	nodes   map[string]cluster.NodeState
+	talos   *rollingTalosUpgrade
+	kubelet *kubernetesUpgrade
+	opts    rollingUpgradeOptions
+
+	// preflight is the compatibility report of this run's pre-flight phase.
+	preflight []upgradeCheck
+
+	// mu guards op while node phases run concurrently.
+	mu sync.Mutex
+}
+
+// executeUpgrade runs the remaining phases of an upgrade operation and
+// returns the result built from every completed node phase.
+func executeUpgrade(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*upgradeResult, error) {
+	result := upgradeResultFromOperation(op, cfg, nil)
+
+	exec, err := newUpgradeExecution(ctx, store, op, cfg)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		phase := op.ResumePhase()
+		if phase == "" {
+			return upgradeResultFromOperation(op, cfg, exec.nodes), nil
+		}
+
+		var phaseErr error
+		if _, ok := upgradeNodeFromPhase(phase); ok {
+			phaseErr = exec.runNodeBatch(ctx, exec.nextNodeBatch())
+			if phaseErr == nil {
+				phaseErr = exec.pauseBeforeNextBatch(ctx)
+			}
+		} else {
+			phaseErr = exec.runPhase(ctx, phase)
+		}
+		if phaseErr != nil {
+			result := upgradeResultFromOperation(op, cfg, exec.nodes)
+			if len(result.Preflight) == 0 {
+				result.Preflight = exec.preflight
+			}
+			return result, phaseErr
+		}
+	}
+}
+
+func newUpgradeExecution(ctx context.Context, store operation.Store, op *operation.Operation, cfg *config.Config) (*upgradeExecution, error) {
+	opts, err := upgradeOptionsFromOperation(op)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := upgradeLoadNodeStateFn(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	candidates := upgradeCandidateNodes(state)
+	nodes := make(map[string]cluster.NodeState, len(candidates))
+	for _, node := range candidates {
+		nodes[node.Name] = node
+	}
+
+	materials, err := upgradeAccessMaterialsFn(ctx, "", op.GetContextString("configPath"))
+	if err != nil {
+		return nil, fmt.Errorf("load cluster access: %w", err)
+	}
+	kubeClient, err := upgradeKubeClientFn(materials.KubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := &upgradeExecution{
+		store: store,
+		op:    op,
+		cfg:   cfg,
+		nodes: nodes,
+		opts:  opts,
+		talos: &rollingTalosUpgrade{
+			kube:          kubeClient,
+			talosconfig:   materials.TalosconfigYAML,
+			image:         op.GetContextString("image"),
+			version:       op.GetContextString("version"),
+			controlPlanes: controlPlaneNodes(candidates),
+			opts:          opts,
+		},
+	}
+
+	if op.Type == operation.TypeUpgradeK8s {
+		apply, err := upgradeKubernetesApplyFn(ctx, cfg, state, op.GetContextString("version"))
+		if err != nil {
+			return nil, err
+		}
+		exec.kubelet = &kubernetesUpgrade{
+			kube:    kubeClient,
+			version: op.GetContextString("version"),
+			apply:   apply,
+			opts:    opts,
+		}
+	}
+
+	return exec, nil
+}
+
+func upgradeOptionsFromOperation(op *operation.Operation) (rollingUpgradeOptions, error) {
+	opts := rollingUpgradeOptions{MaxUnavailable: 1}
+
+	if value := op.GetContextString("maxUnavailable"); value != "" {
+		maxUnavailable, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, fmt.Errorf("parse maxUnavailable from operation context: %w", err)
+		}
+		opts.MaxUnavailable = max(maxUnavailable, 1)
+	}
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"pauseBetween", &opts.PauseBetween},
+		{"drainTimeout", &opts.DrainTimeout},
+		{"nodeTimeout", &opts.NodeTimeout},
+	}
+	for _, d := range durations {
+		value := op.GetContextString(d.key)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return opts, fmt.Errorf("parse %s from operation context: %w", d.key, err)
+		}
+		*d.dst = parsed
+	}
+
+	return opts, nil
+}
+
+// runPhase runs a single non-node phase.
+func (e *upgradeExecution) runPhase(ctx context.Context, phase string) error {
+	slog.Info("executing phase", "phase", phase, "operation", e.op.ID)
+
+	if err := startOperationPhase(ctx, e.store, e.op, phase); err != nil {
+		return err
+	}
+
+	var (
+		data     any
+		phaseErr error
+	)
+	switch phase {
+	case upgradePhasePreFlight:
+		phaseErr = e.preFlight(ctx)
+		data = upgradePreflightData{Checks: e.preflight, Forced: e.force()}
+	case upgradePhasePostVerify:
+		phaseErr = e.postVerify(ctx)
+	case upgradePhaseWriteConfig:
+		phaseErr = e.writeConfig()
+	default:
+		phaseErr = fmt.Errorf("unknown phase %q", phase)
+	}
+	if phaseErr != nil {
+		return failOperationPhase(ctx, e.store, e.op, phase, phaseErr)
+	}
+
+	if err := e.op.CompletePhase(phase, data); err != nil {
+		return fmt.Errorf("complete phase %s: %w", phase, err)
+	}
+	return saveOperation(ctx, e.store, e.op)
+}
+
+// nextNodeBatch returns the node phases to run together, starting from the
+// resume point. A control plane always runs alone so etcd keeps quorum;
+// consecutive workers are grouped up to MaxUnavailable.
+func (e *upgradeExecution) nextNodeBatch() []string {
+	var batch []string
+	for _, phase := range e.op.PhaseOrder {
+		status := e.op.Phases[phase].Status
+		if status == operation.PhaseCompleted || status == operation.PhaseSkipped {
+			if len(batch) > 0 {
+				break
+			}
+			continue
+		}
+
+		name, ok := upgradeNodeFromPhase(phase)
+		if !ok {
+			break
+		}
+		controlPlane := e.nodes[name].Role == config.NodeTypeControlPlane
+		if controlPlane && len(batch) > 0 {
+			break
+		}
+		batch = append(batch, phase)
+		if controlPlane || len(batch) >= e.opts.MaxUnavailable {
+			break
+		}
+	}
+	return batch
+}
+
+// runNodeBatch upgrades every node in the batch concurrently, recording each
+// node phase as it finishes.
+func (e *upgradeExecution) runNodeBatch(ctx context.Context, batch []string) error {
+	retried := make(map[string]bool, len(batch))
+	for _, phase := range batch {
+		retried[phase] = e.op.Phases[phase].Status != operation.PhasePending
+		slog.Info("executing phase", "phase", phase, "operation", e.op.ID, "retry", retried[phase])
+		if err := e.op.StartPhase(phase); err != nil {
+			return fmt.Errorf("start phase %s: %w", phase, err)
+		}
+	}
+	if err := saveOperation(ctx, e.store, e.op); err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(batch))
+	)
+	for i, phase := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = e.runNodePhase(ctx, phase, retried[phase])
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (e *upgradeExecution) runNodePhase(ctx context.Context, phase string, retry bool) error {
+	name, _ := upgradeNodeFromPhase(phase)
+
+	var (
+		skipped bool
+		err     error
+	)
+	node, ok := e.nodes[name]
+	if !ok {
+		// The node was removed from the inventory since the operation started.
+		slog.Warn("node no longer in inventory; skipping", "node", name, "operation", e.op.ID)
+		skipped = true
+	} else if e.kubelet != nil {
+		skipped, err = e.kubelet.upgradeNode(ctx, node)
+	} else {
+		skipped, err = e.talos.upgradeNode(ctx, node, retry)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		return failOperationPhase(ctx, e.store, e.op, phase, fmt.Errorf("node %s: %w", name, err))
+	}
+	if err := e.op.CompletePhase(phase, upgradeNodePhaseData{Skipped: skipped}); err != nil {
+		return fmt.Errorf("complete phase %s: %w", phase, err)
+	}
+	return saveOperation(ctx, e.store, e.op)
+}
+
+func (e *upgradeExecution) pauseBeforeNextBatch(ctx context.Context) error {
+	if e.opts.PauseBetween <= 0 {
+		return nil
+	}
+	if _, ok := upgradeNodeFromPhase(e.op.ResumePhase()); !ok {
+		return nil
+	}
+
+	slog.Info("pausing before next upgrade batch", "pause", e.opts.PauseBetween)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(e.opts.PauseBetween):
+		return nil
+	}
+}
+
+// preFlight refuses to start while the cluster is already degraded: every
+// node must answer the Talos API and be Ready, and etcd must be healthy. It
+// also validates the target version; failed compatibility checks block the
+// upgrade unless it was started with --force.
+func (e *upgradeExecution) preFlight(ctx context.Context) error {
+	if len(e.nodes) == 0 {
+		return fmt.Errorf("no active nodes found to upgrade")
+	}
+
+	var errs []error
+	talosVersions := make(map[string]string, len(e.nodes))
+	for _, name := range e.pendingNodeNames() {
+		node := e.nodes[name]
+		version, err := e.talos.talosVersion(ctx, node)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: read Talos version: %w", name, err))
+		} else {
+			talosVersions[name] = version
+		}
+		kubeNode, err := e.talos.kube.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+		} else if !kube.NodeReady(kubeNode) {
+			errs = append(errs, fmt.Errorf("node %s is not ready", name))
+		}
+	}
+	if err := e.talos.checkEtcdHealthy(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("etcd: %w", err))
+	}
+
+	e.preflight = e.compatibilityChecks(ctx, talosVersions)
+	if failed := failedUpgradeChecks(e.preflight); len(failed) > 0 {
+		if e.force() {
+			slog.Warn("continuing despite failed pre-flight checks", "checks", strings.Join(failed, ","), "operation", e.op.ID)
+		} else {
+			errs = append(errs, fmt.Errorf("pre-flight checks failed: %s (re-run with --force to override)", strings.Join(failed, ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e *upgradeExecution) force() bool {
+	return e.op.GetContextString("force") == "true"
+}
+
+// postVerify confirms every node reached the target version and the cluster
+// is healthy once all node phases are done.
+func (e *upgradeExecution) postVerify(ctx context.Context) error {
+	var errs []error
+	for _, name := range e.upgradedNodeNames() {
+		node := e.nodes[name]
+		if e.kubelet != nil {
+			if err := e.kubelet.checkVersion(ctx, node); err != nil {
+				errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+			}
+		} else {
+			version, err := e.talos.talosVersion(ctx, node)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("node %s: read Talos version: %w", name, err))
+			} else if normalizeVersionTag(version) != normalizeVersionTag(e.talos.version) {
+				errs = append(errs, fmt.Errorf("node %s: running Talos %s, want %s", name, version, e.talos.version))
+			}
+		}
+		if err := upgradeWaitNodeReadyFn(ctx, e.talos.kube, name, e.talos.nodeTimeout(), upgradePollInterval); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := e.talos.waitForEtcdHealthy(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeConfig records the upgraded version in the cluster config so nodes
+// added afterwards are provisioned on it.
+func (e *upgradeExecution) writeConfig() error {
+	path := e.op.GetContextString("configPath")
+	if path == "" {
+		return fmt.Errorf("operation has no config path recorded")
+	}
+
+	fileCfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	version := e.op.GetContextString("version")
+	if e.op.Type == operation.TypeUpgradeK8s {
+		fileCfg.Cluster.KubernetesVersion = version
+		e.cfg.Cluster.KubernetesVersion = version
+	} else {
+		fileCfg.Cluster.TalosVersion = version
+		e.cfg.Cluster.TalosVersion = version
+	}
+
+	if err := config.Save(path, fileCfg); err != nil {
+		return err
+	}
+
+	slog.Info("recorded upgraded version in cluster config", "config", path, "key", upgradeVersionKey(e.op.Type), "version", version)
+	return nil
+}
+
+func upgradeVersionKey(opType operation.Type) string {
+	if opType == operation.TypeUpgradeK8s {
+		return "cluster.kubernetesVersion"
+	}
+	return "cluster.talosVersion"
+}
+
+// pendingNodeNames lists inventory nodes whose phase has not completed.
+func (e *upgradeExecution) pendingNodeNames() []string {
+	var names []string
+	for _, phase := range e.op.PhaseOrder {
+		name, ok := upgradeNodeFromPhase(phase)
+		if !ok || e.op.Phases[phase].Status == operation.PhaseCompleted {
+			continue
+		}
+		if _, known := e.nodes[name]; known {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// upgradedNodeNames lists inventory nodes covered by the operation.
+func (e *upgradeExecution) upgradedNodeNames() []string {
+	var names []string
+	for _, phase := range e.op.PhaseOrder {
+		name, ok := upgradeNodeFromPhase(phase)
+		if !ok {
+			continue
+		}
+		if _, known := e.nodes[name]; known {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// upgradeResultFromOperation reports every completed node phase. nodes fills
+// in inventory details when they are known.
+func upgradeResultFromOperation(op *operation.Operation, cfg *config.Config, nodes map[string]cluster.NodeState) *upgradeResult {
+	result := &upgradeResult{
+		Cluster:    cfg.Environment,
+		ConfigPath: op.GetContextString("configPath"),
+		Component:  upgradeComponent(op.Type),
+		Version:    op.GetContextString("version"),
+		Image:      op.GetContextString("image"),
+		Nodes:      []upgradeNodeResult{},
+	}
+
+	var preflight upgradePreflightData
+	if err := op.PhaseData(upgradePhasePreFlight, &preflight); err == nil {
+		result.Preflight = preflight.Checks
+	}
+	if phase, ok := op.Phases[upgradePhaseWriteConfig]; ok {
+		result.ConfigUpdated = phase.Status == operation.PhaseCompleted
+	}
+
+	for _, phase := range op.PhaseOrder {
+		name, ok := upgradeNodeFromPhase(phase)
+		if !ok || op.Phases[phase].Status != operation.PhaseCompleted {
+			continue
+		}
+
+		nodeResult := upgradeNodeResult{Name: name}
+		if node, known := nodes[name]; known {
+			nodeResult = newUpgradeNodeResult(node)
+		}
+		var data upgradeNodePhaseData
+		if err := op.PhaseData(phase, &data); err == nil {
+			nodeResult.Skipped = data.Skipped
+		}
+		result.Nodes = append(result.Nodes, nodeResult)
+	}
+
+	return result
+}
+
+// kubernetesUpgrade applies a node config carrying the new Kubernetes
+// version and waits for the kubelet to report it.
+type kubernetesUpgrade struct {
+	kube    kubernetes.Interface
+	version string
+	apply   func(ctx context.Context, node cluster.NodeState) error
+	opts    rollingUpgradeOptions
+}
+
+func (u *kubernetesUpgrade) upgradeNode(ctx context.Context, node cluster.NodeState) (bool, error) {
+	if err := u.checkVersion(ctx, node); err == nil {
+		slog.Info("node already on target Kubernetes version", "node", node.Name, "version", u.version)
+		return true, nil
+	}
+
+	slog.Info("upgrading Kubernetes on node", "node", node.Name, "to", u.version)
+	if err := u.apply(ctx, node); err != nil {
+		return false, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, u.nodeTimeout())
+	defer cancel()
+
+	var lastErr error
+	for {
+		lastErr = u.checkVersion(waitCtx, node)
+		if lastErr == nil {
+			break
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return false, fmt.Errorf("kubelet did not report %s: %w", u.version, lastErr)
+		case <-time.After(upgradePollInterval):
+		}
+	}
+
+	deadline, _ := waitCtx.Deadline()
+	if err := upgradeWaitNodeReadyFn(waitCtx, u.kube, node.Name, time.Until(deadline), upgradePollInterval); err != nil {
+		return false, err
+	}
+
+	slog.Info("node upgraded", "node", node.Name, "version", u.version)
+	return false, nil
+}
+
+// checkVersion returns nil once the node's kubelet reports the target version.
+func (u *kubernetesUpgrade) checkVersion(ctx context.Context, node cluster.NodeState) error {
+	kubeNode, err := u.kube.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get node %s: %w", node.Name, err)
+	}
+
+	current := kubeNode.Status.NodeInfo.KubeletVersion
+	if normalizeVersionTag(current) != normalizeVersionTag(u.version) {
+		return fmt.Errorf("kubelet reports %s, want %s", defaultString(current, "unknown"), u.version)
+	}
+	if !kube.NodeReady(kubeNode) {
+		return fmt.Errorf("node is not ready")
+	}
+
+	return nil
+}
+
+func (u *kubernetesUpgrade) nodeTimeout() time.Duration {
+	if u.opts.NodeTimeout <= 0 {
+		return 20 * time.Minute
+	}
+	return u.opts.NodeTimeout
+}
+
+// prepareKubernetesUpgradeApply renders Talos assets for the target
+// Kubernetes version once and returns a function applying them per node.
+func prepareKubernetesUpgradeApply(
+	ctx context.Context,
+	cfg *config.Config,
+	state *cluster.NodesState,
+	version string,
+) (func(ctx context.Context, node cluster.NodeState) error, error) {
+	infClient, err := newInfisicalClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := controlPlaneEndpointFromState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgForUpgrade := *cfg
+	cfgForUpgrade.Cluster.KubernetesVersion = version
+
+	assets, err := ensureTalosAssets(ctx, &cfgForUpgrade, endpoint, infClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets.Talosconfig) == 0 {
+		return nil, fmt.Errorf("generated talosconfig is empty")
+	}
+	netbirdSetupKey, err := loadOptionalNetbirdSetupKeyFromInfisical(ctx, cfg, infClient)
+	if err != nil {
+		return nil, fmt.Errorf("load netbird setup key: %w", err)
+	}
+
+	return func(ctx context.Context, node cluster.NodeState) error {
+		return applyKubernetesUpgradeConfig(ctx, cfg, assets, netbirdSetupKey, node)
+	}, nil
+}
@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	upgradeInstallerImageExistsFn  = talos.InstallerImageExists
+	upgradeDeprecatedAPIRequestsFn = kube.DeprecatedAPIRequests
+)
+
+// talosKubernetesSupport is the range of Kubernetes minors each Talos minor
+// supports, from the Talos support matrix.
+var talosKubernetesSupport = map[int][2]int{
+	8:  {26, 31},
+	9:  {27, 32},
+	10: {28, 33},
+	11: {29, 34},
+	12: {30, 35},
+}
+
+// ciliumKubernetesSupport is the newest Kubernetes minor each Cilium minor is
+// tested against.
+var ciliumKubernetesSupport = map[int]int{
+	14: 28,
+	15: 29,
+	16: 30,
+	17: 32,
+	18: 33,
+	19: 35,
+}
+
+// upgradeCheck is one line of the pre-flight compatibility report. A check
+// that could not be decided, such as a release newer than the support tables
+// above, passes with Warning set.
+type upgradeCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Warning bool   `json:"warning,omitempty"`
+	Message string `json:"message"`
+}
+
+// upgradePreflightData is recorded on the completed pre-flight phase.
+type upgradePreflightData struct {
+	Checks []upgradeCheck `json:"checks"`
+	Forced bool           `json:"forced,omitempty"`
+}
+
+func passedCheck(name, format string, args ...any) upgradeCheck {
+	return upgradeCheck{Name: name, Passed: true, Message: fmt.Sprintf(format, args...)}
+}
+
+func warnedCheck(name, format string, args ...any) upgradeCheck {
+	return upgradeCheck{Name: name, Passed: true, Warning: true, Message: fmt.Sprintf(format, args...)}
+}
+
+func failedCheck(name, format string, args ...any) upgradeCheck {
+	return upgradeCheck{Name: name, Message: fmt.Sprintf(format, args...)}
+}
+
+func failedUpgradeChecks(checks []upgradeCheck) []string {
+	var names []string
+	for _, check := range checks {
+		if !check.Passed {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+// compatibilityChecks validates the upgrade target against what the cluster
+// runs today. talosVersions holds the current Talos version per node.
+func (e *upgradeExecution) compatibilityChecks(ctx context.Context, talosVersions map[string]string) []upgradeCheck {
+	version := e.op.GetContextString("version")
+	kubeletVersions, kubeletErr := currentKubeletVersions(ctx, e.talos.kube)
+
+	if e.op.Type == operation.TypeUpgradeK8s {
+		checks := []upgradeCheck{
+			checkTalosSupportsKubernetes(talosVersions, version),
+			checkKubernetesMinorSkew(kubeletVersions, kubeletErr, version),
+			checkCiliumSupportsKubernetes(e.cfg.Cluster.EffectiveCiliumVersion(), version),
+			checkRemovedAPIsInUse(ctx, e.talos.kube, version),
+		}
+		return checks
+	}
+
+	return []upgradeCheck{
+		checkTalosSupportsCurrentKubernetes(version, kubeletVersions, kubeletErr),
+		checkInstallerImage(ctx, e.cfg.Cluster.TalosSchematic, version),
+	}
+}
+
+// checkTalosSupportsCurrentKubernetes verifies the target Talos release
+// supports the Kubernetes versions the nodes run.
+func checkTalosSupportsCurrentKubernetes(talosVersion string, kubeletVersions map[string]string, kubeletErr error) upgradeCheck {
+	const name = "talos-kubernetes-support"
+	if kubeletErr != nil {
+		return failedCheck(name, "read kubelet versions: %v", kubeletErr)
+	}
+
+	talosMinor, err := versionMinor(talosVersion)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+	supported, ok := talosKubernetesSupport[talosMinor]
+	if !ok {
+		return warnedCheck(name, "Talos 1.%d is unknown to this build; its Kubernetes support was not checked", talosMinor)
+	}
+
+	var unsupported []string
+	for _, node := range sortedKeys(kubeletVersions) {
+		minor, err := versionMinor(kubeletVersions[node])
+		if err != nil || minor < supported[0] || minor > supported[1] {
+			unsupported = append(unsupported, fmt.Sprintf("%s (%s)", node, kubeletVersions[node]))
+		}
+	}
+	if len(unsupported) > 0 {
+		return failedCheck(name, "Talos 1.%d supports Kubernetes 1.%d-1.%d; unsupported: %s", talosMinor, supported[0], supported[1], strings.Join(unsupported, ", "))
+	}
+
+	return passedCheck(name, "Talos 1.%d supports Kubernetes 1.%d-1.%d", talosMinor, supported[0], supported[1])
+}
+
+// checkTalosSupportsKubernetes verifies every node's Talos release supports
+// the target Kubernetes version.
+func checkTalosSupportsKubernetes(talosVersions map[string]string, kubernetesVersion string) upgradeCheck {
+	const name = "talos-kubernetes-support"
+
+	kubeMinor, err := versionMinor(kubernetesVersion)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+
+	var unsupported, unknown []string
+	for _, node := range sortedKeys(talosVersions) {
+		talosMinor, err := versionMinor(talosVersions[node])
+		if err != nil {
+			unsupported = append(unsupported, fmt.Sprintf("%s (Talos %s)", node, talosVersions[node]))
+			continue
+		}
+		supported, known := talosKubernetesSupport[talosMinor]
+		switch {
+		case !known:
+			unknown = append(unknown, fmt.Sprintf("%s (Talos %s)", node, talosVersions[node]))
+		case kubeMinor < supported[0] || kubeMinor > supported[1]:
+			unsupported = append(unsupported, fmt.Sprintf("%s (Talos %s)", node, talosVersions[node]))
+		}
+	}
+	if len(talosVersions) == 0 {
+		return failedCheck(name, "no Talos versions could be read")
+	}
+	if len(unsupported) > 0 {
+		return failedCheck(name, "Kubernetes 1.%d is not supported on: %s", kubeMinor, strings.Join(unsupported, ", "))
+	}
+	if len(unknown) > 0 {
+		return warnedCheck(name, "Talos releases unknown to this build were not checked against Kubernetes 1.%d: %s", kubeMinor, strings.Join(unknown, ", "))
+	}
+
+	return passedCheck(name, "every node runs a Talos release supporting Kubernetes 1.%d", kubeMinor)
+}
+
+// checkKubernetesMinorSkew refuses to skip a Kubernetes minor release or to
+// move a node to an older minor.
+func checkKubernetesMinorSkew(kubeletVersions map[string]string, kubeletErr error, target string) upgradeCheck {
+	const name = "kubernetes-minor-skew"
+	if kubeletErr != nil {
+		return failedCheck(name, "read kubelet versions: %v", kubeletErr)
+	}
+
+	targetMinor, err := versionMinor(target)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+
+	var problems []string
+	for _, node := range sortedKeys(kubeletVersions) {
+		minor, err := versionMinor(kubeletVersions[node])
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", node, err))
+		case targetMinor > minor+1:
+			problems = append(problems, fmt.Sprintf("%s would skip from 1.%d to 1.%d", node, minor, targetMinor))
+		case targetMinor < minor:
+			problems = append(problems, fmt.Sprintf("%s would downgrade from 1.%d to 1.%d", node, minor, targetMinor))
+		}
+	}
+	if len(problems) > 0 {
+		return failedCheck(name, "%s", strings.Join(problems, "; "))
+	}
+
+	return passedCheck(name, "no Kubernetes minor release is skipped")
+}
+
+// checkCiliumSupportsKubernetes verifies the configured Cilium release is
+// tested against the target Kubernetes minor.
+func checkCiliumSupportsKubernetes(ciliumVersion, kubernetesVersion string) upgradeCheck {
+	const name = "cilium-support"
+
+	kubeMinor, err := versionMinor(kubernetesVersion)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+	ciliumMinor, err := versionMinor(ciliumVersion)
+	if err != nil {
+		return failedCheck(name, "cilium: %v", err)
+	}
+	newest, ok := ciliumKubernetesSupport[ciliumMinor]
+	if !ok {
+		return warnedCheck(name, "Cilium 1.%d is unknown to this build; its Kubernetes support was not checked", ciliumMinor)
+	}
+	if kubeMinor > newest {
+		return failedCheck(name, "Cilium %s supports Kubernetes up to 1.%d; upgrade Cilium first", ciliumVersion, newest)
+	}
+
+	return passedCheck(name, "Cilium %s supports Kubernetes 1.%d", ciliumVersion, kubeMinor)
+}
+
+// checkInstallerImage verifies the Image Factory serves the installer for the
+// configured schematic and target version.
+func checkInstallerImage(ctx context.Context, schematic, version string) upgradeCheck {
+	const name = "installer-image"
+
+	if strings.TrimSpace(schematic) == "" {
+		return failedCheck(name, "cluster.talosSchematic is not set")
+	}
+	exists, err := upgradeInstallerImageExistsFn(ctx, schematic, version)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+	if !exists {
+		return failedCheck(name, "factory.talos.dev has no installer for schematic %s at %s", schematic, version)
+	}
+
+	return passedCheck(name, "factory.talos.dev serves installer %s for schematic %s", version, schematic)
+}
+
+// checkRemovedAPIsInUse fails when clients still request an API that the
+// target Kubernetes release no longer serves.
+func checkRemovedAPIsInUse(ctx context.Context, client kubernetes.Interface, kubernetesVersion string) upgradeCheck {
+	const name = "deprecated-apis"
+
+	targetMinor, err := versionMinor(kubernetesVersion)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+
+	inUse, err := removedAPIsInUse(ctx, client, targetMinor)
+	if err != nil {
+		return failedCheck(name, "%v", err)
+	}
+	if len(inUse) > 0 {
+		names := make([]string, 0, len(inUse))
+		for _, request := range inUse {
+			names = append(names, fmt.Sprintf("%s (removed in %s)", request, request.RemovedRelease))
+		}
+		return failedCheck(name, "APIs removed by 1.%d are still requested: %s", targetMinor, strings.Join(names, ", "))
+	}
+
+	return passedCheck(name, "no API removed by 1.%d is in use", targetMinor)
+}
+
+// removedAPIsInUse returns the deprecated APIs clients requested that are
+// still served by discovery and removed at or before targetMinor.
+func removedAPIsInUse(ctx context.Context, client kubernetes.Interface, targetMinor int) ([]kube.DeprecatedAPIRequest, error) {
+	requests, err := upgradeDeprecatedAPIRequestsFn(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var inUse []kube.DeprecatedAPIRequest
+	for _, request := range requests {
+		removedMinor, err := versionMinor(request.RemovedRelease)
+		if err != nil || removedMinor > targetMinor {
+			continue
+		}
+
+		if _, err := client.Discovery().ServerResourcesForGroupVersion(request.GroupVersion()); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("discover %s: %w", request.GroupVersion(), err)
+		}
+		inUse = append(inUse, request)
+	}
+
+	return inUse, nil
+}
+
+// currentKubeletVersions maps node name to the kubelet version it reports.
+func currentKubeletVersions(ctx context.Context, client kubernetes.Interface) (map[string]string, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+
+	versions := make(map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		versions[node.Name] = node.Status.NodeInfo.KubeletVersion
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no nodes registered")
+	}
+
+	return versions, nil
+}
+
+// versionMinor returns the minor of a 1.x version such as v1.35.2 or 1.32.
+func versionMinor(version string) (int, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	parts := strings.SplitN(trimmed, ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("unrecognised version %q", version)
+	}
+
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("unrecognised version %q", version)
+	}
+	return minor, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func printUpgradePreflight(checks []upgradeCheck) {
+	if len(checks) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	for _, check := range checks {
+		result := "pass"
+		switch {
+		case !check.Passed:
+			result = "FAIL"
+		case check.Warning:
+			result = "warn"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, result, check.Message)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExecuteUpgradeBlocksOnFailedPreflightUnlessForced(t *testing.T) {
+	talosCluster := &fakeTalosCluster{versions: map[string]string{
+		"1.1.1.1": "v1.9.4",
+		"2.2.2.2": "v1.9.4",
+		"3.3.3.3": "v1.9.4",
+	}}
+	newRollingUpgradeTest(t, talosCluster)
+	upgradeInstallerImageExistsFn = func(ctx context.Context, schematic, version string) (bool, error) {
+		return false, nil
+	}
+
+	nodes := rollingUpgradeTestNodes()
+	kubeClient := fake.NewSimpleClientset(readyTestNode(nodes[0].Name), readyTestNode(nodes[1].Name), readyTestNode(nodes[2].Name))
+	store, op := newUpgradeTestOperation(t, nodes, kubeClient, "1", "")
+
+	result, err := executeUpgrade(context.Background(), store, op, upgradeTestConfig())
+	if err == nil || !strings.Contains(err.Error(), "installer-image") {
+		t.Fatalf("executeUpgrade error = %v, want installer-image pre-flight failure", err)
+	}
+	if len(talosCluster.events) != 0 {
+		t.Fatalf("nodes were touched despite failed pre-flight: %v", talosCluster.events)
+	}
+	if failed := failedUpgradeChecks(result.Preflight); len(failed) != 1 || failed[0] != "installer-image" {
+		t.Fatalf("failed checks = %v, want [installer-image]", failed)
+	}
+
+	op.SetContext("force", "true")
+	result, err = executeUpgrade(context.Background(), store, op, upgradeTestConfig())
+	if err != nil {
+		t.Fatalf("forced executeUpgrade returned error: %v", err)
+	}
+	if len(result.Preflight) != 2 {
+		t.Fatalf("len(result.Preflight) = %d, want 2", len(result.Preflight))
+	}
+}
+
+func TestKubernetesUpgradeCompatibilityChecks(t *testing.T) {
+	kubelets := map[string]string{"cp-01": "v1.32.3", "worker-01": "v1.31.9"}
+
+	tests := []struct {
+		name  string
+		check upgradeCheck
+		want  bool
+	}{
+		{"next minor", checkKubernetesMinorSkew(map[string]string{"cp-01": "v1.32.3"}, nil, "v1.33.0"), true},
+		{"skips a minor", checkKubernetesMinorSkew(kubelets, nil, "v1.33.0"), false},
+		{"downgrade", checkKubernetesMinorSkew(kubelets, nil, "v1.30.0"), false},
+		{"talos supports target", checkTalosSupportsKubernetes(map[string]string{"cp-01": "v1.10.2"}, "v1.33.0"), true},
+		{"talos too old", checkTalosSupportsKubernetes(map[string]string{"cp-01": "v1.9.5"}, "v1.33.0"), false},
+		{"cilium supports target", checkCiliumSupportsKubernetes("v1.19.0", "v1.35.0"), true},
+		{"cilium too old", checkCiliumSupportsKubernetes("v1.16.4", "v1.32.0"), false},
+		{"talos target supports kubelets", checkTalosSupportsCurrentKubernetes("v1.12.0", kubelets, nil), true},
+		{"talos target drops kubelets", checkTalosSupportsCurrentKubernetes("v1.12.0", map[string]string{"cp-01": "v1.29.0"}, nil), false},
+	}
+	for _, tt := range tests {
+		if tt.check.Passed != tt.want {
+			t.Fatalf("%s: Passed = %t, want %t (%s)", tt.name, tt.check.Passed, tt.want, tt.check.Message)
+		}
+	}
+}
+
+func TestCompatibilityChecksWarnOnMinorsUnknownToThisBuild(t *testing.T) {
+	kubelets := map[string]string{"cp-01": "v1.35.0"}
+
+	tests := []struct {
+		name  string
+		check upgradeCheck
+	}{
+		{"talos node", checkTalosSupportsKubernetes(map[string]string{"cp-01": "v1.12.1", "cp-02": "v1.99.0"}, "v1.35.0")},
+		{"talos target", checkTalosSupportsCurrentKubernetes("v1.99.0", kubelets, nil)},
+		{"cilium", checkCiliumSupportsKubernetes("v1.99.0", "v1.35.0")},
+	}
+	for _, tt := range tests {
+		if !tt.check.Passed || !tt.check.Warning {
+			t.Fatalf("%s: Passed = %t, Warning = %t, want a passed check with a warning (%s)", tt.name, tt.check.Passed, tt.check.Warning, tt.check.Message)
+		}
+		if !strings.Contains(tt.check.Message, "unknown to this build") {
+			t.Fatalf("%s: message = %q, want it to say the release is unknown to this build", tt.name, tt.check.Message)
+		}
+	}
+
+	if check := checkTalosSupportsKubernetes(map[string]string{"cp-01": "v1.9.5", "cp-02": "v1.99.0"}, "v1.33.0"); check.Passed {
+		t.Fatalf("a known Talos release lacking support must still fail: %s", check.Message)
+	}
+}
+
+func TestRemovedAPIsInUseOnlyReportsServedAPIsRemovedByTarget(t *testing.T) {
+	t.Cleanup(restoreUpgradeFns())
+
+	client := fake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	}
+	upgradeDeprecatedAPIRequestsFn = func(ctx context.Context, client kubernetes.Interface) ([]kube.DeprecatedAPIRequest, error) {
+		return []kube.DeprecatedAPIRequest{
+			{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta3", Resource: "flowschemas", RemovedRelease: "1.32"},
+			{Group: "batch", Version: "v1beta1", Resource: "cronjobs", RemovedRelease: "1.25"},
+			{Version: "v1", Resource: "endpoints"},
+		}, nil
+	}
+
+	if check := checkRemovedAPIsInUse(context.Background(), client, "v1.31.0"); !check.Passed {
+		t.Fatalf("1.31 check failed: %s", check.Message)
+	}
+
+	check := checkRemovedAPIsInUse(context.Background(), client, "v1.32.0")
+	if check.Passed {
+		t.Fatal("expected the 1.32 check to fail")
+	}
+	if !strings.Contains(check.Message, "flowschemas.flowcontrol.apiserver.k8s.io/v1beta3") || strings.Contains(check.Message, "cronjobs") {
+		t.Fatalf("check message = %q, want only flowschemas", check.Message)
+	}
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	"k8s.io/client-go/kubernetes"
+)
+
+// talosUpgradeClient is the part of the Talos client a rolling upgrade uses.
+type talosUpgradeClient interface {
+	Version(ctx context.Context) (string, error)
+	Upgrade(ctx context.Context, imageURL string) error
+	HealthCheck(ctx context.Context) error
+	EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error)
+	EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error)
+	Close() error
+}
+
+var (
+	upgradeAccessMaterialsFn = buildClusterAccessMaterials
+	upgradeKubeClientFn      = kube.NewClient
+	upgradeTalosClientFn     = func(endpoint string, talosconfig []byte) (talosUpgradeClient, error) {
+		return talos.NewClient(endpoint, talosconfig)
+	}
+	upgradeCordonFn        = kube.Cordon
+	upgradeDrainFn         = kube.Drain
+	upgradeWaitNodeReadyFn = kube.WaitForNodeReady
+	upgradePollInterval    = 10 * time.Second
+)
+
+// rollingUpgradeOptions controls pacing and timeouts of a rolling upgrade.
+type rollingUpgradeOptions struct {
+	MaxUnavailable int
+	PauseBetween   time.Duration
+	DrainTimeout   time.Duration
+	NodeTimeout    time.Duration
+}
+
+// rollingTalosUpgrade takes nodes out of service, upgrades them, and waits
+// for them to come back healthy before touching the next batch.
+type rollingTalosUpgrade struct {
+	kube          kubernetes.Interface
+	talosconfig   []byte
+	image         string
+	version       string
+	controlPlanes []cluster.NodeState
+	opts          rollingUpgradeOptions
+}
+
+// upgradeNode runs the full cordon, drain, upgrade, verify, uncordon cycle
+// for one node. A node that fails after the upgrade was triggered is left
+// cordoned for investigation. On retry a node already on the target version
+// may be the one left cordoned, so it is verified and uncordoned instead of
+// being skipped.
+func (u *rollingTalosUpgrade) upgradeNode(ctx context.Context, node cluster.NodeState, retry bool) (bool, error) {
+	current, err := u.talosVersion(ctx, node)
+	if err != nil {
+		return false, fmt.Errorf("read current Talos version: %w", err)
+	}
+	if normalizeVersionTag(current) == normalizeVersionTag(u.version) {
+		if retry {
+			slog.Info("node reached target Talos version on a previous attempt; verifying", "node", node.Name, "version", current)
+			if err := u.verifyNode(ctx, node); err != nil {
+				return false, err
+			}
+			return false, u.uncordonNode(ctx, node)
+		}
+		slog.Info("node already on target Talos version", "node", node.Name, "version", current)
+		return true, nil
+	}
+
+	slog.Info("upgrading node", "node", node.Name, "from", current, "to", u.version)
+
+	if err := u.drainNode(ctx, node); err != nil {
+		return false, err
+	}
+
+	if err := u.withTalosClient(node, func(client talosUpgradeClient) error {
+		return client.Upgrade(ctx, u.image)
+	}); err != nil {
+		return false, fmt.Errorf("trigger upgrade: %w", err)
+	}
+
+	if err := u.verifyNode(ctx, node); err != nil {
+		return false, err
+	}
+
+	if err := u.uncordonNode(ctx, node); err != nil {
+		return false, err
+	}
+
+	slog.Info("node upgraded", "node", node.Name, "version", u.version)
+	return false, nil
+}
+
+// drainNode cordons and drains the node. If draining fails the node is made
+// schedulable again since nothing has been changed on it yet.
+func (u *rollingTalosUpgrade) drainNode(ctx context.Context, node cluster.NodeState) error {
+	if err := upgradeCordonFn(ctx, u.kube, node.Name, true); err != nil {
+		return err
+	}
+
+	if err := upgradeDrainFn(ctx, u.kube, node.Name, kube.DrainOptions{Timeout: u.opts.DrainTimeout}); err != nil {
+		if uncordonErr := upgradeCordonFn(ctx, u.kube, node.Name, false); uncordonErr != nil {
+			return errors.Join(err, uncordonErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// verifyNode waits until the node runs the target version, etcd is healthy
+// when it is a control plane, Talos services are healthy, and Kubernetes
+// reports the node Ready.
+func (u *rollingTalosUpgrade) verifyNode(ctx context.Context, node cluster.NodeState) error {
+	verifyCtx, cancel := context.WithTimeout(ctx, u.nodeTimeout())
+	defer cancel()
+
+	if err := u.waitForTalosVersion(verifyCtx, node); err != nil {
+		return err
+	}
+
+	if node.Role == config.NodeTypeControlPlane {
+		if err := u.waitForEtcdHealthy(verifyCtx); err != nil {
+			return err
+		}
+	}
+
+	if err := u.withTalosClient(node, func(client talosUpgradeClient) error {
+		return client.HealthCheck(verifyCtx)
+	}); err != nil {
+		return fmt.Errorf("talos health check: %w", err)
+	}
+
+	deadline, _ := verifyCtx.Deadline()
+	return upgradeWaitNodeReadyFn(verifyCtx, u.kube, node.Name, time.Until(deadline), upgradePollInterval)
+}
+
+func (u *rollingTalosUpgrade) waitForTalosVersion(ctx context.Context, node cluster.NodeState) error {
+	want := normalizeVersionTag(u.version)
+
+	var last string
+	var lastErr error
+	for {
+		version, err := u.talosVersion(ctx, node)
+		if err == nil && normalizeVersionTag(version) == want {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			last = version
+		}
+
+		select {
+		case <-ctx.Done():
+			if last != "" {
+				return fmt.Errorf("node still reports Talos %s, want %s", last, want)
+			}
+			return fmt.Errorf("node did not return on Talos %s: %w", want, errors.Join(ctx.Err(), lastErr))
+		case <-time.After(upgradePollInterval):
+		}
+	}
+}
+
+// waitForEtcdHealthy waits until every etcd member answers with a status
+// free of errors, so taking down the next control plane keeps quorum.
+func (u *rollingTalosUpgrade) waitForEtcdHealthy(ctx context.Context) error {
+	var lastErr error
+	for {
+		lastErr = u.checkEtcdHealthy(ctx)
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("etcd did not become healthy: %w", lastErr)
+		case <-time.After(upgradePollInterval):
+		}
+	}
+}
+
+func (u *rollingTalosUpgrade) checkEtcdHealthy(ctx context.Context) error {
+	if len(u.controlPlanes) == 0 {
+		return fmt.Errorf("no control-plane nodes known")
+	}
+
+	var members []talos.EtcdMember
+	if err := u.withTalosClient(u.controlPlanes[0], func(client talosUpgradeClient) error {
+		var err error
+		members, err = client.EtcdMembers(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	healthy := 0
+	var errs []error
+	for _, node := range u.controlPlanes {
+		err := u.withTalosClient(node, func(client talosUpgradeClient) error {
+			status, err := client.EtcdStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if len(status.Errors) > 0 {
+				return fmt.Errorf("member reports errors: %v", status.Errors)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node.Name, err))
+			continue
+		}
+		healthy++
+	}
+
+	if healthy < len(members) {
+		return fmt.Errorf("%d/%d etcd members healthy: %w", healthy, len(members), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (u *rollingTalosUpgrade) uncordonNode(ctx context.Context, node cluster.NodeState) error {
+	uncordonCtx, cancel := context.WithTimeout(ctx, u.nodeTimeout())
+	defer cancel()
+
+	for {
+		err := upgradeCordonFn(uncordonCtx, u.kube, node.Name, false)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-uncordonCtx.Done():
+			return err
+		case <-time.After(upgradePollInterval):
+		}
+	}
+}
+
+func (u *rollingTalosUpgrade) talosVersion(ctx context.Context, node cluster.NodeState) (string, error) {
+	var version string
+	err := u.withTalosClient(node, func(client talosUpgradeClient) error {
+		var err error
+		version, err = client.Version(ctx)
+		return err
+	})
+	return version, err
+}
+
+// withTalosClient opens a fresh client per call; nodes reboot during an
+// upgrade, so long-lived connections are not worth keeping.
+func (u *rollingTalosUpgrade) withTalosClient(node cluster.NodeState, fn func(client talosUpgradeClient) error) error {
+	client, err := upgradeTalosClientFn(node.PublicIP, u.talosconfig)
+	if err != nil {
+		return fmt.Errorf("create talos client: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
+
+func (u *rollingTalosUpgrade) nodeTimeout() time.Duration {
+	if u.opts.NodeTimeout <= 0 {
+		return 20 * time.Minute
+	}
+	return u.opts.NodeTimeout
+}
@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/cluster"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/config"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/kube"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/talos"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restoreUpgradeFns() func() {
+	accessMaterials := upgradeAccessMaterialsFn
+	kubeClient := upgradeKubeClientFn
+	talosClient := upgradeTalosClientFn
+	cordon := upgradeCordonFn
+	drain := upgradeDrainFn
+	waitNodeReady := upgradeWaitNodeReadyFn
+	pollInterval := upgradePollInterval
+	loadNodeState := upgradeLoadNodeStateFn
+	installerImageExists := upgradeInstallerImageExistsFn
+	deprecatedAPIRequests := upgradeDeprecatedAPIRequestsFn
+
+	return func() {
+		upgradeAccessMaterialsFn = accessMaterials
+		upgradeKubeClientFn = kubeClient
+		upgradeTalosClientFn = talosClient
+		upgradeCordonFn = cordon
+		upgradeDrainFn = drain
+		upgradeWaitNodeReadyFn = waitNodeReady
+		upgradePollInterval = pollInterval
+		upgradeLoadNodeStateFn = loadNodeState
+		upgradeInstallerImageExistsFn = installerImageExists
+		upgradeDeprecatedAPIRequestsFn = deprecatedAPIRequests
+	}
+}
+
+// fakeTalosCluster tracks the Talos version of every node by endpoint and
+// records the order of upgrade steps.
+type fakeTalosCluster struct {
+	mu          sync.Mutex
+	versions    map[string]string
+	failUpgrade map[string]bool
+	events      []string
+}
+
+func (f *fakeTalosCluster) record(event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
+type fakeTalosUpgradeClient struct {
+	cluster  *fakeTalosCluster
+	endpoint string
+}
+
+func (c *fakeTalosUpgradeClient) Version(ctx context.Context) (string, error) {
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	return c.cluster.versions[c.endpoint], nil
+}
+
+func (c *fakeTalosUpgradeClient) Upgrade(ctx context.Context, imageURL string) error {
+	c.cluster.record("upgrade " + c.endpoint)
+	c.cluster.mu.Lock()
+	defer c.cluster.mu.Unlock()
+	if c.cluster.failUpgrade[c.endpoint] {
+		return errors.New("installer image pull failed")
+	}
+	c.cluster.versions[c.endpoint] = "v1.9.5"
+	return nil
+}
+
+func (c *fakeTalosUpgradeClient) HealthCheck(ctx context.Context) error { return nil }
+
+func (c *fakeTalosUpgradeClient) EtcdMembers(ctx context.Context) ([]talos.EtcdMember, error) {
+	return []talos.EtcdMember{{ID: 1, Hostname: "production-control-plane-01"}}, nil
+}
+
+func (c *fakeTalosUpgradeClient) EtcdStatus(ctx context.Context) (*talos.EtcdMemberStatus, error) {
+	return &talos.EtcdMemberStatus{MemberID: 1, Leader: 1}, nil
+}
+
+func (c *fakeTalosUpgradeClient) Close() error { return nil }
+
+func newRollingUpgradeTest(t *testing.T, talosCluster *fakeTalosCluster) {
+	t.Helper()
+	t.Cleanup(restoreUpgradeFns())
+
+	upgradePollInterval = time.Millisecond
+	upgradeInstallerImageExistsFn = func(ctx context.Context, schematic, version string) (bool, error) {
+		return true, nil
+	}
+	upgradeDeprecatedAPIRequestsFn = func(ctx context.Context, client kubernetes.Interface) ([]kube.DeprecatedAPIRequest, error) {
+		return nil, nil
+	}
+	upgradeTalosClientFn = func(endpoint string, talosconfig []byte) (talosUpgradeClient, error) {
+		return &fakeTalosUpgradeClient{cluster: talosCluster, endpoint: endpoint}, nil
+	}
+	upgradeCordonFn = func(ctx context.Context, client kubernetes.Interface, nodeName string, unschedulable bool) error {
+		if unschedulable {
+			talosCluster.record("cordon " + nodeName)
+		} else {
+			talosCluster.record("uncordon " + nodeName)
+		}
+		return kube.Cordon(ctx, client, nodeName, unschedulable)
+	}
+	upgradeDrainFn = func(ctx context.Context, client kubernetes.Interface, nodeName string, opts kube.DrainOptions) error {
+		talosCluster.record("drain " + nodeName)
+		return nil
+	}
+}
+
+func readyTestNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.32.0"},
+		},
+	}
+}
+
+func upgradeTestConfig() *config.Config {
+	return &config.Config{
+		Environment: "production",
+		Cluster:     config.ClusterConfig{TalosSchematic: "abc", CiliumVersion: "v1.17.0"},
+	}
+}
+
+func rollingUpgradeTestNodes() []cluster.NodeState {
+	return []cluster.NodeState{
+		{Name: "production-control-plane-01", Role: config.NodeTypeControlPlane, PublicIP: "1.1.1.1"},
+		{Name: "production-worker-01", Role: config.NodeTypeWorker, PublicIP: "2.2.2.2"},
+		{Name: "production-worker-02", Role: config.NodeTypeWorker, PublicIP: "3.3.3.3"},
+	}
+}
+
+// newUpgradeTestOperation records a Talos upgrade to v1.9.5. A non-empty
+// configPath adds the write-config phase.
+func newUpgradeTestOperation(t *testing.T, nodes []cluster.NodeState, kubeClient kubernetes.Interface, maxUnavailable, configPath string) (operation.Store, *operation.Operation) {
+	t.Helper()
+
+	upgradeLoadNodeStateFn = func(ctx context.Context, cfg *config.Config) (*cluster.NodesState, error) {
+		return &cluster.NodesState{Environment: cfg.Environment, Nodes: nodes}, nil
+	}
+	upgradeAccessMaterialsFn = func(ctx context.Context, clusterName, cfgFile string) (*clusterAccessMaterials, error) {
+		return &clusterAccessMaterials{ConfigPath: cfgFile}, nil
+	}
+	upgradeKubeClientFn = func(kubeconfigYAML []byte) (kubernetes.Interface, error) {
+		return kubeClient, nil
+	}
+
+	store, err := operation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	op := operation.New("op-upgrade", operation.TypeUpgradeTalos, "production", upgradePhases(upgradeCandidateNodes(&cluster.NodesState{Nodes: nodes}), configPath != ""))
+	op.SetContext("version", "v1.9.5")
+	op.SetContext("configPath", configPath)
+	op.SetContext("maxUnavailable", maxUnavailable)
+	op.SetContext("nodeTimeout", "1s")
+	if err := store.Save(context.Background(), op); err != nil {
+		t.Fatalf("save operation: %v", err)
+	}
+
+	return store, op
+}
+
+func TestExecuteUpgradeGatesEachNode(t *testing.T) {
+	talosCluster := &fakeTalosCluster{versions: map[string]string{
+		"1.1.1.1": "v1.9.4",
+		"2.2.2.2": "v1.9.5",
+		"3.3.3.3": "v1.9.4",
+	}}
+	newRollingUpgradeTest(t, talosCluster)
+
+	nodes := rollingUpgradeTestNodes()
+	kubeClient := fake.NewSimpleClientset(readyTestNode(nodes[0].Name), readyTestNode(nodes[1].Name), readyTestNode(nodes[2].Name))
+	store, op := newUpgradeTestOperation(t, nodes, kubeClient, "1", "")
+
+	result, err := executeUpgrade(context.Background(), store, op, upgradeTestConfig())
+	if err != nil {
+		t.Fatalf("executeUpgrade returned error: %v", err)
+	}
+	if !op.IsComplete() {
+		t.Fatalf("operation not complete; resume phase %q", op.ResumePhase())
+	}
+
+	want := []string{
+		"cordon production-control-plane-01",
+		"drain production-control-plane-01",
+		"upgrade 1.1.1.1",
+		"uncordon production-control-plane-01",
+		"cordon production-worker-02",
+		"drain production-worker-02",
+		"upgrade 3.3.3.3",
+		"uncordon production-worker-02",
+	}
+	assertUpgradeEvents(t, talosCluster.events, want)
+
+	if len(result.Nodes) != 3 {
+		t.Fatalf("len(result.Nodes) = %d, want 3", len(result.Nodes))
+	}
+	if !result.Nodes[1].Skipped || result.Nodes[1].Name != "production-worker-01" {
+		t.Fatalf("result.Nodes[1] = %+v, want skipped production-worker-01", result.Nodes[1])
+	}
+
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "production-worker-02", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node: %v", err)
+	}
+	if node.Spec.Unschedulable {
+		t.Fatal("expected production-worker-02 to be uncordoned")
+	}
+}
+
+func TestExecuteUpgradeResumesOnlyRemainingNodes(t *testing.T) {
+	talosCluster := &fakeTalosCluster{
+		versions: map[string]string{
+			"1.1.1.1": "v1.9.4",
+			"2.2.2.2": "v1.9.4",
+			"3.3.3.3": "v1.9.4",
+		},
+		failUpgrade: map[string]bool{"2.2.2.2": true},
+	}
+	newRollingUpgradeTest(t, talosCluster)
+
+	nodes := rollingUpgradeTestNodes()
+	kubeClient := fake.NewSimpleClientset(readyTestNode(nodes[0].Name), readyTestNode(nodes[1].Name), readyTestNode(nodes[2].Name))
+	store, op := newUpgradeTestOperation(t, nodes, kubeClient, "1", "")
+	cfg := upgradeTestConfig()
+
+	if _, err := executeUpgrade(context.Background(), store, op, cfg); err == nil {
+		t.Fatal("expected executeUpgrade to fail")
+	}
+	for _, event := range talosCluster.events {
+		if event == "upgrade 3.3.3.3" || event == "cordon production-worker-02" {
+			t.Fatalf("upgrade continued past the failed node: %v", talosCluster.events)
+		}
+	}
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "production-worker-01", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node: %v", err)
+	}
+	if !node.Spec.Unschedulable {
+		t.Fatal("expected the failed node to stay cordoned")
+	}
+
+	persisted, err := store.Load(context.Background(), "production", op.ID)
+	if err != nil {
+		t.Fatalf("load operation: %v", err)
+	}
+	if got := persisted.ResumePhase(); got != "node/production-worker-01" {
+		t.Fatalf("ResumePhase() = %q, want %q", got, "node/production-worker-01")
+	}
+	if got := persisted.Phases["node/production-worker-01"].Status; got != operation.PhaseFailed {
+		t.Fatalf("failed node phase status = %q, want %q", got, operation.PhaseFailed)
+	}
+
+	// The installer image is fixed and the operation is resumed from the store.
+	talosCluster.mu.Lock()
+	talosCluster.failUpgrade = nil
+	talosCluster.events = nil
+	talosCluster.mu.Unlock()
+
+	result, err := executeUpgrade(context.Background(), store, persisted, cfg)
+	if err != nil {
+		t.Fatalf("resumed executeUpgrade returned error: %v", err)
+	}
+	if !persisted.IsComplete() {
+		t.Fatalf("operation not complete; resume phase %q", persisted.ResumePhase())
+	}
+
+	want := []string{
+		"cordon production-worker-01",
+		"drain production-worker-01",
+		"upgrade 2.2.2.2",
+		"uncordon production-worker-01",
+		"cordon production-worker-02",
+		"drain production-worker-02",
+		"upgrade 3.3.3.3",
+		"uncordon production-worker-02",
+	}
+	assertUpgradeEvents(t, talosCluster.events, want)
+	if len(result.Nodes) != 3 {
+		t.Fatalf("len(result.Nodes) = %d, want 3", len(result.Nodes))
+	}
+}
+
+func TestNextNodeBatchKeepsControlPlanesAlone(t *testing.T) {
+	nodes := []cluster.NodeState{
+		{Name: "cp-01", Role: config.NodeTypeControlPlane},
+		{Name: "cp-02", Role: config.NodeTypeControlPlane},
+		{Name: "worker-01", Role: config.NodeTypeWorker},
+		{Name: "worker-02", Role: config.NodeTypeWorker},
+		{Name: "worker-03", Role: config.NodeTypeWorker},
+	}
+	byName := make(map[string]cluster.NodeState, len(nodes))
+	for _, node := range nodes {
+		byName[node.Name] = node
+	}
+
+	op := operation.New("op-batches", operation.TypeUpgradeTalos, "production", upgradePhases(nodes, false))
+	_ = op.CompletePhase(upgradePhasePreFlight, nil)
+	exec := &upgradeExecution{op: op, nodes: byName, opts: rollingUpgradeOptions{MaxUnavailable: 2}}
+
+	want := [][]string{
+		{"node/cp-01"},
+		{"node/cp-02"},
+		{"node/worker-01", "node/worker-02"},
+		{"node/worker-03"},
+	}
+	for i := range want {
+		batch := exec.nextNodeBatch()
+		if len(batch) != len(want[i]) {
+			t.Fatalf("batch %d = %v, want %v", i, batch, want[i])
+		}
+		for j := range want[i] {
+			if batch[j] != want[i][j] {
+				t.Fatalf("batch %d = %v, want %v", i, batch, want[i])
+			}
+			_ = op.CompletePhase(batch[j], nil)
+		}
+	}
+	if batch := exec.nextNodeBatch(); len(batch) != 0 {
+		t.Fatalf("batch after all nodes = %v, want empty", batch)
+	}
+}
+
+func assertUpgradeEvents(t *testing.T, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events[%d] = %q, want %q (events %v)", i, got[i], want[i], got)
+		}
+	}
+}
+
+func TestExecuteUpgradeWritesVersionToConfig(t *testing.T) {
+	talosCluster := &fakeTalosCluster{versions: map[string]string{
+		"1.1.1.1": "v1.9.5",
+		"2.2.2.2": "v1.9.5",
+		"3.3.3.3": "v1.9.5",
+	}}
+	newRollingUpgradeTest(t, talosCluster)
+
+	configPath := filepath.Join(t.TempDir(), "production.yaml")
+	original := "environment: production\n\ncluster:\n  talosVersion: v1.9.4 # pinned\n  kubernetesVersion: v1.32.0\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	nodes := rollingUpgradeTestNodes()
+	kubeClient := fake.NewSimpleClientset(readyTestNode(nodes[0].Name), readyTestNode(nodes[1].Name), readyTestNode(nodes[2].Name))
+	store, op := newUpgradeTestOperation(t, nodes, kubeClient, "1", configPath)
+	cfg := upgradeTestConfig()
+
+	result, err := executeUpgrade(context.Background(), store, op, cfg)
+	if err != nil {
+		t.Fatalf("executeUpgrade returned error: %v", err)
+	}
+	if !result.ConfigUpdated {
+		t.Fatal("expected result to report the config update")
+	}
+	if cfg.Cluster.TalosVersion != "v1.9.5" {
+		t.Fatalf("cfg.Cluster.TalosVersion = %q, want %q", cfg.Cluster.TalosVersion, "v1.9.5")
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	want := strings.Replace(original, "v1.9.4", "v1.9.5", 1)
+	if string(data) != want {
+		t.Fatalf("config =\n%s\nwant\n%s", data, want)
+	}
+}
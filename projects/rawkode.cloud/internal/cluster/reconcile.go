@@ -2,20 +2,21 @@ package cluster
 
 import (
 	"fmt"
+	"sort"
 )
 
 // DriftResult describes differences between desired and actual cluster state.
 type DriftResult struct {
-	MissingNodes []string // Nodes in state but not in Scaleway
-	ExtraNodes   []string // Nodes in Scaleway but not in state
-	IPMismatches []IPMismatch
+	MissingNodes []string     `json:"missingNodes,omitempty"` // Nodes in state but not in the cluster
+	ExtraNodes   []string     `json:"extraNodes,omitempty"`   // Nodes in the cluster but not in state
+	IPMismatches []IPMismatch `json:"ipMismatches,omitempty"`
 }
 
 // IPMismatch describes a node whose actual IP doesn't match state.
 type IPMismatch struct {
-	NodeName string
-	Expected string
-	Actual   string
+	NodeName string `json:"nodeName"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
 }
 
 // HasDrift returns true if any differences were detected.
@@ -41,7 +42,8 @@ func (d *DriftResult) String() string {
 	return result
 }
 
-// DetectDrift compares known node state against actual Scaleway servers.
+// DetectDrift compares known node state against actual nodes keyed by name.
+// Results are sorted by node name.
 func DetectDrift(knownNodes []NodeState, actualNodes map[string]string) *DriftResult {
 	result := &DriftResult{}
 
@@ -71,5 +73,11 @@ func DetectDrift(knownNodes []NodeState, actualNodes map[string]string) *DriftRe
 		}
 	}
 
+	sort.Strings(result.MissingNodes)
+	sort.Strings(result.ExtraNodes)
+	sort.Slice(result.IPMismatches, func(i, j int) bool {
+		return result.IPMismatches[i].NodeName < result.IPMismatches[j].NodeName
+	})
+
 	return result
 }
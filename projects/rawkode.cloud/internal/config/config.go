@@ -12,49 +12,89 @@ import (
 
 // Config is the top-level cluster configuration loaded from YAML.
 type Config struct {
-	Environment string           `yaml:"environment"`
-	Cluster     ClusterConfig    `yaml:"cluster"`
-	Scaleway    ScalewayConfig   `yaml:"scaleway"`
-	NodePools   []NodePoolConfig `yaml:"nodePools"`
-	Storage     StorageConfig    `yaml:"storage"`
-	Infisical   InfisicalConfig  `yaml:"infisical"`
-	Flux        FluxConfig       `yaml:"flux"`
+	Environment string           `yaml:"environment,omitempty"`
+	Cluster     ClusterConfig    `yaml:"cluster,omitempty"`
+	Scaleway    ScalewayConfig   `yaml:"scaleway,omitempty"`
+	NodePools   []NodePoolConfig `yaml:"nodePools,omitempty"`
+	Storage     StorageConfig    `yaml:"storage,omitempty"`
+	Infisical   InfisicalConfig  `yaml:"infisical,omitempty"`
+	Flux        FluxConfig       `yaml:"flux,omitempty"`
+	Backup      BackupConfig     `yaml:"backup,omitempty"`
 
 	// Runtime credentials loaded from secret providers, never serialized.
 	scwAccessKey       string
 	scwSecretKey       string
 	cloudflareAPIToken string
 	cloudflareAccount  string
+
+	// Infisical credentials taken from the environment by Load; Save never
+	// writes these back to the file.
+	envInfisicalClientID     string
+	envInfisicalClientSecret string
 }
 
 // ClusterConfig holds Kubernetes/Talos version info.
 type ClusterConfig struct {
-	TalosVersion      string `yaml:"talosVersion"`
-	KubernetesVersion string `yaml:"kubernetesVersion"`
-	TalosSchematic    string `yaml:"talosSchematic"`
-	CiliumVersion     string `yaml:"ciliumVersion"`
-	FluxVersion       string `yaml:"fluxVersion"`
+	TalosVersion      string `yaml:"talosVersion,omitempty"`
+	KubernetesVersion string `yaml:"kubernetesVersion,omitempty"`
+	TalosSchematic    string `yaml:"talosSchematic,omitempty"`
+	CiliumVersion     string `yaml:"ciliumVersion,omitempty"`
+	FluxVersion       string `yaml:"fluxVersion,omitempty"`
 	// ControlPlaneTaints controls whether control-plane NoSchedule taints are kept.
 	// true keeps taints (isolated control-plane), false removes them (schedulable).
-	ControlPlaneTaints *bool `yaml:"controlPlaneTaints"`
+	ControlPlaneTaints *bool `yaml:"controlPlaneTaints,omitempty"`
+	// ConfigPatches are applied to every node's machine config, before the
+	// node pool's own patches.
+	ConfigPatches []ConfigPatch `yaml:"configPatches,omitempty"`
 }
 
 // ScalewayConfig holds Scaleway infrastructure settings (no credentials).
 type ScalewayConfig struct {
-	ProjectID      string `yaml:"projectId"`
-	OrganizationID string `yaml:"organizationId"`
+	ProjectID      string `yaml:"projectId,omitempty"`
+	OrganizationID string `yaml:"organizationId,omitempty"`
 }
 
 // NodePoolConfig describes a group of nodes sharing the same hardware/disk layout.
 type NodePoolConfig struct {
-	Name               string     `yaml:"name"`
-	Type               string     `yaml:"type"`
-	Zone               string     `yaml:"zone"`
-	Size               int        `yaml:"size"`
-	Offer              string     `yaml:"offer"`
-	BillingCycle       string     `yaml:"billingCycle"`
-	Disks              DiskConfig `yaml:"disks"`
-	ReservedPrivateIPs []string   `yaml:"reservedPrivateIPs"`
+	Name               string     `yaml:"name,omitempty"`
+	Type               string     `yaml:"type,omitempty"`
+	Zone               string     `yaml:"zone,omitempty"`
+	Size               int        `yaml:"size,omitempty"`
+	Offer              string     `yaml:"offer,omitempty"`
+	BillingCycle       string     `yaml:"billingCycle,omitempty"`
+	Disks              DiskConfig `yaml:"disks,omitempty"`
+	ReservedPrivateIPs []string   `yaml:"reservedPrivateIPs,omitempty"`
+	// Labels, Taints and Annotations are applied to every node of the pool
+	// through its machine config, so they survive reboots and upgrades.
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Taints      []NodeTaint       `yaml:"taints,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	// ConfigPatches are applied to the machine config of the pool's nodes
+	// after the cluster-level patches.
+	ConfigPatches []ConfigPatch `yaml:"configPatches,omitempty"`
+}
+
+// ConfigPatch is a Talos machine-config patch. Exactly one of
+// StrategicMerge or JSONPatch must be set.
+type ConfigPatch struct {
+	Name           string               `yaml:"name,omitempty"`
+	StrategicMerge map[string]any       `yaml:"strategicMerge,omitempty"`
+	JSONPatch      []JSONPatchOperation `yaml:"jsonPatch,omitempty"`
+}
+
+// JSONPatchOperation is one RFC 6902 operation.
+type JSONPatchOperation struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from,omitempty"`
+	Value any    `yaml:"value,omitempty"`
+}
+
+// NodeTaint is a Kubernetes taint applied to the nodes of a pool.
+type NodeTaint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value,omitempty"`
+	Effect string `yaml:"effect"`
 }
 
 const (
@@ -69,36 +109,70 @@ const (
 
 // DiskConfig holds disk device paths.
 type DiskConfig struct {
-	OS   string `yaml:"os"`
-	Data string `yaml:"data"`
+	OS   string `yaml:"os,omitempty"`
+	Data string `yaml:"data,omitempty"`
 }
 
 // StorageConfig holds optional storage platform settings.
 type StorageConfig struct {
-	Mayastor MayastorConfig `yaml:"mayastor"`
+	Mayastor MayastorConfig `yaml:"mayastor,omitempty"`
 }
 
 // MayastorConfig enables Talos prerequisites for OpenEBS Replicated PV Mayastor.
 type MayastorConfig struct {
-	Enabled bool `yaml:"enabled"`
+	Enabled bool `yaml:"enabled,omitempty"`
 }
 
 // InfisicalConfig holds secrets management settings.
 type InfisicalConfig struct {
-	SiteURL           string `yaml:"siteUrl"`
-	ProjectID         string `yaml:"projectId"`
-	ProjectSlug       string `yaml:"projectSlug"`
-	Environment       string `yaml:"environment"`
-	SecretPath        string `yaml:"secretPath"`
-	NetbirdSecretPath string `yaml:"netbirdSecretPath"`
-	NetbirdSecretKey  string `yaml:"netbirdSecretKey"`
-	ClientID          string `yaml:"clientId"`
-	ClientSecret      string `yaml:"clientSecret"`
+	SiteURL           string `yaml:"siteUrl,omitempty"`
+	ProjectID         string `yaml:"projectId,omitempty"`
+	ProjectSlug       string `yaml:"projectSlug,omitempty"`
+	Environment       string `yaml:"environment,omitempty"`
+	SecretPath        string `yaml:"secretPath,omitempty"`
+	NetbirdSecretPath string `yaml:"netbirdSecretPath,omitempty"`
+	NetbirdSecretKey  string `yaml:"netbirdSecretKey,omitempty"`
+	ClientID          string `yaml:"clientId,omitempty"`
+	ClientSecret      string `yaml:"clientSecret,omitempty"`
 }
 
 // FluxConfig holds FluxCD configuration.
 type FluxConfig struct {
-	OCIRepo string `yaml:"ociRepo"`
+	OCIRepo string `yaml:"ociRepo,omitempty"`
+}
+
+// BackupConfig holds where `etcd backup` stores snapshots. Any
+// S3-compatible object store works; Scaleway Object Storage is the default
+// and is reached with the cluster's Scaleway credentials.
+type BackupConfig struct {
+	Bucket string `yaml:"bucket,omitempty"`
+	// Region defaults to fr-par.
+	Region string `yaml:"region,omitempty"`
+	// Endpoint defaults to Scaleway Object Storage in Region.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Prefix is prepended to every key and defaults to "etcd".
+	Prefix     string           `yaml:"prefix,omitempty"`
+	Retention  BackupRetention  `yaml:"retention,omitempty"`
+	Encryption BackupEncryption `yaml:"encryption,omitempty"`
+}
+
+// BackupEncryption encrypts etcd snapshots with age before they leave the
+// machine running the CLI. It applies to `etcd snapshot` and `etcd backup`.
+type BackupEncryption struct {
+	// Recipients are age public keys (age1...) snapshots are encrypted to.
+	Recipients []string `yaml:"recipients,omitempty"`
+	// InfisicalKey names a secret in the cluster's Infisical path holding
+	// an age identity (AGE-SECRET-KEY-1...). Snapshots are also encrypted
+	// to it, and `etcd restore` decrypts with it.
+	InfisicalKey string `yaml:"infisicalKey,omitempty"`
+}
+
+// BackupRetention is how many hourly, daily and weekly snapshots to keep.
+// When none is set 24 hourly, 7 daily and 4 weekly snapshots are kept.
+type BackupRetention struct {
+	Hourly int `yaml:"hourly,omitempty"`
+	Daily  int `yaml:"daily,omitempty"`
+	Weekly int `yaml:"weekly,omitempty"`
 }
 
 const (
@@ -132,9 +206,11 @@ func Load(path string) (*Config, error) {
 	}
 	if v := os.Getenv("INFISICAL_CLIENT_ID"); v != "" && cfg.Infisical.ClientID == "" {
 		cfg.Infisical.ClientID = v
+		cfg.envInfisicalClientID = v
 	}
 	if v := os.Getenv("INFISICAL_CLIENT_SECRET"); v != "" && cfg.Infisical.ClientSecret == "" {
 		cfg.Infisical.ClientSecret = v
+		cfg.envInfisicalClientSecret = v
 	}
 
 	return &cfg, nil
@@ -226,20 +302,6 @@ func requiredInfisicalSecret(
 	return trimmed, nil
 }
 
-// Save writes the configuration back to a YAML file.
-func Save(path string, cfg *Config) error {
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
-		return fmt.Errorf("marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(path, data, 0o644); err != nil {
-		return fmt.Errorf("write config %s: %w", path, err)
-	}
-
-	return nil
-}
-
 // ScalewayCredentials returns the Scaleway credentials loaded from Infisical.
 func (c *Config) ScalewayCredentials() (accessKey, secretKey string) {
 	return c.scwAccessKey, c.scwSecretKey
@@ -306,6 +368,33 @@ func (p NodePoolConfig) DesiredSize() int {
 	return p.Size
 }
 
+// TalosNodeTaints returns the pool taints in the machine.nodeTaints form
+// Talos expects: key mapped to "value:Effect".
+func (p NodePoolConfig) TalosNodeTaints() (map[string]string, error) {
+	if len(p.Taints) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]string, len(p.Taints))
+	for _, taint := range p.Taints {
+		key := strings.TrimSpace(taint.Key)
+		if key == "" {
+			return nil, fmt.Errorf("node pool %q has a taint without a key", p.Name)
+		}
+		switch taint.Effect {
+		case "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return nil, fmt.Errorf("node pool %q taint %q has invalid effect %q (want NoSchedule, PreferNoSchedule or NoExecute)", p.Name, key, taint.Effect)
+		}
+		if _, duplicate := out[key]; duplicate {
+			return nil, fmt.Errorf("node pool %q has more than one taint with key %q", p.Name, key)
+		}
+		out[key] = strings.TrimSpace(taint.Value) + ":" + taint.Effect
+	}
+
+	return out, nil
+}
+
 // EffectiveZone returns the node pool zone value with surrounding whitespace removed.
 func (p NodePoolConfig) EffectiveZone() string {
 	return strings.TrimSpace(p.Zone)
@@ -130,3 +130,26 @@ func TestClusterEffectiveControlPlaneTaints(t *testing.T) {
 		t.Fatalf("ClusterConfig{ControlPlaneTaints:false}.EffectiveControlPlaneTaints() = %t, want false", got)
 	}
 }
+
+func TestNodePoolTalosNodeTaints(t *testing.T) {
+	pool := NodePoolConfig{Name: "gpu", Taints: []NodeTaint{
+		{Key: "nvidia.com/gpu", Value: "present", Effect: "NoSchedule"},
+		{Key: "dedicated", Effect: "NoExecute"},
+	}}
+
+	taints, err := pool.TalosNodeTaints()
+	if err != nil {
+		t.Fatalf("TalosNodeTaints returned error: %v", err)
+	}
+	if got := taints["nvidia.com/gpu"]; got != "present:NoSchedule" {
+		t.Fatalf("taints[nvidia.com/gpu] = %q, want %q", got, "present:NoSchedule")
+	}
+	if got := taints["dedicated"]; got != ":NoExecute" {
+		t.Fatalf("taints[dedicated] = %q, want %q", got, ":NoExecute")
+	}
+
+	pool.Taints = []NodeTaint{{Key: "dedicated", Effect: "Sometimes"}}
+	if _, err := pool.TalosNodeTaints(); err == nil {
+		t.Fatal("expected an error for an invalid taint effect")
+	}
+}
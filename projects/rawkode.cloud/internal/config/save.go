@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Save writes the configuration back to a YAML file.
+//
+// When the file already exists only the fields that differ from what the
+// file decodes to are written, into its yaml.v3 document, so comments, key
+// order, keys Config does not know and explicitly written zero values all
+// survive. If only scalar values changed, just those values are rewritten in
+// the original bytes and the rest of the file, including blank lines, is
+// left untouched. Infisical credentials that Load took from the environment
+// are never written.
+func Save(path string, cfg *Config) error {
+	if cfg == nil {
+		return fmt.Errorf("config is required")
+	}
+
+	out := *cfg
+	if out.envInfisicalClientID != "" && out.Infisical.ClientID == out.envInfisicalClientID {
+		out.Infisical.ClientID = ""
+	}
+	if out.envInfisicalClientSecret != "" && out.Infisical.ClientSecret == out.envInfisicalClientSecret {
+		out.Infisical.ClientSecret = ""
+	}
+
+	var desired yaml.Node
+	if err := desired.Encode(&out); err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	data, err := renderConfigDocument(existing, &desired)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, existing) {
+		return nil
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// scalarEdit replaces one scalar value at its position in the source bytes.
+type scalarEdit struct {
+	line, column int
+	style        yaml.Style
+	oldTag       string
+	oldValue     string
+	newTag       string
+	newValue     string
+}
+
+// configMerge collects what merging the desired document into the existing
+// one changed.
+type configMerge struct {
+	edits      []scalarEdit
+	structural bool
+}
+
+func renderConfigDocument(existing []byte, desired *yaml.Node) ([]byte, error) {
+	var doc yaml.Node
+	if len(bytes.TrimSpace(existing)) > 0 {
+		if err := yaml.Unmarshal(existing, &doc); err != nil {
+			return nil, fmt.Errorf("parse existing config: %w", err)
+		}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return encodeConfigNode(desired)
+	}
+
+	// The file as Config sees it: comparing against this rather than the
+	// raw document tells the fields the caller changed apart from keys
+	// Config drops on decode or omits on encode.
+	var current Config
+	if err := yaml.Unmarshal(existing, &current); err != nil {
+		return nil, fmt.Errorf("parse existing config: %w", err)
+	}
+	var base yaml.Node
+	if err := base.Encode(&current); err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+
+	var merge configMerge
+	merge.mergeNode(doc.Content[0], &base, desired)
+	if !merge.structural {
+		if patched, ok := applyScalarEdits(existing, merge.edits); ok {
+			return patched, nil
+		}
+	}
+
+	// Structural changes cannot be patched in place; re-encoding keeps
+	// comments and order but not blank lines.
+	return encodeConfigNode(&doc)
+}
+
+// mergeNode applies to dst the changes from base to src, where base is dst
+// as Config encodes it and may be nil where dst has nothing Config knows.
+// Anything src leaves as it was in base is left untouched in dst.
+func (m *configMerge) mergeNode(dst, base, src *yaml.Node) {
+	if base != nil && equalNodes(base, src) {
+		return
+	}
+	if dst.Kind != src.Kind {
+		replaceNodeKeepingComments(dst, src)
+		m.structural = true
+		return
+	}
+
+	switch dst.Kind {
+	case yaml.MappingNode:
+		m.mergeMapping(dst, base, src)
+	case yaml.SequenceNode:
+		for i := range min(len(dst.Content), len(src.Content)) {
+			m.mergeNode(dst.Content[i], childNode(base, i), src.Content[i])
+		}
+		if len(dst.Content) != len(src.Content) {
+			if len(src.Content) > len(dst.Content) {
+				dst.Content = append(dst.Content, src.Content[len(dst.Content):]...)
+			} else {
+				dst.Content = dst.Content[:len(src.Content)]
+			}
+			m.structural = true
+		}
+	case yaml.ScalarNode:
+		if dst.Value == src.Value && dst.ShortTag() == src.ShortTag() {
+			return
+		}
+		m.edits = append(m.edits, scalarEdit{
+			line:     dst.Line,
+			column:   dst.Column,
+			style:    dst.Style,
+			oldTag:   dst.Tag,
+			oldValue: dst.Value,
+			newTag:   src.Tag,
+			newValue: src.Value,
+		})
+		dst.Tag = src.Tag
+		dst.Value = src.Value
+	}
+}
+
+// mergeMapping merges key by key. A key src lacks is removed only when base
+// has it, meaning the caller unset the field; a key neither has is unknown
+// to Config or holds an explicit zero value, and stays.
+func (m *configMerge) mergeMapping(dst, base, src *yaml.Node) {
+	wanted := mappingValues(src)
+	known := mappingValues(base)
+
+	kept := dst.Content[:0]
+	seen := make(map[string]bool, len(wanted))
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key, value := dst.Content[i], dst.Content[i+1]
+		seen[key.Value] = true
+		srcValue, ok := wanted[key.Value]
+		if !ok {
+			if _, unset := known[key.Value]; unset {
+				m.structural = true
+				continue
+			}
+			kept = append(kept, key, value)
+			continue
+		}
+		m.mergeNode(value, known[key.Value], srcValue)
+		kept = append(kept, key, value)
+	}
+	dst.Content = kept
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if seen[src.Content[i].Value] {
+			continue
+		}
+		dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+		m.structural = true
+	}
+}
+
+func mappingValues(node *yaml.Node) map[string]*yaml.Node {
+	values := map[string]*yaml.Node{}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return values
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		values[node.Content[i].Value] = node.Content[i+1]
+	}
+	return values
+}
+
+func childNode(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
+
+// equalNodes compares two encoded nodes by content, ignoring positions and
+// comments.
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && (a.Value != b.Value || a.ShortTag() != b.ShortTag()) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func replaceNodeKeepingComments(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// applyScalarEdits rewrites changed scalars in the original bytes. It
+// reports false when a scalar cannot be located exactly, in which case the
+// caller re-encodes the whole document instead.
+func applyScalarEdits(source []byte, edits []scalarEdit) ([]byte, bool) {
+	// Later columns first so earlier edits on the same line keep their offsets.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line < edits[j].line
+		}
+		return edits[i].column > edits[j].column
+	})
+
+	lines := strings.SplitAfter(string(source), "\n")
+	for _, edit := range edits {
+		if edit.line < 1 || edit.line > len(lines) || edit.column < 1 {
+			return nil, false
+		}
+
+		oldRendered, ok := renderScalar(edit.oldValue, edit.oldTag, edit.style)
+		if !ok {
+			return nil, false
+		}
+		newRendered, ok := renderScalar(edit.newValue, edit.newTag, edit.style)
+		if !ok {
+			return nil, false
+		}
+
+		line := lines[edit.line-1]
+		start := edit.column - 1
+		end := start + len(oldRendered)
+		if end > len(line) || line[start:end] != oldRendered {
+			return nil, false
+		}
+		lines[edit.line-1] = line[:start] + newRendered + line[end:]
+	}
+
+	return []byte(strings.Join(lines, "")), true
+}
+
+// renderScalar encodes a scalar in the given style, refusing anything that
+// would not fit on a single line.
+func renderScalar(value, tag string, style yaml.Style) (string, bool) {
+	data, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Style: style})
+	if err != nil {
+		return "", false
+	}
+	rendered := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(rendered, "\n") {
+		return "", false
+	}
+	return rendered, true
+}
+
+func encodeConfigNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
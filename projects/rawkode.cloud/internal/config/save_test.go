@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const saveTestConfig = `# Production cluster.
+environment: production
+
+cluster:
+  talosVersion: v1.12.0 # bumped by upgrade talos
+  kubernetesVersion: "v1.35.0"
+  controlPlaneTaints: false
+
+nodePools:
+  - name: control-plane
+    type: control-plane
+    zone: fr-par-1
+
+infisical:
+  siteUrl: https://app.infisical.com
+  projectId: project
+`
+
+func writeSaveTestConfig(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "production.yaml")
+	if err := os.WriteFile(path, []byte(saveTestConfig), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestSaveRewritesOnlyChangedScalars(t *testing.T) {
+	path := writeSaveTestConfig(t)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	cfg.Cluster.TalosVersion = "v1.12.4"
+	cfg.Cluster.KubernetesVersion = "v1.35.2"
+
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	want := strings.Replace(saveTestConfig, "talosVersion: v1.12.0 #", "talosVersion: v1.12.4 #", 1)
+	want = strings.Replace(want, `kubernetesVersion: "v1.35.0"`, `kubernetesVersion: "v1.35.2"`, 1)
+	if got := string(data); got != want {
+		t.Fatalf("saved config =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSaveKeepsCommentsOnStructuralChange(t *testing.T) {
+	path := writeSaveTestConfig(t)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	cfg.NodePools[0].Size = 3
+
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{"# Production cluster.", "# bumped by upgrade talos", "size: 3"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("saved config missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "environment:") > strings.Index(got, "cluster:") {
+		t.Fatalf("saved config reordered keys:\n%s", got)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.NodePools[0].Size != 3 || reloaded.Cluster.ControlPlaneTaints == nil || *reloaded.Cluster.ControlPlaneTaints {
+		t.Fatalf("reloaded config = %+v, want size 3 and controlPlaneTaints false", reloaded)
+	}
+}
+
+func TestSaveDoesNotWriteEnvironmentCredentials(t *testing.T) {
+	path := writeSaveTestConfig(t)
+	t.Setenv("INFISICAL_CLIENT_ID", "client-id-from-env")
+	t.Setenv("INFISICAL_CLIENT_SECRET", "client-secret-from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	cfg.Cluster.TalosVersion = "v1.12.4"
+
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(data), "from-env") {
+		t.Fatalf("saved config leaked environment credentials:\n%s", data)
+	}
+}
+
+func TestSaveKeepsUnknownKeysAndExplicitZeroValues(t *testing.T) {
+	const source = `environment: production
+team: platform # not a Config field
+
+cluster:
+  talosVersion: v1.12.0
+
+storage:
+  mayastor:
+    enabled: false
+
+nodePools:
+  - name: worker
+    type: worker
+    zone: fr-par-1
+    size: 0
+    owner: data
+`
+	path := filepath.Join(t.TempDir(), "production.yaml")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	cfg.Cluster.TalosVersion = "v1.12.4"
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	want := strings.Replace(source, "talosVersion: v1.12.0", "talosVersion: v1.12.4", 1)
+	if got := string(data); got != want {
+		t.Fatalf("saved config =\n%s\nwant\n%s", got, want)
+	}
+
+	// A structural change re-encodes the document and must keep them too.
+	cfg.Cluster.KubernetesVersion = "v1.35.2"
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{"team: platform", "enabled: false", "size: 0", "owner: data", "kubernetesVersion: v1.35.2"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("saved config missing %q:\n%s", want, got)
+		}
+	}
+}
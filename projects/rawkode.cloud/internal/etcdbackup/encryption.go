@@ -0,0 +1,94 @@
+package etcdbackup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// EncryptionAge marks a manifest whose snapshot is age-encrypted.
+const EncryptionAge = "age"
+
+// ageHeader starts every binary age file.
+var ageHeader = []byte("age-encryption.org/v1\n")
+
+// ErrNoIdentity is returned when an encrypted snapshot is opened without
+// any identity to decrypt it.
+var ErrNoIdentity = errors.New("snapshot is age-encrypted but no identity was given")
+
+// ParseRecipients parses age public keys (age1...).
+func ParseRecipients(values []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		recipient, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse age recipient %q: %w", value, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// ParseIdentity parses one age secret key (AGE-SECRET-KEY-1...).
+func ParseIdentity(value string) (*age.X25519Identity, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("parse age identity: %w", err)
+	}
+	return identity, nil
+}
+
+// ParseIdentities parses an age identity file: one secret key per line,
+// with blank lines and # comments ignored.
+func ParseIdentities(r io.Reader) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse age identities: %w", err)
+	}
+	return identities, nil
+}
+
+// Encrypt returns a writer that encrypts everything written to it for the
+// recipients. Close must be called to flush the final chunk.
+func Encrypt(w io.Writer, recipients []age.Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required")
+	}
+	encrypted, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("start age encryption: %w", err)
+	}
+	return encrypted, nil
+}
+
+// DetectEncryption reports whether r holds an age-encrypted snapshot. The
+// returned reader replays the bytes inspected and must be used instead of r.
+func DetectEncryption(r io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(len(ageHeader))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, fmt.Errorf("read snapshot header: %w", err)
+	}
+	return buffered, bytes.Equal(header, ageHeader), nil
+}
+
+// Decrypt returns the plaintext of an age-encrypted snapshot.
+func Decrypt(r io.Reader, identities []age.Identity) (io.Reader, error) {
+	if len(identities) == 0 {
+		return nil, ErrNoIdentity
+	}
+	plaintext, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt snapshot: %w", err)
+	}
+	return plaintext, nil
+}
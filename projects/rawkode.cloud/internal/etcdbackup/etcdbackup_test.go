@@ -0,0 +1,319 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeTestSnapshot creates a bbolt database laid out like an etcd
+// snapshot, with one key per revision up to revision and three members.
+func writeTestSnapshot(t *testing.T, revision int64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("open bolt: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(keyBucket)
+		if err != nil {
+			return err
+		}
+		for rev := int64(1); rev <= revision; rev++ {
+			key := make([]byte, 17)
+			binary.BigEndian.PutUint64(key, uint64(rev))
+			key[8] = '_'
+			if err := bucket.Put(key, []byte("value")); err != nil {
+				return err
+			}
+		}
+		members, err := tx.CreateBucket(membersBucket)
+		if err != nil {
+			return err
+		}
+		for _, id := range []string{"8e9e05c52164694d", "91bc3c398fb3c146", "fd422379fda50e48"} {
+			if err := members.Put([]byte(id), []byte("{}")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+	return path
+}
+
+func TestInspectSnapshotReadsRevisionAndHash(t *testing.T) {
+	path := writeTestSnapshot(t, 42)
+
+	info, err := InspectSnapshot(path)
+	if err != nil {
+		t.Fatalf("InspectSnapshot returned error: %v", err)
+	}
+	if info.Revision != 42 || info.Members != 3 {
+		t.Fatalf("revision = %d, members = %d, want 42 and 3", info.Revision, info.Members)
+	}
+	if len(info.SHA256) != 64 || info.Size == 0 || info.IntegrityHash {
+		t.Fatalf("info = %+v", info)
+	}
+}
+
+func TestInspectSnapshotChecksIntegrityHash(t *testing.T) {
+	path := writeTestSnapshot(t, 7)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if err := os.WriteFile(path, append(data, sum[:]...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := InspectSnapshot(path)
+	if err != nil {
+		t.Fatalf("InspectSnapshot returned error: %v", err)
+	}
+	if !info.IntegrityHash || info.Revision != 7 {
+		t.Fatalf("info = %+v, want a checked integrity hash", info)
+	}
+
+	sum[0] ^= 0xff
+	if err := os.WriteFile(path, append(data, sum[:]...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InspectSnapshot(path); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Fatalf("error = %v, want ErrCorruptSnapshot", err)
+	}
+}
+
+func TestInspectSnapshotRefusesEmptyAndTruncated(t *testing.T) {
+	path := writeTestSnapshot(t, 500)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		data []byte
+		want error
+	}{
+		"empty":          {nil, ErrEmptySnapshot},
+		"header only":    {data[:100], ErrTruncatedSnapshot},
+		"missing pages":  {data[:4*4096], ErrTruncatedSnapshot},
+		"not a database": {bytes.Repeat([]byte("x"), 8192), ErrCorruptSnapshot},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			broken := filepath.Join(t.TempDir(), "snapshot.db")
+			if err := os.WriteFile(broken, tc.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := InspectSnapshot(broken); !errors.Is(err, tc.want) {
+				t.Fatalf("error = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	plaintext := &SnapshotInfo{Size: 100, SHA256: "plain", Revision: 9}
+	stored := &SnapshotInfo{Size: 150, SHA256: "stored"}
+	manifest := &Manifest{Size: 150, SHA256: "stored", Revision: 9, Encryption: EncryptionAge, PlaintextSize: 100, PlaintextSHA256: "plain"}
+
+	if err := manifest.Verify(stored, true, plaintext); err != nil {
+		t.Fatalf("encrypted file: %v", err)
+	}
+	if err := manifest.Verify(plaintext, false, plaintext); err != nil {
+		t.Fatalf("decrypted copy: %v", err)
+	}
+	if err := manifest.Verify(&SnapshotInfo{Size: 150, SHA256: "other"}, true, plaintext); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("error = %v, want ErrChecksumMismatch", err)
+	}
+	if err := (&Manifest{Size: 100, SHA256: "plain", Revision: 8}).Verify(plaintext, false, plaintext); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("revision mismatch error = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestSnapshotKeysRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	key := SnapshotKey("", "production", createdAt, false)
+	if key != "etcd/production/20261016T093000Z.db" {
+		t.Fatalf("key = %s", key)
+	}
+	if ManifestKey(key) != "etcd/production/20261016T093000Z.json" {
+		t.Fatalf("manifest key = %s", ManifestKey(key))
+	}
+
+	encrypted := SnapshotKey("/etcd/", "production", createdAt.Add(time.Hour), true)
+	if encrypted != "etcd/production/20261016T103000Z.db.age" || ManifestKey(encrypted) != "etcd/production/20261016T103000Z.json" {
+		t.Fatalf("encrypted key = %s, manifest %s", encrypted, ManifestKey(encrypted))
+	}
+
+	keys := []string{key, ManifestKey(key), "etcd/production/notes.txt", encrypted, ManifestKey(encrypted)}
+	backups := Backups(keys)
+	if len(backups) != 2 || backups[0].Snapshot != encrypted {
+		t.Fatalf("backups = %+v, want two, newest first", backups)
+	}
+}
+
+func TestBackupsLeaveOutSnapshotsWithoutManifest(t *testing.T) {
+	createdAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	complete := SnapshotKey("", "production", createdAt, false)
+	orphan := SnapshotKey("", "production", createdAt.Add(time.Hour), false)
+	keys := []string{complete, ManifestKey(complete), orphan}
+
+	if backups := Backups(keys); len(backups) != 1 || backups[0].Snapshot != complete {
+		t.Fatalf("backups = %+v, want only %s", backups, complete)
+	}
+	if orphans := Orphans(keys); len(orphans) != 1 || orphans[0].Snapshot != orphan {
+		t.Fatalf("orphans = %+v, want only %s", orphans, orphan)
+	}
+
+	// The orphan is newer, but retention must not count it as the newest
+	// backup and expire the only complete one.
+	if expired := (Retention{Hourly: 1}).Expired(Backups(keys)); len(expired) != 0 {
+		t.Fatalf("expired = %+v, want the complete backup kept", expired)
+	}
+}
+
+func TestRetentionKeepsNewestPerPeriod(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var keys []string
+	// Every 30 minutes for 20 days.
+	for i := 0; i < 20*48; i++ {
+		key := SnapshotKey("", "production", now.Add(-time.Duration(i)*30*time.Minute), false)
+		keys = append(keys, key, ManifestKey(key))
+	}
+	backups := Backups(keys)
+
+	expired := Retention{Hourly: 3, Daily: 2, Weekly: 2}.Expired(backups)
+	expiredSet := map[string]bool{}
+	for _, backup := range expired {
+		expiredSet[backup.Snapshot] = true
+	}
+
+	var kept []string
+	for _, backup := range backups {
+		if !expiredSet[backup.Snapshot] {
+			kept = append(kept, strings.TrimPrefix(backup.Snapshot, "etcd/production/"))
+		}
+	}
+	want := []string{
+		"20261016T120000Z.db", // newest: hour, day and week
+		"20261016T113000Z.db", // hour 11
+		"20261016T103000Z.db", // hour 10
+		"20261015T233000Z.db", // previous day
+		"20261011T233000Z.db", // previous ISO week ends on Sunday
+	}
+	if strings.Join(kept, ",") != strings.Join(want, ",") {
+		t.Fatalf("kept = %v, want %v", kept, want)
+	}
+}
+
+func TestRetentionAlwaysKeepsNewest(t *testing.T) {
+	key := SnapshotKey("", "production", time.Now(), false)
+	backups := Backups([]string{key, ManifestKey(key)})
+	if expired := (Retention{}).Expired(backups); len(expired) != 0 {
+		t.Fatalf("expired = %+v, want the only backup kept", expired)
+	}
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := ParseRecipients([]string{identity.Recipient().String(), " "})
+	if err != nil || len(recipients) != 1 {
+		t.Fatalf("ParseRecipients = %d, %v", len(recipients), err)
+	}
+
+	var encrypted bytes.Buffer
+	w, err := Encrypt(&encrypted, recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("snapshot")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok, err := DetectEncryption(&encrypted)
+	if err != nil || !ok {
+		t.Fatalf("DetectEncryption = %v, %v; want encrypted", ok, err)
+	}
+	if _, err := Decrypt(r, nil); !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("Decrypt without identities = %v, want ErrNoIdentity", err)
+	}
+	plaintext, err := Decrypt(r, []age.Identity{identity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(plaintext); string(got) != "snapshot" {
+		t.Fatalf("plaintext = %q", got)
+	}
+
+	r, ok, err = DetectEncryption(strings.NewReader("bolt"))
+	if err != nil || ok {
+		t.Fatalf("DetectEncryption of plaintext = %v, %v", ok, err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "bolt" {
+		t.Fatalf("replayed plaintext = %q", got)
+	}
+}
+
+func TestDigestMatchesInspectSnapshotInSmallWrites(t *testing.T) {
+	path := writeTestSnapshot(t, 9)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	data = append(data, sum[:]...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	want, err := InspectSnapshot(path)
+	if err != nil {
+		t.Fatalf("InspectSnapshot returned error: %v", err)
+	}
+
+	// Writes shorter than the integrity hash exercise the held-back tail.
+	digest := NewDigest()
+	for chunk := range slices.Chunk(data, 7) {
+		if _, err := digest.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := digest.Info()
+	if err != nil {
+		t.Fatalf("Info returned error: %v", err)
+	}
+	if got.SHA256 != want.SHA256 || got.Size != want.Size || !got.IntegrityHash {
+		t.Fatalf("digest = %+v, want %+v", got, want)
+	}
+
+	data[len(data)-1] ^= 0xff
+	digest = NewDigest()
+	digest.Write(data)
+	if _, err := digest.Info(); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Fatalf("error = %v, want ErrCorruptSnapshot", err)
+	}
+}
@@ -0,0 +1,163 @@
+package etcdbackup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPrefix is the key prefix backups are stored under.
+	DefaultPrefix = "etcd"
+
+	snapshotSuffix  = ".db"
+	encryptedSuffix = ".age"
+	manifestSuffix  = ".json"
+	timeLayout      = "20060102T150405Z"
+)
+
+// Manifest is stored next to every snapshot and records what it contains.
+type Manifest struct {
+	Cluster   string    `json:"cluster"`
+	Snapshot  string    `json:"snapshot"`
+	Node      string    `json:"node"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Size and SHA256 describe the stored object.
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Revision int64  `json:"revision"`
+	// Encryption is EncryptionAge for encrypted snapshots, whose plaintext
+	// is described by PlaintextSize and PlaintextSHA256.
+	Encryption      string `json:"encryption,omitempty"`
+	PlaintextSize   int64  `json:"plaintextSize,omitempty"`
+	PlaintextSHA256 string `json:"plaintextSha256,omitempty"`
+}
+
+// Backup is a snapshot found in the bucket.
+type Backup struct {
+	Snapshot  string
+	Manifest  string
+	CreatedAt time.Time
+}
+
+// ClusterPrefix returns the key prefix of a cluster's backups.
+func ClusterPrefix(prefix, cluster string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return path.Join(prefix, cluster) + "/"
+}
+
+// SnapshotKey names the snapshot of cluster taken at createdAt. Encrypted
+// snapshots carry an extra .age suffix.
+func SnapshotKey(prefix, cluster string, createdAt time.Time, encrypted bool) string {
+	key := ClusterPrefix(prefix, cluster) + createdAt.UTC().Format(timeLayout) + snapshotSuffix
+	if encrypted {
+		key += encryptedSuffix
+	}
+	return key
+}
+
+// ManifestKey returns the key of a snapshot's manifest.
+func ManifestKey(snapshotKey string) string {
+	return snapshotStem(snapshotKey) + manifestSuffix
+}
+
+func snapshotStem(snapshotKey string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(snapshotKey, encryptedSuffix), snapshotSuffix)
+}
+
+// Backups returns the complete backups among keys, newest first: the
+// snapshots whose manifest is also among keys. Keys that do not follow the
+// snapshot naming are ignored.
+func Backups(keys []string) []Backup {
+	complete, _ := splitBackups(keys)
+	return complete
+}
+
+// Orphans returns the snapshots among keys that have no manifest, newest
+// first. They are uploads that did not finish, or are still running, and
+// prunes that stopped after deleting the manifest.
+func Orphans(keys []string) []Backup {
+	_, orphans := splitBackups(keys)
+	return orphans
+}
+
+func splitBackups(keys []string) ([]Backup, []Backup) {
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		present[key] = true
+	}
+
+	var complete, orphans []Backup
+	for _, key := range keys {
+		stamp, ok := strings.CutSuffix(strings.TrimSuffix(path.Base(key), encryptedSuffix), snapshotSuffix)
+		if !ok {
+			continue
+		}
+		createdAt, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		backup := Backup{Snapshot: key, Manifest: ManifestKey(key), CreatedAt: createdAt}
+		if present[backup.Manifest] {
+			complete = append(complete, backup)
+		} else {
+			orphans = append(orphans, backup)
+		}
+	}
+	sortNewestFirst(complete)
+	sortNewestFirst(orphans)
+	return complete, orphans
+}
+
+// ErrChecksumMismatch is returned when a snapshot does not match its
+// manifest.
+var ErrChecksumMismatch = errors.New("snapshot does not match its manifest")
+
+// Verify checks a snapshot file against the manifest. file describes the
+// file as given and encrypted whether it is age-encrypted; plaintext
+// describes the decrypted database. A decrypted copy of an encrypted
+// backup is checked against the plaintext checksums.
+func (m *Manifest) Verify(file *SnapshotInfo, encrypted bool, plaintext *SnapshotInfo) error {
+	wantSize, wantSHA := m.Size, m.SHA256
+	switch {
+	case encrypted && m.Encryption == "":
+		return fmt.Errorf("%w: the file is encrypted but the manifest records an unencrypted snapshot", ErrChecksumMismatch)
+	case !encrypted && m.Encryption != "":
+		wantSize, wantSHA = m.PlaintextSize, m.PlaintextSHA256
+	}
+	if file.Size != wantSize || file.SHA256 != wantSHA {
+		return fmt.Errorf("%w: file has sha256 %s (%d bytes), manifest records %s (%d bytes)", ErrChecksumMismatch, file.SHA256, file.Size, wantSHA, wantSize)
+	}
+
+	if encrypted && (plaintext.Size != m.PlaintextSize || plaintext.SHA256 != m.PlaintextSHA256) {
+		return fmt.Errorf("%w: decrypted snapshot has sha256 %s, manifest records %s", ErrChecksumMismatch, plaintext.SHA256, m.PlaintextSHA256)
+	}
+	if plaintext.Revision != m.Revision {
+		return fmt.Errorf("%w: snapshot is at revision %d, manifest records %d", ErrChecksumMismatch, plaintext.Revision, m.Revision)
+	}
+	return nil
+}
+
+// MarshalManifest encodes a manifest for upload.
+func MarshalManifest(m *Manifest) ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// UnmarshalManifest decodes a downloaded manifest.
+func UnmarshalManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	return &m, nil
+}
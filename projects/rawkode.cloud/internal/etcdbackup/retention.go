@@ -0,0 +1,67 @@
+package etcdbackup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Retention is how many hourly, daily and weekly backups to keep. A backup
+// is kept while it is the newest of one of the most recent N hours, days or
+// ISO weeks that have a backup.
+type Retention struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
+
+// DefaultRetention keeps a day of hourly, a week of daily and a month of
+// weekly backups.
+var DefaultRetention = Retention{Hourly: 24, Daily: 7, Weekly: 4}
+
+// Expired returns the backups the policy no longer keeps. The newest backup
+// is always kept.
+func (r Retention) Expired(backups []Backup) []Backup {
+	sorted := append([]Backup(nil), backups...)
+	sortNewestFirst(sorted)
+
+	keep := map[string]bool{}
+	if len(sorted) > 0 {
+		keep[sorted[0].Snapshot] = true
+	}
+
+	mark := func(limit int, period func(time.Time) string) {
+		seen := map[string]bool{}
+		for _, backup := range sorted {
+			if len(seen) >= limit {
+				return
+			}
+			key := period(backup.CreatedAt.UTC())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[backup.Snapshot] = true
+		}
+	}
+	mark(r.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") })
+	mark(r.Daily, func(t time.Time) string { return t.Format("2006-01-02") })
+	mark(r.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	var expired []Backup
+	for _, backup := range sorted {
+		if !keep[backup.Snapshot] {
+			expired = append(expired, backup)
+		}
+	}
+	return expired
+}
+
+func sortNewestFirst(backups []Backup) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+}
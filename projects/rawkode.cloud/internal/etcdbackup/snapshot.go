@@ -0,0 +1,271 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// keyBucket is the etcd bucket holding every key revision. Its keys
+	// start with the 8-byte big-endian main revision.
+	keyBucket = []byte("key")
+	// membersBucket holds one entry per cluster member, keyed by member ID.
+	membersBucket = []byte("members")
+)
+
+var (
+	// ErrEmptySnapshot is returned for a zero-length snapshot file.
+	ErrEmptySnapshot = errors.New("snapshot is empty")
+	// ErrTruncatedSnapshot is returned when a snapshot is shorter than its
+	// database header says it is.
+	ErrTruncatedSnapshot = errors.New("snapshot is truncated")
+	// ErrCorruptSnapshot is returned when a snapshot is not a readable etcd
+	// database or does not match its integrity hash.
+	ErrCorruptSnapshot = errors.New("snapshot is corrupt")
+)
+
+// bbolt on-disk layout: two meta pages, each a 16-byte page header
+// followed by the meta fields. The meta checksum is an FNV-64a of the
+// fields before it.
+const (
+	boltMagic          = 0xED0CDAED
+	boltVersion        = 2
+	boltPageHeaderSize = 16
+	boltMetaSize       = 64
+	boltMetaSumOffset  = 56
+	boltMinPageSize    = 512
+)
+
+// SnapshotInfo describes an etcd snapshot file.
+type SnapshotInfo struct {
+	Size     int64
+	SHA256   string
+	Revision int64
+	// Members is the number of cluster members recorded in the snapshot.
+	Members int
+	// IntegrityHash reports whether the snapshot ends with the SHA-256
+	// etcd appends to streamed snapshots, which was checked.
+	IntegrityHash bool
+}
+
+// InspectSnapshot hashes the snapshot at path, checks that it is a
+// complete etcd database and reads the revision it was taken at and the
+// members it records. Empty, truncated and corrupt snapshots are refused.
+func InspectSnapshot(path string) (*SnapshotInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	digest := NewDigest()
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, fmt.Errorf("hash snapshot: %w", err)
+	}
+	info, err := digest.Info()
+	if err != nil {
+		return nil, err
+	}
+	if err := InspectDatabase(path, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// InspectDatabase checks the database of the snapshot at path, whose
+// Digest returned info, and records its revision and members in info.
+// Reading the database needs random access, so it must be a file.
+func InspectDatabase(path string, info *SnapshotInfo) error {
+	dbSize := info.Size
+	if info.IntegrityHash {
+		dbSize -= sha256.Size
+	}
+	if err := checkDatabaseSize(path, dbSize); err != nil {
+		return err
+	}
+
+	var err error
+	info.Revision, info.Members, err = readSnapshotDatabase(path)
+	return err
+}
+
+// InspectFile returns the size and SHA-256 of a file without reading it as
+// a database, for example an encrypted snapshot.
+func InspectFile(path string) (*SnapshotInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("hash snapshot: %w", err)
+	}
+	return &SnapshotInfo{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Digest hashes a snapshot as it streams past and checks the SHA-256 etcd
+// appends to streamed snapshots, so neither needs the snapshot on disk.
+type Digest struct {
+	size int64
+	all  hash.Hash
+	// db hashes everything but the last sha256.Size bytes, held in tail
+	// until more arrives.
+	db   hash.Hash
+	tail []byte
+}
+
+// NewDigest returns an empty Digest.
+func NewDigest() *Digest {
+	return &Digest{all: sha256.New(), db: sha256.New(), tail: make([]byte, 0, 2*sha256.Size)}
+}
+
+// Write adds p to the digest. It never fails.
+func (d *Digest) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	d.all.Write(p)
+
+	if len(p) >= sha256.Size {
+		d.db.Write(d.tail)
+		d.db.Write(p[:len(p)-sha256.Size])
+		d.tail = append(d.tail[:0], p[len(p)-sha256.Size:]...)
+		return len(p), nil
+	}
+	d.tail = append(d.tail, p...)
+	if over := len(d.tail) - sha256.Size; over > 0 {
+		d.db.Write(d.tail[:over])
+		d.tail = d.tail[:copy(d.tail, d.tail[over:])]
+	}
+	return len(p), nil
+}
+
+// Info returns the size and SHA-256 of what was written. A snapshot
+// carrying an integrity hash is refused unless the hash matches.
+func (d *Digest) Info() (*SnapshotInfo, error) {
+	if d.size == 0 {
+		return nil, ErrEmptySnapshot
+	}
+	info := &SnapshotInfo{Size: d.size, SHA256: hex.EncodeToString(d.all.Sum(nil))}
+	if d.size%boltMinPageSize == sha256.Size {
+		if !bytes.Equal(d.db.Sum(nil), d.tail) {
+			return nil, fmt.Errorf("%w: database does not match the integrity hash at the end of the file", ErrCorruptSnapshot)
+		}
+		info.IntegrityHash = true
+	}
+	return info, nil
+}
+
+// checkDatabaseSize reads the bbolt meta pages and refuses a database that
+// is shorter than the pages they say were allocated.
+func checkDatabaseSize(path string, size int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	first, err := readBoltMeta(file, 0, size)
+	if err != nil {
+		return err
+	}
+	meta := first
+	if second, err := readBoltMeta(file, int64(first.pageSize), size); err == nil && second.txid > first.txid {
+		meta = second
+	}
+
+	want := int64(meta.pgid) * int64(meta.pageSize)
+	if size < want {
+		return fmt.Errorf("%w: database is %d bytes, its header records %d pages of %d bytes", ErrTruncatedSnapshot, size, meta.pgid, meta.pageSize)
+	}
+	return nil
+}
+
+type boltMeta struct {
+	pageSize uint32
+	pgid     uint64
+	txid     uint64
+}
+
+func readBoltMeta(file io.ReaderAt, pageOffset, size int64) (*boltMeta, error) {
+	if pageOffset+boltPageHeaderSize+boltMetaSize > size {
+		return nil, fmt.Errorf("%w: %d bytes is too short for an etcd database header", ErrTruncatedSnapshot, size)
+	}
+	buf := make([]byte, boltMetaSize)
+	if _, err := file.ReadAt(buf, pageOffset+boltPageHeaderSize); err != nil {
+		return nil, fmt.Errorf("read snapshot header: %w", err)
+	}
+
+	order := binary.NativeEndian
+	if order.Uint32(buf[0:]) != boltMagic || order.Uint32(buf[4:]) != boltVersion {
+		return nil, fmt.Errorf("%w: not a bbolt database", ErrCorruptSnapshot)
+	}
+	sum := fnv.New64a()
+	sum.Write(buf[:boltMetaSumOffset])
+	if order.Uint64(buf[boltMetaSumOffset:]) != sum.Sum64() {
+		return nil, fmt.Errorf("%w: database header checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	meta := &boltMeta{
+		pageSize: order.Uint32(buf[8:]),
+		pgid:     order.Uint64(buf[40:]),
+		txid:     order.Uint64(buf[48:]),
+	}
+	if meta.pageSize < boltMinPageSize {
+		return nil, fmt.Errorf("%w: invalid page size %d", ErrCorruptSnapshot, meta.pageSize)
+	}
+	return meta, nil
+}
+
+// readSnapshotDatabase checks every page of the snapshot database and
+// returns its newest revision and member count.
+func readSnapshotDatabase(path string) (int64, int, error) {
+	db, err := bolt.Open(path, 0o400, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: open snapshot database: %w", ErrCorruptSnapshot, err)
+	}
+	defer db.Close()
+
+	var (
+		revision int64
+		members  int
+	)
+	err = db.View(func(tx *bolt.Tx) error {
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%w: %w", ErrCorruptSnapshot, errors.Join(errs...))
+		}
+
+		bucket := tx.Bucket(keyBucket)
+		if bucket == nil {
+			return fmt.Errorf("%w: no %q bucket; is it an etcd snapshot?", ErrCorruptSnapshot, keyBucket)
+		}
+		if last, _ := bucket.Cursor().Last(); len(last) >= 8 {
+			revision = int64(binary.BigEndian.Uint64(last[:8]))
+		}
+
+		if bucket := tx.Bucket(membersBucket); bucket != nil {
+			members = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return revision, members, nil
+}
@@ -85,6 +85,50 @@ func (c *Client) SetSecret(_ context.Context, projectID, environment, secretPath
 	return nil
 }
 
+// ErrSecretExists is returned by CreateSecret when the key is already set.
+var ErrSecretExists = errors.New("secret already exists")
+
+// CreateSecret creates a secret only when the key is not already set,
+// returning ErrSecretExists otherwise. Infisical enforces this server-side,
+// so of several concurrent creates exactly one succeeds.
+func (c *Client) CreateSecret(_ context.Context, projectID, environment, secretPath, key, value string) error {
+	_, err := c.sdk.Secrets().Create(infisicalsdk.CreateSecretOptions{
+		SecretKey:   key,
+		SecretValue: value,
+		ProjectID:   projectID,
+		Environment: environment,
+		SecretPath:  secretPath,
+	})
+	if err != nil {
+		if isSecretAlreadyExistsError(err) {
+			return ErrSecretExists
+		}
+		return fmt.Errorf("create secret %s: %w", key, err)
+	}
+
+	slog.Debug("secret created in infisical", "key", key)
+	return nil
+}
+
+// DeleteSecret removes a single secret. Deleting a missing secret is not an error.
+func (c *Client) DeleteSecret(_ context.Context, projectID, environment, secretPath, key string) error {
+	_, err := c.sdk.Secrets().Delete(infisicalsdk.DeleteSecretOptions{
+		SecretKey:   key,
+		ProjectID:   projectID,
+		Environment: environment,
+		SecretPath:  secretPath,
+	})
+	if err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("delete secret %s: %w", key, err)
+	}
+
+	slog.Debug("secret deleted from infisical", "key", key)
+	return nil
+}
+
 // GetSecrets fetches all secrets from a given path.
 func (c *Client) GetSecrets(_ context.Context, projectID, environment, secretPath string) (map[string]string, error) {
 	list, err := c.sdk.Secrets().List(infisicalsdk.ListSecretsOptions{
@@ -156,6 +200,22 @@ func isFolderAlreadyExistsError(err error) bool {
 	return false
 }
 
+// isSecretAlreadyExistsError reports whether err is Infisical refusing to
+// create a secret whose key is taken. Depending on the server version the
+// message reads "already exist" or "already exists".
+func isSecretAlreadyExistsError(err error) bool {
+	var apiErr *sdkerrors.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == 409 {
+		return true
+	}
+
+	return apiErr.StatusCode == 400 && strings.Contains(strings.ToLower(apiErr.ErrorMessage), "already exist")
+}
+
 func normalizeSiteURL(siteURL string) string {
 	siteURL = strings.TrimSpace(siteURL)
 	siteURL = strings.TrimRight(siteURL, "/")
@@ -0,0 +1,216 @@
+package infisical
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+)
+
+// LockSecretKey is the secret holding a cluster's mutation lease.
+const LockSecretKey = "CLUSTER_LOCK"
+
+// lockTakeoverKeyPrefix names the claim a holder creates before taking over
+// an expired lease, suffixed with that lease's token.
+const lockTakeoverKeyPrefix = "CLUSTER_LOCK_TAKEOVER_"
+
+// lockSecrets is the part of the Infisical client the lock backend uses.
+type lockSecrets interface {
+	GetSecret(ctx context.Context, projectID, environment, secretPath, key string) (string, error)
+	CreateSecret(ctx context.Context, projectID, environment, secretPath, key, value string) error
+	SetSecret(ctx context.Context, projectID, environment, secretPath, key, value string) error
+	DeleteSecret(ctx context.Context, projectID, environment, secretPath, key string) error
+	EnsureSecretPath(ctx context.Context, projectID, environment, secretPath string) error
+}
+
+// LockBackend keeps the cluster lease as a JSON secret next to the cluster's
+// persisted operations.
+//
+// Infisical only offers one conditional write: creating a secret fails when
+// the key exists. Acquiring a free lock is that create, so exactly one of
+// several racing holders wins. Taking over an expired lease first creates a
+// claim keyed by the expired token, so only one holder can replace a given
+// lease. Renewals are plain updates made only by the holder; a holder that
+// stalled past its TTL finds the lease replaced on its next renewal and
+// reports the lock lost.
+type LockBackend struct {
+	client      lockSecrets
+	projectID   string
+	environment string
+	basePath    string
+}
+
+// NewLockBackend creates a backend that stores leases below basePath/<cluster>.
+func NewLockBackend(client *Client, projectID, environment, basePath string) (*LockBackend, error) {
+	if client == nil {
+		return nil, fmt.Errorf("infisical client is required")
+	}
+	return newLockBackend(client, projectID, environment, basePath)
+}
+
+func newLockBackend(client lockSecrets, projectID, environment, basePath string) (*LockBackend, error) {
+	if strings.TrimSpace(projectID) == "" {
+		return nil, fmt.Errorf("infisical project ID is required")
+	}
+	if strings.TrimSpace(environment) == "" {
+		return nil, fmt.Errorf("infisical environment is required")
+	}
+	if strings.TrimSpace(basePath) == "" {
+		return nil, fmt.Errorf("infisical lock path is required")
+	}
+
+	return &LockBackend{
+		client:      client,
+		projectID:   strings.TrimSpace(projectID),
+		environment: strings.TrimSpace(environment),
+		basePath:    strings.TrimSpace(basePath),
+	}, nil
+}
+
+// Get reads the lease for a cluster.
+func (b *LockBackend) Get(ctx context.Context, cluster string) (*lock.Lease, error) {
+	secretPath, err := b.clusterPath(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := b.client.GetSecret(ctx, b.projectID, b.environment, secretPath, LockSecretKey)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("load cluster lock: %w", err)
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var lease lock.Lease
+	if err := json.Unmarshal([]byte(value), &lease); err != nil {
+		return nil, fmt.Errorf("decode cluster lock: %w", err)
+	}
+
+	return &lease, nil
+}
+
+// Create stores the lease with a conditional create, so only one of
+// several racing holders wins.
+func (b *LockBackend) Create(ctx context.Context, lease *lock.Lease) error {
+	secretPath, data, err := b.encode(ctx, lease)
+	if err != nil {
+		return err
+	}
+
+	err = b.client.CreateSecret(ctx, b.projectID, b.environment, secretPath, LockSecretKey, data)
+	if errors.Is(err, ErrSecretExists) {
+		return lock.ErrHeld
+	}
+	if err != nil {
+		return fmt.Errorf("store cluster lock: %w", err)
+	}
+
+	return b.confirm(ctx, lease)
+}
+
+// Replace renews the holder's own lease, or takes over an expired one. A
+// takeover first claims the expired token with a conditional create, so of
+// several holders replacing the same lease only one proceeds.
+func (b *LockBackend) Replace(ctx context.Context, previous, next *lock.Lease) error {
+	secretPath, data, err := b.encode(ctx, next)
+	if err != nil {
+		return err
+	}
+
+	if previous.Token != next.Token {
+		claimKey := lockTakeoverKeyPrefix + previous.Token
+		err := b.client.CreateSecret(ctx, b.projectID, b.environment, secretPath, claimKey, next.Token)
+		if errors.Is(err, ErrSecretExists) {
+			return lock.ErrLost
+		}
+		if err != nil {
+			return fmt.Errorf("claim cluster lock: %w", err)
+		}
+		defer func() {
+			_ = b.client.DeleteSecret(context.WithoutCancel(ctx), b.projectID, b.environment, secretPath, claimKey)
+		}()
+	}
+
+	current, err := b.Get(ctx, next.Cluster)
+	if err != nil {
+		return err
+	}
+	// A lease renewed since it was read is no longer expired.
+	if current == nil || current.Token != previous.Token || !current.ExpiresAt.Equal(previous.ExpiresAt) {
+		return lock.ErrLost
+	}
+
+	if err := b.client.SetSecret(ctx, b.projectID, b.environment, secretPath, LockSecretKey, data); err != nil {
+		return fmt.Errorf("store cluster lock: %w", err)
+	}
+
+	return b.confirm(ctx, next)
+}
+
+// Delete removes the lease when the token matches or token is empty.
+func (b *LockBackend) Delete(ctx context.Context, cluster, token string) error {
+	current, err := b.Get(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+	if token != "" && current.Token != token {
+		return lock.ErrLost
+	}
+
+	secretPath, err := b.clusterPath(cluster)
+	if err != nil {
+		return err
+	}
+
+	return b.client.DeleteSecret(ctx, b.projectID, b.environment, secretPath, LockSecretKey)
+}
+
+func (b *LockBackend) encode(ctx context.Context, lease *lock.Lease) (string, string, error) {
+	secretPath, err := b.clusterPath(lease.Cluster)
+	if err != nil {
+		return "", "", err
+	}
+	if err := b.client.EnsureSecretPath(ctx, b.projectID, b.environment, secretPath); err != nil {
+		return "", "", fmt.Errorf("ensure cluster lock path: %w", err)
+	}
+
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return "", "", fmt.Errorf("encode cluster lock: %w", err)
+	}
+
+	return secretPath, string(data), nil
+}
+
+// confirm re-reads the lease so a concurrent writer that raced us is detected.
+func (b *LockBackend) confirm(ctx context.Context, lease *lock.Lease) error {
+	stored, err := b.Get(ctx, lease.Cluster)
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.Token != lease.Token {
+		return lock.ErrLost
+	}
+
+	return nil
+}
+
+func (b *LockBackend) clusterPath(cluster string) (string, error) {
+	cluster = strings.TrimSpace(cluster)
+	if cluster == "" {
+		return "", fmt.Errorf("cluster is required")
+	}
+
+	return path.Join(ensureLeadingSlash(b.basePath), cluster), nil
+}
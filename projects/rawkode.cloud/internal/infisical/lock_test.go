@@ -0,0 +1,155 @@
+package infisical
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/lock"
+)
+
+// fakeLockSecrets keeps secrets in memory; like Infisical, creating a key
+// that is already set fails.
+type fakeLockSecrets struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (f *fakeLockSecrets) GetSecret(ctx context.Context, projectID, environment, secretPath, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.secrets[secretPath+"/"+key]
+	if !ok {
+		return "", &APIError{StatusCode: 404, Message: "secret not found"}
+	}
+	return value, nil
+}
+
+func (f *fakeLockSecrets) CreateSecret(ctx context.Context, projectID, environment, secretPath, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.secrets[secretPath+"/"+key]; ok {
+		return ErrSecretExists
+	}
+	f.secrets[secretPath+"/"+key] = value
+	return nil
+}
+
+func (f *fakeLockSecrets) SetSecret(ctx context.Context, projectID, environment, secretPath, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[secretPath+"/"+key] = value
+	return nil
+}
+
+func (f *fakeLockSecrets) DeleteSecret(ctx context.Context, projectID, environment, secretPath, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.secrets, secretPath+"/"+key)
+	return nil
+}
+
+func (f *fakeLockSecrets) EnsureSecretPath(ctx context.Context, projectID, environment, secretPath string) error {
+	return nil
+}
+
+func newTestLockBackend(t *testing.T) *LockBackend {
+	t.Helper()
+	backend, err := newLockBackend(&fakeLockSecrets{secrets: map[string]string{}}, "project", "prod", "/operations")
+	if err != nil {
+		t.Fatalf("newLockBackend returned error: %v", err)
+	}
+	return backend
+}
+
+func testLease(token string, expiresAt time.Time) *lock.Lease {
+	return &lock.Lease{Cluster: "production", Holder: token, Token: token, ExpiresAt: expiresAt}
+}
+
+// race runs fn once per token concurrently and returns the tokens that won.
+func race(tokens []string, fn func(token string) error) []string {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		winners []string
+	)
+	for _, token := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if fn(token) == nil {
+				mu.Lock()
+				winners = append(winners, token)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return winners
+}
+
+func TestLockBackendCreateHasOneWinner(t *testing.T) {
+	backend := newTestLockBackend(t)
+	expires := time.Now().Add(time.Minute)
+
+	winners := race([]string{"a", "b", "c", "d"}, func(token string) error {
+		return backend.Create(context.Background(), testLease(token, expires))
+	})
+	if len(winners) != 1 {
+		t.Fatalf("winners = %v, want exactly one", winners)
+	}
+
+	if err := backend.Create(context.Background(), testLease("e", expires)); !errors.Is(err, lock.ErrHeld) {
+		t.Fatalf("Create on held lock error = %v, want ErrHeld", err)
+	}
+}
+
+func TestLockBackendTakeoverOfExpiredLeaseHasOneWinner(t *testing.T) {
+	backend := newTestLockBackend(t)
+	stale := testLease("stale", time.Now().Add(-time.Minute))
+	if err := backend.Create(context.Background(), stale); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	previous, err := backend.Get(context.Background(), "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	expires := time.Now().Add(time.Minute)
+	winners := race([]string{"a", "b", "c", "d"}, func(token string) error {
+		return backend.Replace(context.Background(), previous, testLease(token, expires))
+	})
+	if len(winners) != 1 {
+		t.Fatalf("winners = %v, want exactly one", winners)
+	}
+
+	current, err := backend.Get(context.Background(), "production")
+	if err != nil || current.Token != winners[0] {
+		t.Fatalf("stored lease = %+v (%v), want token %s", current, err, winners[0])
+	}
+}
+
+func TestLockBackendTakeoverRefusesRenewedLease(t *testing.T) {
+	backend := newTestLockBackend(t)
+	lease := testLease("holder", time.Now().Add(-time.Second))
+	if err := backend.Create(context.Background(), lease); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	seen, err := backend.Get(context.Background(), "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	renewed := *seen
+	renewed.ExpiresAt = time.Now().Add(time.Minute)
+	if err := backend.Replace(context.Background(), seen, &renewed); err != nil {
+		t.Fatalf("renewal returned error: %v", err)
+	}
+
+	err = backend.Replace(context.Background(), seen, testLease("taker", time.Now().Add(time.Minute)))
+	if !errors.Is(err, lock.ErrLost) {
+		t.Fatalf("takeover of renewed lease error = %v, want ErrLost", err)
+	}
+}
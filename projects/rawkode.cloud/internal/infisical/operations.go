@@ -0,0 +1,138 @@
+package infisical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/operation"
+)
+
+const operationSecretKeyPrefix = "OPERATION_"
+
+// OperationStore persists operations as JSON secrets in a per-cluster Infisical folder.
+type OperationStore struct {
+	client      *Client
+	projectID   string
+	environment string
+	basePath    string
+}
+
+// NewOperationStore creates a store that keeps operations below basePath/<cluster>.
+func NewOperationStore(client *Client, projectID, environment, basePath string) (*OperationStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("infisical client is required")
+	}
+	if strings.TrimSpace(projectID) == "" {
+		return nil, fmt.Errorf("infisical project ID is required")
+	}
+	if strings.TrimSpace(environment) == "" {
+		return nil, fmt.Errorf("infisical environment is required")
+	}
+	if strings.TrimSpace(basePath) == "" {
+		return nil, fmt.Errorf("infisical operations path is required")
+	}
+
+	return &OperationStore{
+		client:      client,
+		projectID:   strings.TrimSpace(projectID),
+		environment: strings.TrimSpace(environment),
+		basePath:    strings.TrimSpace(basePath),
+	}, nil
+}
+
+// Save writes the operation to Infisical, creating the cluster folder on first use.
+func (s *OperationStore) Save(ctx context.Context, op *operation.Operation) error {
+	if op == nil {
+		return fmt.Errorf("operation is required")
+	}
+
+	secretPath, err := s.clusterPath(op.Cluster)
+	if err != nil {
+		return err
+	}
+	if err := s.client.EnsureSecretPath(ctx, s.projectID, s.environment, secretPath); err != nil {
+		return fmt.Errorf("ensure operation secret path: %w", err)
+	}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("encode operation %s: %w", op.ID, err)
+	}
+
+	if err := s.client.SetSecret(ctx, s.projectID, s.environment, secretPath, OperationSecretKey(op.ID), string(data)); err != nil {
+		return fmt.Errorf("store operation %s: %w", op.ID, err)
+	}
+
+	return nil
+}
+
+// Load fetches a single operation for a cluster.
+func (s *OperationStore) Load(ctx context.Context, cluster, id string) (*operation.Operation, error) {
+	secretPath, err := s.clusterPath(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.client.GetSecret(ctx, s.projectID, s.environment, secretPath, OperationSecretKey(id))
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", operation.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("load operation %s: %w", id, err)
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("%w: %s", operation.ErrNotFound, id)
+	}
+
+	return operation.Decode([]byte(value))
+}
+
+// List returns every operation stored for a cluster.
+func (s *OperationStore) List(ctx context.Context, cluster string) ([]*operation.Operation, error) {
+	secretPath, err := s.clusterPath(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.client.GetSecrets(ctx, s.projectID, s.environment, secretPath)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list operations for cluster %q: %w", cluster, err)
+	}
+
+	ops := make([]*operation.Operation, 0, len(all))
+	for key, value := range all {
+		if !strings.HasPrefix(key, operationSecretKeyPrefix) || strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		op, err := operation.Decode([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func (s *OperationStore) clusterPath(cluster string) (string, error) {
+	cluster = strings.TrimSpace(cluster)
+	if cluster == "" {
+		return "", fmt.Errorf("cluster is required")
+	}
+
+	return path.Join(ensureLeadingSlash(s.basePath), cluster), nil
+}
+
+// OperationSecretKey maps an operation ID to an Infisical-safe secret key.
+func OperationSecretKey(id string) string {
+	normalized := strings.ToUpper(strings.TrimSpace(id))
+	normalized = strings.NewReplacer("-", "_", ".", "_").Replace(normalized)
+	return operationSecretKeyPrefix + normalized
+}
@@ -0,0 +1,89 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const deprecatedAPIMetric = "apiserver_requested_deprecated_apis"
+
+var metricLabelPattern = regexp.MustCompile(`(\w+)="((?:[^"\\]|\\.)*)"`)
+
+// DeprecatedAPIRequest is a deprecated API that clients have requested since
+// the API server started.
+type DeprecatedAPIRequest struct {
+	Group          string `json:"group,omitempty"`
+	Version        string `json:"version"`
+	Resource       string `json:"resource"`
+	RemovedRelease string `json:"removedRelease,omitempty"`
+}
+
+// GroupVersion returns the API group version in discovery form.
+func (r DeprecatedAPIRequest) GroupVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+func (r DeprecatedAPIRequest) String() string {
+	return r.Resource + "." + r.GroupVersion()
+}
+
+// DeprecatedAPIRequests reads the apiserver_requested_deprecated_apis metric
+// from the API server. Only the API server instance that answers is
+// consulted.
+func DeprecatedAPIRequests(ctx context.Context, client kubernetes.Interface) ([]DeprecatedAPIRequest, error) {
+	data, err := client.Discovery().RESTClient().Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read API server metrics: %w", err)
+	}
+
+	return parseDeprecatedAPIRequests(data), nil
+}
+
+func parseDeprecatedAPIRequests(data []byte) []DeprecatedAPIRequest {
+	seen := map[DeprecatedAPIRequest]bool{}
+	var requests []DeprecatedAPIRequest
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, deprecatedAPIMetric+"{") {
+			continue
+		}
+		end := strings.LastIndex(line, "}")
+		if end < 0 || strings.TrimSpace(line[end+1:]) == "0" {
+			continue
+		}
+
+		labels := map[string]string{}
+		for _, match := range metricLabelPattern.FindAllStringSubmatch(line[len(deprecatedAPIMetric)+1:end], -1) {
+			labels[match[1]] = match[2]
+		}
+		request := DeprecatedAPIRequest{
+			Group:          labels["group"],
+			Version:        labels["version"],
+			Resource:       labels["resource"],
+			RemovedRelease: labels["removed_release"],
+		}
+		if request.Version == "" || request.Resource == "" || seen[request] {
+			continue
+		}
+		seen[request] = true
+		requests = append(requests, request)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].String() < requests[j].String()
+	})
+	return requests
+}
@@ -0,0 +1,28 @@
+package kube
+
+import "testing"
+
+func TestParseDeprecatedAPIRequests(t *testing.T) {
+	metrics := []byte(`# HELP apiserver_requested_deprecated_apis [STABLE] Gauge of deprecated APIs that have been requested.
+# TYPE apiserver_requested_deprecated_apis gauge
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.32",resource="flowschemas",subresource="",version="v1beta3"} 1
+apiserver_requested_deprecated_apis{group="",removed_release="",resource="endpoints",subresource="",version="v1"} 1
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="1.32",resource="flowschemas",subresource="status",version="v1beta3"} 1
+apiserver_requested_deprecated_apis{group="batch",removed_release="1.25",resource="cronjobs",subresource="",version="v1beta1"} 0
+apiserver_request_total{code="200"} 12
+`)
+
+	got := parseDeprecatedAPIRequests(metrics)
+	want := []DeprecatedAPIRequest{
+		{Version: "v1", Resource: "endpoints"},
+		{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta3", Resource: "flowschemas", RemovedRelease: "1.32"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseDeprecatedAPIRequests() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parseDeprecatedAPIRequests()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
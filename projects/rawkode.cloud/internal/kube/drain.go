@@ -0,0 +1,121 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+// DrainOptions controls how pods are evicted from a node.
+type DrainOptions struct {
+	// Timeout bounds the whole drain, including retries blocked by
+	// PodDisruptionBudgets. Zero waits indefinitely.
+	Timeout time.Duration
+	// GracePeriodSeconds overrides pod termination grace; negative uses the
+	// pod's own value.
+	GracePeriodSeconds int
+	// Force also deletes pods that are not managed by a controller.
+	Force bool
+	// Out receives progress messages; nil discards them.
+	Out io.Writer
+}
+
+// Cordon marks a node unschedulable, or schedulable again when unschedulable
+// is false.
+func Cordon(ctx context.Context, client kubernetes.Interface, nodeName string, unschedulable bool) error {
+	node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get node %s: %w", nodeName, err)
+	}
+
+	helper := &drain.Helper{Ctx: ctx, Client: client, Out: io.Discard, ErrOut: io.Discard}
+	if err := drain.RunCordonOrUncordon(helper, node, unschedulable); err != nil {
+		if unschedulable {
+			return fmt.Errorf("cordon node %s: %w", nodeName, err)
+		}
+		return fmt.Errorf("uncordon node %s: %w", nodeName, err)
+	}
+
+	return nil
+}
+
+// Drain evicts every pod except DaemonSet pods from a node. Evictions go
+// through the eviction API, so PodDisruptionBudgets are respected and blocked
+// evictions are retried until the timeout.
+func Drain(ctx context.Context, client kubernetes.Interface, nodeName string, opts DrainOptions) error {
+	out := opts.Out
+	if out == nil {
+		out = io.Discard
+	}
+	gracePeriod := opts.GracePeriodSeconds
+	if gracePeriod == 0 {
+		gracePeriod = -1
+	}
+
+	helper := &drain.Helper{
+		Ctx:                 ctx,
+		Client:              client,
+		Force:               opts.Force,
+		GracePeriodSeconds:  gracePeriod,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		Timeout:             opts.Timeout,
+		Out:                 out,
+		ErrOut:              out,
+	}
+
+	if err := drain.RunNodeDrain(helper, nodeName); err != nil {
+		return fmt.Errorf("drain node %s: %w", nodeName, err)
+	}
+
+	return nil
+}
+
+// NodeReady reports whether the node's Ready condition is true.
+func NodeReady(node *corev1.Node) bool {
+	if node == nil {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// WaitForNodeReady polls until the node reports Ready. API errors are
+// tolerated because the API server may be restarting alongside the node.
+func WaitForNodeReady(ctx context.Context, client kubernetes.Interface, nodeName string, timeout, interval time.Duration) error {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		node, err := client.CoreV1().Nodes().Get(waitCtx, nodeName, metav1.GetOptions{})
+		if err == nil && NodeReady(node) {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("node is not ready")
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("node %s not ready after %s: %w", nodeName, timeout, lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
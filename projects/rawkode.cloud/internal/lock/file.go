@@ -0,0 +1,167 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileBackend keeps one lease file per cluster under <dir>/<cluster>.lock.
+// It only coordinates processes that share the same filesystem.
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates a backend rooted at dir.
+func NewFileBackend(dir string) (*FileBackend, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("lock directory is required")
+	}
+
+	return &FileBackend{dir: dir}, nil
+}
+
+// Get reads the lease for a cluster.
+func (b *FileBackend) Get(ctx context.Context, cluster string) (*Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := b.path(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lock file: %w", err)
+	}
+
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("decode lock file %s: %w", path, err)
+	}
+
+	return &lease, nil
+}
+
+// Create writes the lease with O_EXCL so only one process can win.
+func (b *FileBackend) Create(ctx context.Context, lease *Lease) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := b.path(lease.Cluster)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create lock directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(lease, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode lease: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrHeld
+	}
+	if err != nil {
+		return fmt.Errorf("create lock file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("write lock file: %w", err)
+	}
+
+	return file.Close()
+}
+
+// Replace rewrites the lease when the stored token still matches.
+func (b *FileBackend) Replace(ctx context.Context, previous, next *Lease) error {
+	current, err := b.Get(ctx, next.Cluster)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Token != previous.Token {
+		return ErrLost
+	}
+
+	path, err := b.path(next.Cluster)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode lease: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+next.Cluster+"-*.lock")
+	if err != nil {
+		return fmt.Errorf("create temporary lock file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("write lock file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("close lock file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("persist lock file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the lease file when the token matches or token is empty.
+func (b *FileBackend) Delete(ctx context.Context, cluster, token string) error {
+	current, err := b.Get(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+	if token != "" && current.Token != token {
+		return ErrLost
+	}
+
+	path, err := b.path(cluster)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove lock file: %w", err)
+	}
+
+	return nil
+}
+
+func (b *FileBackend) path(cluster string) (string, error) {
+	cluster = strings.TrimSpace(cluster)
+	if cluster == "" {
+		return "", fmt.Errorf("cluster is required")
+	}
+	if strings.ContainsAny(cluster, `/\`) || cluster == ".." || cluster == "." {
+		return "", fmt.Errorf("invalid cluster name %q", cluster)
+	}
+
+	return filepath.Join(b.dir, cluster+".lock"), nil
+}
@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultLeaseNamespace is where cluster mutation Leases are created.
+	DefaultLeaseNamespace = "kube-system"
+
+	leaseNamePrefix          = "rawkode-cloud-lock-"
+	leaseTokenAnnotation     = "rawkode.cloud/lock-token"
+	leaseOperationAnnotation = "rawkode.cloud/operation-id"
+	leaseCommandAnnotation   = "rawkode.cloud/command"
+	leaseClusterLabel        = "rawkode.cloud/cluster"
+)
+
+// KubernetesBackend stores the lease as a coordination.k8s.io Lease inside
+// the cluster itself, using resourceVersion for compare-and-swap.
+type KubernetesBackend struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// NewKubernetesBackend creates a backend that keeps Leases in namespace.
+func NewKubernetesBackend(client kubernetes.Interface, namespace string) (*KubernetesBackend, error) {
+	if client == nil {
+		return nil, fmt.Errorf("kubernetes client is required")
+	}
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" {
+		namespace = DefaultLeaseNamespace
+	}
+
+	return &KubernetesBackend{client: client, namespace: namespace}, nil
+}
+
+// Get reads the Lease for a cluster.
+func (b *KubernetesBackend) Get(ctx context.Context, cluster string) (*Lease, error) {
+	obj, err := b.client.CoordinationV1().Leases(b.namespace).Get(ctx, leaseName(cluster), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lease %s/%s: %w", b.namespace, leaseName(cluster), err)
+	}
+
+	return leaseFromObject(cluster, obj), nil
+}
+
+// Create creates the Lease, relying on the API server to reject duplicates.
+func (b *KubernetesBackend) Create(ctx context.Context, lease *Lease) error {
+	obj := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseName(lease.Cluster),
+			Namespace: b.namespace,
+			Labels: map[string]string{
+				leaseClusterLabel: lease.Cluster,
+			},
+		},
+	}
+	applyLeaseToObject(lease, obj)
+
+	_, err := b.client.CoordinationV1().Leases(b.namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return ErrHeld
+	}
+	if err != nil {
+		return fmt.Errorf("create lease %s/%s: %w", b.namespace, obj.Name, err)
+	}
+
+	return nil
+}
+
+// Replace updates the Lease when it still carries the previous token.
+func (b *KubernetesBackend) Replace(ctx context.Context, previous, next *Lease) error {
+	leases := b.client.CoordinationV1().Leases(b.namespace)
+
+	obj, err := leases.Get(ctx, leaseName(next.Cluster), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrLost
+	}
+	if err != nil {
+		return fmt.Errorf("get lease %s/%s: %w", b.namespace, leaseName(next.Cluster), err)
+	}
+	if obj.Annotations[leaseTokenAnnotation] != previous.Token {
+		return ErrLost
+	}
+
+	applyLeaseToObject(next, obj)
+	if _, err := leases.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			return ErrLost
+		}
+		return fmt.Errorf("update lease %s/%s: %w", b.namespace, obj.Name, err)
+	}
+
+	return nil
+}
+
+// Delete removes the Lease when the token matches or token is empty.
+func (b *KubernetesBackend) Delete(ctx context.Context, cluster, token string) error {
+	leases := b.client.CoordinationV1().Leases(b.namespace)
+
+	options := metav1.DeleteOptions{}
+	if token != "" {
+		obj, err := leases.Get(ctx, leaseName(cluster), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("get lease %s/%s: %w", b.namespace, leaseName(cluster), err)
+		}
+		if obj.Annotations[leaseTokenAnnotation] != token {
+			return ErrLost
+		}
+		resourceVersion := obj.ResourceVersion
+		options.Preconditions = &metav1.Preconditions{ResourceVersion: &resourceVersion}
+	}
+
+	err := leases.Delete(ctx, leaseName(cluster), options)
+	if err != nil && !apierrors.IsNotFound(err) {
+		if apierrors.IsConflict(err) {
+			return ErrLost
+		}
+		return fmt.Errorf("delete lease %s/%s: %w", b.namespace, leaseName(cluster), err)
+	}
+
+	return nil
+}
+
+func leaseName(cluster string) string {
+	return leaseNamePrefix + strings.ToLower(strings.TrimSpace(cluster))
+}
+
+func applyLeaseToObject(lease *Lease, obj *coordinationv1.Lease) {
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[leaseTokenAnnotation] = lease.Token
+	obj.Annotations[leaseOperationAnnotation] = lease.OperationID
+	obj.Annotations[leaseCommandAnnotation] = lease.Command
+
+	holder := lease.Holder
+	durationSeconds := int32(lease.ExpiresAt.Sub(lease.RenewedAt) / time.Second)
+	acquired := metav1.NewMicroTime(lease.AcquiredAt)
+	renewed := metav1.NewMicroTime(lease.RenewedAt)
+
+	obj.Spec.HolderIdentity = &holder
+	obj.Spec.LeaseDurationSeconds = &durationSeconds
+	obj.Spec.AcquireTime = &acquired
+	obj.Spec.RenewTime = &renewed
+}
+
+func leaseFromObject(cluster string, obj *coordinationv1.Lease) *Lease {
+	lease := &Lease{
+		Cluster:     cluster,
+		Token:       obj.Annotations[leaseTokenAnnotation],
+		OperationID: obj.Annotations[leaseOperationAnnotation],
+		Command:     obj.Annotations[leaseCommandAnnotation],
+	}
+	if obj.Spec.HolderIdentity != nil {
+		lease.Holder = *obj.Spec.HolderIdentity
+	}
+	if obj.Spec.AcquireTime != nil {
+		lease.AcquiredAt = obj.Spec.AcquireTime.UTC()
+	}
+	if obj.Spec.RenewTime != nil {
+		lease.RenewedAt = obj.Spec.RenewTime.UTC()
+	}
+	if obj.Spec.LeaseDurationSeconds != nil {
+		lease.ExpiresAt = lease.RenewedAt.Add(time.Duration(*obj.Spec.LeaseDurationSeconds) * time.Second)
+	}
+
+	return lease
+}
@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesBackendLifecycle(t *testing.T) {
+	backend, err := NewKubernetesBackend(fake.NewSimpleClientset(), "")
+	if err != nil {
+		t.Fatalf("NewKubernetesBackend returned error: %v", err)
+	}
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	lease := &Lease{
+		Cluster:     "production",
+		Holder:      "alice",
+		OperationID: "op-1",
+		Token:       "token-1",
+		AcquiredAt:  now,
+		RenewedAt:   now,
+		ExpiresAt:   now.Add(DefaultTTL),
+	}
+	if err := backend.Create(ctx, lease); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if err := backend.Create(ctx, lease); !errors.Is(err, ErrHeld) {
+		t.Fatalf("second Create error = %v, want ErrHeld", err)
+	}
+
+	got, err := backend.Get(ctx, "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Holder != "alice" || got.OperationID != "op-1" || !got.ExpiresAt.Equal(lease.ExpiresAt) {
+		t.Fatalf("Get() = %+v, want holder alice, op-1, expires %s", got, lease.ExpiresAt)
+	}
+
+	stranger := &Lease{Cluster: "production", Token: "other"}
+	if err := backend.Replace(ctx, stranger, stranger); !errors.Is(err, ErrLost) {
+		t.Fatalf("Replace with wrong token error = %v, want ErrLost", err)
+	}
+	if err := backend.Delete(ctx, "production", "other"); !errors.Is(err, ErrLost) {
+		t.Fatalf("Delete with wrong token error = %v, want ErrLost", err)
+	}
+
+	if err := backend.Delete(ctx, "production", "token-1"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if got, err := backend.Get(ctx, "production"); err != nil || got != nil {
+		t.Fatalf("Get after delete = %+v, %v; want nil, nil", got, err)
+	}
+}
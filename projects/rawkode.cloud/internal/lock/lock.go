@@ -0,0 +1,322 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long a lease survives without a heartbeat.
+	DefaultTTL = 2 * time.Minute
+)
+
+var (
+	// ErrHeld is returned when another holder owns a live lease.
+	ErrHeld = errors.New("cluster lock is held")
+	// ErrLost is returned when a lease was broken or taken over while held.
+	ErrLost = errors.New("cluster lock was lost")
+)
+
+// Lease describes who holds the mutation lock for a cluster.
+type Lease struct {
+	Cluster     string    `json:"cluster"`
+	Holder      string    `json:"holder"`
+	Command     string    `json:"command,omitempty"`
+	OperationID string    `json:"operationId,omitempty"`
+	Token       string    `json:"token"`
+	AcquiredAt  time.Time `json:"acquiredAt"`
+	RenewedAt   time.Time `json:"renewedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// Expired reports whether the lease has gone without a heartbeat for its TTL.
+func (l *Lease) Expired(now time.Time) bool {
+	return l == nil || !now.Before(l.ExpiresAt)
+}
+
+// HeldError reports the current holder of a live lease.
+type HeldError struct {
+	Lease *Lease
+}
+
+func (e *HeldError) Error() string {
+	if e.Lease == nil {
+		return ErrHeld.Error()
+	}
+
+	operationID := e.Lease.OperationID
+	if operationID == "" {
+		operationID = "none"
+	}
+
+	return fmt.Sprintf(
+		"cluster %q is locked by %s (command=%q, operation=%s, acquired=%s, expires=%s)",
+		e.Lease.Cluster,
+		e.Lease.Holder,
+		e.Lease.Command,
+		operationID,
+		e.Lease.AcquiredAt.Local().Format(time.RFC3339),
+		e.Lease.ExpiresAt.Local().Format(time.RFC3339),
+	)
+}
+
+func (e *HeldError) Is(target error) bool {
+	return target == ErrHeld
+}
+
+// Backend stores at most one lease per cluster.
+type Backend interface {
+	// Get returns the stored lease, or nil when the cluster is unlocked.
+	Get(ctx context.Context, cluster string) (*Lease, error)
+	// Create stores lease only when no lease exists, returning ErrHeld otherwise.
+	Create(ctx context.Context, lease *Lease) error
+	// Replace swaps the stored lease for next when it still carries previous.Token,
+	// returning ErrLost otherwise.
+	Replace(ctx context.Context, previous, next *Lease) error
+	// Delete removes the lease when it carries token; an empty token removes any lease.
+	Delete(ctx context.Context, cluster, token string) error
+}
+
+// Request describes a lock acquisition.
+type Request struct {
+	Cluster     string
+	Holder      string
+	Command     string
+	OperationID string
+	TTL         time.Duration
+}
+
+// Lock is a held lease that is renewed in the background until released.
+type Lock struct {
+	backend Backend
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	lease Lease
+	err   error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Acquire takes the cluster lease, replacing it only when the previous holder
+// has stopped renewing it.
+func Acquire(ctx context.Context, backend Backend, req Request) (*Lock, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("lock backend is required")
+	}
+	cluster := strings.TrimSpace(req.Cluster)
+	if cluster == "" {
+		return nil, fmt.Errorf("cluster is required")
+	}
+
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	holder := strings.TrimSpace(req.Holder)
+	if holder == "" {
+		holder = DefaultHolder()
+	}
+
+	now := time.Now().UTC()
+	lease := &Lease{
+		Cluster:     cluster,
+		Holder:      holder,
+		Command:     strings.TrimSpace(req.Command),
+		OperationID: strings.TrimSpace(req.OperationID),
+		Token:       newToken(),
+		AcquiredAt:  now,
+		RenewedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	err := backend.Create(ctx, lease)
+	if errors.Is(err, ErrHeld) {
+		existing, getErr := backend.Get(ctx, cluster)
+		if getErr != nil {
+			return nil, fmt.Errorf("read existing lock: %w", getErr)
+		}
+		switch {
+		case existing == nil:
+			err = backend.Create(ctx, lease)
+		case existing.Expired(now):
+			slog.Warn("taking over stale cluster lock",
+				"cluster", cluster,
+				"previous_holder", existing.Holder,
+				"previous_operation", existing.OperationID,
+				"expired_at", existing.ExpiresAt,
+			)
+			err = backend.Replace(ctx, existing, lease)
+			if errors.Is(err, ErrLost) {
+				current, _ := backend.Get(ctx, cluster)
+				return nil, &HeldError{Lease: current}
+			}
+		default:
+			return nil, &HeldError{Lease: existing}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{
+		backend: backend,
+		ttl:     ttl,
+		lease:   *lease,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go l.heartbeat()
+
+	return l, nil
+}
+
+// Break removes the lease for a cluster regardless of who holds it.
+func Break(ctx context.Context, backend Backend, cluster string) (*Lease, error) {
+	existing, err := backend.Get(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	if err := backend.Delete(ctx, cluster, ""); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// Lease returns a copy of the currently held lease.
+func (l *Lock) Lease() Lease {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lease
+}
+
+// Err reports that the lock can no longer be relied on: the lease was
+// broken or taken over, or renewals kept failing until it expired.
+func (l *Lock) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	if l.lease.Expired(time.Now()) {
+		return fmt.Errorf("%w: lease expired at %s without being renewed", ErrLost, l.lease.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// SetOperationID records the operation the lock is protecting.
+func (l *Lock) SetOperationID(ctx context.Context, operationID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := l.lease
+	next.OperationID = strings.TrimSpace(operationID)
+	return l.replaceLocked(ctx, next)
+}
+
+// Release stops renewal and removes the lease if it is still ours.
+func (l *Lock) Release(ctx context.Context) error {
+	select {
+	case <-l.stop:
+		return nil
+	default:
+		close(l.stop)
+	}
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil
+	}
+
+	return l.backend.Delete(ctx, l.lease.Cluster, l.lease.Token)
+}
+
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.renew(); err != nil {
+				slog.Error("renew cluster lock", "cluster", l.lease.Cluster, "error", err)
+				if errors.Is(err, ErrLost) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *Lock) renew() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+
+	return l.replaceLocked(ctx, l.lease)
+}
+
+func (l *Lock) replaceLocked(ctx context.Context, next Lease) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	now := time.Now().UTC()
+	next.RenewedAt = now
+	next.ExpiresAt = now.Add(l.ttl)
+
+	current := l.lease
+	if err := l.backend.Replace(ctx, &current, &next); err != nil {
+		if errors.Is(err, ErrLost) {
+			l.err = err
+		}
+		return err
+	}
+
+	l.lease = next
+	return nil
+}
+
+// DefaultHolder identifies the current process as user@host (pid N).
+func DefaultHolder() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil && strings.TrimSpace(current.Username) != "" {
+		name = current.Username
+	}
+	host, err := os.Hostname()
+	if err != nil || strings.TrimSpace(host) == "" {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
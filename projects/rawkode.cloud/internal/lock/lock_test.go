@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestFileBackend(t *testing.T) *FileBackend {
+	t.Helper()
+
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileBackend returned error: %v", err)
+	}
+	return backend
+}
+
+func TestAcquireRejectsLiveLease(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	held, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "alice", OperationID: "op-1"})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	defer held.Release(ctx)
+
+	_, err = Acquire(ctx, backend, Request{Cluster: "production", Holder: "bob"})
+	var heldErr *HeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("Acquire error = %v, want HeldError", err)
+	}
+	if heldErr.Lease.Holder != "alice" || heldErr.Lease.OperationID != "op-1" {
+		t.Fatalf("HeldError lease = %+v, want alice/op-1", heldErr.Lease)
+	}
+}
+
+func TestAcquireTakesOverExpiredLease(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	stale := &Lease{
+		Cluster:    "production",
+		Holder:     "alice",
+		Token:      "stale-token",
+		AcquiredAt: time.Now().Add(-time.Hour),
+		RenewedAt:  time.Now().Add(-time.Hour),
+		ExpiresAt:  time.Now().Add(-time.Minute),
+	}
+	if err := backend.Create(ctx, stale); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	held, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "bob"})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	defer held.Release(ctx)
+
+	if got := held.Lease().Holder; got != "bob" {
+		t.Fatalf("holder = %q, want %q", got, "bob")
+	}
+}
+
+func TestReleaseRemovesOnlyOwnLease(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	held, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "alice"})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	if _, err := Break(ctx, backend, "production"); err != nil {
+		t.Fatalf("Break returned error: %v", err)
+	}
+
+	next, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "bob"})
+	if err != nil {
+		t.Fatalf("Acquire after break returned error: %v", err)
+	}
+	defer next.Release(ctx)
+
+	if err := held.Release(ctx); !errors.Is(err, ErrLost) {
+		t.Fatalf("Release of broken lease error = %v, want ErrLost", err)
+	}
+
+	lease, err := backend.Get(ctx, "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if lease == nil || lease.Holder != "bob" {
+		t.Fatalf("lease = %+v, want bob to still hold it", lease)
+	}
+}
+
+func TestSetOperationIDRenewsLease(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	held, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "alice"})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	defer held.Release(ctx)
+
+	if err := held.SetOperationID(ctx, "op-42"); err != nil {
+		t.Fatalf("SetOperationID returned error: %v", err)
+	}
+
+	lease, err := backend.Get(ctx, "production")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if lease.OperationID != "op-42" {
+		t.Fatalf("stored operation = %q, want %q", lease.OperationID, "op-42")
+	}
+}
+
+// unreachableBackend fails every renewal without saying the lease was lost.
+type unreachableBackend struct {
+	*FileBackend
+}
+
+func (b unreachableBackend) Replace(ctx context.Context, previous, next *Lease) error {
+	return errors.New("connection refused")
+}
+
+func TestErrReportsLeaseExpiredWithoutRenewal(t *testing.T) {
+	backend := unreachableBackend{newTestFileBackend(t)}
+	ctx := context.Background()
+
+	held, err := Acquire(ctx, backend, Request{Cluster: "production", Holder: "alice", TTL: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	defer held.Release(ctx)
+
+	if err := held.Err(); err != nil {
+		t.Fatalf("Err() on fresh lease = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := held.Err(); !errors.Is(err, ErrLost) {
+		t.Fatalf("Err() after failed renewals = %v, want ErrLost", err)
+	}
+}
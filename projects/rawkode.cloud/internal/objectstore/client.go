@@ -0,0 +1,236 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// DefaultRegion is the Scaleway Object Storage region used when none is
+// configured.
+const DefaultRegion = "fr-par"
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Config describes an S3-compatible bucket and the credentials to reach it.
+type Config struct {
+	// Endpoint is the base URL of the service. It defaults to Scaleway
+	// Object Storage in Region.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// HTTPClient defaults to the SDK's client.
+	HTTPClient *http.Client
+}
+
+// Object is an entry of a bucket listing.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Error is an error response from the object store.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("object store returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("object store returned HTTP %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client talks to one bucket of an S3-compatible object store with the AWS
+// SDK, sending path-style requests to the configured endpoint.
+type Client struct {
+	s3     *s3.Client
+	bucket string
+}
+
+// DefaultEndpoint returns the Scaleway Object Storage endpoint of a region.
+func DefaultEndpoint(region string) string {
+	return "https://s3." + region + ".scw.cloud"
+}
+
+// NewClient validates cfg and returns a client for its bucket.
+func NewClient(cfg Config) (*Client, error) {
+	region := strings.TrimSpace(cfg.Region)
+	if region == "" {
+		region = DefaultRegion
+	}
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	if endpoint == "" {
+		endpoint = DefaultEndpoint(region)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid object store endpoint %q", endpoint)
+	}
+
+	bucket := strings.TrimSpace(cfg.Bucket)
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	accessKey, secretKey := strings.TrimSpace(cfg.AccessKey), strings.TrimSpace(cfg.SecretKey)
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("object store credentials are required")
+	}
+
+	options := s3.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(endpoint),
+		// Bucket subdomains need DNS for every bucket; S3-compatible
+		// stores and local stand-ins all serve path-style requests.
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		// The SDK's default CRC checksums are not supported by every
+		// S3-compatible store; Put sends the SHA-256 it is given instead.
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	}
+	if cfg.HTTPClient != nil {
+		options.HTTPClient = cfg.HTTPClient
+	}
+
+	return &Client{s3: s3.New(options), bucket: bucket}, nil
+}
+
+// Bucket returns the bucket the client writes to.
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// Put uploads size bytes from body to key. payloadSHA256 is the hex SHA-256
+// of the body; when set the store rejects a body that does not match it.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64, payloadSHA256 string) error {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	}
+	if payloadSHA256 != "" {
+		sum, err := hex.DecodeString(payloadSHA256)
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("put %s: invalid payload sha256 %q", key, payloadSHA256)
+		}
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+	}
+
+	if _, err := c.s3.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("put %s: %w", key, storeError(err, false))
+	}
+	return nil
+}
+
+// PutBytes uploads a small in-memory object.
+func (c *Client) PutBytes(ctx context.Context, key string, data []byte) error {
+	sum := sha256.Sum256(data)
+	return c.Put(ctx, key, bytes.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]))
+}
+
+// Get opens the object at key. The caller closes the returned reader.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, storeError(err, true))
+	}
+	return out.Body, nil
+}
+
+// GetBytes reads a small object into memory.
+func (c *Client) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	body, err := c.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// Delete removes the object at key. Deleting a missing object succeeds.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if err := storeError(err, true); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// List returns every object whose key starts with prefix, ordered by key.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	pages := s3.NewListObjectsV2Paginator(c.s3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var objects []Object
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, storeError(err, true))
+		}
+		for _, entry := range page.Contents {
+			objects = append(objects, Object{
+				Key:          aws.ToString(entry.Key),
+				Size:         aws.ToInt64(entry.Size),
+				LastModified: aws.ToTime(entry.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// storeError turns an error response into ErrNotFound, when notFound is set
+// and the store answered 404, or an *Error. Other errors pass through.
+func storeError(err error, notFound bool) error {
+	var response *awshttp.ResponseError
+	if !errors.As(err, &response) {
+		return err
+	}
+	if notFound && response.HTTPStatusCode() == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	storeErr := &Error{StatusCode: response.HTTPStatusCode()}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		storeErr.Code, storeErr.Message = apiErr.ErrorCode(), apiErr.ErrorMessage()
+	}
+	return storeErr
+}
@@ -0,0 +1,106 @@
+package objectstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rawkode-academy/rawkode-cloud3/internal/objectstore"
+	"github.com/rawkode-academy/rawkode-cloud3/internal/objectstore/objectstoretest"
+)
+
+func newTestClient(t *testing.T, server *objectstoretest.Server) *objectstore.Client {
+	t.Helper()
+	client, err := objectstore.NewClient(objectstore.Config{
+		Endpoint:  server.URL,
+		Bucket:    objectstoretest.Bucket,
+		AccessKey: objectstoretest.AccessKey,
+		SecretKey: objectstoretest.SecretKey,
+	})
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	return client
+}
+
+func TestClientPutGetDelete(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	if err := client.PutBytes(ctx, "etcd/production/a b.db", []byte("snapshot")); err != nil {
+		t.Fatalf("PutBytes returned error: %v", err)
+	}
+	if data, ok := server.Object("etcd/production/a b.db"); !ok || string(data) != "snapshot" {
+		t.Fatalf("stored object = %q, %v", data, ok)
+	}
+
+	data, err := client.GetBytes(ctx, "etcd/production/a b.db")
+	if err != nil || string(data) != "snapshot" {
+		t.Fatalf("GetBytes = %q, %v", data, err)
+	}
+
+	if err := client.Delete(ctx, "etcd/production/a b.db"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := client.GetBytes(ctx, "etcd/production/a b.db"); !errors.Is(err, objectstore.ErrNotFound) {
+		t.Fatalf("GetBytes after delete error = %v, want ErrNotFound", err)
+	}
+	if err := client.Delete(ctx, "etcd/production/a b.db"); err != nil {
+		t.Fatalf("deleting a missing object returned error: %v", err)
+	}
+}
+
+func TestClientPutRejectsMismatchedPayloadHash(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	client := newTestClient(t, server)
+
+	other := sha256.Sum256([]byte("other"))
+	err := client.Put(context.Background(), "etcd/x.db", bytes.NewReader([]byte("data")), 4, hex.EncodeToString(other[:]))
+	var storeErr *objectstore.Error
+	if !errors.As(err, &storeErr) || storeErr.Code != "BadDigest" {
+		t.Fatalf("Put error = %v, want a payload hash mismatch", err)
+	}
+	if _, ok := server.Object("etcd/x.db"); ok {
+		t.Fatal("object was stored despite the mismatch")
+	}
+}
+
+func TestClientListFollowsContinuationTokens(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	server.PageSize = 2
+	for i := range 5 {
+		server.SetObject(fmt.Sprintf("etcd/production/%d.db", i), []byte("x"))
+	}
+	server.SetObject("etcd/staging/0.db", []byte("x"))
+
+	objects, err := newTestClient(t, server).List(context.Background(), "etcd/production/")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(objects) != 5 || objects[0].Key != "etcd/production/0.db" || objects[4].Key != "etcd/production/4.db" {
+		t.Fatalf("objects = %+v", objects)
+	}
+}
+
+func TestClientRejectsWrongCredentials(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	client, err := objectstore.NewClient(objectstore.Config{
+		Endpoint:  server.URL,
+		Bucket:    objectstoretest.Bucket,
+		AccessKey: "someone-else",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+
+	var storeErr *objectstore.Error
+	if _, err := client.List(context.Background(), ""); !errors.As(err, &storeErr) || storeErr.StatusCode != 403 {
+		t.Fatalf("List error = %v, want HTTP 403", err)
+	}
+}
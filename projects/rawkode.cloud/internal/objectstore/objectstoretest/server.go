@@ -0,0 +1,201 @@
+// Package objectstoretest provides an in-memory S3-compatible server for
+// tests of code that talks to object storage.
+package objectstoretest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// Bucket is the only bucket the server knows.
+	Bucket = "backups"
+	// AccessKey and SecretKey are the credentials clients must use.
+	AccessKey = "test-access-key"
+	SecretKey = "test-secret-key"
+)
+
+// Server is a MinIO-style stand-in serving one bucket from memory to the
+// S3 SDK. It supports path-style PUT, GET and DELETE of objects and
+// ListObjectsV2, and checks the payload hash and SHA-256 checksum of
+// uploads.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	// PageSize limits the keys of one listing page.
+	PageSize int
+	// Now stamps uploaded objects.
+	Now func() time.Time
+	// Requests counts the requests served, by method.
+	Requests map[string]int
+}
+
+type object struct {
+	Key          string `xml:"Key"`
+	Size         int    `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string   `xml:"Name"`
+	Prefix                string   `xml:"Prefix"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	Contents              []object `xml:"Contents"`
+}
+
+type errorResult struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		objects:  map[string][]byte{},
+		PageSize: 1000,
+		Now:      time.Now,
+		Requests: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Object returns the content stored at key.
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+// SetObject stores content at key, bypassing the HTTP API.
+func (s *Server) SetObject(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+}
+
+// Keys returns the stored keys in order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Requests[r.Method]++
+
+	if !strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+AccessKey+"/") {
+		writeError(w, http.StatusForbidden, "AccessDenied", "request is not signed with the test credentials")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != Bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket "+bucket+" does not exist")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+	case r.Method == http.MethodPut:
+		s.put(w, r, key)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "key "+key+" does not exist")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" is not supported")
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if r.ContentLength >= 0 && int64(len(data)) != r.ContentLength {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", "body does not match Content-Length")
+		return
+	}
+	sum := sha256.Sum256(data)
+	if signed := r.Header.Get("X-Amz-Content-Sha256"); signed != "UNSIGNED-PAYLOAD" && signed != hex.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match the body")
+		return
+	}
+	if checksum := r.Header.Get("X-Amz-Checksum-Sha256"); checksum != "" && checksum != base64.StdEncoding.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "BadDigest", "the SHA256 you specified did not match the calculated checksum")
+		return
+	}
+	s.objects[key] = data
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	after := query.Get("continuation-token")
+
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := listResult{Name: Bucket, Prefix: prefix}
+	if len(keys) > s.PageSize {
+		keys = keys[:s.PageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	stamp := s.Now().UTC().Format(time.RFC3339)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, object{Key: key, Size: len(s.objects[key]), LastModified: stamp})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(errorResult{Code: code, Message: message})
+}
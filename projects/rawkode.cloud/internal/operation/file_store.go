@@ -0,0 +1,156 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore keeps one JSON document per operation under <dir>/<cluster>/<id>.json.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store rooted at dir.
+func NewFileStore(dir string) (*FileStore, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("state directory is required")
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes the operation atomically so a crash never leaves a torn file.
+func (s *FileStore) Save(ctx context.Context, op *Operation) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if op == nil {
+		return fmt.Errorf("operation is required")
+	}
+
+	path, err := s.path(op.Cluster, op.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode operation %s: %w", op.ID, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create operation state directory: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+op.ID+"-*.json")
+	if err != nil {
+		return fmt.Errorf("create temporary operation file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("write operation %s: %w", op.ID, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("close operation %s: %w", op.ID, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("persist operation %s: %w", op.ID, err)
+	}
+
+	return nil
+}
+
+// Load reads a single operation for a cluster.
+func (s *FileStore) Load(ctx context.Context, cluster, id string) (*Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := s.path(cluster, id)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := readOperationFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return op, err
+}
+
+// List returns every operation recorded for a cluster.
+func (s *FileStore) List(ctx context.Context, cluster string) ([]*Operation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cluster = strings.TrimSpace(cluster)
+	if cluster == "" {
+		return nil, fmt.Errorf("cluster is required")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, cluster))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list operations for cluster %q: %w", cluster, err)
+	}
+
+	ops := make([]*Operation, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		op, err := readOperationFile(filepath.Join(s.dir, cluster, name))
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func (s *FileStore) path(cluster, id string) (string, error) {
+	cluster = strings.TrimSpace(cluster)
+	id = strings.TrimSpace(id)
+	if cluster == "" {
+		return "", fmt.Errorf("cluster is required")
+	}
+	if id == "" {
+		return "", fmt.Errorf("operation ID is required")
+	}
+	if strings.ContainsAny(cluster+id, `/\`) || cluster == ".." || id == ".." {
+		return "", fmt.Errorf("invalid operation reference %s/%s", cluster, id)
+	}
+
+	return filepath.Join(s.dir, cluster, id+".json"), nil
+}
+
+func readOperationFile(path string) (*Operation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return op, nil
+}
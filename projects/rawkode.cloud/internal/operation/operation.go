@@ -10,11 +10,13 @@ import (
 type Type string
 
 const (
-	TypeCreateCluster Type = "create-cluster"
-	TypeAddNode       Type = "add-node"
-	TypeRemoveNode    Type = "remove-node"
-	TypeUpgradeTalos  Type = "upgrade-talos"
-	TypeUpgradeK8s    Type = "upgrade-k8s"
+	TypeCreateCluster  Type = "create-cluster"
+	TypeAddNode        Type = "add-node"
+	TypeRemoveNode     Type = "remove-node"
+	TypeReplaceNode    Type = "replace-node"
+	TypeUpgradeTalos   Type = "upgrade-talos"
+	TypeUpgradeK8s     Type = "upgrade-k8s"
+	TypeRestoreCluster Type = "restore-cluster"
 )
 
 // PhaseStatus tracks the state of a single phase.
@@ -45,18 +47,30 @@ type CleanupAction struct {
 
 // Operation is the full state of a resumable provisioning operation.
 type Operation struct {
-	ID           string                `json:"id"`
-	Type         Type                  `json:"type"`
-	Cluster      string                `json:"cluster"`
-	CreatedAt    time.Time             `json:"createdAt"`
-	UpdatedAt    time.Time             `json:"updatedAt"`
-	CurrentPhase string                `json:"currentPhase"`
-	PhaseOrder   []string              `json:"phaseOrder"`
-	Phases       map[string]*Phase     `json:"phases"`
-	Context      map[string]any        `json:"context"`
-	Cleanup      []CleanupAction       `json:"cleanup"`
+	ID           string            `json:"id"`
+	Type         Type              `json:"type"`
+	Cluster      string            `json:"cluster"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	CurrentPhase string            `json:"currentPhase"`
+	PhaseOrder   []string          `json:"phaseOrder"`
+	Phases       map[string]*Phase `json:"phases"`
+	Context      map[string]any    `json:"context"`
+	Cleanup      []CleanupAction   `json:"cleanup"`
+	AbortedAt    *time.Time        `json:"abortedAt,omitempty"`
 }
 
+// Status summarises the state of an operation as a whole.
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusInProgress Status = "in-progress"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+	StatusAborted    Status = "aborted"
+)
+
 // New creates a new operation with the given phases in order.
 func New(id string, opType Type, cluster string, phases []string) *Operation {
 	now := time.Now().UTC()
@@ -94,6 +108,49 @@ func (o *Operation) IsComplete() bool {
 	return true
 }
 
+// IsAborted returns true once the operation has been aborted.
+func (o *Operation) IsAborted() bool {
+	return o.AbortedAt != nil
+}
+
+// Abort marks the operation as aborted so it is never resumed.
+func (o *Operation) Abort() {
+	now := time.Now().UTC()
+	if o.AbortedAt == nil {
+		o.AbortedAt = &now
+	}
+	o.UpdatedAt = now
+}
+
+// Status derives the overall operation status from its phases.
+func (o *Operation) Status() Status {
+	if o.IsAborted() {
+		return StatusAborted
+	}
+	if o.IsComplete() {
+		return StatusCompleted
+	}
+
+	started := false
+	for _, name := range o.PhaseOrder {
+		phase, ok := o.Phases[name]
+		if !ok {
+			continue
+		}
+		switch phase.Status {
+		case PhaseFailed:
+			return StatusFailed
+		case PhaseInProgress, PhaseCompleted, PhaseSkipped:
+			started = true
+		}
+	}
+	if started {
+		return StatusInProgress
+	}
+
+	return StatusPending
+}
+
 // StartPhase marks a phase as in-progress.
 func (o *Operation) StartPhase(name string) error {
 	phase, ok := o.Phases[name]
@@ -0,0 +1,64 @@
+package operation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOperationStatus(t *testing.T) {
+	phases := []string{"init", "order-server"}
+
+	tests := []struct {
+		name   string
+		mutate func(op *Operation)
+		want   Status
+	}{
+		{
+			name:   "pending",
+			mutate: func(op *Operation) {},
+			want:   StatusPending,
+		},
+		{
+			name: "in progress",
+			mutate: func(op *Operation) {
+				_ = op.StartPhase("init")
+				_ = op.CompletePhase("init", nil)
+			},
+			want: StatusInProgress,
+		},
+		{
+			name: "failed",
+			mutate: func(op *Operation) {
+				_ = op.StartPhase("init")
+				_ = op.FailPhase("init", errors.New("boom"))
+			},
+			want: StatusFailed,
+		},
+		{
+			name: "completed",
+			mutate: func(op *Operation) {
+				_ = op.CompletePhase("init", nil)
+				_ = op.CompletePhase("order-server", nil)
+			},
+			want: StatusCompleted,
+		},
+		{
+			name: "aborted wins over failed",
+			mutate: func(op *Operation) {
+				_ = op.FailPhase("init", errors.New("boom"))
+				op.Abort()
+			},
+			want: StatusAborted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := New("op-1", TypeCreateCluster, "production", phases)
+			tt.mutate(op)
+			if got := op.Status(); got != tt.want {
+				t.Fatalf("Status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
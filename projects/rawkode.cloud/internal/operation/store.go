@@ -0,0 +1,71 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotFound is returned when a store has no record of an operation.
+var ErrNotFound = errors.New("operation not found")
+
+// Store persists operations so that interrupted runs can be resumed.
+type Store interface {
+	// Save writes the full operation state, replacing any previous copy.
+	Save(ctx context.Context, op *Operation) error
+	// Load returns a single operation for a cluster by ID.
+	Load(ctx context.Context, cluster, id string) (*Operation, error)
+	// List returns every operation recorded for a cluster.
+	List(ctx context.Context, cluster string) ([]*Operation, error)
+}
+
+// SortByUpdated orders operations from most to least recently updated.
+func SortByUpdated(ops []*Operation) {
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].UpdatedAt.After(ops[j].UpdatedAt)
+	})
+}
+
+// LatestIncomplete returns the most recently updated operation of the given
+// type that still has pending phases and was not aborted, or nil when there is none.
+func LatestIncomplete(ctx context.Context, store Store, cluster string, opType Type) (*Operation, error) {
+	if store == nil {
+		return nil, fmt.Errorf("operation store is required")
+	}
+
+	ops, err := store.List(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	SortByUpdated(ops)
+
+	for _, op := range ops {
+		if op.Type != opType {
+			continue
+		}
+		if op.IsComplete() || op.IsAborted() {
+			continue
+		}
+		return op, nil
+	}
+
+	return nil, nil
+}
+
+// Decode parses a persisted operation document.
+func Decode(data []byte) (*Operation, error) {
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("decode operation: %w", err)
+	}
+	if op.Phases == nil {
+		op.Phases = map[string]*Phase{}
+	}
+	if op.Context == nil {
+		op.Context = map[string]any{}
+	}
+
+	return &op, nil
+}
@@ -0,0 +1,146 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	op := New("op-1", TypeCreateCluster, "production", []string{"init", "order-server"})
+	op.SetContext("serverId", "server-123")
+	if err := op.StartPhase("init"); err != nil {
+		t.Fatalf("StartPhase returned error: %v", err)
+	}
+	if err := op.CompletePhase("init", nil); err != nil {
+		t.Fatalf("CompletePhase returned error: %v", err)
+	}
+	if err := store.Save(context.Background(), op); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	loaded, err := store.Load(context.Background(), "production", "op-1")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := loaded.ResumePhase(); got != "order-server" {
+		t.Fatalf("ResumePhase() = %q, want %q", got, "order-server")
+	}
+	if got := loaded.GetContextString("serverId"); got != "server-123" {
+		t.Fatalf("serverId context = %q, want %q", got, "server-123")
+	}
+}
+
+func TestFileStoreLoadMissingReturnsErrNotFound(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	_, err = store.Load(context.Background(), "production", "op-missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreListIgnoresOtherClustersAndTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	for _, op := range []*Operation{
+		New("op-1", TypeCreateCluster, "production", []string{"init"}),
+		New("op-2", TypeAddNode, "production", []string{"init"}),
+		New("op-3", TypeCreateCluster, "staging", []string{"init"}),
+	} {
+		if err := store.Save(context.Background(), op); err != nil {
+			t.Fatalf("Save(%s) returned error: %v", op.ID, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "production", ".op-4-123.json"), []byte("{"), 0o600); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	ops, err := store.List(context.Background(), "production")
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("List() returned %d operations, want 2", len(ops))
+	}
+}
+
+func TestFileStoreRejectsPathTraversal(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	if _, err := store.Load(context.Background(), "production", "../op-1"); err == nil {
+		t.Fatal("expected error for operation ID containing a path separator")
+	}
+}
+
+func TestLatestIncompletePicksMostRecentUnfinishedOfType(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	older := New("op-older", TypeCreateCluster, "production", []string{"init"})
+	older.UpdatedAt = time.Now().Add(-time.Hour)
+
+	newer := New("op-newer", TypeCreateCluster, "production", []string{"init"})
+
+	complete := New("op-complete", TypeCreateCluster, "production", []string{"init"})
+	_ = complete.CompletePhase("init", nil)
+	complete.UpdatedAt = time.Now().Add(time.Hour)
+
+	otherType := New("op-other", TypeAddNode, "production", []string{"init"})
+	otherType.UpdatedAt = time.Now().Add(time.Hour)
+
+	for _, op := range []*Operation{older, newer, complete, otherType} {
+		if err := store.Save(context.Background(), op); err != nil {
+			t.Fatalf("Save(%s) returned error: %v", op.ID, err)
+		}
+	}
+
+	got, err := LatestIncomplete(context.Background(), store, "production", TypeCreateCluster)
+	if err != nil {
+		t.Fatalf("LatestIncomplete returned error: %v", err)
+	}
+	if got == nil || got.ID != "op-newer" {
+		t.Fatalf("LatestIncomplete() = %v, want op-newer", got)
+	}
+}
+
+func TestLatestIncompleteSkipsAbortedOperations(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore returned error: %v", err)
+	}
+
+	aborted := New("op-aborted", TypeCreateCluster, "production", []string{"init"})
+	aborted.Abort()
+	if err := store.Save(context.Background(), aborted); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	got, err := LatestIncomplete(context.Background(), store, "production", TypeCreateCluster)
+	if err != nil {
+		t.Fatalf("LatestIncomplete returned error: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("LatestIncomplete() = %s, want nil", got.ID)
+	}
+}
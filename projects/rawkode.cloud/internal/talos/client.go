@@ -25,7 +25,10 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-const talosAPIDefaultPort = "50000"
+const (
+	talosAPIDefaultPort = "50000"
+	machineConfigPath   = "/system/state/config.yaml"
+)
 
 var (
 	waitForMaintenancePollInterval = 15 * time.Second
@@ -124,30 +127,108 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// ApplyMode selects how a Talos node takes on a new machine config.
+type ApplyMode string
+
+const (
+	// ApplyModeAuto lets Talos decide whether the change needs a reboot.
+	ApplyModeAuto ApplyMode = "auto"
+	// ApplyModeNoReboot applies the change live and fails if it cannot be.
+	ApplyModeNoReboot ApplyMode = "no-reboot"
+	// ApplyModeReboot applies the change and reboots the node.
+	ApplyModeReboot ApplyMode = "reboot"
+	// ApplyModeStaged stores the change for the next reboot.
+	ApplyModeStaged ApplyMode = "staged"
+)
+
+var applyModes = map[ApplyMode]machineapi.ApplyConfigurationRequest_Mode{
+	ApplyModeAuto:     machineapi.ApplyConfigurationRequest_AUTO,
+	ApplyModeNoReboot: machineapi.ApplyConfigurationRequest_NO_REBOOT,
+	ApplyModeReboot:   machineapi.ApplyConfigurationRequest_REBOOT,
+	ApplyModeStaged:   machineapi.ApplyConfigurationRequest_STAGED,
+}
+
+// ParseApplyMode validates an apply mode name.
+func ParseApplyMode(value string) (ApplyMode, error) {
+	mode := ApplyMode(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := applyModes[mode]; !ok {
+		return "", fmt.Errorf("unknown apply mode %q (want auto, no-reboot, reboot or staged)", value)
+	}
+	return mode, nil
+}
+
+// ApplyResult reports what Talos did with an applied machine config.
+type ApplyResult struct {
+	// Mode is the mode Talos used; never ApplyModeAuto.
+	Mode     ApplyMode
+	Details  string
+	Warnings []string
+}
+
 // ApplyConfig sends a machine configuration to a Talos node.
 func (c *Client) ApplyConfig(ctx context.Context, configYAML []byte) error {
+	_, err := c.ApplyConfigWithMode(ctx, configYAML, ApplyModeAuto, false)
+	return err
+}
+
+// ApplyConfigWithMode sends a machine configuration to a Talos node using
+// the given mode. With dryRun set Talos only validates the config and
+// reports the mode it would use.
+func (c *Client) ApplyConfigWithMode(ctx context.Context, configYAML []byte, mode ApplyMode, dryRun bool) (*ApplyResult, error) {
 	if c.machine == nil {
-		return fmt.Errorf("talos client is not initialized")
+		return nil, fmt.Errorf("talos client is not initialized")
 	}
 	if len(strings.TrimSpace(string(configYAML))) == 0 {
-		return fmt.Errorf("machine config is required")
+		return nil, fmt.Errorf("machine config is required")
+	}
+	requestMode, ok := applyModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown apply mode %q", mode)
 	}
 
-	slog.Info("applying Talos machine config", "target", c.targetNode)
+	if !dryRun {
+		slog.Info("applying Talos machine config", "target", c.targetNode, "mode", mode)
+	}
 
-	_, err := c.machine.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
-		Data: configYAML,
-		Mode: machineapi.ApplyConfigurationRequest_AUTO,
+	resp, err := c.machine.ApplyConfiguration(ctx, &machineapi.ApplyConfigurationRequest{
+		Data:   configYAML,
+		Mode:   requestMode,
+		DryRun: dryRun,
 	})
 	if err != nil {
-		return fmt.Errorf("apply talos machine config: %w", err)
+		return nil, fmt.Errorf("apply talos machine config: %w", err)
 	}
 
-	return nil
+	result := &ApplyResult{Mode: mode}
+	for _, msg := range resp.GetMessages() {
+		for applied, value := range applyModes {
+			if applied != ApplyModeAuto && value == msg.GetMode() {
+				result.Mode = applied
+			}
+		}
+		result.Details = strings.TrimSpace(msg.GetModeDetails())
+		result.Warnings = append(result.Warnings, msg.GetWarnings()...)
+	}
+
+	return result, nil
 }
 
 // Bootstrap bootstraps etcd on the first control plane node.
 func (c *Client) Bootstrap(ctx context.Context) error {
+	return c.bootstrap(ctx, &machineapi.BootstrapRequest{})
+}
+
+// BootstrapFromSnapshot bootstraps etcd from the snapshot uploaded with
+// EtcdRestoreFrom. skipHashCheck is needed for snapshots that do not end
+// with etcd's integrity hash, such as a copied database file.
+func (c *Client) BootstrapFromSnapshot(ctx context.Context, skipHashCheck bool) error {
+	return c.bootstrap(ctx, &machineapi.BootstrapRequest{
+		RecoverEtcd:          true,
+		RecoverSkipHashCheck: skipHashCheck,
+	})
+}
+
+func (c *Client) bootstrap(ctx context.Context, req *machineapi.BootstrapRequest) error {
 	if c.machine == nil {
 		return fmt.Errorf("talos client is not initialized")
 	}
@@ -155,9 +236,9 @@ func (c *Client) Bootstrap(ctx context.Context) error {
 		return fmt.Errorf("bootstrap requires talosconfig")
 	}
 
-	slog.Info("bootstrapping etcd", "target", c.targetNode)
+	slog.Info("bootstrapping etcd", "target", c.targetNode, "recover", req.GetRecoverEtcd())
 
-	if _, err := c.machine.Bootstrap(ctx, &machineapi.BootstrapRequest{}); err != nil {
+	if _, err := c.machine.Bootstrap(ctx, req); err != nil {
 		return fmt.Errorf("bootstrap etcd: %w", err)
 	}
 
@@ -197,6 +278,39 @@ func (c *Client) Kubeconfig(ctx context.Context) ([]byte, error) {
 	return extractKubeconfig(out.Bytes())
 }
 
+// MachineConfig reads the machine configuration the node is running with.
+func (c *Client) MachineConfig(ctx context.Context) ([]byte, error) {
+	if c.machine == nil {
+		return nil, fmt.Errorf("talos client is not initialized")
+	}
+	if c.insecure {
+		return nil, fmt.Errorf("reading machine config requires talosconfig")
+	}
+
+	stream, err := c.machine.Read(ctx, &machineapi.ReadRequest{Path: machineConfigPath})
+	if err != nil {
+		return nil, fmt.Errorf("request machine config: %w", err)
+	}
+
+	var out bytes.Buffer
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read machine config stream: %w", err)
+		}
+		out.Write(chunk.GetBytes())
+	}
+
+	if len(bytes.TrimSpace(out.Bytes())) == 0 {
+		return nil, fmt.Errorf("machine config is empty")
+	}
+
+	return out.Bytes(), nil
+}
+
 func extractKubeconfig(data []byte) ([]byte, error) {
 	gzReader, err := gzip.NewReader(bytes.NewReader(data))
 	if err != nil {
@@ -268,6 +382,69 @@ func (c *Client) HealthCheck(ctx context.Context) error {
 	}
 }
 
+// ServiceStatus is the state of a single Talos service as reported by the node.
+type ServiceStatus struct {
+	ID      string `json:"id"`
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+	Unknown bool   `json:"unknown,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastEvent is the message of the service's most recent event.
+	LastEvent string `json:"lastEvent,omitempty"`
+}
+
+// Services lists Talos services and their health on the target node.
+func (c *Client) Services(ctx context.Context) ([]ServiceStatus, error) {
+	if c.machine == nil {
+		return nil, fmt.Errorf("talos client is not initialized")
+	}
+
+	response, err := c.machine.ServiceList(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+
+	var services []ServiceStatus
+	for _, message := range response.GetMessages() {
+		for _, service := range message.GetServices() {
+			health := service.GetHealth()
+			status := ServiceStatus{
+				ID:      strings.TrimSpace(service.GetId()),
+				State:   strings.TrimSpace(service.GetState()),
+				Healthy: health.GetHealthy(),
+				Unknown: health.GetUnknown(),
+				Message: strings.TrimSpace(health.GetLastMessage()),
+			}
+			if events := service.GetEvents().GetEvents(); len(events) > 0 {
+				status.LastEvent = strings.TrimSpace(events[len(events)-1].GetMsg())
+			}
+			services = append(services, status)
+		}
+	}
+
+	return services, nil
+}
+
+// Version returns the Talos version tag running on the target node.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	if c.machine == nil {
+		return "", fmt.Errorf("talos client is not initialized")
+	}
+
+	response, err := c.machine.Version(ctx, &emptypb.Empty{})
+	if err != nil {
+		return "", fmt.Errorf("get version: %w", err)
+	}
+
+	for _, message := range response.GetMessages() {
+		if tag := strings.TrimSpace(message.GetVersion().GetTag()); tag != "" {
+			return tag, nil
+		}
+	}
+
+	return "", fmt.Errorf("version response did not include a tag")
+}
+
 // WaitForServiceRunning waits until a Talos service reaches running state.
 func (c *Client) WaitForServiceRunning(ctx context.Context, serviceID string, timeout time.Duration) error {
 	if c.machine == nil {
@@ -382,6 +559,33 @@ func (c *Client) Reset(ctx context.Context) error {
 	return nil
 }
 
+// ResetEphemeral wipes the EPHEMERAL partition, which holds the etcd data,
+// and reboots without leaving etcd first. The machine config survives, so
+// a control plane comes back with etcd waiting to be bootstrapped or to
+// join a cluster.
+func (c *Client) ResetEphemeral(ctx context.Context) error {
+	if c.machine == nil {
+		return fmt.Errorf("talos client is not initialized")
+	}
+	if c.insecure {
+		return fmt.Errorf("reset requires talosconfig")
+	}
+
+	slog.Info("resetting EPHEMERAL partition", "target", c.targetNode)
+
+	if _, err := c.machine.Reset(ctx, &machineapi.ResetRequest{
+		Graceful: false,
+		Reboot:   true,
+		SystemPartitionsToWipe: []*machineapi.ResetPartitionSpec{
+			{Label: "EPHEMERAL", Wipe: true},
+		},
+	}); err != nil {
+		return fmt.Errorf("reset failed: %w", err)
+	}
+
+	return nil
+}
+
 func probeTalosMaintenance(ctx context.Context, endpoint string) error {
 	client, err := NewInsecureClient(endpoint)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 
 	commonapi "github.com/siderolabs/talos/pkg/machinery/api/common"
 	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // EtcdSnapshot downloads an etcd snapshot from a control plane node.
@@ -18,6 +19,24 @@ func (c *Client) EtcdSnapshot(ctx context.Context, outputPath string) error {
 	if strings.TrimSpace(outputPath) == "" {
 		return fmt.Errorf("output path is required")
 	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("create snapshot output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	if err := c.EtcdSnapshotTo(ctx, outputFile); err != nil {
+		return err
+	}
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("write snapshot output file: %w", err)
+	}
+	return nil
+}
+
+// EtcdSnapshotTo streams an etcd snapshot from a control plane node to w.
+func (c *Client) EtcdSnapshotTo(ctx context.Context, w io.Writer) error {
 	if c.machine == nil {
 		return fmt.Errorf("etcd snapshot requires talosconfig")
 	}
@@ -25,19 +44,13 @@ func (c *Client) EtcdSnapshot(ctx context.Context, outputPath string) error {
 		return fmt.Errorf("etcd snapshot requires talosconfig")
 	}
 
-	slog.Info("taking etcd snapshot", "target", c.targetNode, "output", outputPath)
+	slog.Info("taking etcd snapshot", "target", c.targetNode)
 
 	stream, err := c.machine.EtcdSnapshot(ctx, &machineapi.EtcdSnapshotRequest{})
 	if err != nil {
 		return fmt.Errorf("etcd snapshot failed: %w", err)
 	}
 
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("create snapshot output file: %w", err)
-	}
-	defer outputFile.Close()
-
 	for {
 		chunk, err := stream.Recv()
 		if err == io.EOF {
@@ -46,8 +59,8 @@ func (c *Client) EtcdSnapshot(ctx context.Context, outputPath string) error {
 		if err != nil {
 			return fmt.Errorf("read etcd snapshot stream: %w", err)
 		}
-		if _, err := outputFile.Write(chunk.GetBytes()); err != nil {
-			return fmt.Errorf("write snapshot output file: %w", err)
+		if _, err := w.Write(chunk.GetBytes()); err != nil {
+			return fmt.Errorf("write snapshot: %w", err)
 		}
 	}
 
@@ -59,6 +72,19 @@ func (c *Client) EtcdRestore(ctx context.Context, snapshotPath string) error {
 	if strings.TrimSpace(snapshotPath) == "" {
 		return fmt.Errorf("snapshot path is required")
 	}
+
+	snapshotFile, err := os.Open(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("open snapshot file: %w", err)
+	}
+	defer snapshotFile.Close()
+
+	return c.EtcdRestoreFrom(ctx, snapshotFile)
+}
+
+// EtcdRestoreFrom restores etcd on a control plane node from the snapshot
+// read from r.
+func (c *Client) EtcdRestoreFrom(ctx context.Context, r io.Reader) error {
 	if c.machine == nil {
 		return fmt.Errorf("etcd restore requires talosconfig")
 	}
@@ -66,13 +92,7 @@ func (c *Client) EtcdRestore(ctx context.Context, snapshotPath string) error {
 		return fmt.Errorf("etcd restore requires talosconfig")
 	}
 
-	slog.Info("restoring etcd", "target", c.targetNode, "snapshot", snapshotPath)
-
-	snapshotFile, err := os.Open(snapshotPath)
-	if err != nil {
-		return fmt.Errorf("open snapshot file: %w", err)
-	}
-	defer snapshotFile.Close()
+	slog.Info("restoring etcd", "target", c.targetNode)
 
 	stream, err := c.machine.EtcdRecover(ctx)
 	if err != nil {
@@ -81,7 +101,7 @@ func (c *Client) EtcdRestore(ctx context.Context, snapshotPath string) error {
 
 	buffer := make([]byte, 4096)
 	for {
-		n, readErr := snapshotFile.Read(buffer)
+		n, readErr := r.Read(buffer)
 		if n > 0 {
 			if sendErr := stream.Send(&commonapi.Data{Bytes: buffer[:n]}); sendErr != nil {
 				return fmt.Errorf("stream etcd recovery data: %w", sendErr)
@@ -91,7 +111,7 @@ func (c *Client) EtcdRestore(ctx context.Context, snapshotPath string) error {
 			break
 		}
 		if readErr != nil {
-			return fmt.Errorf("read snapshot file: %w", readErr)
+			return fmt.Errorf("read snapshot: %w", readErr)
 		}
 	}
 
@@ -128,3 +148,158 @@ func (c *Client) EtcdRemoveMember(ctx context.Context, memberID string) error {
 
 	return nil
 }
+
+// EtcdMember describes a single etcd cluster member.
+type EtcdMember struct {
+	ID         uint64   `json:"id"`
+	Hostname   string   `json:"hostname"`
+	PeerURLs   []string `json:"peerUrls,omitempty"`
+	ClientURLs []string `json:"clientUrls,omitempty"`
+	IsLearner  bool     `json:"isLearner,omitempty"`
+}
+
+// EtcdMemberStatus is the etcd status reported by the target node's member.
+type EtcdMemberStatus struct {
+	MemberID        uint64   `json:"memberId"`
+	Leader          uint64   `json:"leader"`
+	DBSize          int64    `json:"dbSize"`
+	DBSizeInUse     int64    `json:"dbSizeInUse"`
+	RaftIndex       uint64   `json:"raftIndex"`
+	RaftTerm        uint64   `json:"raftTerm"`
+	ProtocolVersion string   `json:"protocolVersion,omitempty"`
+	IsLearner       bool     `json:"isLearner,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+// EtcdMembers lists the etcd cluster members as seen by the target node.
+func (c *Client) EtcdMembers(ctx context.Context) ([]EtcdMember, error) {
+	if c.machine == nil || c.insecure {
+		return nil, fmt.Errorf("etcd member list requires talosconfig")
+	}
+
+	response, err := c.machine.EtcdMemberList(ctx, &machineapi.EtcdMemberListRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("etcd member list failed: %w", err)
+	}
+
+	var members []EtcdMember
+	for _, message := range response.GetMessages() {
+		for _, member := range message.GetMembers() {
+			members = append(members, EtcdMember{
+				ID:         member.GetId(),
+				Hostname:   member.GetHostname(),
+				PeerURLs:   member.GetPeerUrls(),
+				ClientURLs: member.GetClientUrls(),
+				IsLearner:  member.GetIsLearner(),
+			})
+		}
+	}
+
+	return members, nil
+}
+
+// EtcdStatus returns the status of the etcd member running on the target node.
+func (c *Client) EtcdStatus(ctx context.Context) (*EtcdMemberStatus, error) {
+	if c.machine == nil || c.insecure {
+		return nil, fmt.Errorf("etcd status requires talosconfig")
+	}
+
+	response, err := c.machine.EtcdStatus(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("etcd status failed: %w", err)
+	}
+
+	for _, message := range response.GetMessages() {
+		status := message.GetMemberStatus()
+		if status == nil {
+			continue
+		}
+		return &EtcdMemberStatus{
+			MemberID:        status.GetMemberId(),
+			Leader:          status.GetLeader(),
+			DBSize:          status.GetDbSize(),
+			DBSizeInUse:     status.GetDbSizeInUse(),
+			RaftIndex:       status.GetRaftIndex(),
+			RaftTerm:        status.GetRaftTerm(),
+			ProtocolVersion: status.GetProtocolVersion(),
+			IsLearner:       status.GetIsLearner(),
+			Errors:          status.GetErrors(),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("etcd status response did not include member status")
+}
+
+// EtcdDefragment defragments the etcd member running on the target node.
+// The member stops serving while it runs, so defragment one member at a
+// time.
+func (c *Client) EtcdDefragment(ctx context.Context) error {
+	if c.machine == nil || c.insecure {
+		return fmt.Errorf("etcd defragment requires talosconfig")
+	}
+
+	slog.Info("defragmenting etcd member", "target", c.targetNode)
+	if _, err := c.machine.EtcdDefragment(ctx, &emptypb.Empty{}); err != nil {
+		return fmt.Errorf("etcd defragment failed: %w", err)
+	}
+
+	return nil
+}
+
+// EtcdAlarm is an alarm raised by an etcd member, such as NOSPACE when the
+// database exceeds its quota.
+type EtcdAlarm struct {
+	MemberID uint64 `json:"memberId"`
+	Alarm    string `json:"alarm"`
+}
+
+// EtcdAlarms lists the alarms raised in the etcd cluster.
+func (c *Client) EtcdAlarms(ctx context.Context) ([]EtcdAlarm, error) {
+	if c.machine == nil || c.insecure {
+		return nil, fmt.Errorf("etcd alarm list requires talosconfig")
+	}
+
+	response, err := c.machine.EtcdAlarmList(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("etcd alarm list failed: %w", err)
+	}
+
+	var alarms []EtcdAlarm
+	for _, message := range response.GetMessages() {
+		alarms = appendEtcdAlarms(alarms, message.GetMemberAlarms())
+	}
+	return alarms, nil
+}
+
+// EtcdAlarmDisarm disarms every alarm in the etcd cluster and returns the
+// alarms that were disarmed.
+func (c *Client) EtcdAlarmDisarm(ctx context.Context) ([]EtcdAlarm, error) {
+	if c.machine == nil || c.insecure {
+		return nil, fmt.Errorf("etcd alarm disarm requires talosconfig")
+	}
+
+	slog.Info("disarming etcd alarms", "target", c.targetNode)
+	response, err := c.machine.EtcdAlarmDisarm(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("etcd alarm disarm failed: %w", err)
+	}
+
+	var alarms []EtcdAlarm
+	for _, message := range response.GetMessages() {
+		alarms = appendEtcdAlarms(alarms, message.GetMemberAlarms())
+	}
+	return alarms, nil
+}
+
+func appendEtcdAlarms(alarms []EtcdAlarm, memberAlarms []*machineapi.EtcdMemberAlarm) []EtcdAlarm {
+	for _, alarm := range memberAlarms {
+		if alarm.GetAlarm() == machineapi.EtcdMemberAlarm_NONE {
+			continue
+		}
+		alarms = append(alarms, EtcdAlarm{
+			MemberID: alarm.GetMemberId(),
+			Alarm:    alarm.GetAlarm().String(),
+		})
+	}
+	return alarms
+}
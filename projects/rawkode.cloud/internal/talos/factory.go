@@ -0,0 +1,132 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// factoryURL is the Talos Image Factory, which also serves installer images
+// as an OCI registry.
+var factoryURL = "https://factory.talos.dev"
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// InstallerImageExists reports whether the Image Factory serves the installer
+// image for a schematic and Talos version. The factory builds images on
+// demand, so a missing manifest means the schematic or version is unknown.
+func InstallerImageExists(ctx context.Context, schematic, version string) (bool, error) {
+	schematic = strings.TrimSpace(schematic)
+	version = strings.TrimSpace(version)
+	if schematic == "" || version == "" {
+		return false, fmt.Errorf("schematic and version are required")
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/installer/%s/manifests/%s", strings.TrimRight(factoryURL, "/"), url.PathEscape(schematic), url.PathEscape(version))
+
+	resp, err := headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := registryToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return false, err
+		}
+		if resp, err = headManifest(ctx, manifestURL, token); err != nil {
+			return false, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("query installer image manifest: unexpected status %s", resp.Status)
+	}
+}
+
+func headManifest(ctx context.Context, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build manifest request: %w", err)
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query installer image manifest: %w", err)
+	}
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// registryToken fetches an anonymous pull token for a Bearer challenge.
+func registryToken(ctx context.Context, challenge string) (string, error) {
+	scheme, params, ok := strings.Cut(challenge, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	values := map[string]string{}
+	for _, part := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+	if values["realm"] == "" {
+		return "", fmt.Errorf("registry auth challenge has no realm")
+	}
+
+	tokenURL, err := url.Parse(values["realm"])
+	if err != nil {
+		return "", fmt.Errorf("parse registry token realm: %w", err)
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("build registry token request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch registry token: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
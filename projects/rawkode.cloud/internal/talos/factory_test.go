@@ -0,0 +1,56 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstallerImageExistsFollowsBearerChallenge(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:installer/abc:pull" {
+				t.Errorf("token scope = %q", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token":"anonymous"}`)
+		case "/v2/installer/abc/manifests/v1.12.4", "/v2/installer/abc/manifests/v9.9.9":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="factory",scope="repository:installer/abc:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/v2/installer/abc/manifests/v9.9.9" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	originalURL := factoryURL
+	factoryURL = server.URL
+	t.Cleanup(func() { factoryURL = originalURL })
+
+	exists, err := InstallerImageExists(context.Background(), "abc", "v1.12.4")
+	if err != nil {
+		t.Fatalf("InstallerImageExists returned error: %v", err)
+	}
+	if !exists {
+		t.Fatal("expected v1.12.4 installer to exist")
+	}
+
+	exists, err = InstallerImageExists(context.Background(), "abc", "v9.9.9")
+	if err != nil {
+		t.Fatalf("InstallerImageExists returned error: %v", err)
+	}
+	if exists {
+		t.Fatal("expected v9.9.9 installer to be missing")
+	}
+}
@@ -172,6 +172,56 @@ func WithMayastorNode(machineConfig []byte) ([]byte, error) {
 	return out, nil
 }
 
+// WithNodeLabels merges labels into machine.nodeLabels.
+func WithNodeLabels(machineConfig []byte, labels map[string]string) ([]byte, error) {
+	return withMachineStringMap(machineConfig, "nodeLabels", labels)
+}
+
+// WithNodeTaints merges taints, keyed by taint key with "value:Effect"
+// values, into machine.nodeTaints.
+func WithNodeTaints(machineConfig []byte, taints map[string]string) ([]byte, error) {
+	return withMachineStringMap(machineConfig, "nodeTaints", taints)
+}
+
+// WithNodeAnnotations merges annotations into machine.nodeAnnotations.
+func WithNodeAnnotations(machineConfig []byte, annotations map[string]string) ([]byte, error) {
+	return withMachineStringMap(machineConfig, "nodeAnnotations", annotations)
+}
+
+func withMachineStringMap(machineConfig []byte, field string, values map[string]string) ([]byte, error) {
+	if len(machineConfig) == 0 {
+		return nil, fmt.Errorf("machine config is required")
+	}
+	if len(values) == 0 {
+		return machineConfig, nil
+	}
+
+	var cfg map[string]any
+	if err := yaml.Unmarshal(machineConfig, &cfg); err != nil {
+		return nil, fmt.Errorf("parse machine config YAML: %w", err)
+	}
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+
+	machine := ensureMapField(cfg, "machine")
+	existing := ensureMapField(machine, field)
+	for key, value := range values {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("machine.%s keys must not be empty", field)
+		}
+		existing[key] = value
+	}
+
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode machine config YAML: %w", err)
+	}
+
+	return out, nil
+}
+
 func ensureMapField(parent map[string]any, key string) map[string]any {
 	if existing, ok := parent[key].(map[string]any); ok {
 		return existing
@@ -351,3 +351,55 @@ func mustStringSlice(t *testing.T, parent map[string]any, key string) []string {
 
 	return out
 }
+
+func TestWithNodeLabelsAndTaintsMergeIntoMachineConfig(t *testing.T) {
+	input := []byte(`
+machine:
+  nodeLabels:
+    openebs.io/engine: mayastor
+`)
+
+	out, err := WithNodeLabels(input, map[string]string{"node.rawkode.cloud/pool": "gpu"})
+	if err != nil {
+		t.Fatalf("WithNodeLabels returned error: %v", err)
+	}
+	out, err = WithNodeTaints(out, map[string]string{"nvidia.com/gpu": "present:NoSchedule"})
+	if err != nil {
+		t.Fatalf("WithNodeTaints returned error: %v", err)
+	}
+	out, err = WithNodeAnnotations(out, map[string]string{"rawkode.cloud/owner": "platform"})
+	if err != nil {
+		t.Fatalf("WithNodeAnnotations returned error: %v", err)
+	}
+
+	var cfg map[string]any
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("unmarshal output: %v", err)
+	}
+
+	machine := mustMap(t, cfg, "machine")
+	nodeLabels := mustMap(t, machine, "nodeLabels")
+	if got := nodeLabels["openebs.io/engine"]; got != "mayastor" {
+		t.Fatalf("machine.nodeLabels.openebs.io/engine = %v, want existing label kept", got)
+	}
+	if got := nodeLabels["node.rawkode.cloud/pool"]; got != "gpu" {
+		t.Fatalf("machine.nodeLabels.node.rawkode.cloud/pool = %v, want %q", got, "gpu")
+	}
+	if got := mustMap(t, machine, "nodeTaints")["nvidia.com/gpu"]; got != "present:NoSchedule" {
+		t.Fatalf("machine.nodeTaints.nvidia.com/gpu = %v, want %q", got, "present:NoSchedule")
+	}
+	if got := mustMap(t, machine, "nodeAnnotations")["rawkode.cloud/owner"]; got != "platform" {
+		t.Fatalf("machine.nodeAnnotations.rawkode.cloud/owner = %v, want %q", got, "platform")
+	}
+}
+
+func TestWithNodeLabelsLeavesConfigUntouchedWhenEmpty(t *testing.T) {
+	input := []byte("machine: {}\n")
+	out, err := WithNodeLabels(input, nil)
+	if err != nil {
+		t.Fatalf("WithNodeLabels returned error: %v", err)
+	}
+	if string(out) != string(input) {
+		t.Fatalf("output = %q, want input unchanged", out)
+	}
+}
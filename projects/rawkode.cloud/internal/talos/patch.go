@@ -0,0 +1,83 @@
+package talos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/configloader"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+)
+
+// ConfigPatch is a named machine-config patch. Exactly one of
+// StrategicMerge (a YAML document merged into the config) or JSON6902 (RFC
+// 6902 operations, as JSON or YAML) is set. Both are applied with Talos's
+// own config patcher, so they behave as with `talosctl --config-patch`.
+type ConfigPatch struct {
+	Name           string
+	StrategicMerge []byte
+	JSON6902       []byte
+}
+
+// ApplyConfigPatches applies patches to a machine config one at a time, in
+// the order given, so a later patch sees the result of the earlier ones.
+func ApplyConfigPatches(machineConfig []byte, patches []ConfigPatch) ([]byte, error) {
+	if len(machineConfig) == 0 {
+		return nil, fmt.Errorf("machine config is required")
+	}
+
+	out := machineConfig
+	for i, patch := range patches {
+		name := strings.TrimSpace(patch.Name)
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		var err error
+		switch {
+		case len(patch.StrategicMerge) > 0 && len(patch.JSON6902) > 0:
+			err = fmt.Errorf("set either a strategic merge or a JSON patch, not both")
+		case len(patch.StrategicMerge) == 0 && len(patch.JSON6902) == 0:
+			err = fmt.Errorf("patch is empty")
+		default:
+			out, err = applyConfigPatch(out, patch)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("apply config patch %s: %w", name, err)
+		}
+	}
+
+	return out, nil
+}
+
+func applyConfigPatch(machineConfig []byte, patch ConfigPatch) ([]byte, error) {
+	loaded, err := loadConfigPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := configpatcher.Apply(configpatcher.WithBytes(machineConfig), []configpatcher.Patch{loaded})
+	if err != nil {
+		return nil, err
+	}
+	return patched.Bytes()
+}
+
+// loadConfigPatch loads a patch the way `talosctl --config-patch` does, so
+// it renders the same here as there.
+func loadConfigPatch(patch ConfigPatch) (configpatcher.Patch, error) {
+	if len(patch.StrategicMerge) > 0 {
+		cfg, err := configloader.NewFromBytes(patch.StrategicMerge, configloader.WithAllowPatchDelete())
+		if err != nil {
+			return nil, fmt.Errorf("parse strategic merge patch: %w", err)
+		}
+		return configpatcher.NewStrategicMergePatch(cfg), nil
+	}
+
+	loaded, err := configpatcher.LoadPatch(patch.JSON6902)
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON patch: %w", err)
+	}
+	if _, ok := loaded.(configpatcher.StrategicMergePatch); ok {
+		return nil, fmt.Errorf("parse JSON patch: expected a list of RFC 6902 operations")
+	}
+	return loaded, nil
+}
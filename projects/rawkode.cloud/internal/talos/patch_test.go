@@ -0,0 +1,100 @@
+package talos
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const patchTestMachineConfig = `version: v1alpha1
+machine:
+  type: worker
+  install:
+    disk: /dev/nvme0n1
+  certSANs:
+    - production-worker-01
+  sysctls:
+    vm.nr_hugepages: "1024"
+cluster:
+  clusterName: production
+`
+
+func TestApplyConfigPatchesAppliesInOrder(t *testing.T) {
+	out, err := ApplyConfigPatches([]byte(patchTestMachineConfig), []ConfigPatch{
+		{Name: "sysctls", StrategicMerge: []byte("machine:\n  sysctls:\n    net.core.somaxconn: \"65535\"\n  certSANs:\n    - extra.example.com\n")},
+		{Name: "somaxconn", JSON6902: []byte(`[{"op": "replace", "path": "/machine/sysctls/net.core.somaxconn", "value": "4096"}]`)},
+		{Name: "kubelet", JSON6902: []byte("- op: add\n  path: /machine/kubelet\n  value:\n    extraArgs:\n      max-pods: \"250\"\n")},
+		{Name: "drop-hugepages", StrategicMerge: []byte("machine:\n  install:\n    $patch: delete\n")},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfigPatches returned error: %v", err)
+	}
+
+	var cfg map[string]any
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("unmarshal output: %v", err)
+	}
+
+	machine := mustMap(t, cfg, "machine")
+	sysctls := mustMap(t, machine, "sysctls")
+	if got := sysctls["net.core.somaxconn"]; got != "4096" {
+		t.Fatalf("machine.sysctls.net.core.somaxconn = %v, want later patch to win", got)
+	}
+	if got := sysctls["vm.nr_hugepages"]; got != "1024" {
+		t.Fatalf("machine.sysctls.vm.nr_hugepages = %v, want existing value kept", got)
+	}
+	if got := mustStringSlice(t, machine, "certSANs"); strings.Join(got, ",") != "production-worker-01,extra.example.com" {
+		t.Fatalf("machine.certSANs = %v, want patch entries appended", got)
+	}
+	if _, ok := machine["install"]; ok {
+		t.Fatal("machine.install still present after $patch: delete")
+	}
+	extraArgs := mustMap(t, mustMap(t, machine, "kubelet"), "extraArgs")
+	if got := extraArgs["max-pods"]; got != "250" {
+		t.Fatalf("machine.kubelet.extraArgs.max-pods = %v, want %q", got, "250")
+	}
+}
+
+func TestApplyConfigPatchesNamesFailingPatch(t *testing.T) {
+	_, err := ApplyConfigPatches([]byte(patchTestMachineConfig), []ConfigPatch{
+		{Name: "missing-path", JSON6902: []byte(`[{"op": "remove", "path": "/machine/doesNotExist"}]`)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing-path") {
+		t.Fatalf("error = %v, want it to name the failing patch", err)
+	}
+}
+
+func TestApplyConfigPatchesMergesInterfacesByName(t *testing.T) {
+	base := `version: v1alpha1
+machine:
+  type: worker
+  network:
+    interfaces:
+      - interface: eth0
+        dhcp: true
+`
+	out, err := ApplyConfigPatches([]byte(base), []ConfigPatch{
+		{Name: "mtu", StrategicMerge: []byte("machine:\n  network:\n    interfaces:\n      - interface: eth0\n        mtu: 9000\n")},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfigPatches returned error: %v", err)
+	}
+
+	var cfg map[string]any
+	if err := yaml.Unmarshal(out, &cfg); err != nil {
+		t.Fatalf("unmarshal output: %v", err)
+	}
+
+	interfaces, ok := mustMap(t, mustMap(t, cfg, "machine"), "network")["interfaces"].([]any)
+	if !ok || len(interfaces) != 1 {
+		t.Fatalf("machine.network.interfaces = %v, want eth0 merged into one entry", interfaces)
+	}
+	eth0, ok := interfaces[0].(map[string]any)
+	if !ok {
+		t.Fatalf("interface has unexpected type %T", interfaces[0])
+	}
+	if eth0["dhcp"] != true || eth0["mtu"] != 9000 {
+		t.Fatalf("eth0 = %v, want dhcp kept and mtu 9000", eth0)
+	}
+}
@@ -0,0 +1,87 @@
+package talos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in redacted machine configs.
+const Redacted = "<redacted>"
+
+// secretFields are the machine-config keys whose string values are secret:
+// private keys (including WireGuard interface keys), bootstrap tokens,
+// encryption secrets and the registry credentials under
+// machine.registries.config.
+var secretFields = map[string]bool{
+	"key":                       true,
+	"privateKey":                true,
+	"token":                     true,
+	"secret":                    true,
+	"bootstrapToken":            true,
+	"secretboxEncryptionSecret": true,
+	"aescbcEncryptionSecret":    true,
+	"password":                  true,
+	"auth":                      true,
+	"identityToken":             true,
+}
+
+// RedactSecrets returns the machine config documents with secret values
+// replaced. Environment entries of extension services (KEY=value) keep
+// their name and lose their value.
+func RedactSecrets(machineConfig []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(machineConfig))
+
+	var docs [][]byte
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("parse machine config YAML: %w", err)
+		}
+		redactNode(&doc, "")
+
+		out, err := yaml.Marshal(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("encode machine config YAML: %w", err)
+		}
+		docs = append(docs, bytes.TrimSpace(out))
+	}
+
+	return append(bytes.Join(docs, []byte("\n---\n")), '\n'), nil
+}
+
+func redactNode(node *yaml.Node, field string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			redactNode(child, field)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			redactNode(node.Content[i+1], node.Content[i].Value)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			if field == "environment" && child.Kind == yaml.ScalarNode {
+				if name, _, ok := strings.Cut(child.Value, "="); ok {
+					child.Value = name + "=" + Redacted
+				}
+				continue
+			}
+			redactNode(child, field)
+		}
+	case yaml.ScalarNode:
+		if secretFields[field] && node.Value != "" {
+			node.Value = Redacted
+			node.Tag = "!!str"
+			node.Style = 0
+		}
+	}
+}
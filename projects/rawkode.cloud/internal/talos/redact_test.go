@@ -0,0 +1,88 @@
+package talos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactSecretsHidesKeysTokensAndEnvironmentValues(t *testing.T) {
+	input := []byte(`version: v1alpha1
+machine:
+  token: abc.0123456789abcdef
+  ca:
+    crt: LS0tLS1CRUdJTi1DRVJU
+    key: LS0tLS1CRUdJTi1LRVk=
+  network:
+    interfaces:
+      - interface: wg0
+        wireguard:
+          privateKey: aGVsbG8td2lyZWd1YXJkLXByaXZhdGUta2V5
+          peers:
+            - publicKey: cHVibGljLXBlZXIta2V5
+cluster:
+  secretboxEncryptionSecret: c2VjcmV0
+---
+apiVersion: v1alpha1
+kind: ExtensionServiceConfig
+name: netbird
+environment:
+  - NB_SETUP_KEY=00000000-1111-2222-3333-444444444444
+`)
+
+	out, err := RedactSecrets(input)
+	if err != nil {
+		t.Fatalf("RedactSecrets returned error: %v", err)
+	}
+	rendered := string(out)
+
+	for _, secret := range []string{"abc.0123456789abcdef", "LS0tLS1CRUdJTi1LRVk=", "c2VjcmV0", "00000000-1111", "aGVsbG8td2lyZWd1YXJkLXByaXZhdGUta2V5"} {
+		if strings.Contains(rendered, secret) {
+			t.Fatalf("redacted config still contains %q:\n%s", secret, rendered)
+		}
+	}
+	for _, kept := range []string{"LS0tLS1CRUdJTi1DRVJU", "NB_SETUP_KEY=" + Redacted, "\n---\n", "kind: ExtensionServiceConfig", "privateKey: " + Redacted, "publicKey: cHVibGljLXBlZXIta2V5"} {
+		if !strings.Contains(rendered, kept) {
+			t.Fatalf("redacted config is missing %q:\n%s", kept, rendered)
+		}
+	}
+}
+
+func TestRedactSecretsHidesRegistryCredentials(t *testing.T) {
+	machineConfig, err := ApplyConfigPatches([]byte("version: v1alpha1\nmachine:\n  type: worker\n"), []ConfigPatch{{
+		Name: "registry-auth",
+		StrategicMerge: []byte(`machine:
+  registries:
+    config:
+      ghcr.io:
+        auth:
+          username: robot
+          password: hunter2-password
+      registry.example.com:
+        auth:
+          auth: cm9ib3Q6aHVudGVyMg==
+      private.example.com:
+        auth:
+          identityToken: eyJhbGciOiJSUzI1NiJ9
+`),
+	}})
+	if err != nil {
+		t.Fatalf("ApplyConfigPatches returned error: %v", err)
+	}
+
+	out, err := RedactSecrets(machineConfig)
+	if err != nil {
+		t.Fatalf("RedactSecrets returned error: %v", err)
+	}
+	rendered := string(out)
+
+	for _, secret := range []string{"hunter2-password", "cm9ib3Q6aHVudGVyMg==", "eyJhbGciOiJSUzI1NiJ9"} {
+		if strings.Contains(rendered, secret) {
+			t.Fatalf("redacted config still contains %q:\n%s", secret, rendered)
+		}
+	}
+	for _, kept := range []string{"username: robot", "ghcr.io:", "password: " + Redacted} {
+		if !strings.Contains(rendered, kept) {
+			t.Fatalf("redacted config is missing %q:\n%s", kept, rendered)
+		}
+	}
+}